@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gin-gonic/gin"
+)
+
+// adaptiveRateStep is how much an adjustment relaxes the rate limit factor
+// while the service is idle.
+const adaptiveRateStep = 0.1
+
+// adaptiveRateBounds are the bounds and load thresholds of adaptive rate
+// limiting, see nextRateFactor.
+type adaptiveRateBounds struct {
+	min, max       float64 // bounds of the factor
+	targetInFlight int64   // in-flight requests above which the limit tightens
+	errorRate      float64 // upstream error rate above which the limit tightens
+}
+
+// nextRateFactor adjusts the factor the configured rate limits are scaled by,
+// additive increase, multiplicative decrease: while more requests than the
+// target are in flight, or the upstream error rate exceeds its threshold over
+// at least degradeMinSamples calls, the factor halves; while at most half the
+// target are in flight and the upstream is healthy, it grows by
+// adaptiveRateStep; otherwise it holds. The factor stays within the bounds. The
+// reason names what decided the adjustment.
+func nextRateFactor(factor float64, inFlight int64, errorRate float64, samples int, b adaptiveRateBounds) (float64, string) {
+	switch {
+	case inFlight > b.targetInFlight:
+		return math.Max(b.min, factor/2), "in-flight requests"
+	case samples >= degradeMinSamples && errorRate > b.errorRate:
+		return math.Max(b.min, factor/2), "upstream errors"
+	case inFlight <= b.targetInFlight/2:
+		// Rounded so repeated steps don't accumulate float error.
+		return math.Min(b.max, math.Round((factor+adaptiveRateStep)*100)/100), "idle"
+	}
+	return factor, "steady"
+}
+
+// adaptiveLimiter is a route limiter and the rate it was configured with.
+type adaptiveLimiter struct {
+	lmt  *limiter.Limiter
+	base float64
+}
+
+// adaptiveRateLimiter scales every route limiter by a common factor.
+type adaptiveRateLimiter struct {
+	mu       sync.Mutex
+	limiters []adaptiveLimiter
+	factor   float64
+	reason   string
+}
+
+var adaptiveRate = &adaptiveRateLimiter{factor: 1, reason: "steady"}
+
+// register adds a route limiter configured for base requests per second.
+func (a *adaptiveRateLimiter) register(lmt *limiter.Limiter, base float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limiters = append(a.limiters, adaptiveLimiter{lmt: lmt, base: base})
+	scaleLimiter(lmt, base, a.factor)
+}
+
+// scaleLimiter sets the rate and bucket size of lmt to base scaled by factor,
+// as tollbooth.NewLimiter sizes buckets.
+func scaleLimiter(lmt *limiter.Limiter, base, factor float64) {
+	rate := base * factor
+	lmt.SetMax(rate)
+	lmt.SetBurst(int(math.Max(1, rate)))
+}
+
+// adjust moves the factor on by one step of nextRateFactor, rescaling the
+// limiters when it changes.
+func (a *adaptiveRateLimiter) adjust(b adaptiveRateBounds) {
+	errorRate, samples := upstreamErrors.rate()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	factor, reason := nextRateFactor(a.factor, inFlightRequests.Load(), errorRate, samples, b)
+	a.reason = reason
+	if factor == a.factor {
+		return
+	}
+	if factor < a.factor {
+		log.Printf("Tightening rate limits to %.2fx of the configured rates (%s)", factor, reason)
+	}
+	a.factor = factor
+	for _, l := range a.limiters {
+		scaleLimiter(l.lmt, l.base, factor)
+	}
+}
+
+// stats reports the factor and the resulting effective RATE_LIMIT for /stats;
+// without ADAPTIVE_RATE_LIMIT the factor stays 1.
+func (a *adaptiveRateLimiter) stats() gin.H {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return gin.H{
+		"adaptive":       cfg.AdaptiveRateLimit,
+		"factor":         a.factor,
+		"effectiveLimit": cfg.RateLimit * a.factor,
+		"reason":         a.reason,
+	}
+}
+
+// startAdaptiveRateLimit adjusts the rate limits every interval until ctx is
+// done. The returned channel is closed once it has stopped.
+func startAdaptiveRateLimit(ctx context.Context, interval time.Duration, b adaptiveRateBounds) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				adaptiveRate.adjust(b)
+			}
+		}
+	}()
+	return done
+}
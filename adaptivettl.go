@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Thresholds of the adaptive cache TTL, applied to the first day of a response.
+const (
+	volatilePrecipProb = 70 // % chance of precipitation making conditions volatile
+	stablePrecipProb   = 10 // % chance of precipitation at most for stable conditions
+)
+
+// volatileIcons are the Visual Crossing icons of fast-changing weather.
+var volatileIcons = []string{"thunder", "snow", "showers", "hail", "sleet"}
+
+// stableIcons are the Visual Crossing icons of settled weather.
+var stableIcons = map[string]bool{"clear-day": true, "clear-night": true}
+
+// adaptiveTTL picks the cache TTL for a weather response from how settled the
+// near-term weather looks:
+//
+//   - volatile (minTTL): alerts are in effect, or today's or the current icon
+//     is thunder, snow, showers, hail or sleet, or today's precipitation
+//     probability is at least volatilePrecipProb;
+//   - stable (maxTTL): no alerts, today's and the current icon are clear and
+//     today's precipitation probability is at most stablePrecipProb;
+//   - anything else keeps base, clamped to [minTTL, maxTTL].
+//
+// Only today and the current conditions are considered, since they are what a
+// stale entry gets wrong first.
+func adaptiveTTL(data map[string]interface{}, base, minTTL, maxTTL time.Duration) time.Duration {
+	if alerts, _ := data["alerts"].([]interface{}); len(alerts) > 0 {
+		return minTTL
+	}
+	days, err := decodeDays(data)
+	if err != nil || len(days) == 0 {
+		return clampTTL(base, minTTL, maxTTL)
+	}
+	today := days[0]
+	icons := []string{today.Icon}
+	if current, err := decodeCurrent(data); err == nil && current != nil && current.Icon != "" {
+		icons = append(icons, current.Icon)
+	}
+
+	if today.PrecipProb >= volatilePrecipProb {
+		return minTTL
+	}
+	stable := today.PrecipProb <= stablePrecipProb
+	for _, icon := range icons {
+		for _, v := range volatileIcons {
+			if strings.Contains(icon, v) {
+				return minTTL
+			}
+		}
+		stable = stable && stableIcons[icon]
+	}
+	if stable {
+		return maxTTL
+	}
+	return clampTTL(base, minTTL, maxTTL)
+}
+
+// clampTTL bounds ttl to [minTTL, maxTTL].
+func clampTTL(ttl, minTTL, maxTTL time.Duration) time.Duration {
+	if ttl < minTTL {
+		return minTTL
+	}
+	if ttl > maxTTL {
+		return maxTTL
+	}
+	return ttl
+}
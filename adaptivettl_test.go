@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTTL(t *testing.T) {
+	const min, base, max = 5 * time.Minute, time.Hour, 6 * time.Hour
+	for _, tc := range []struct {
+		name, data string
+		want       time.Duration
+	}{
+		{"alerts", `{"alerts":[{"event":"Wind"}],"days":[{"icon":"clear-day","precipprob":0}]}`, min},
+		{"thunder today", `{"days":[{"icon":"thunder-rain","precipprob":40}]}`, min},
+		{"showers now", `{"days":[{"icon":"clear-day","precipprob":0}],"currentConditions":{"icon":"showers-day"}}`, min},
+		{"likely rain", `{"days":[{"icon":"cloudy","precipprob":70}]}`, min},
+		{"clear", `{"days":[{"icon":"clear-day","precipprob":10}]}`, max},
+		{"clear night", `{"days":[{"icon":"clear-day","precipprob":0}],"currentConditions":{"icon":"clear-night"}}`, max},
+		{"clear but cloudy now", `{"days":[{"icon":"clear-day","precipprob":0}],"currentConditions":{"icon":"cloudy"}}`, base},
+		{"clear with some rain", `{"days":[{"icon":"clear-day","precipprob":11}]}`, base},
+		{"cloudy", `{"days":[{"icon":"partly-cloudy-day","precipprob":20}]}`, base},
+		// Only today counts.
+		{"storm tomorrow", `{"days":[{"icon":"clear-day","precipprob":0},{"icon":"thunder","precipprob":90}]}`, max},
+		{"empty alerts", `{"alerts":[],"days":[{"icon":"cloudy"}]}`, base},
+		{"no days", `{"currentConditions":{"icon":"snow"}}`, base},
+	} {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.data), &data); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := adaptiveTTL(data, base, min, max); got != tc.want {
+			t.Errorf("%s: adaptiveTTL = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestClampTTL(t *testing.T) {
+	for _, tc := range []struct{ ttl, want time.Duration }{
+		{time.Second, time.Minute},
+		{time.Minute, time.Minute},
+		{30 * time.Minute, 30 * time.Minute},
+		{time.Hour, time.Hour},
+		{2 * time.Hour, time.Hour},
+	} {
+		if got := clampTTL(tc.ttl, time.Minute, time.Hour); got != tc.want {
+			t.Errorf("clampTTL(%s) = %s, want %s", tc.ttl, got, tc.want)
+		}
+	}
+}
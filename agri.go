@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// agriDepths are the soil depths Visual Crossing reports soil elements for, by
+// the suffix of their element names, with the label used in /weather/agri.
+var agriDepths = []struct{ suffix, label string }{
+	{"01", "1cm"},
+	{"04", "4cm"},
+	{"10", "10cm"},
+	{"20", "20cm"},
+}
+
+// agriEvapotranspiration is the upstream element holding the daily reference
+// evapotranspiration, in mm.
+const agriEvapotranspiration = "et0"
+
+// agriElements is the elements value added to agricultural queries: soil
+// temperature and volumetric soil moisture at every depth, and
+// evapotranspiration, on top of the default elements.
+var agriElements = func() string {
+	var names []string
+	for _, prefix := range []string{"soiltemp", "soilmoisturevol"} {
+		for _, d := range agriDepths {
+			names = append(names, prefix+d.suffix)
+		}
+	}
+	return "add:" + strings.Join(append(names, agriEvapotranspiration), ",")
+}()
+
+// agriDay is the per-day agricultural detail returned by /weather/agri. Elements
+// the upstream didn't report are left out.
+type agriDay struct {
+	Date               string             `json:"date"`
+	SoilTemp           map[string]float64 `json:"soilTemp,omitempty"`     // °C by depth
+	SoilMoisture       map[string]float64 `json:"soilMoisture,omitempty"` // m³/m³ by depth
+	Evapotranspiration *float64           `json:"evapotranspiration,omitempty"`
+}
+
+// agriDays extracts the soil and evapotranspiration elements of the upstream
+// days, reporting whether any day carried one.
+func agriDays(days []interface{}) ([]agriDay, bool) {
+	out := make([]agriDay, 0, len(days))
+	available := false
+	for _, d := range days {
+		day, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		date, _ := day["datetime"].(string)
+		ad := agriDay{Date: date}
+		for _, depth := range agriDepths {
+			if v, ok := day["soiltemp"+depth.suffix].(float64); ok {
+				if ad.SoilTemp == nil {
+					ad.SoilTemp = make(map[string]float64)
+				}
+				ad.SoilTemp[depth.label] = v
+			}
+			if v, ok := day["soilmoisturevol"+depth.suffix].(float64); ok {
+				if ad.SoilMoisture == nil {
+					ad.SoilMoisture = make(map[string]float64)
+				}
+				ad.SoilMoisture[depth.label] = v
+			}
+		}
+		if v, ok := day[agriEvapotranspiration].(float64); ok {
+			ad.Evapotranspiration = &v
+		}
+		if ad.SoilTemp != nil || ad.SoilMoisture != nil || ad.Evapotranspiration != nil {
+			available = true
+		}
+		out = append(out, ad)
+	}
+	return out, available
+}
+
+// withAgri marks q as requesting the agricultural elements. They are requested
+// through the upstream elements option, so agricultural queries can't pass
+// elements through as well; such requests get a 400 and false.
+func withAgri(c *gin.Context, q weatherQuery) (weatherQuery, bool) {
+	if _, ok := q.Passthrough["elements"]; ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "agricultural elements can't be combined with " + passthroughPrefix + "elements"})
+		return q, false
+	}
+	q.Agri = true
+	return q, true
+}
+
+// fakeAgriElements adds plausible soil and evapotranspiration elements, derived
+// from the day's temperature and precipitation, to a mock or fake upstream day.
+func fakeAgriElements(day map[string]interface{}) {
+	temp, _ := day["temp"].(float64)
+	precip, _ := day["precip"].(float64)
+	for i, depth := range agriDepths {
+		// Deeper soil is damped towards a steady 10°C and holds moisture longer.
+		damping := float64(i+1) / float64(len(agriDepths)+1)
+		day["soiltemp"+depth.suffix] = math.Round((temp+(10-temp)*damping)*10) / 10
+		day["soilmoisturevol"+depth.suffix] = math.Round(math.Min(0.45, 0.2+precip*0.01*(1-damping)+0.1*damping)*1000) / 1000
+	}
+	day[agriEvapotranspiration] = math.Round(math.Max(0, 0.15*temp)*10) / 10
+}
+
+// getAgriHandler handles GET /weather/agri requests, returning the soil
+// temperature, soil moisture and evapotranspiration of each day. The elements
+// are requested from the upstream on top of the days, so agricultural lookups
+// are cached separately from plain ones. Providers or locations without them
+// give days with only a date and "available": false.
+func getAgriHandler(c *gin.Context) {
+	q, ok := parseWeatherQuery(c)
+	if !ok {
+		return
+	}
+	if q, ok = withAgri(c, q); !ok {
+		return
+	}
+	data, _, ok := loadQuery(c, q)
+	if !ok {
+		return
+	}
+	days, _ := data["days"].([]interface{})
+	out, available := agriDays(days)
+	c.JSON(http.StatusOK, gin.H{
+		"location":  q.Location,
+		"available": available,
+		"days":      out,
+	})
+}
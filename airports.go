@@ -0,0 +1,61 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// airportsCSV is the bundled IATA lookup table: code, name, latitude, longitude.
+//
+//go:embed airports.csv
+var airportsCSV string
+
+// airport is an entry of the bundled airport table.
+type airport struct {
+	Code string  `json:"code"`
+	Name string  `json:"name"`
+	Lat  float64 `json:"-"`
+	Lon  float64 `json:"-"`
+}
+
+// airports maps IATA codes to their airport, loaded from airportsCSV.
+var airports = loadAirports(airportsCSV)
+
+// loadAirports parses the airport table, skipping the header and logging
+// malformed rows.
+func loadAirports(raw string) map[string]airport {
+	rows, err := csv.NewReader(strings.NewReader(raw)).ReadAll()
+	if err != nil {
+		log.Printf("Error reading the airport table: %v", err)
+		return nil
+	}
+	out := make(map[string]airport, len(rows))
+	for _, row := range rows[1:] {
+		if len(row) != 4 {
+			log.Printf("Ignoring malformed airport row %q", row)
+			continue
+		}
+		lat, errLat := strconv.ParseFloat(row[2], 64)
+		lon, errLon := strconv.ParseFloat(row[3], 64)
+		if errLat != nil || errLon != nil {
+			log.Printf("Ignoring malformed airport row %q", row)
+			continue
+		}
+		out[row[0]] = airport{Code: row[0], Name: row[1], Lat: lat, Lon: lon}
+	}
+	return out
+}
+
+// lookupAirport returns the airport for a location that is exactly a known
+// three-letter IATA code in upper case, like "LHR". Lower-case input is left to
+// free-text handling so words such as "bos" aren't taken for airports.
+func lookupAirport(location string) (airport, bool) {
+	if len(location) != 3 || strings.ToUpper(location) != location {
+		return airport{}, false
+	}
+	a, ok := airports[location]
+	return a, ok
+}
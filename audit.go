@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// auditTargetKey is the context key under which admin handlers name what they
+// acted on, when the request's parameters don't say.
+const auditTargetKey = "auditTarget"
+
+// auditEvent is the structured record of one admin action.
+type auditEvent struct {
+	Time      string `json:"time"`
+	Type      string `json:"type"`   // always "audit", telling audit lines apart from access log lines
+	Action    string `json:"action"` // method and route, e.g. "POST /admin/config/ttl"
+	Target    string `json:"target,omitempty"`
+	Result    string `json:"result"` // "ok", "failed" or "denied"
+	Status    int    `json:"status"`
+	Actor     string `json:"actor"` // see auditActor
+	ClientIP  string `json:"client_ip"`
+	RequestID string `json:"request_id"`
+}
+
+// auditActor identifies the caller of an admin endpoint by a hash of the token
+// it presented, so actions can be attributed without the token being logged.
+// Callers without a token are "anonymous".
+func auditActor(token string) string {
+	if token == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:8])
+}
+
+// auditTarget returns what an admin request acted on: the target its handler
+// set, or else its key, location or zip parameter.
+func auditTarget(c *gin.Context) string {
+	if target := c.GetString(auditTargetKey); target != "" {
+		return target
+	}
+	for _, param := range []string{"key", "location", "zip"} {
+		if v := c.Query(param); v != "" {
+			return param + "=" + v
+		}
+	}
+	return ""
+}
+
+// auditMiddleware writes an audit event for every request to the admin
+// endpoints, including those rejected for a missing or wrong token, as a JSON
+// line on the access log output. With a stream name set, events are also
+// appended to that Redis stream, capped at about maxLen entries, for
+// GET /admin/audit. It must run before adminMiddleware.
+func auditMiddleware(stream string, maxLen int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		result := "ok"
+		switch {
+		case status == http.StatusUnauthorized:
+			result = "denied"
+		case status >= 400:
+			result = "failed"
+		}
+		event := auditEvent{
+			Time:      start.UTC().Format(time.RFC3339),
+			Type:      "audit",
+			Action:    c.Request.Method + " " + c.FullPath(),
+			Target:    auditTarget(c),
+			Result:    result,
+			Status:    status,
+			Actor:     auditActor(adminToken(c)),
+			ClientIP:  c.ClientIP(),
+			RequestID: c.GetString("requestID"),
+		}
+		if c.FullPath() == "" {
+			event.Action = c.Request.Method + " " + c.Request.URL.Path
+		}
+		line, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		accessLogger.Println(string(line))
+
+		if stream == "" {
+			return
+		}
+		err = redisClient.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			MaxLen: maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"event": line},
+		}).Err()
+		if err != nil {
+			log.Printf("Error persisting audit event to %s: %v", stream, err)
+		}
+	}
+}
+
+// auditHandler handles GET /admin/audit, listing the most recent audit events
+// kept in AUDIT_STREAM, newest first; count (default 100, at most 1000) bounds
+// how many.
+func auditHandler(c *gin.Context) {
+	if cfg.AuditStream == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "audit events are not persisted, set AUDIT_STREAM"})
+		return
+	}
+	count := int64(100)
+	if raw := c.Query("count"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "count must be an integer between 1 and 1000"})
+			return
+		}
+		count = n
+	}
+	msgs, err := redisClient.XRevRangeN(ctx, cfg.AuditStream, "+", "-", count).Result()
+	if err != nil {
+		log.Printf("Error reading audit events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	events := []auditEvent{}
+	for _, msg := range msgs {
+		raw, _ := msg.Values["event"].(string)
+		var event auditEvent
+		if json.Unmarshal([]byte(raw), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
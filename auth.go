@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHeader is the request header carrying the client's API key.
+const apiKeyHeader = "X-API-Key"
+
+// keyStore validates client API keys. Implementations may be backed by static
+// configuration or by an external store.
+type keyStore interface {
+	Valid(key string) (bool, error)
+}
+
+// staticKeyStore validates keys against a fixed set loaded from configuration.
+type staticKeyStore map[string]bool
+
+func (s staticKeyStore) Valid(key string) (bool, error) {
+	return s[key], nil
+}
+
+// redisKeyStore validates keys against a Redis set, so keys can be issued and
+// revoked without a restart.
+type redisKeyStore struct {
+	set string
+}
+
+func (s redisKeyStore) Valid(key string) (bool, error) {
+	return redisClient.SIsMember(ctx, s.set, key).Result()
+}
+
+// parseKeySet splits a comma-separated list of API keys into a static store.
+func parseKeySet(raw string) staticKeyStore {
+	keys := make(staticKeyStore)
+	for _, k := range strings.Split(raw, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys[k] = true
+		}
+	}
+	return keys
+}
+
+// authMiddleware rejects requests whose X-API-Key is missing or not accepted by
+// store. The validated key is stored in the context as "apiKey". When the store
+// itself fails, failOpen lets the request through with a warning instead of
+// answering 503.
+func authMiddleware(store keyStore, failOpen bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(apiKeyHeader)
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing " + apiKeyHeader + " header"})
+			return
+		}
+
+		ok, err := store.Valid(key)
+		if err != nil {
+			if failOpen {
+				log.Printf("WARNING: auth backend unavailable, allowing request (AUTH_FAIL_MODE=open): %v", err)
+				c.Next()
+				return
+			}
+			log.Printf("Error validating API key: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication backend unavailable"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+
+		c.Set("apiKey", key)
+		c.Next()
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchLocations bounds the locations of one /weather/batch request.
+const maxBatchLocations = 25
+
+// batchFetches bounds how many locations /weather/batch looks up at once.
+const batchFetches = 8
+
+// batchRequest is the body of POST /weather/batch.
+type batchRequest struct {
+	Locations []string `json:"locations" binding:"required"`
+}
+
+// batchError is the error of one location in a /weather/batch response.
+type batchError struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// getBatchHandler handles /weather/batch, returning the weather of several
+// locations in one response: GET takes them comma-separated in locations, POST
+// as a body {"locations":[...]}, for locations that contain commas themselves.
+// Locations are looked up like /weather requests, sharing their cache entries,
+// at most batchFetches at a time. results maps the locations as given to their
+// data and errors those whose lookup failed, without failing the others.
+func getBatchHandler(c *gin.Context) {
+	var raw []string
+	if c.Request.Method == http.MethodPost {
+		var req batchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `body must be a JSON object like {"locations":["London","Paris"]}`})
+			return
+		}
+		raw = req.Locations
+	} else {
+		for _, location := range strings.Split(c.Query("locations"), ",") {
+			if location = strings.TrimSpace(location); location != "" {
+				raw = append(raw, location)
+			}
+		}
+	}
+	var locations []string
+	seen := make(map[string]bool, len(raw))
+	for _, location := range raw {
+		if !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+	if len(locations) == 0 || len(locations) > maxBatchLocations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("between 1 and %d locations are required", maxBatchLocations)})
+		return
+	}
+	lang := cfg.DefaultLang
+	if raw := c.Query("lang"); raw != "" {
+		var err error
+		if lang, err = parseLang(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	results := make(map[string]map[string]interface{}, len(locations))
+	failed := make(map[string]batchError)
+	for i, lookup := range lookupLocations(c, locations, "/weather/batch", lang, batchFetches) {
+		if lookup.err != nil {
+			e := batchError{Error: lookup.err.Error()}
+			var ae *apiError
+			if errors.As(lookup.err, &ae) {
+				e.Code = ae.Code
+			}
+			failed[locations[i]] = e
+			continue
+		}
+		results[locations[i]] = lookup.result.Data
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "errors": failed})
+}
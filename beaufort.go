@@ -0,0 +1,47 @@
+package main
+
+import "strings"
+
+// beaufortLowerBounds are the lowest wind speeds, in km/h, of Beaufort forces 1
+// to 12 (WMO); anything slower is force 0.
+var beaufortLowerBounds = [12]float64{1, 6, 12, 20, 29, 39, 50, 62, 75, 89, 103, 118}
+
+// beaufortLabels are the descriptions of Beaufort forces 0 to 12.
+var beaufortLabels = [13]string{
+	"Calm", "Light air", "Light breeze", "Gentle breeze", "Moderate breeze", "Fresh breeze",
+	"Strong breeze", "Near gale", "Gale", "Strong gale", "Storm", "Violent storm", "Hurricane force",
+}
+
+// windSpeedKmh converts a wind speed in the units of a Visual Crossing unit
+// group to km/h: metric reports km/h, us and uk mph and base m/s. Unknown unit
+// groups are taken as metric.
+func windSpeedKmh(speed float64, unitGroup string) float64 {
+	switch strings.ToLower(unitGroup) {
+	case "us", "uk":
+		return speed * 1.609344
+	case "base":
+		return speed * 3.6
+	}
+	return speed
+}
+
+// beaufort returns the Beaufort force and its description for a wind speed in
+// the units of unitGroup. Negative speeds are calm.
+func beaufort(speed float64, unitGroup string) (int, string) {
+	kmh := windSpeedKmh(speed, unitGroup)
+	force := 0
+	for force < len(beaufortLowerBounds) && kmh >= beaufortLowerBounds[force] {
+		force++
+	}
+	return force, beaufortLabels[force]
+}
+
+// applyBeaufort adds beaufort and beaufortLabel next to every numeric windspeed,
+// which the upstream reports in unitGroup.
+func applyBeaufort(data map[string]interface{}, unitGroup string) {
+	forEachPeriod(data, func(period map[string]interface{}) {
+		if speed, ok := period["windspeed"].(float64); ok {
+			period["beaufort"], period["beaufortLabel"] = beaufort(speed, unitGroup)
+		}
+	})
+}
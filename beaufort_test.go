@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBeaufort(t *testing.T) {
+	for _, tc := range []struct {
+		speed     float64
+		unitGroup string
+		force     int
+		label     string
+	}{
+		{-3, "metric", 0, "Calm"},
+		{0, "metric", 0, "Calm"},
+		{0.9, "metric", 0, "Calm"},
+		{1, "metric", 1, "Light air"},
+		{19.9, "metric", 3, "Gentle breeze"},
+		{20, "metric", 4, "Moderate breeze"},
+		{117.9, "metric", 11, "Violent storm"},
+		{118, "metric", 12, "Hurricane force"},
+		{300, "", 12, "Hurricane force"},
+		// 25 mph is 40.2 km/h.
+		{25, "us", 6, "Strong breeze"},
+		{25, "UK", 6, "Strong breeze"},
+		// 10 m/s is 36 km/h.
+		{10, "base", 5, "Fresh breeze"},
+		{10, "unknown", 2, "Light breeze"},
+	} {
+		force, label := beaufort(tc.speed, tc.unitGroup)
+		if force != tc.force || label != tc.label {
+			t.Errorf("beaufort(%v, %q) = %d %q, want %d %q", tc.speed, tc.unitGroup, force, label, tc.force, tc.label)
+		}
+	}
+}
+
+func TestWindSpeedKmh(t *testing.T) {
+	for _, tc := range []struct {
+		speed     float64
+		unitGroup string
+		want      float64
+	}{
+		{10, "metric", 10},
+		{10, "", 10},
+		{10, "us", 16.09344},
+		{10, "uk", 16.09344},
+		{10, "base", 36},
+	} {
+		if got := windSpeedKmh(tc.speed, tc.unitGroup); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("windSpeedKmh(%v, %q) = %v, want %v", tc.speed, tc.unitGroup, got, tc.want)
+		}
+	}
+}
+
+func TestApplyBeaufort(t *testing.T) {
+	day := map[string]interface{}{"windspeed": 40.0}
+	missing := map[string]interface{}{"windspeed": nil}
+	applyBeaufort(map[string]interface{}{"days": []interface{}{day, missing}}, "metric")
+	if day["beaufort"] != 6 || day["beaufortLabel"] != "Strong breeze" {
+		t.Errorf("day annotated %v %v, want 6 Strong breeze", day["beaufort"], day["beaufortLabel"])
+	}
+	if _, ok := missing["beaufort"]; ok {
+		t.Error("annotated a day without a wind speed")
+	}
+}
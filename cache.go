@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cacheSchemaVersion identifies the shape of cached weather data. Bump it whenever
+// the transform/normalisation of stored responses changes so entries written by
+// older deploys are treated as misses instead of being served in the old shape.
+//
+// Version 2 fills in missing feels-like temperatures, version 3 in the query's
+// unit group.
+const cacheSchemaVersion = 3
+
+// cacheEntry is the envelope stored in Redis for each weather lookup.
+type cacheEntry struct {
+	Version   int        `json:"v"`
+	Key       string     `json:"key,omitempty"` // canonical query, see weatherQuery.canonicalKey
+	FetchedAt *time.Time `json:"fetchedAt,omitempty"`
+	// FreshUntil ends the entry's fresh window with CACHE_STALE_GRACE; after it
+	// the entry is served stale and refreshed, see revalidateStale.
+	FreshUntil *time.Time             `json:"freshUntil,omitempty"`
+	Ref        string                 `json:"ref,omitempty"`   // set on COORDINATE_DEDUP references instead of Data
+	Error      *cachedError           `json:"error,omitempty"` // set on negatively cached upstream errors
+	Data       map[string]interface{} `json:"data"`
+}
+
+// cachedError is a negatively cached upstream error, replayed on hits.
+type cachedError struct {
+	Status         int    `json:"status"`
+	Code           string `json:"code"`
+	Message        string `json:"message"`
+	UpstreamStatus int    `json:"upstreamStatus"`
+}
+
+// apiError rebuilds the error.
+func (e *cachedError) apiError() *apiError {
+	return &apiError{Status: e.Status, Code: e.Code, Message: e.Message, UpstreamStatus: e.UpstreamStatus}
+}
+
+// errSchemaMismatch reports a cached entry written with a different schema version,
+// or a coordinate reference read while COORDINATE_DEDUP is off.
+var errSchemaMismatch = errors.New("cache entry schema version mismatch")
+
+// encodeEntry wraps weather data for the canonical key, fetched from the upstream
+// at fetchedAt, in a versioned cache envelope. A non-zero freshUntil records the
+// end of its fresh window, see staleWindow.
+func encodeEntry(key string, data map[string]interface{}, fetchedAt, freshUntil time.Time) ([]byte, error) {
+	entry := cacheEntry{Version: cacheSchemaVersion, Key: key, FetchedAt: &fetchedAt, Data: data}
+	if !freshUntil.IsZero() {
+		entry.FreshUntil = &freshUntil
+	}
+	return json.Marshal(entry)
+}
+
+// encodeErrorEntry wraps an upstream error for the canonical key in a cache
+// envelope without data, see CLIENT_ERROR_CACHE_TTL.
+func encodeErrorEntry(key string, ae *apiError, fetchedAt time.Time) ([]byte, error) {
+	cached := &cachedError{Status: ae.Status, Code: ae.Code, Message: ae.Message, UpstreamStatus: ae.UpstreamStatus}
+	return json.Marshal(cacheEntry{Version: cacheSchemaVersion, Key: key, FetchedAt: &fetchedAt, Error: cached})
+}
+
+// decodeEntry unwraps a cached envelope, returning the data and when it was
+// fetched (zero for entries that don't record it). It returns errSchemaMismatch
+// for entries of another schema version (including pre-versioning raw payloads),
+// the cached *apiError for negatively cached upstream errors and the JSON error
+// for unreadable ones.
+func decodeEntry(raw string) (map[string]interface{}, time.Time, error) {
+	var entry cacheEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return nil, time.Time{}, err
+	}
+	if entry.Version != cacheSchemaVersion || entry.Ref != "" {
+		return nil, time.Time{}, errSchemaMismatch
+	}
+	if entry.Error != nil {
+		return nil, entry.fetchedAt(), entry.Error.apiError()
+	}
+	return entry.Data, entry.fetchedAt(), nil
+}
+
+// fetchedAt returns when the entry was fetched, or the zero time.
+func (e cacheEntry) fetchedAt() time.Time {
+	if e.FetchedAt == nil {
+		return time.Time{}
+	}
+	return *e.FetchedAt
+}
+
+// rawCacheEntry is cacheEntry with the data left encoded, for serving hits
+// without decoding and re-encoding the payload.
+type rawCacheEntry struct {
+	Version   int             `json:"v"`
+	FetchedAt *time.Time      `json:"fetchedAt,omitempty"`
+	Ref       string          `json:"ref,omitempty"`
+	Error     *cachedError    `json:"error,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// errNotObject reports a cache entry whose data is neither an object nor null.
+var errNotObject = errors.New("cache entry data is not a JSON object")
+
+// decodeEntryRaw is decodeEntry without decoding the data itself. The entry is
+// still fully syntax-checked. Negatively cached entries yield nil data.
+func decodeEntryRaw(raw string) (json.RawMessage, time.Time, error) {
+	var entry rawCacheEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return nil, time.Time{}, err
+	}
+	if entry.Version != cacheSchemaVersion || entry.Ref != "" {
+		return nil, time.Time{}, errSchemaMismatch
+	}
+	var fetchedAt time.Time
+	if entry.FetchedAt != nil {
+		fetchedAt = *entry.FetchedAt
+	}
+	if entry.Error != nil {
+		return nil, fetchedAt, entry.Error.apiError()
+	}
+	switch {
+	case len(entry.Data) == 0 || string(entry.Data) == "null":
+		return nil, fetchedAt, nil
+	case entry.Data[0] != '{':
+		return nil, time.Time{}, errNotObject
+	}
+	return entry.Data, fetchedAt, nil
+}
+
+// replicaClient is an optional read replica. When nil, reads go to redisClient.
+var replicaClient *redis.Client
+
+// cacheGet reads a cached value from its shard, preferring the read replica when
+// configured and falling back to the primary if the replica errors. redis.Nil is
+// returned as-is, and errRedisCircuitOpen while redisBreaker is open. With a
+// failover Redis, reads the primary can't answer go there instead.
+func cacheGet(ctx context.Context, key string) (string, error) {
+	err := errRedisCircuitOpen
+	var val string
+	if redisBreaker.allow() {
+		val, err = readGet(ctx, key)
+		redisBreaker.record(err)
+	}
+	if failoverEligible(err) {
+		useFailover(err)
+		return failoverClient.Get(ctx, key).Result()
+	}
+	if err == nil || err == redis.Nil {
+		primarySucceeded()
+	}
+	return val, err
+}
+
+// readGet implements cacheGet.
+func readGet(ctx context.Context, key string) (string, error) {
+	if replica := replicaFor(key); replica != nil {
+		val, err := replica.Get(ctx, key).Result()
+		if err == nil || err == redis.Nil {
+			return val, err
+		}
+		log.Printf("Replica read failed for %s, falling back to primary: %v", key, err)
+	}
+	return primaryFor(key).Get(ctx, key).Result()
+}
+
+// cacheMGet reads the cached values of keys with one MGET per Redis, from the
+// same stores cacheGet would read each key from. Keys without a value map to
+// "". The result is all or nothing: on error no value is returned.
+func cacheMGet(ctx context.Context, keys []string) ([]string, error) {
+	vals := make(map[string]string, len(keys))
+	err := errRedisCircuitOpen
+	if redisBreaker.allow() {
+		err = readMGet(ctx, keys, vals)
+		redisBreaker.record(err)
+	}
+	if failoverEligible(err) {
+		useFailover(err)
+		vals = make(map[string]string, len(keys))
+		err = mgetInto(ctx, failoverClient, keys, vals)
+	} else if err == nil {
+		primarySucceeded()
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = vals[key]
+	}
+	return out, nil
+}
+
+// readMGet implements cacheMGet, storing the values found in vals.
+func readMGet(ctx context.Context, keys []string, vals map[string]string) error {
+	if replicaFor(keys[0]) != nil {
+		replicas := make(map[*redis.Client][]string)
+		for _, key := range keys {
+			replicas[replicaFor(key)] = append(replicas[replicaFor(key)], key)
+		}
+		var failed []string
+		for replica, group := range replicas {
+			if err := mgetInto(ctx, replica, group, vals); err != nil {
+				log.Printf("Replica read failed for %d keys, falling back to primary: %v", len(group), err)
+				failed = append(failed, group...)
+			}
+		}
+		if keys = failed; len(keys) == 0 {
+			return nil
+		}
+	}
+	for shard, group := range keysByShard(keys) {
+		if err := mgetInto(ctx, shard, group, vals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mgetInto reads keys from client with MGET, storing the values found in vals.
+func mgetInto(ctx context.Context, client *redis.Client, keys []string, vals map[string]string) error {
+	res, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return err
+	}
+	for i, v := range res {
+		if s, ok := v.(string); ok {
+			vals[keys[i]] = s
+		}
+	}
+	return nil
+}
+
+// cacheTTL returns the remaining TTL of a key, preferring the read replica.
+func cacheTTL(ctx context.Context, key string) (time.Duration, error) {
+	err := errRedisCircuitOpen
+	var ttl time.Duration
+	if redisBreaker.allow() {
+		ttl, err = readTTL(ctx, key)
+		redisBreaker.record(err)
+	}
+	if failoverEligible(err) {
+		useFailover(err)
+		return failoverClient.TTL(ctx, key).Result()
+	}
+	return ttl, err
+}
+
+// readTTL implements cacheTTL.
+func readTTL(ctx context.Context, key string) (time.Duration, error) {
+	if replica := replicaFor(key); replica != nil {
+		ttl, err := replica.TTL(ctx, key).Result()
+		if err == nil {
+			return ttl, nil
+		}
+		log.Printf("Replica TTL failed for %s, falling back to primary: %v", key, err)
+	}
+	return primaryFor(key).TTL(ctx, key).Result()
+}
+
+// cacheSet writes a value to the primary. While writeGuard has suspended writes
+// or redisBreaker is open the value is dropped without contacting Redis. With a
+// failover Redis, writes the primary can't take go there instead, and with
+// CACHE_DUAL_WRITE the others are mirrored there.
+func cacheSet(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	set := func(client *redis.Client) error { return client.Set(ctx, key, value, ttl).Err() }
+	if !writeGuard.allow() {
+		cacheWritesSkipped.Add(1)
+		return nil
+	}
+	if !redisBreaker.allow() {
+		if failoverClient != nil {
+			useFailover(errRedisCircuitOpen)
+			return set(failoverClient)
+		}
+		cacheWritesSkipped.Add(1)
+		return nil
+	}
+	err := set(primaryFor(key))
+	redisBreaker.record(err)
+	writeGuard.record(err)
+	if failoverEligible(err) {
+		useFailover(err)
+		return set(failoverClient)
+	}
+	if err == nil {
+		primarySucceeded()
+		dualWrite(key, set)
+	}
+	return err
+}
+
+// cacheSetIfNewer is cacheSet for cache entries fetched at fetchedAt: the value
+// is dropped when the key already holds an entry fetched at the same time or
+// later, so a slow fetch finishing after a newer one never replaces fresher data
+// and concurrent writes of the same fetch happen once. The check and the write
+// run in a WATCH transaction, retried whenever another write lands in between:
+// each lost race means another write succeeded, and once the newest entry is
+// in place every remaining writer sees it and drops its value, so the retries
+// end with the newest of the concurrent writes persisted. The failover Redis
+// is used as by cacheSet.
+func cacheSetIfNewer(ctx context.Context, key string, value []byte, fetchedAt time.Time, ttl time.Duration) error {
+	set := func(client *redis.Client) error { return setIfNewerOn(ctx, client, key, value, fetchedAt, ttl) }
+	if !writeGuard.allow() {
+		cacheWritesSkipped.Add(1)
+		return nil
+	}
+	if !redisBreaker.allow() {
+		if failoverClient != nil {
+			useFailover(errRedisCircuitOpen)
+			return set(failoverClient)
+		}
+		cacheWritesSkipped.Add(1)
+		return nil
+	}
+	err := set(primaryFor(key))
+	redisBreaker.record(err)
+	writeGuard.record(err)
+	if failoverEligible(err) {
+		useFailover(err)
+		return set(failoverClient)
+	}
+	if err == nil {
+		primarySucceeded()
+		dualWrite(key, set)
+	}
+	return err
+}
+
+// setIfNewerOn implements cacheSetIfNewer on one client.
+func setIfNewerOn(ctx context.Context, client *redis.Client, key string, value []byte, fetchedAt time.Time, ttl time.Duration) error {
+	write := func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil && !entryFetchedAt(current).Before(fetchedAt) {
+			cacheWritesStale.Add(1)
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, value, ttl)
+			return nil
+		})
+		return err
+	}
+	for {
+		err := client.Watch(ctx, write, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// entryFetchedAt returns when a raw cache entry was fetched, or the zero time
+// for entries that are unreadable or don't record it.
+func entryFetchedAt(raw string) time.Time {
+	var entry struct {
+		FetchedAt *time.Time `json:"fetchedAt"`
+	}
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil || entry.FetchedAt == nil {
+		return time.Time{}
+	}
+	return *entry.FetchedAt
+}
+
+// cacheDelete removes keys from the primary, from whichever shard holds each,
+// and from the failover Redis, if any, so deleted entries don't come back on a
+// failover.
+func cacheDelete(keys ...string) error {
+	if failoverClient != nil {
+		if err := failoverClient.Del(ctx, keys...).Err(); err != nil {
+			log.Printf("Error deleting cache keys from the failover Redis: %v", err)
+		}
+	}
+	if !redisBreaker.allow() {
+		return errRedisCircuitOpen
+	}
+	var err error
+	for shard, group := range keysByShard(keys) {
+		if err = shard.Del(ctx, group...).Err(); err != nil {
+			break
+		}
+	}
+	redisBreaker.record(err)
+	return err
+}
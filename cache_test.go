@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// entryAt encodes a cache entry for key fetched at fetchedAt.
+func entryAt(t *testing.T, key string, fetchedAt time.Time) []byte {
+	t.Helper()
+	entry, err := encodeEntry(key, map[string]interface{}{"days": []interface{}{map[string]interface{}{"temp": 1.0}}}, fetchedAt, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestCacheSetIfNewerKeepsFresherEntry(t *testing.T) {
+	mr := setupTest(t, testConfig(t), nil)
+	ctx := context.Background()
+	newer := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	stale := cacheWritesStale.Load()
+
+	if err := cacheSetIfNewer(ctx, "k", entryAt(t, "k", newer), newer, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// A slower fetch started earlier finishes last, with the same write repeated.
+	for _, at := range []time.Time{newer.Add(-time.Second), newer} {
+		if err := cacheSetIfNewer(ctx, "k", entryAt(t, "k", at), at, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, _ := mr.Get("k")
+	if got := entryFetchedAt(raw); !got.Equal(newer) {
+		t.Errorf("entry fetched at %s, want the newer %s", got, newer)
+	}
+	if got := cacheWritesStale.Load() - stale; got != 2 {
+		t.Errorf("counted %d stale writes, want 2", got)
+	}
+
+	later := newer.Add(time.Second)
+	if err := cacheSetIfNewer(ctx, "k", entryAt(t, "k", later), later, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := mr.Get("k"); !entryFetchedAt(raw).Equal(later) {
+		t.Error("newer fetch didn't replace the entry")
+	}
+}
+
+func TestCacheSetIfNewerConcurrent(t *testing.T) {
+	mr := setupTest(t, testConfig(t), nil)
+	base := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	const writers = 20
+	times := make([]time.Time, writers)
+	for i, j := range rand.Perm(writers) {
+		times[i] = base.Add(time.Duration(j) * time.Second)
+	}
+
+	var wg sync.WaitGroup
+	for _, at := range times {
+		wg.Add(1)
+		go func(at time.Time) {
+			defer wg.Done()
+			if err := cacheSetIfNewer(context.Background(), "k", entryAt(t, "k", at), at, time.Minute); err != nil {
+				t.Errorf("cacheSetIfNewer: %v", err)
+			}
+		}(at)
+	}
+	wg.Wait()
+
+	raw, _ := mr.Get("k")
+	if got, want := entryFetchedAt(raw), base.Add((writers-1)*time.Second); !got.Equal(want) {
+		t.Errorf("entry fetched at %s after out-of-order writes, want the newest %s", got, want)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxConsistencyLocations bounds the locations of one consistency check.
+const maxConsistencyLocations = 50
+
+// cacheCopy is what one Redis holds under a cache key, as reported by
+// GET /admin/cache/consistency.
+type cacheCopy struct {
+	Store         string `json:"store"`           // "primary", "replica" or "failover"
+	Shard         *int   `json:"shard,omitempty"` // database offset with CACHE_SHARDS
+	Present       bool   `json:"present"`
+	Hash          string `json:"hash,omitempty"` // of the stored value, to compare copies
+	TTLSeconds    *int64 `json:"ttlSeconds,omitempty"`
+	FetchedAt     string `json:"fetchedAt,omitempty"`
+	SchemaVersion int    `json:"schemaVersion,omitempty"`
+	Ref           string `json:"ref,omitempty"` // COORDINATE_DEDUP or CONTENT_ADDRESSED_CACHE reference
+	Error         string `json:"error,omitempty"`
+}
+
+// readCacheCopy reads key from client, bypassing the breaker and every
+// fallback, so each store is seen as it is.
+func readCacheCopy(client *redis.Client, store string, shard *int, key string) cacheCopy {
+	cp := cacheCopy{Store: store, Shard: shard}
+	raw, err := client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return cp
+	}
+	if err != nil {
+		cp.Error = err.Error()
+		return cp
+	}
+	cp.Present = true
+	sum := sha256.Sum256([]byte(raw))
+	cp.Hash = hex.EncodeToString(sum[:8])
+	var entry cacheEntry
+	if json.Unmarshal([]byte(raw), &entry) == nil {
+		cp.SchemaVersion = entry.Version
+		cp.Ref = entry.Ref
+		if !entry.fetchedAt().IsZero() {
+			cp.FetchedAt = entry.fetchedAt().UTC().Format(time.RFC3339)
+		}
+	}
+	if ttl, err := client.TTL(ctx, key).Result(); err == nil {
+		seconds := int64(ttl.Seconds())
+		if ttl < 0 {
+			seconds = int64(ttl) // -1 without expiry, as Redis reports it
+		}
+		cp.TTLSeconds = &seconds
+	}
+	return cp
+}
+
+// cacheCopies reads every copy of key: one per primary shard, the owning
+// shard's replica and the failover, as configured.
+func cacheCopies(key string) []cacheCopy {
+	var copies []cacheCopy
+	shards := primaryShards()
+	for i, shard := range shards {
+		var index *int
+		if len(shards) > 1 {
+			i := i
+			index = &i
+		}
+		copies = append(copies, readCacheCopy(shard, "primary", index, key))
+	}
+	if replica := replicaFor(key); replica != nil {
+		var index *int
+		if len(replicaShards) > 1 {
+			i := shardIndex(key, len(replicaShards))
+			index = &i
+		}
+		copies = append(copies, readCacheCopy(replica, "replica", index, key))
+	}
+	if failoverClient != nil {
+		copies = append(copies, readCacheCopy(failoverClient, "failover", nil, key))
+	}
+	return copies
+}
+
+// cacheInconsistencies describes what is wrong with the copies of a key owned
+// by shard owner: copies on shards other than the owner, left behind by a
+// change of CACHE_SHARDS; a replica or failover disagreeing with the owner,
+// as after a split brain or a missed invalidation; entries of another schema
+// version; and entries without expiry. Stores that couldn't be read are
+// reported too. Without CACHE_DUAL_WRITE the failover only holds what was
+// written during outages, so its absence isn't flagged.
+func cacheInconsistencies(copies []cacheCopy, owner int) []string {
+	problems := []string{}
+	var primary *cacheCopy
+	for i := range copies {
+		cp := &copies[i]
+		name := cp.Store
+		if cp.Shard != nil {
+			name = fmt.Sprintf("%s shard %d", cp.Store, *cp.Shard)
+		}
+		if cp.Error != "" {
+			problems = append(problems, fmt.Sprintf("%s could not be read: %s", name, cp.Error))
+			continue
+		}
+		if cp.Store == "primary" && (cp.Shard == nil || *cp.Shard == owner) {
+			primary = cp
+		} else if cp.Store == "primary" && cp.Present {
+			problems = append(problems, fmt.Sprintf("%s holds the key, but shard %d owns it", name, owner))
+		}
+		if cp.Present && cp.SchemaVersion != cacheSchemaVersion {
+			problems = append(problems, fmt.Sprintf("%s has schema version %d, current is %d", name, cp.SchemaVersion, cacheSchemaVersion))
+		}
+		if cp.Present && cp.TTLSeconds != nil && *cp.TTLSeconds == -1 {
+			problems = append(problems, name+" has no expiry")
+		}
+	}
+	if primary == nil || primary.Error != "" {
+		return problems
+	}
+	for _, cp := range copies {
+		if cp.Error != "" || cp.Store == "primary" {
+			continue
+		}
+		switch {
+		case cp.Present && !primary.Present:
+			problems = append(problems, cp.Store+" holds the key, the primary doesn't")
+		case !cp.Present && primary.Present && (cp.Store == "replica" || cfg.CacheDualWrite):
+			problems = append(problems, "the primary holds the key, "+cp.Store+" doesn't")
+		case cp.Present && cp.Hash != primary.Hash:
+			problems = append(problems, cp.Store+" differs from the primary")
+		}
+	}
+	return problems
+}
+
+// cacheConsistencyHandler handles GET /admin/cache/consistency, reporting for
+// each location parameter (repeat it for several, at most
+// maxConsistencyLocations) every copy of its cache entry across the primary
+// shards, the read replica and the failover, with their hash, TTL, fetch time
+// and schema version, and what doesn't agree. endpoint (default /weather) and
+// lang pick the cache entry as for GET /admin/weather/diff. Nothing is fetched
+// or written.
+func cacheConsistencyHandler(c *gin.Context) {
+	locations := c.QueryArray("location")
+	if len(locations) == 0 || len(locations) > maxConsistencyLocations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("between 1 and %d location parameters are required", maxConsistencyLocations)})
+		return
+	}
+	endpoint := c.DefaultQuery("endpoint", "/weather")
+	if _, ok := defaultEndpointIncludes[endpoint]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown endpoint %q", endpoint)})
+		return
+	}
+	lang := cfg.DefaultLang
+	if raw := c.Query("lang"); raw != "" {
+		var err error
+		if lang, err = parseLang(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := clock.Now()
+	inconsistent := 0
+	results := make([]gin.H, 0, len(locations))
+	for _, location := range locations {
+		parsed, err := parseLocation(queryParams{Location: location})
+		if err != nil {
+			results = append(results, gin.H{"location": location, "error": errInvalidLocation.Message})
+			continue
+		}
+		q := weatherQuery{Location: parsed.Upstream, Airport: parsed.Airport, Include: endpointInclude(endpoint), Lang: lang}
+		q = routeQuery(q, endpoint, now)
+		key := q.cacheKey()
+		copies := cacheCopies(key)
+		owner := 0
+		if len(cacheShards) > 1 {
+			owner = shardIndex(key, len(cacheShards))
+		}
+		problems := cacheInconsistencies(copies, owner)
+		if len(problems) > 0 {
+			inconsistent++
+		}
+		result := gin.H{
+			"location":        q.Location,
+			"key":             q.canonicalKey(),
+			"consistent":      len(problems) == 0,
+			"copies":          copies,
+			"inconsistencies": problems,
+		}
+		if len(cacheShards) > 1 {
+			result["shard"] = owner
+		}
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, gin.H{"locations": results, "inconsistent": inconsistent})
+}
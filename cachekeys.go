@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// entryKey reads the canonical key stored in a cache entry. Entries written
+// before keys were hashed carry none.
+func entryKey(raw string) (string, error) {
+	var entry struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return "", err
+	}
+	return entry.Key, nil
+}
+
+// cacheKeysHandler handles GET /admin/cache/keys, mapping hashed cache keys back
+// to the query they cache. With ?key= it resolves a single key; otherwise it
+// lists every cached weather entry.
+func cacheKeysHandler(c *gin.Context) {
+	if key := c.Query("key"); key != "" {
+		val, err := cacheGet(ctx, key)
+		if err == redis.Nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "cache key not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading cache key %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		canonical, err := entryKey(val)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cache entry is unreadable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"key": key, "params": canonical})
+		return
+	}
+
+	keys := make(map[string]string)
+	err := scanCacheKeys(func(key string) {
+		val, err := cacheGet(ctx, key)
+		if err != nil {
+			// Expired between SCAN and GET.
+			return
+		}
+		if canonical, err := entryKey(val); err == nil {
+			keys[key] = canonical
+		}
+	})
+	if err != nil {
+		log.Printf("Error scanning cache keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
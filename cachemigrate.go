@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+)
+
+// migrateBatch is how many orphaned keys a migration sweep deletes per call.
+const migrateBatch = 100
+
+// isLiveCacheKey reports whether key has the shape of a current cache key, see
+// weatherQuery.cacheKey. Migration sweeps never delete such keys, so
+// CACHE_MIGRATE_FROM_PREFIX may be the live prefix itself, to drop entries
+// written before keys were hashed.
+func isLiveCacheKey(key string) bool {
+	hash, ok := strings.CutPrefix(key, cachePrefix)
+	if !ok || len(hash) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// globEscaper escapes the pattern characters of Redis SCAN MATCH.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// sweepCacheKeys deletes every key starting with prefix that isn't a live cache
+// key, on every shard, returning how many were deleted.
+func sweepCacheKeys(ctx context.Context, prefix string) (int, error) {
+	deleted := 0
+	for _, shard := range primaryShards() {
+		var batch []string
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			n, err := shard.Del(ctx, batch...).Result()
+			deleted += int(n)
+			batch = batch[:0]
+			return err
+		}
+		iter := shard.Scan(ctx, 0, globEscaper.Replace(prefix)+"*", migrateBatch).Iterator()
+		for iter.Next(ctx) {
+			if key := iter.Val(); !isLiveCacheKey(key) {
+				if batch = append(batch, key); len(batch) == migrateBatch {
+					if err := flush(); err != nil {
+						return deleted, err
+					}
+				}
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return deleted, err
+		}
+		if err := flush(); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// startCacheMigration sweeps keys of the old cache key scheme under prefix once
+// right away and then every interval, or only once when interval is zero, until
+// ctx is done. The returned channel is closed once it has stopped.
+func startCacheMigration(ctx context.Context, prefix string, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			start := time.Now()
+			n, err := sweepCacheKeys(ctx, prefix)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Cache migration from %q stopped after deleting %d keys: %v", prefix, n, err)
+			} else {
+				log.Printf("Cache migration from %q deleted %d orphaned keys in %s", prefix, n, time.Since(start).Round(time.Millisecond))
+			}
+			if tick == nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"hash/fnv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cacheShards spreads cache entries over CACHE_SHARDS Redis databases: shard i
+// uses database REDIS_DB+i, shard 0 being redisClient itself. Nil keeps every
+// entry in redisClient. Other data such as quotas and rate limits always lives
+// in redisClient.
+var cacheShards []*redis.Client
+
+// replicaShards are the read replica's clients for cacheShards, set with
+// REDIS_REPLICA_URL; shard 0 is replicaClient.
+var replicaShards []*redis.Client
+
+// shardIndex maps a cache key to one of n shards by its FNV-1a hash.
+func shardIndex(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// primaryFor returns the client holding key.
+func primaryFor(key string) *redis.Client {
+	if len(cacheShards) == 0 {
+		return redisClient
+	}
+	return cacheShards[shardIndex(key, len(cacheShards))]
+}
+
+// replicaFor returns the read replica client for key, or nil without a replica.
+func replicaFor(key string) *redis.Client {
+	if len(replicaShards) == 0 {
+		return replicaClient
+	}
+	return replicaShards[shardIndex(key, len(replicaShards))]
+}
+
+// primaryShards returns the clients of every shard, in shard order.
+func primaryShards() []*redis.Client {
+	if len(cacheShards) == 0 {
+		return []*redis.Client{redisClient}
+	}
+	return cacheShards
+}
+
+// newShards returns n clients for the databases db to db+n-1 of the server opt
+// points at, reusing first for database db.
+func newShards(first *redis.Client, opt *redis.Options, db, n int) []*redis.Client {
+	if n <= 1 {
+		return nil
+	}
+	shards := []*redis.Client{first}
+	for i := 1; i < n; i++ {
+		shardOpt := *opt
+		shardOpt.DB = db + i
+		shards = append(shards, redis.NewClient(&shardOpt))
+	}
+	return shards
+}
+
+// closeShards closes the shard clients other than shard 0, which is closed with
+// redisClient or replicaClient.
+func closeShards() {
+	for _, shards := range [][]*redis.Client{cacheShards, replicaShards} {
+		for i, shard := range shards {
+			if i > 0 {
+				shard.Close()
+			}
+		}
+	}
+}
+
+// scanCacheKeys calls fn for every cached weather key on every shard.
+func scanCacheKeys(fn func(key string)) error {
+	for _, shard := range primaryShards() {
+		iter := shard.Scan(ctx, 0, cachePrefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			fn(iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keysByShard groups keys by the client holding them.
+func keysByShard(keys []string) map[*redis.Client][]string {
+	groups := make(map[*redis.Client][]string)
+	for _, key := range keys {
+		shard := primaryFor(key)
+		groups[shard] = append(groups[shard], key)
+	}
+	return groups
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCacheExpiration caps the cache TTL that can be set at runtime.
+const maxCacheExpiration = 30 * 24 * time.Hour
+
+// cacheExpirationOverride holds the cache TTL set through POST
+// /admin/config/ttl; zero means cfg.CacheExpiration is in effect. It is per
+// instance and lost on restart.
+var cacheExpirationOverride atomic.Int64
+
+// cacheExpiration returns the base TTL for new cache entries, before adaptive
+// degradation.
+func cacheExpiration() time.Duration {
+	if d := cacheExpirationOverride.Load(); d > 0 {
+		return time.Duration(d)
+	}
+	return cfg.CacheExpiration
+}
+
+// cacheTTLHandler handles POST /admin/config/ttl, setting the cache TTL used for
+// subsequent writes from a {"seconds":N} body. N must lie between CACHE_MIN_TTL
+// and 30 days. Existing entries keep their TTL.
+func cacheTTLHandler(c *gin.Context) {
+	var body struct {
+		Seconds *int64 `json:"seconds"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Seconds == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `expected a JSON body like {"seconds":3600}`})
+		return
+	}
+	ttl := time.Duration(*body.Seconds) * time.Second
+	if ttl < cfg.CacheMinTTL || ttl > maxCacheExpiration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("seconds must be between %d and %d",
+			int64(cfg.CacheMinTTL.Seconds()), int64(maxCacheExpiration.Seconds()))})
+		return
+	}
+	c.Set(auditTargetKey, "cacheExpiration")
+	cacheExpirationOverride.Store(int64(ttl))
+	c.JSON(http.StatusOK, gin.H{"seconds": *body.Seconds})
+}
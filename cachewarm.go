@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// startCacheWarmer refreshes the cache entries of c.WarmLocations every
+// c.WarmInterval, starting after delay, until ctx is done. Each round spreads the
+// locations over c.WarmConcurrency workers that pause c.WarmDelay between their
+// upstream calls, so the upstream sees a gentle trickle rather than a burst. The
+// returned channel is closed once the warmer has stopped.
+func startCacheWarmer(ctx context.Context, c Config, delay time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		ticker := time.NewTicker(c.WarmInterval)
+		defer ticker.Stop()
+		for {
+			warmCache(ctx, c)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// warmCache runs one warming round over c.WarmLocations. Rounds stop early while
+// the upstream quota is known to be exhausted.
+func warmCache(ctx context.Context, c Config) {
+	start := time.Now()
+	locations := make(chan string)
+	var mu sync.Mutex
+	var refreshed, failed int
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.WarmConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for location := range locations {
+				err := warmLocation(ctx, location)
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					refreshed++
+				}
+				mu.Unlock()
+				if err != nil {
+					log.Printf("Cache warming failed for %s: %v", location, err)
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(c.WarmDelay):
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, location := range c.WarmLocations {
+		if _, exhausted := quotaExhaustedUntil(clock.Now()); exhausted {
+			log.Printf("Cache warming paused: upstream quota exhausted")
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break feed
+		case locations <- location:
+		}
+	}
+	close(locations)
+	wg.Wait()
+	log.Printf("Cache warming refreshed %d of %d locations in %s (%d failed)",
+		refreshed, len(c.WarmLocations), time.Since(start).Round(time.Millisecond), failed)
+}
+
+// warmLocation fetches location as /weather would and overwrites its cache entry.
+func warmLocation(ctx context.Context, location string) error {
+	loc, err := parseLocation(queryParams{Location: location})
+	if err != nil {
+		return err
+	}
+	q := weatherQuery{Location: loc.Upstream, Include: endpointInclude("/weather"), Lang: cfg.DefaultLang}
+	_, _, err = coalescedFetchAndCache(ctx, q, q.cacheKey())
+	return err
+}
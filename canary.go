@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// canaryCacheKey is written and read back by /canary to exercise Redis.
+const canaryCacheKey = "canary:roundtrip"
+
+// canaryHandler handles GET /canary requests. It fetches the configured canary
+// location straight from the upstream (bypassing the cache), checks the response
+// shape and performs a Redis write/read round trip, reporting latencies for both.
+func canaryHandler(c *gin.Context) {
+	start := time.Now()
+	data, _, err := fetchWeatherData(c.Request.Context(), weatherQuery{Location: cfg.CanaryLocation})
+	upstreamLatency := time.Since(start)
+
+	result := gin.H{
+		"location":   cfg.CanaryLocation,
+		"latency_ms": upstreamLatency.Milliseconds(),
+	}
+	if err != nil {
+		result["ok"] = false
+		result["error"] = err.Error()
+		c.JSON(http.StatusServiceUnavailable, result)
+		return
+	}
+	if days, _ := data["days"].([]interface{}); len(days) == 0 {
+		result["ok"] = false
+		result["error"] = "upstream response has no daily data"
+		c.JSON(http.StatusServiceUnavailable, result)
+		return
+	}
+
+	start = time.Now()
+	if err := cacheSet(ctx, canaryCacheKey, start.UTC().Format(time.RFC3339Nano), time.Minute); err != nil {
+		result["ok"] = false
+		result["error"] = "cache write failed: " + err.Error()
+		c.JSON(http.StatusServiceUnavailable, result)
+		return
+	}
+	if _, err := cacheGet(ctx, canaryCacheKey); err != nil {
+		result["ok"] = false
+		result["error"] = "cache read failed: " + err.Error()
+		c.JSON(http.StatusServiceUnavailable, result)
+		return
+	}
+	result["cache_latency_ms"] = time.Since(start).Milliseconds()
+
+	result["ok"] = true
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// Clock abstracts the current time for logic that depends on wall-clock time
+// (cache freshness, quota resets, date horizons), so tests can substitute a fake
+// clock instead of sleeping. Latency measurements keep using time.Now directly.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock backed by the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// clock is the Clock used by the service; tests may replace it.
+var clock Clock = realClock{}
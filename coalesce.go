@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// fetchGroup coalesces concurrent cache misses for the same key into a single
+// upstream call.
+var fetchGroup singleflight.Group
+
+// Coalescing counters, reported by /stats.
+var (
+	upstreamFetchesLed atomic.Int64 // requests that made the upstream call themselves
+	coalescedRequests  atomic.Int64 // requests served by another request's call
+)
+
+// sharedFetch is the result handed to every caller of a coalesced fetch. The data
+// is shared encoded so each caller decodes its own copy and can transform it
+// freely.
+type sharedFetch struct {
+	encoded []byte
+	info    upstreamInfo
+}
+
+// coalescedFetchAndCache is fetchAndCache with concurrent calls for the same key
+// sharing one upstream call, and with FETCH_LOCK_TTL the calls of other
+// instances too, see lockedFetchAndCache. The call runs under the context of the request that
+// started it; if that request goes away, waiting requests whose own context is
+// still live fetch for themselves instead of failing with its cancellation.
+func coalescedFetchAndCache(ctx context.Context, q weatherQuery, cacheKey string) (map[string]interface{}, upstreamInfo, error) {
+	var led bool
+	var leaderData map[string]interface{}
+	v, err, _ := fetchGroup.Do(cacheKey, func() (interface{}, error) {
+		led = true
+		data, info, err := lockedFetchAndCache(ctx, q, cacheKey)
+		if err != nil {
+			return sharedFetch{info: info}, err
+		}
+		leaderData = data
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return sharedFetch{info: info}, err
+		}
+		return sharedFetch{encoded: encoded, info: info}, nil
+	})
+	res, _ := v.(sharedFetch)
+
+	// The closure only ran for the request that led the call.
+	if led {
+		upstreamFetchesLed.Add(1)
+		if err != nil {
+			return nil, res.info, err
+		}
+		return leaderData, res.info, nil
+	}
+	if err != nil && isContextError(err) && ctx.Err() == nil {
+		// The leader was cancelled, not us.
+		upstreamFetchesLed.Add(1)
+		return lockedFetchAndCache(ctx, q, cacheKey)
+	}
+
+	coalescedRequests.Add(1)
+	if err != nil {
+		return nil, res.info, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(res.encoded, &data); err != nil {
+		return nil, res.info, err
+	}
+	return data, res.info, nil
+}
+
+// isContextError reports whether err stems from a cancelled or expired context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// comfortDay is the per-day comfort detail returned by /weather/comfort.
+type comfortDay struct {
+	Date      string   `json:"date"`
+	Temp      float64  `json:"temp"`
+	Humidity  *float64 `json:"humidity,omitempty"`
+	Dew       *float64 `json:"dew,omitempty"`
+	HeatIndex *float64 `json:"heatIndex,omitempty"`
+}
+
+// heatIndexC returns the NWS heat index (apparent temperature) in °C for a dry-bulb
+// temperature in °C and relative humidity in percent. It uses Steadman's simple
+// formula and switches to the Rothfusz regression, with the NWS low/high
+// humidity adjustments, once the simple result reaches 80°F.
+func heatIndexC(tempC, rh float64) float64 {
+	t := tempC*9/5 + 32
+
+	hi := 0.5 * (t + 61.0 + (t-68.0)*1.2 + rh*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*rh -
+			0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+			0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+		switch {
+		case rh < 13 && t >= 80 && t <= 112:
+			hi -= ((13 - rh) / 4) * math.Sqrt((17-math.Abs(t-95))/17)
+		case rh > 85 && t >= 80 && t <= 87:
+			hi += ((rh - 85) / 10) * ((87 - t) / 5)
+		}
+	}
+
+	return (hi - 32) * 5 / 9
+}
+
+// comfortDays extracts humidity and dew point per day and computes the heat index.
+// Days without humidity carry no computed fields.
+func comfortDays(days []weatherDay) []comfortDay {
+	out := make([]comfortDay, 0, len(days))
+	for _, d := range days {
+		cd := comfortDay{Date: d.Datetime, Temp: d.Temp, Humidity: d.Humidity, Dew: d.Dew}
+		if d.Humidity != nil {
+			hi := math.Round(heatIndexC(d.Temp, *d.Humidity)*10) / 10
+			cd.HeatIndex = &hi
+		}
+		out = append(out, cd)
+	}
+	return out
+}
+
+// getComfortHandler handles GET /weather/comfort requests, returning humidity, dew
+// point and heat index per day from the (cached) full response.
+func getComfortHandler(c *gin.Context) {
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"location": q.Location,
+		"days":     comfortDays(days),
+	})
+}
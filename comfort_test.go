@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComfortDays(t *testing.T) {
+	num := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		name      string
+		day       weatherDay
+		heatIndex *float64
+	}{
+		{"no humidity", weatherDay{Datetime: "2026-10-14", Temp: 30, Dew: num(12)}, nil},
+		// Below 80°F Steadman's simple formula applies: 0.5*(68+61+0+4.7)°F.
+		{"mild", weatherDay{Datetime: "2026-10-14", Temp: 20, Humidity: num(50)}, num(19.4)},
+		// 90°F at 50% is 95°F on the NWS chart.
+		{"hot", weatherDay{Datetime: "2026-10-14", Temp: 32.2, Humidity: num(50)}, num(35)},
+	} {
+		got := comfortDays([]weatherDay{tc.day})
+		if len(got) != 1 {
+			t.Fatalf("%s: %d days", tc.name, len(got))
+		}
+		cd := got[0]
+		if cd.Date != tc.day.Datetime || cd.Temp != tc.day.Temp || cd.Humidity != tc.day.Humidity || cd.Dew != tc.day.Dew {
+			t.Errorf("%s: %+v doesn't carry the day's fields", tc.name, cd)
+		}
+		switch {
+		case tc.heatIndex == nil && cd.HeatIndex != nil:
+			t.Errorf("%s: heat index %v without humidity", tc.name, *cd.HeatIndex)
+		case tc.heatIndex != nil && (cd.HeatIndex == nil || math.Abs(*cd.HeatIndex-*tc.heatIndex) > 0.3):
+			t.Errorf("%s: heat index %v, want about %v", tc.name, cd.HeatIndex, *tc.heatIndex)
+		case cd.HeatIndex != nil && math.Round(*cd.HeatIndex*10) != *cd.HeatIndex*10:
+			t.Errorf("%s: heat index %v not rounded to a tenth", tc.name, *cd.HeatIndex)
+		}
+	}
+	if got := comfortDays(nil); got == nil || len(got) != 0 {
+		t.Errorf("comfortDays(nil) = %#v, want an empty list", got)
+	}
+}
+
+// TestHeatIndexAdjustments checks the NWS adjustments to the Rothfusz
+// regression for very dry and very humid air.
+func TestHeatIndexAdjustments(t *testing.T) {
+	rothfusz := func(t, rh float64) float64 {
+		return -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh - 0.00683783*t*t -
+			0.05481717*rh*rh + 0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	}
+	for _, tc := range []struct {
+		tempF, rh float64
+		adjust    float64 // °F added to the regression
+	}{
+		{100, 10, -(13.0 - 10) / 4 * math.Sqrt((17.0-5)/17)},
+		{84, 90, (90.0 - 85) / 10 * (87.0 - 84) / 5},
+		{100, 50, 0},
+	} {
+		got := heatIndexC(fahrenheitToC(tc.tempF), tc.rh)
+		want := fahrenheitToC(rothfusz(tc.tempF, tc.rh) + tc.adjust)
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("heatIndexC(%v°F, %v%%) = %v, want %v", tc.tempF, tc.rh, got, want)
+		}
+	}
+}
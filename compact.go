@@ -0,0 +1,68 @@
+package main
+
+// compactResponse is the typed payload returned for profile=compact: the
+// resolved address and timezone, the current conditions and the daily
+// forecast, each only when the include parameter asks for it. Fields the
+// upstream didn't provide are zero values.
+type compactResponse struct {
+	Address  string          `json:"address"`
+	Timezone string          `json:"timezone"`
+	Current  *compactCurrent `json:"current,omitempty"`
+	Days     []compactDay    `json:"days,omitempty"`
+}
+
+// compactCurrent is the current conditions part of compactResponse.
+type compactCurrent struct {
+	Temp       float64 `json:"temp"`
+	Humidity   float64 `json:"humidity"`
+	Conditions string  `json:"conditions"`
+}
+
+// compactDay is one day's forecast in compactResponse.
+type compactDay struct {
+	Date   string  `json:"date"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Precip float64 `json:"precip"`
+}
+
+// compactIncludes reports which sections of compactResponse the include
+// parameter (days, current or all, the default) selects.
+func compactIncludes(include string) (days, current bool) {
+	switch include {
+	case "days":
+		return true, false
+	case "current":
+		return false, true
+	}
+	return true, true
+}
+
+// compactView builds the profile=compact payload from the typed model. Current
+// conditions asked for are always present, zero-valued if the upstream sent
+// none.
+func compactView(data map[string]interface{}, current *weatherCurrent, days []weatherDay, include string) compactResponse {
+	out := compactResponse{}
+	out.Address, _ = data["resolvedAddress"].(string)
+	out.Timezone, _ = data["timezone"].(string)
+	withDays, withCurrent := compactIncludes(include)
+	if withCurrent {
+		out.Current = &compactCurrent{}
+		if current != nil {
+			out.Current.Conditions = current.Conditions
+			if current.Temp != nil {
+				out.Current.Temp = *current.Temp
+			}
+			if current.Humidity != nil {
+				out.Current.Humidity = *current.Humidity
+			}
+		}
+	}
+	if withDays {
+		out.Days = make([]compactDay, 0, len(days))
+		for _, d := range days {
+			out.Days = append(out.Days, compactDay{Date: d.Datetime, Min: d.TempMin, Max: d.TempMax, Precip: d.Precip})
+		}
+	}
+	return out
+}
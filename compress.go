@@ -0,0 +1,175 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipWriters pools gzip writers, which are costly to allocate per response.
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+
+// gzipMiddleware compresses responses for clients that accept gzip once they
+// reach minBytes. Smaller bodies are buffered and sent as they are, with the
+// Content-Length the handler set, since compressing them costs CPU and often
+// makes them larger. A handler flushing a streamed response before minBytes is
+// reached commits it to compression, as its final size is unknown.
+func gzipMiddleware(minBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+		w := &gzipWriter{ResponseWriter: c.Writer, minBytes: minBytes,
+			gzipValidated: strings.Contains(c.GetHeader("If-None-Match"), gzipETagSuffix+`"`)}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, ignoring
+// entries disabled with q=0.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipETagSuffix sets the strong ETag of a gzipped body apart from that of the
+// identity body, as different content codings must have different validators.
+const gzipETagSuffix = "-gzip"
+
+// gzipETag returns the ETag of the gzipped form of the body tagged etag. Weak
+// ETags are returned as they are.
+func gzipETag(etag string) string {
+	if len(etag) < 2 || etag[0] != '"' || strings.HasSuffix(etag, gzipETagSuffix+`"`) {
+		return etag
+	}
+	return etag[:len(etag)-1] + gzipETagSuffix + `"`
+}
+
+// identityETag undoes gzipETag, so If-None-Match can present either form.
+func identityETag(etag string) string {
+	if base, ok := strings.CutSuffix(etag, gzipETagSuffix+`"`); ok {
+		return base + `"`
+	}
+	return etag
+}
+
+// gzipWriter holds back the start of a response until it knows whether the body
+// reaches minBytes, then either compresses it or writes it unchanged.
+type gzipWriter struct {
+	gin.ResponseWriter
+	minBytes      int
+	buf           []byte
+	gz            *gzip.Writer
+	plain         bool // decided against compression
+	gzipValidated bool // If-None-Match holds the ETag of a gzipped body
+}
+
+// Write implements http.ResponseWriter.
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(b)
+	case w.plain:
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minBytes {
+		if err := w.compress(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// WriteString implements gin.ResponseWriter.
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush implements http.Flusher, committing an undecided response to
+// compression.
+func (w *gzipWriter) Flush() {
+	if w.gz == nil && !w.plain {
+		if err := w.compress(); err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compress switches the response to gzip and writes the buffered start of the
+// body through it. Responses that already carry a Content-Encoding, and
+// statuses without a body, are left uncompressed.
+func (w *gzipWriter) compress() error {
+	h := w.Header()
+	if status := w.Status(); h.Get("Content-Encoding") != "" || status == http.StatusNoContent || status == http.StatusNotModified {
+		return w.writePlain()
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); etag != "" {
+		h.Set("ETag", gzipETag(etag))
+	}
+	w.gz = gzipWriters.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+// writePlain sends the buffered start of the body uncompressed.
+func (w *gzipWriter) writePlain() error {
+	w.plain = true
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// finish completes the response once the handlers have returned: it closes the
+// gzip stream, or sends a body that stayed below minBytes as it is. A 304 for a
+// gzipped copy repeats that copy's ETag.
+func (w *gzipWriter) finish() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+		w.gz.Reset(nil)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+		return
+	}
+	if !w.plain {
+		if etag := w.Header().Get("ETag"); etag != "" && w.gzipValidated && w.Status() == http.StatusNotModified {
+			w.Header().Set("ETag", gzipETag(etag))
+		}
+		_ = w.writePlain()
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// getGzipWeather serves GET /weather?location=London, accepting gzip when asked,
+// with If-None-Match set to match unless it is empty.
+func getGzipWeather(gzipped bool, match string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+	if gzipped {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	if match != "" {
+		req.Header.Set("If-None-Match", match)
+	}
+	return serveRequest(req)
+}
+
+func TestGzipETag(t *testing.T) {
+	c := testConfig(t)
+	c.Gzip = true
+	c.GzipMinBytes = 0
+	setupTest(t, c, respondWith(http.StatusOK, fixtureWeather))
+
+	plain, gzipped := getGzipWeather(false, ""), getGzipWeather(true, "")
+	if gzipped.Header().Get("Content-Encoding") != "gzip" || plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("Content-Encoding %q with gzip, %q without", gzipped.Header().Get("Content-Encoding"), plain.Header().Get("Content-Encoding"))
+	}
+	plainTag, gzipTag := plain.Header().Get("ETag"), gzipped.Header().Get("ETag")
+	if plainTag == "" || plainTag == gzipTag {
+		t.Fatalf("ETag %q without gzip, %q with it, want them to differ", plainTag, gzipTag)
+	}
+	if want := strings.TrimSuffix(plainTag, `"`) + `-gzip"`; gzipTag != want {
+		t.Errorf("gzipped ETag %q, want %q", gzipTag, want)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		gzipped bool
+		match   string
+		etag    string
+	}{
+		{"identity", false, plainTag, plainTag},
+		{"gzipped", true, gzipTag, gzipTag},
+		// A client holding either copy is up to date.
+		{"gzipped copy without gzip", false, gzipTag, plainTag},
+		{"identity copy with gzip", true, plainTag, plainTag},
+	} {
+		w := getGzipWeather(tc.gzipped, tc.match)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: revalidation got %d, want 304", tc.name, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got != tc.etag {
+			t.Errorf("%s: 304 ETag %q, want %q", tc.name, got, tc.etag)
+		}
+	}
+}
+
+func TestGzipETagForms(t *testing.T) {
+	for _, tc := range []struct{ etag, gzipped string }{
+		{`"abc"`, `"abc-gzip"`},
+		{`"abc-gzip"`, `"abc-gzip"`},
+		{`W/"abc"`, `W/"abc"`},
+		{``, ``},
+	} {
+		if got := gzipETag(tc.etag); got != tc.gzipped {
+			t.Errorf("gzipETag(%q) = %q, want %q", tc.etag, got, tc.gzipped)
+		}
+	}
+	for _, tc := range []struct{ etag, identity string }{
+		{`"abc-gzip"`, `"abc"`},
+		{`"abc"`, `"abc"`},
+		{`W/"abc"`, `W/"abc"`},
+	} {
+		if got := identityETag(tc.etag); got != tc.identity {
+			t.Errorf("identityETag(%q) = %q, want %q", tc.etag, got, tc.identity)
+		}
+	}
+}
+
+func TestGzipContentSHA256(t *testing.T) {
+	c := testConfig(t)
+	c.Gzip = true
+	c.GzipMinBytes = 0
+	setupTest(t, c, respondWith(http.StatusOK, fixtureWeather))
+
+	w := getGzipWeather(true, "")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("response not gzipped, Content-Encoding %q", w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("reading gzipped body: %v", err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decoding gzipped body: %v", err)
+	}
+	sum := sha256.Sum256(decoded)
+	if got := w.Header().Get(contentSHA256Header); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("%s %s doesn't match the decoded body", contentSHA256Header, got)
+	}
+	if plain := getGzipWeather(false, ""); plain.Header().Get(contentSHA256Header) != w.Header().Get(contentSHA256Header) {
+		t.Errorf("%s differs between the gzipped and identity responses", contentSHA256Header)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inFlightRequests counts requests currently being handled by the service.
+var inFlightRequests atomic.Int64
+
+// concurrencyLimitMiddleware sheds load once max requests are in flight, answering
+// 503 immediately instead of queueing. Paths in exempt, the probes, are counted
+// but never shed, so a loaded instance isn't restarted or taken out of rotation
+// for being busy. A non-positive max disables the cap but still tracks the
+// in-flight count.
+func concurrencyLimitMiddleware(max int, exempt map[string]bool) gin.HandlerFunc {
+	var sem chan struct{}
+	if max > 0 {
+		sem = make(chan struct{}, max)
+	}
+	return func(c *gin.Context) {
+		if sem != nil && !exempt[c.Request.URL.Path] {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			default:
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+					"error": "server is busy, try again shortly",
+					"code":  "SERVER_BUSY",
+				})
+				return
+			}
+		}
+
+		inFlightRequests.Add(1)
+		defer inFlightRequests.Add(-1)
+		c.Next()
+	}
+}
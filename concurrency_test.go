@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestProbesNotShed holds the only MAX_CONCURRENT_REQUESTS slot with a lookup
+// waiting on the upstream and checks further lookups are shed while the probes
+// still answer.
+func TestProbesNotShed(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	c := testConfig(t)
+	c.MaxConcurrentRequests = 1
+	setupTest(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+	}))
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+
+	router := newRouter(cfg)
+	held := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+		held <- w.Code
+	}()
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("lookup never reached the upstream")
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	if w := get("/weather?location=Paris"); w.Code != http.StatusServiceUnavailable || errorCode(t, w) != "SERVER_BUSY" {
+		t.Errorf("lookup beyond the limit: %d %s, want 503 SERVER_BUSY", w.Code, w.Body)
+	}
+	for _, path := range []string{"/livez", "/readyz", "/health"} {
+		if w := get(path); w.Code == http.StatusServiceUnavailable && errorCode(t, w) == "SERVER_BUSY" {
+			t.Errorf("%s shed at the concurrency limit", path)
+		}
+	}
+	if w := get("/livez"); w.Code != http.StatusOK {
+		t.Errorf("/livez at the concurrency limit: %d, want 200", w.Code)
+	}
+
+	unblock()
+	if code := <-held; code != http.StatusOK {
+		t.Errorf("held lookup: %d, want 200", code)
+	}
+}
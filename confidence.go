@@ -0,0 +1,68 @@
+package main
+
+import (
+	"time"
+)
+
+// Forecast confidence levels, from least to most reliable.
+const (
+	confidenceLow    = "low"
+	confidenceMedium = "medium"
+	confidenceHigh   = "high"
+)
+
+// confidenceRank orders the confidence levels for minConfidence filtering.
+var confidenceRank = map[string]int{confidenceLow: 0, confidenceMedium: 1, confidenceHigh: 2}
+
+// leadDays returns how many days after today's date date is, negative for past
+// days, reporting false for dates that don't parse.
+func leadDays(date string, today time.Time) (int, bool) {
+	d, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return 0, false
+	}
+	return int(d.Sub(today.UTC().Truncate(24*time.Hour)).Hours() / 24), true
+}
+
+// forecastConfidence rates how reliable the data for date is, given today's date,
+// from the forecast lead time alone: past days and up to 3 days ahead are high,
+// 4 to 7 days ahead medium and anything further out low. Dates that don't
+// parse are rated low.
+func forecastConfidence(date string, today time.Time) string {
+	lead, ok := leadDays(date, today)
+	if !ok {
+		return confidenceLow
+	}
+	switch {
+	case lead <= 3:
+		return confidenceHigh
+	case lead <= 7:
+		return confidenceMedium
+	}
+	return confidenceLow
+}
+
+// applyConfidence annotates every day with its forecast confidence and, when
+// min is set, drops the days rated below it.
+func applyConfidence(data map[string]interface{}, today time.Time, min string) {
+	days, ok := data["days"].([]interface{})
+	if !ok {
+		return
+	}
+	kept := make([]interface{}, 0, len(days))
+	for _, d := range days {
+		day, ok := d.(map[string]interface{})
+		if !ok {
+			kept = append(kept, d)
+			continue
+		}
+		date, _ := day["datetime"].(string)
+		level := forecastConfidence(date, today)
+		if min != "" && confidenceRank[level] < confidenceRank[min] {
+			continue
+		}
+		day["confidence"] = level
+		kept = append(kept, day)
+	}
+	data["days"] = kept
+}
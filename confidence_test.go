@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestForecastConfidence(t *testing.T) {
+	// Late in the day, so truncation to the date matters.
+	today := time.Date(2026, 10, 14, 23, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		date string
+		lead int
+		want string
+	}{
+		{"2026-10-01", -13, confidenceHigh},
+		{"2026-10-14", 0, confidenceHigh},
+		{"2026-10-17", 3, confidenceHigh},
+		{"2026-10-18", 4, confidenceMedium},
+		{"2026-10-21", 7, confidenceMedium},
+		{"2026-10-22", 8, confidenceLow},
+		{"2026-11-14", 31, confidenceLow},
+	} {
+		if lead, ok := leadDays(tc.date, today); !ok || lead != tc.lead {
+			t.Errorf("leadDays(%q) = %d, %v; want %d", tc.date, lead, ok, tc.lead)
+		}
+		if got := forecastConfidence(tc.date, today); got != tc.want {
+			t.Errorf("forecastConfidence(%q) = %q, want %q", tc.date, got, tc.want)
+		}
+	}
+	for _, date := range []string{"", "14/10/2026", "2026-13-01"} {
+		if _, ok := leadDays(date, today); ok {
+			t.Errorf("leadDays(%q) parsed", date)
+		}
+		if got := forecastConfidence(date, today); got != confidenceLow {
+			t.Errorf("forecastConfidence(%q) = %q, want low", date, got)
+		}
+	}
+}
+
+func TestApplyConfidence(t *testing.T) {
+	today := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	dates := []string{"2026-10-14", "2026-10-19", "2026-10-30"}
+	for _, tc := range []struct {
+		min  string
+		want []string // confidence of the kept days
+	}{
+		{"", []string{confidenceHigh, confidenceMedium, confidenceLow}},
+		{confidenceLow, []string{confidenceHigh, confidenceMedium, confidenceLow}},
+		{confidenceMedium, []string{confidenceHigh, confidenceMedium}},
+		{confidenceHigh, []string{confidenceHigh}},
+	} {
+		days := make([]interface{}, 0, len(dates)+1)
+		for _, date := range dates {
+			days = append(days, map[string]interface{}{"datetime": date})
+		}
+		days = append(days, "not a day")
+		data := map[string]interface{}{"days": days}
+
+		applyConfidence(data, today, tc.min)
+		var got []string
+		for _, d := range data["days"].([]interface{}) {
+			if day, ok := d.(map[string]interface{}); ok {
+				got = append(got, day["confidence"].(string))
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("min %q: kept %v, want %v", tc.min, got, tc.want)
+		}
+		if kept := data["days"].([]interface{}); kept[len(kept)-1] != "not a day" {
+			t.Errorf("min %q: non-object entry dropped", tc.min)
+		}
+	}
+}
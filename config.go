@@ -0,0 +1,701 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the service configuration. It is loaded from the environment by
+// loadConfig, but can equally be constructed directly (e.g. in tests).
+type Config struct {
+	// Required settings.
+	APIKey string // VISUAL_CROSSING_API_KEY
+	APIURL string // VISUAL_CROSSING_API_URL, or the UPSTREAM_REGION endpoint
+
+	// Upstream.
+	UpstreamWarmup  bool   // prime the upstream connection at startup
+	UpstreamFake    bool   // serve canned weather from the built-in fake upstream
+	MockMode        bool   // serve synthetic weather without the upstream or Redis, see mockWeather
+	AttributionText string // data source credit, sent as X-Data-Source and in meta
+
+	// Redis.
+	RedisURL         string
+	RedisDB          int // -1 keeps the database selected by RedisURL
+	RedisReplicaURL  string
+	RedisFailoverURL string // Redis in another region cache operations fall back to
+	CacheDualWrite   bool   // mirror cache writes to RedisFailoverURL
+	CacheShards      int    // Redis databases cache entries are spread over, see cacheShards
+
+	// Caching.
+	CacheExpiration       time.Duration
+	CacheMinTTL           time.Duration // lower bound for CacheExpiration and runtime TTL changes
+	AdaptiveTTL           bool          // pick the TTL of new entries from the weather, see adaptiveTTL
+	AdaptiveTTLMin        time.Duration // TTL for volatile weather
+	AdaptiveTTLMax        time.Duration // TTL for stable weather
+	HistoryCacheTTL       time.Duration // TTL of months served by /weather/history that are over
+	DiffSnapshotTTL       time.Duration // how long delta=true responses are kept to diff against
+	CurrentConditionsTTL  time.Duration // freshness of current conditions cached with other sections; zero keeps them with the entry
+	NormalMaxYears        int           // most years /weather/normal averages over
+	SavedLocationsMax     int           // most locations an API key may save for /user/weather
+	EmptyResponseTTL      time.Duration // zero disables negative caching of empty responses
+	ClientErrorCacheTTL   time.Duration // zero disables negative caching of upstream 4xx answers
+	MaxCacheEntryBytes    int           // larger entries are served but not cached; zero disables the limit
+	PartialCachePolicy    string        // "full", "short" or "never" for responses failing validation
+	PartialCacheTTL       time.Duration // TTL of partial responses under the short policy
+	RefetchOnCorruption   bool
+	CoordinateDedup       bool // share cached data between queries resolving to the same coordinates
+	ContentAddressedCache bool // store cached data once per distinct payload, see contentKey
+	CacheBypassAdminOnly  bool // restrict nocache=true to admins
+	NormalsEnabled        bool // allow normals=true, fetching climate normals
+
+	// Consecutive cache write failures before warning; with a non-zero cooldown
+	// writes are then suspended for that long.
+	CacheWriteFailureThreshold int
+	CacheWriteCooldown         time.Duration
+	RedisBreakerThreshold      int // consecutive Redis failures opening redisBreaker; zero disables it
+	RedisBreakerCooldown       time.Duration
+	CacheControlDirective      string // "public" or "private"
+	CacheControlFreshMaxAge    time.Duration
+
+	// Cache warming: WarmLocations are refreshed every WarmInterval by
+	// WarmConcurrency workers pausing WarmDelay between upstream calls.
+	WarmLocations   []string
+	WarmInterval    time.Duration
+	WarmConcurrency int
+	WarmDelay       time.Duration
+
+	// PredictivePrefetch warms the forecast after lookups of today, see
+	// prefetchForecast.
+	PredictivePrefetch bool
+
+	// Cache hits in the last RefreshAheadRatio of an entry's lifetime refresh
+	// it in the background once it had RefreshAheadMinHits of them, see
+	// refreshAheadOf. Zero disables it.
+	RefreshAheadRatio   float64
+	RefreshAheadMinHits int
+
+	// CacheStaleGrace keeps entries this long past their TTL, served stale
+	// while they are refreshed, see revalidateStale. Zero disables it.
+	CacheStaleGrace time.Duration
+
+	// FetchLockTTL, when set, makes misses for the same query across instances
+	// share one upstream call through a Redis lock held at most this long,
+	// waited on for at most FetchLockWait, see lockedFetchAndCache.
+	FetchLockTTL  time.Duration
+	FetchLockWait time.Duration
+
+	// Bulk extraction jobs, see POST /weather/jobs: JobWorkers per instance take
+	// jobs of at most JobMaxDays days and look them up JobChunkDays at a time,
+	// pausing JobChunkDelay after every upstream fetch. Jobs and their results
+	// are kept for JobTTL.
+	JobWorkers    int
+	JobMaxDays    int
+	JobChunkDays  int
+	JobChunkDelay time.Duration
+	JobTTL        time.Duration
+	// JobWorkerID names this instance's workers in Redis. Jobs they were
+	// running when the instance died resume once an instance with the same ID
+	// starts, so it should outlive restarts (a StatefulSet pod name, say).
+	JobWorkerID string
+
+	// Response metadata: meta.fetchedAt, plus meta.servedAt when enabled.
+	ResponseMeta         bool
+	ResponseMetaServedAt bool
+
+	// Adaptive TTL degradation: while the rolling upstream error rate exceeds
+	// DegradeErrorRate (zero disables), new entries get CacheExpiration multiplied
+	// by DegradeTTLMultiplier, capped at DegradeMaxTTL.
+	DegradeErrorRate     float64
+	DegradeTTLMultiplier float64
+	DegradeMaxTTL        time.Duration
+
+	// Request handling.
+	MaxForecastDays            int
+	MaxHistoryDays             int
+	MaxConcurrentRequests      int  // zero disables the cap
+	MaxQueryParams             int  // zero disables the check
+	MaxQueryLength             int  // bytes of raw query string; zero disables the check
+	Gzip                       bool // compress responses for clients accepting gzip
+	GzipMinBytes               int  // smallest body compressed
+	FingerprintTracking        bool // count requests per client fingerprint, see fingerprintMiddleware
+	FingerprintWindow          time.Duration
+	FingerprintThreshold       int64         // requests per window before a fingerprint is flagged
+	FingerprintThrottle        bool          // reject flagged fingerprints for the rest of the window
+	FingerprintFlagTTL         time.Duration // how long flags are listed
+	UpstreamTransport          upstreamTransport
+	UpstreamTimeout            time.Duration      // default deadline of a weather request, see upstreamTimeoutMiddleware; zero means none
+	UpstreamTimeoutMax         time.Duration      // ceiling of the timeout parameter
+	BatchTimeout               time.Duration      // deadline of the lookups of a multi-location request, see lookupLocations; zero means none
+	UpstreamQueueWorkers       int                // concurrent upstream fetches; zero disables the queue
+	UpstreamQueueDepth         int                // fetches allowed to wait for a worker
+	UpstreamRetries            int                // retries of a failed upstream fetch, see fetchWithRetries
+	UpstreamRetryBackoff       time.Duration      // wait before the first retry, doubling for each further one
+	RetryBudgetRate            float64            // retries per second shared by all requests
+	RetryBudgetBurst           int                // retries allowed in a burst
+	LocationBreakerThreshold   int                // consecutive upstream failures opening a location's breaker; zero disables
+	LocationBreakerCooldown    time.Duration      // how long an open location breaker fails fast
+	LocationBreakerIdle        time.Duration      // idle time after which a location's breaker is forgotten
+	RateLimit                  float64            // requests per second per client IP
+	RouteRateLimits            map[string]float64 // per-route overrides from RATE_LIMIT_<ROUTE>
+	APIKeyRateLimits           map[string]float64 // per-key requests per second on the weather endpoints, see keyRateLimiter
+	AnonymousRateLimit         float64            // requests per second per client IP without a key of APIKeyRateLimits; zero rejects them
+	RateLimitBackend           string             // "memory" or "redis", see RATE_LIMIT_BACKEND
+	AdaptiveRateLimit          bool               // scale the rate limits with load, see nextRateFactor
+	AdaptiveRateInterval       time.Duration
+	AdaptiveRateMinFactor      float64
+	AdaptiveRateMaxFactor      float64
+	AdaptiveRateTargetInFlight int64
+	AdaptiveRateErrorRate      float64
+	MaxUpstreamResponseBytes   int64
+	LocationAliases            map[string]string
+	LocationWhitelist          map[string]bool          // normalised locations; nil allows any
+	FieldRenames               map[string]string        // upstream field name -> response field name
+	NullPolicy                 string                   // "null", "omit" or "zero" for null fields in /weather responses
+	TrendSteadyThreshold       float64                  // smallest temperature change (°C) /weather/hourly reports as a trend
+	ScoreWeights               scoreWeights             // component weights of /weather/score
+	DefaultLang                string                   // condition text language when the request sets no lang
+	EndpointIncludes           map[string]string        // endpoint path -> upstream include set
+	UpstreamRoutes             map[string]upstreamRoute // query type -> upstream period and include set
+	PassthroughParams          map[string]bool          // upstream options clients may set via vc.<name>
+	CanaryLocation             string
+	AccessLogSkip              map[string]bool
+	AccessLogFormat            string          // "json" or "combined"
+	SlowRequestThreshold       time.Duration   // zero disables the slow request log
+	TopLocationsCapacity       int             // locations tracked for /stats/top
+	HealthChecks               map[string]bool // dependency probes run by /health
+	HealthCheckTimeout         time.Duration   // per-probe timeout
+	HealthFormat               string          // "simple", "detailed" or "kubernetes", see HEALTH_FORMAT
+
+	// Analytics events published to a Redis pub/sub channel per lookup.
+	EventsEnabled bool
+	EventsChannel string
+
+	// Authentication.
+	AuthEnabled           bool
+	APIKeys               staticKeyStore
+	APIKeysRedisSet       string // when set, keys are looked up in this Redis set instead of APIKeys
+	AuthFailOpen          bool
+	APIKeyQuotas          keyQuotas
+	APIKeyTeams           map[string]string // API key -> team for upstream usage attribution
+	AdminToken            string            // enables admin-only features; empty disables them
+	AdminReplayProtection bool              // require X-Timestamp and X-Nonce on admin requests
+	AdminReplayWindow     time.Duration
+	AuditLog              bool   // log an audit event per admin request
+	AuditStream           string // Redis stream audit events are also appended to; empty keeps them in the log only
+	AuditStreamMaxLen     int64
+
+	// Server.
+	TrustedProxies         []string // IPs/CIDRs whose forwarding headers are trusted
+	Port                   string
+	UnixSocket             string
+	DrainPeriod            time.Duration
+	ShutdownTimeout        time.Duration // how long in-flight requests may take to finish once shutting down
+	StartupJitter          time.Duration // upper bound of the random delay before reporting ready
+	FlushCacheOnShutdown   bool          // delete all cached weather entries after shutting down
+	CacheMigrateFromPrefix string        // key prefix of an old cache key scheme to sweep, see sweepCacheKeys
+	CacheMigrateInterval   time.Duration // zero sweeps once at startup
+	UpstreamHealthInterval time.Duration // zero disables the upstream health poller
+	UpstreamHealthFailures int           // failed polls before the upstream is considered down
+}
+
+// cfg is the configuration the running service uses, set by main. Handlers and
+// the fetch path read it rather than taking a Config, so tests build one with
+// loadConfig (or by hand) and install it through setupTest.
+var cfg Config
+
+// loadConfig reads the configuration from the environment, after filling in
+// variables from CONFIG_FILE when set. Missing or invalid required settings, and
+// an unreadable config file, are returned as an error; invalid optional settings
+// are logged and replaced by their defaults.
+func loadConfig() (Config, error) {
+	if err := applyConfigFile(os.Getenv("CONFIG_FILE")); err != nil {
+		return Config{}, err
+	}
+
+	hostname, _ := os.Hostname()
+	var errs []error
+	c := Config{
+		APIKey:           os.Getenv("VISUAL_CROSSING_API_KEY"),
+		APIURL:           os.Getenv("VISUAL_CROSSING_API_URL"),
+		UpstreamWarmup:   envBool("UPSTREAM_WARMUP", false),
+		UpstreamFake:     envBool("UPSTREAM_FAKE", false),
+		MockMode:         envBool("MOCK_MODE", false),
+		RedisURL:         envString("REDIS_URL", "localhost:6379"),
+		RedisDB:          -1,
+		RedisReplicaURL:  os.Getenv("REDIS_REPLICA_URL"),
+		RedisFailoverURL: os.Getenv("REDIS_FAILOVER_URL"),
+		CacheDualWrite:   envBool("CACHE_DUAL_WRITE", false),
+		CacheShards:      envInt("CACHE_SHARDS", 1),
+
+		CacheExpiration:            envSeconds("CACHE_EXPIRATION", 43200), // Default: 12 hours
+		CacheMinTTL:                envSeconds("CACHE_MIN_TTL", 60),
+		AdaptiveTTL:                envBool("ADAPTIVE_TTL", false),
+		AdaptiveTTLMin:             envSeconds("ADAPTIVE_TTL_MIN", 1800),
+		AdaptiveTTLMax:             envSeconds("ADAPTIVE_TTL_MAX", 86400),
+		HistoryCacheTTL:            envSeconds("HISTORY_CACHE_TTL", 2592000), // Default: 30 days
+		DiffSnapshotTTL:            envSeconds("DIFF_SNAPSHOT_TTL", 600),
+		CurrentConditionsTTL:       envSeconds("CURRENT_CONDITIONS_TTL", 0),
+		NormalMaxYears:             envInt("NORMAL_MAX_YEARS", 30),
+		SavedLocationsMax:          envInt("SAVED_LOCATIONS_MAX", 20),
+		EmptyResponseTTL:           envSeconds("EMPTY_RESPONSE_CACHE_TTL", 0),
+		ClientErrorCacheTTL:        envSeconds("CLIENT_ERROR_CACHE_TTL", 300),
+		MaxCacheEntryBytes:         envInt("MAX_CACHE_ENTRY_BYTES", 0),
+		PartialCachePolicy:         parsePartialPolicy(os.Getenv("CACHE_PARTIAL_RESPONSES")),
+		PartialCacheTTL:            envSeconds("PARTIAL_RESPONSE_CACHE_TTL", 300),
+		CacheControlFreshMaxAge:    envSeconds("CACHE_CONTROL_FRESH_MAX_AGE", 300),
+		ResponseMeta:               envBool("RESPONSE_META", false),
+		ResponseMetaServedAt:       envBool("RESPONSE_META_SERVED_AT", false),
+		CoordinateDedup:            envBool("COORDINATE_DEDUP", false),
+		ContentAddressedCache:      envBool("CONTENT_ADDRESSED_CACHE", false),
+		CacheBypassAdminOnly:       envBool("CACHE_BYPASS_ADMIN_ONLY", true),
+		NormalsEnabled:             envBool("NORMALS_ENABLED", false),
+		CacheWriteFailureThreshold: envInt("CACHE_WRITE_FAILURE_THRESHOLD", 5),
+		CacheWriteCooldown:         envSeconds("CACHE_WRITE_COOLDOWN", 0),
+		RedisBreakerThreshold:      envInt("REDIS_BREAKER_THRESHOLD", 0),
+		RedisBreakerCooldown:       envSeconds("REDIS_BREAKER_COOLDOWN", 30),
+		DegradeErrorRate:           envFloat("DEGRADE_ERROR_RATE", 0.5),
+		DegradeTTLMultiplier:       envFloat("DEGRADE_TTL_MULTIPLIER", 4),
+		DegradeMaxTTL:              envSeconds("DEGRADE_MAX_TTL", 172800), // Default: 48 hours
+
+		WarmInterval:        envSeconds("WARM_INTERVAL", 3600),
+		WarmConcurrency:     envInt("WARM_CONCURRENCY", 2),
+		WarmDelay:           time.Duration(envInt("WARM_DELAY_MS", 500)) * time.Millisecond,
+		PredictivePrefetch:  envBool("PREDICTIVE_PREFETCH", false),
+		RefreshAheadRatio:   envFloat("REFRESH_AHEAD_RATIO", 0),
+		RefreshAheadMinHits: envInt("REFRESH_AHEAD_MIN_HITS", 2),
+		CacheStaleGrace:     envSeconds("CACHE_STALE_GRACE", 0),
+		FetchLockTTL:        envSeconds("FETCH_LOCK_TTL", 0),
+		FetchLockWait:       envSeconds("FETCH_LOCK_WAIT", 5),
+
+		JobWorkers:    envInt("JOB_WORKERS", 1),
+		JobMaxDays:    envInt("JOB_MAX_DAYS", 3660),
+		JobChunkDays:  envInt("JOB_CHUNK_DAYS", 31),
+		JobChunkDelay: time.Duration(envInt("JOB_CHUNK_DELAY_MS", 1000)) * time.Millisecond,
+		JobTTL:        envSeconds("JOB_TTL", 86400),
+		JobWorkerID:   envString("JOB_WORKER_ID", hostname),
+
+		MaxForecastDays:       envInt("MAX_FORECAST_DAYS", 15),
+		MaxHistoryDays:        envInt("MAX_HISTORY_DAYS", 365),
+		MaxConcurrentRequests: envInt("MAX_CONCURRENT_REQUESTS", 100),
+		MaxQueryParams:        envInt("MAX_QUERY_PARAMS", 50),
+		MaxQueryLength:        envInt("MAX_QUERY_LENGTH", 2048),
+		Gzip:                  envBool("GZIP", true),
+		GzipMinBytes:          envInt("GZIP_MIN_BYTES", 1024),
+		FingerprintTracking:   envBool("FINGERPRINT_TRACKING", false),
+		FingerprintWindow:     envSeconds("FINGERPRINT_WINDOW", 60),
+		FingerprintThreshold:  int64(envInt("FINGERPRINT_THRESHOLD", 300)),
+		FingerprintThrottle:   envBool("FINGERPRINT_THROTTLE", false),
+		FingerprintFlagTTL:    envSeconds("FINGERPRINT_FLAG_TTL", 86400),
+		UpstreamTransport: upstreamTransport{
+			MaxIdleConnsPerHost:   envInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 16),
+			DisableKeepAlives:     envBool("UPSTREAM_DISABLE_KEEPALIVES", false),
+			ResponseHeaderTimeout: envSeconds("UPSTREAM_RESPONSE_HEADER_TIMEOUT", 30),
+			ExpectContinueTimeout: envSeconds("UPSTREAM_EXPECT_CONTINUE_TIMEOUT", 1),
+		},
+		UpstreamTimeout:            envSeconds("UPSTREAM_TIMEOUT", 5),
+		UpstreamTimeoutMax:         envSeconds("UPSTREAM_TIMEOUT_MAX", 60),
+		BatchTimeout:               envSeconds("BATCH_TIMEOUT", 10),
+		UpstreamQueueWorkers:       envInt("UPSTREAM_QUEUE_WORKERS", 0),
+		UpstreamQueueDepth:         envInt("UPSTREAM_QUEUE_DEPTH", 50),
+		UpstreamRetries:            envInt("UPSTREAM_RETRIES", 2),
+		UpstreamRetryBackoff:       time.Duration(envInt("UPSTREAM_RETRY_BACKOFF_MS", 200)) * time.Millisecond,
+		RetryBudgetRate:            envFloat("RETRY_BUDGET_PER_SECOND", 1),
+		RetryBudgetBurst:           envInt("RETRY_BUDGET_BURST", 10),
+		LocationBreakerThreshold:   envInt("LOCATION_BREAKER_THRESHOLD", 0),
+		LocationBreakerCooldown:    envSeconds("LOCATION_BREAKER_COOLDOWN", 300),
+		LocationBreakerIdle:        envSeconds("LOCATION_BREAKER_IDLE", 1800),
+		RateLimit:                  envFloat("RATE_LIMIT", 1),
+		RouteRateLimits:            parseRouteRateLimits(os.Environ()),
+		APIKeyRateLimits:           parseKeyRateLimits(os.Getenv("API_KEY_RATE_LIMITS")),
+		AnonymousRateLimit:         envFloat("ANONYMOUS_RATE_LIMIT", 0),
+		RateLimitBackend:           parseRateLimitBackend(os.Getenv("RATE_LIMIT_BACKEND")),
+		AdaptiveRateLimit:          envBool("ADAPTIVE_RATE_LIMIT", false),
+		AdaptiveRateInterval:       envSeconds("ADAPTIVE_RATE_INTERVAL", 5),
+		AdaptiveRateMinFactor:      envFloat("ADAPTIVE_RATE_MIN_FACTOR", 0.25),
+		AdaptiveRateMaxFactor:      envFloat("ADAPTIVE_RATE_MAX_FACTOR", 2),
+		AdaptiveRateTargetInFlight: int64(envInt("ADAPTIVE_RATE_TARGET_IN_FLIGHT", 50)),
+		AdaptiveRateErrorRate:      envFloat("ADAPTIVE_RATE_ERROR_RATE", 0.2),
+		// Largest upstream body read before giving up (default 5 MiB).
+		MaxUpstreamResponseBytes: int64(envInt("MAX_UPSTREAM_RESPONSE_BYTES", 5<<20)),
+		CanaryLocation:           envString("CANARY_LOCATION", "London"),
+		SlowRequestThreshold:     time.Duration(envInt("SLOW_REQUEST_THRESHOLD_MS", 0)) * time.Millisecond,
+		TopLocationsCapacity:     envInt("TOP_LOCATIONS_CAPACITY", 1000),
+		HealthChecks:             parseSet(envString("HEALTH_CHECKS", "redis,replica")),
+		HealthCheckTimeout:       time.Duration(envInt("HEALTH_CHECK_TIMEOUT_MS", 2000)) * time.Millisecond,
+		HealthFormat:             parseHealthFormat(os.Getenv("HEALTH_FORMAT")),
+		PassthroughParams:        parsePassthroughParams(os.Getenv("ALLOWED_PASSTHROUGH_PARAMS")),
+		NullPolicy:               parseNullPolicy(os.Getenv("NULL_POLICY")),
+		TrendSteadyThreshold:     envFloat("TREND_STEADY_THRESHOLD", 1),
+		AccessLogFormat:          parseAccessLogFormat(os.Getenv("ACCESS_LOG_FORMAT")),
+
+		EventsEnabled: envBool("EVENTS_ENABLED", false),
+		EventsChannel: envString("EVENTS_CHANNEL", "weather-api:events"),
+
+		AuthEnabled:           envBool("AUTH_ENABLED", false),
+		APIKeys:               parseKeySet(os.Getenv("API_KEYS")),
+		APIKeysRedisSet:       os.Getenv("API_KEYS_REDIS_SET"),
+		AdminToken:            os.Getenv("ADMIN_TOKEN"),
+		AdminReplayProtection: envBool("ADMIN_REPLAY_PROTECTION", false),
+		AdminReplayWindow:     envSeconds("ADMIN_REPLAY_WINDOW", 300),
+		AuditLog:              envBool("AUDIT_LOG", true),
+		AuditStream:           envString("AUDIT_STREAM", ""),
+		AuditStreamMaxLen:     int64(envInt("AUDIT_STREAM_MAXLEN", 10000)),
+		// Daily per-key quotas as comma-separated key:limit pairs; keys without an
+		// entry use DEFAULT_DAILY_QUOTA (0 = unlimited).
+		APIKeyQuotas: parseKeyQuotas(os.Getenv("API_KEY_QUOTAS"), int64(envInt("DEFAULT_DAILY_QUOTA", 0))),
+		// Teams as comma-separated key:team pairs; other requests may send X-Team.
+		APIKeyTeams: parseKeyTeams(os.Getenv("API_KEY_TEAMS")),
+
+		Port:                   envString("PORT", "8080"),
+		UnixSocket:             os.Getenv("UNIX_SOCKET"),
+		DrainPeriod:            envSeconds("DRAIN_SECONDS", 0),
+		ShutdownTimeout:        envSeconds("SHUTDOWN_TIMEOUT", 10),
+		StartupJitter:          envSeconds("STARTUP_JITTER_SECONDS", 0),
+		FlushCacheOnShutdown:   envBool("FLUSH_CACHE_ON_SHUTDOWN", false),
+		CacheMigrateFromPrefix: envString("CACHE_MIGRATE_FROM_PREFIX", ""),
+		CacheMigrateInterval:   envSeconds("CACHE_MIGRATE_INTERVAL", 0),
+		UpstreamHealthInterval: envSeconds("UPSTREAM_HEALTH_INTERVAL", 0),
+		UpstreamHealthFailures: envInt("UPSTREAM_HEALTH_FAILURES", 3),
+	}
+
+	// The fake upstream needs neither a real key nor an endpoint; main starts it
+	// and fills in its URL. Mock mode never calls the upstream at all.
+	if (c.UpstreamFake || c.MockMode) && c.APIKey == "" {
+		c.APIKey = "fake"
+	}
+	if c.APIKey == "" {
+		errs = append(errs, errors.New("VISUAL_CROSSING_API_KEY must be set"))
+	}
+
+	// VISUAL_CROSSING_API_URL takes precedence over UPSTREAM_REGION.
+	regions, err := upstreamRegions(os.Getenv("UPSTREAM_REGIONS"))
+	if err != nil {
+		errs = append(errs, err)
+	} else if c.UpstreamFake || c.MockMode {
+		c.APIURL = ""
+	} else if url, err := resolveUpstreamURL(c.APIURL, os.Getenv("UPSTREAM_REGION"), regions); err != nil {
+		errs = append(errs, err)
+	} else {
+		if c.APIURL == "" {
+			log.Printf("Using upstream region %s: %s", os.Getenv("UPSTREAM_REGION"), url)
+		} else {
+			log.Printf("Using upstream endpoint %s", url)
+		}
+		c.APIURL = url
+	}
+
+	// REDIS_URL may select a database (redis://host:6379/3); an explicit REDIS_DB
+	// takes precedence so the service can be isolated on a shared instance.
+	if raw := os.Getenv("REDIS_DB"); raw != "" {
+		db, err := strconv.Atoi(raw)
+		if err != nil || db < 0 {
+			errs = append(errs, fmt.Errorf("invalid REDIS_DB %q", raw))
+		} else {
+			c.RedisDB = db
+		}
+	}
+
+	if c.FingerprintTracking {
+		if c.FingerprintWindow <= 0 {
+			errs = append(errs, errors.New("FINGERPRINT_WINDOW must be positive"))
+		}
+		if c.FingerprintThreshold < 1 {
+			errs = append(errs, errors.New("FINGERPRINT_THRESHOLD must be at least 1"))
+		}
+	}
+	if c.CacheMigrateInterval < 0 {
+		errs = append(errs, errors.New("CACHE_MIGRATE_INTERVAL must not be negative"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.UpstreamHealthInterval < 0 {
+		errs = append(errs, errors.New("UPSTREAM_HEALTH_INTERVAL must not be negative"))
+	}
+	if c.UpstreamHealthFailures < 1 {
+		errs = append(errs, errors.New("UPSTREAM_HEALTH_FAILURES must be at least 1"))
+	}
+	if c.AnonymousRateLimit < 0 {
+		errs = append(errs, errors.New("ANONYMOUS_RATE_LIMIT must not be negative"))
+	}
+	if c.AdaptiveRateLimit {
+		if c.AdaptiveRateInterval <= 0 {
+			errs = append(errs, errors.New("ADAPTIVE_RATE_INTERVAL must be positive"))
+		}
+		if c.AdaptiveRateMinFactor <= 0 || c.AdaptiveRateMinFactor > 1 {
+			errs = append(errs, errors.New("ADAPTIVE_RATE_MIN_FACTOR must be above 0 and at most 1"))
+		}
+		if c.AdaptiveRateMaxFactor < 1 {
+			errs = append(errs, errors.New("ADAPTIVE_RATE_MAX_FACTOR must be at least 1"))
+		}
+		if c.AdaptiveRateTargetInFlight < 1 {
+			errs = append(errs, errors.New("ADAPTIVE_RATE_TARGET_IN_FLIGHT must be at least 1"))
+		}
+		if c.AdaptiveRateErrorRate <= 0 || c.AdaptiveRateErrorRate > 1 {
+			errs = append(errs, errors.New("ADAPTIVE_RATE_ERROR_RATE must be above 0 and at most 1"))
+		}
+	}
+	if c.UpstreamTimeout < 0 || c.UpstreamTimeoutMax <= 0 {
+		errs = append(errs, errors.New("UPSTREAM_TIMEOUT must be non-negative and UPSTREAM_TIMEOUT_MAX positive"))
+	} else if c.UpstreamTimeout > c.UpstreamTimeoutMax {
+		errs = append(errs, errors.New("UPSTREAM_TIMEOUT must not exceed UPSTREAM_TIMEOUT_MAX"))
+	}
+	if c.BatchTimeout < 0 {
+		errs = append(errs, errors.New("BATCH_TIMEOUT must not be negative"))
+	}
+	if c.RefreshAheadRatio < 0 || c.RefreshAheadRatio >= 1 {
+		errs = append(errs, errors.New("REFRESH_AHEAD_RATIO must be at least 0 and below 1"))
+	}
+	if c.FetchLockTTL < 0 || c.FetchLockWait <= 0 {
+		errs = append(errs, errors.New("FETCH_LOCK_TTL must not be negative and FETCH_LOCK_WAIT must be positive"))
+	}
+	if c.CacheStaleGrace < 0 {
+		errs = append(errs, errors.New("CACHE_STALE_GRACE must not be negative"))
+	}
+	if c.RefreshAheadMinHits < 1 {
+		errs = append(errs, errors.New("REFRESH_AHEAD_MIN_HITS must be positive"))
+	}
+	if c.GzipMinBytes < 0 {
+		errs = append(errs, errors.New("GZIP_MIN_BYTES must not be negative"))
+	}
+	if c.JobChunkDays < 1 {
+		errs = append(errs, errors.New("JOB_CHUNK_DAYS must be at least 1"))
+	}
+	if c.JobTTL <= 0 {
+		errs = append(errs, errors.New("JOB_TTL must be positive"))
+	}
+	if c.CacheShards < 1 {
+		errs = append(errs, errors.New("CACHE_SHARDS must be at least 1"))
+	}
+
+	// Internal location codes, as a JSON object or the path to a JSON file.
+	c.LocationAliases, err = loadLocationAliases(os.Getenv("LOCATION_ALIASES"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid LOCATION_ALIASES: %v", err))
+	}
+
+	// Allowed locations, as a comma-separated list or a file with one per line.
+	c.LocationWhitelist, err = loadLocationWhitelist(os.Getenv("LOCATION_WHITELIST"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid LOCATION_WHITELIST: %v", err))
+	}
+
+	// Upstream include sets per endpoint, overriding defaultEndpointIncludes.
+	c.EndpointIncludes, err = parseEndpointIncludes(os.Getenv("ENDPOINT_INCLUDES"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid ENDPOINT_INCLUDES: %v", err))
+	}
+
+	// Upstream periods and include sets per query type, overriding
+	// defaultUpstreamRoutes.
+	c.UpstreamRoutes, err = parseUpstreamRoutes(os.Getenv("UPSTREAM_ROUTES"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid UPSTREAM_ROUTES: %v", err))
+	}
+
+	if c.PartialCachePolicy == partialCacheShort && c.PartialCacheTTL <= 0 {
+		errs = append(errs, errors.New("PARTIAL_RESPONSE_CACHE_TTL must be positive with CACHE_PARTIAL_RESPONSES=short"))
+	}
+	if c.HistoryCacheTTL <= 0 {
+		errs = append(errs, errors.New("HISTORY_CACHE_TTL must be positive"))
+	}
+	if c.DiffSnapshotTTL <= 0 {
+		errs = append(errs, errors.New("DIFF_SNAPSHOT_TTL must be positive"))
+	}
+	if c.CurrentConditionsTTL < 0 {
+		errs = append(errs, errors.New("CURRENT_CONDITIONS_TTL must not be negative"))
+	}
+	if c.AdminReplayWindow <= 0 {
+		errs = append(errs, errors.New("ADMIN_REPLAY_WINDOW must be positive"))
+	}
+	if c.AuditStreamMaxLen < 1 {
+		errs = append(errs, errors.New("AUDIT_STREAM_MAXLEN must be at least 1"))
+	}
+	if c.NormalMaxYears < 1 {
+		errs = append(errs, errors.New("NORMAL_MAX_YEARS must be at least 1"))
+	}
+	if c.SavedLocationsMax < 1 {
+		errs = append(errs, errors.New("SAVED_LOCATIONS_MAX must be at least 1"))
+	}
+
+	c.DefaultLang = defaultLang
+	if raw := os.Getenv("DEFAULT_LANG"); raw != "" {
+		if c.DefaultLang, err = parseLang(raw); err != nil {
+			errs = append(errs, fmt.Errorf("invalid DEFAULT_LANG: %v", err))
+		}
+	}
+
+	c.ScoreWeights, err = parseScoreWeights(os.Getenv("SCORE_WEIGHTS"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid SCORE_WEIGHTS: %v", err))
+	}
+
+	// Response field renames for clients with a fixed schema, as a JSON object.
+	c.FieldRenames, err = parseFieldRenames(os.Getenv("FIELD_RENAMES"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid FIELD_RENAMES: %v", err))
+	}
+
+	switch mode := os.Getenv("AUTH_FAIL_MODE"); mode {
+	case "", "closed":
+	case "open":
+		c.AuthFailOpen = true
+	default:
+		log.Printf("Invalid AUTH_FAIL_MODE %q, defaulting to closed", mode)
+	}
+
+	c.CacheControlDirective = os.Getenv("CACHE_CONTROL_DIRECTIVE")
+	if c.CacheControlDirective != "public" && c.CacheControlDirective != "private" {
+		if c.CacheControlDirective != "" {
+			log.Printf("Invalid CACHE_CONTROL_DIRECTIVE, defaulting to public")
+		}
+		c.CacheControlDirective = "public"
+	}
+
+	// What to do when a cached entry can't be decoded: "refetch" (default) drops
+	// it and fetches fresh data, "error" drops it and fails the request.
+	switch policy := os.Getenv("CACHE_CORRUPT_POLICY"); policy {
+	case "", "refetch":
+		c.RefetchOnCorruption = true
+	case "error":
+	default:
+		log.Printf("Invalid CACHE_CORRUPT_POLICY %q, defaulting to refetch", policy)
+		c.RefetchOnCorruption = true
+	}
+
+	// Proxies allowed to set X-Forwarded-For/X-Real-IP (comma-separated IPs or CIDRs).
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			errs = append(errs, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", p))
+			continue
+		}
+		c.TrustedProxies = append(c.TrustedProxies, p)
+	}
+
+	// Data source credit; may be set to an empty string to disable it.
+	c.AttributionText = "Weather data provided by Visual Crossing (https://www.visualcrossing.com/)"
+	if text, ok := os.LookupEnv("ATTRIBUTION_TEXT"); ok {
+		c.AttributionText = text
+	}
+
+	for name := range c.HealthChecks {
+		if _, ok := healthProbes[name]; !ok {
+			log.Printf("Ignoring unknown HEALTH_CHECKS entry %q", name)
+			delete(c.HealthChecks, name)
+		}
+	}
+
+	// Paths excluded from the access log (comma-separated).
+	skip, ok := os.LookupEnv("ACCESS_LOG_SKIP_PATHS")
+	if !ok {
+		skip = "/health,/metrics"
+	}
+	c.AccessLogSkip = parseSet(skip)
+
+	// Locations kept warm in the cache (comma-separated).
+	for _, l := range strings.Split(os.Getenv("WARM_LOCATIONS"), ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			c.WarmLocations = append(c.WarmLocations, l)
+		}
+	}
+	if c.WarmInterval <= 0 {
+		log.Printf("Invalid WARM_INTERVAL, defaulting to 3600")
+		c.WarmInterval = time.Hour
+	}
+	if c.WarmConcurrency < 1 {
+		log.Printf("Invalid WARM_CONCURRENCY, defaulting to 2")
+		c.WarmConcurrency = 2
+	}
+	if c.WarmDelay < 0 {
+		c.WarmDelay = 0
+	}
+
+	if c.CacheExpiration < c.CacheMinTTL {
+		log.Printf("CACHE_EXPIRATION is below CACHE_MIN_TTL, using %s", c.CacheMinTTL)
+		c.CacheExpiration = c.CacheMinTTL
+	}
+
+	if c.AdaptiveTTL {
+		if c.AdaptiveTTLMin < c.CacheMinTTL {
+			log.Printf("ADAPTIVE_TTL_MIN is below CACHE_MIN_TTL, using %s", c.CacheMinTTL)
+			c.AdaptiveTTLMin = c.CacheMinTTL
+		}
+		if c.AdaptiveTTLMax < c.AdaptiveTTLMin {
+			errs = append(errs, errors.New("ADAPTIVE_TTL_MAX must not be below ADAPTIVE_TTL_MIN"))
+		}
+	}
+
+	if c.MaxUpstreamResponseBytes <= 0 {
+		log.Printf("Invalid MAX_UPSTREAM_RESPONSE_BYTES, defaulting to %d", 5<<20)
+		c.MaxUpstreamResponseBytes = 5 << 20
+	}
+
+	return c, errors.Join(errs...)
+}
+
+// envString reads a string environment variable, falling back to def when unset.
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt reads an integer environment variable, falling back to def when it is unset
+// or invalid.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("Invalid %s, defaulting to %d", name, def)
+		return def
+	}
+	return v
+}
+
+// envFloat reads a floating-point environment variable, falling back to def when
+// it is unset or invalid.
+func envFloat(name string, def float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("Invalid %s, defaulting to %g", name, def)
+		return def
+	}
+	return v
+}
+
+// envSeconds reads a duration given in whole seconds, falling back to def seconds.
+func envSeconds(name string, def int) time.Duration {
+	return time.Duration(envInt(name, def)) * time.Second
+}
+
+// envBool reads a boolean environment variable, falling back to def when it is
+// unset or invalid.
+func envBool(name string, def bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("Invalid %s, defaulting to %t", name, def)
+		return def
+	}
+	return v
+}
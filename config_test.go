@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// setEnv sets the environment loadConfig reads for one test, starting from a
+// valid minimal configuration.
+func setEnv(t *testing.T, vars map[string]string) {
+	t.Helper()
+	base := map[string]string{
+		"CONFIG_FILE":             "",
+		"VISUAL_CROSSING_API_KEY": "testkey",
+		"VISUAL_CROSSING_API_URL": "http://upstream.invalid",
+		"UPSTREAM_REGION":         "",
+		"UPSTREAM_FAKE":           "",
+		"MOCK_MODE":               "",
+	}
+	for k, v := range vars {
+		base[k] = v
+	}
+	for k, v := range base {
+		t.Setenv(k, v)
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	setEnv(t, map[string]string{"UPSTREAM_RETRIES": "", "FETCH_LOCK_WAIT": "", "CACHE_CORRUPT_POLICY": ""})
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if c.APIKey != "testkey" || c.APIURL != "http://upstream.invalid" {
+		t.Errorf("upstream = %q at %q, want testkey at http://upstream.invalid", c.APIKey, c.APIURL)
+	}
+	if c.UpstreamRetries != 2 {
+		t.Errorf("UpstreamRetries = %d, want 2", c.UpstreamRetries)
+	}
+	if c.FetchLockWait != 5*time.Second {
+		t.Errorf("FetchLockWait = %s, want 5s", c.FetchLockWait)
+	}
+	if !c.RefetchOnCorruption {
+		t.Error("corrupt entries aren't refetched by default")
+	}
+}
+
+func TestLoadConfigRequired(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		vars map[string]string
+		want string
+	}{
+		{"no api key", map[string]string{"VISUAL_CROSSING_API_KEY": ""}, "VISUAL_CROSSING_API_KEY must be set"},
+		{"no upstream", map[string]string{"VISUAL_CROSSING_API_URL": ""}, "VISUAL_CROSSING_API_URL or UPSTREAM_REGION must be set"},
+		{"unknown region", map[string]string{"VISUAL_CROSSING_API_URL": "", "UPSTREAM_REGION": "mars"}, `unknown UPSTREAM_REGION "mars"`},
+		{"negative stale grace", map[string]string{"CACHE_STALE_GRACE": "-1"}, "CACHE_STALE_GRACE must not be negative"},
+		{"no lock wait", map[string]string{"FETCH_LOCK_WAIT": "0"}, "FETCH_LOCK_WAIT must be positive"},
+		{"negative anonymous limit", map[string]string{"ANONYMOUS_RATE_LIMIT": "-5"}, "ANONYMOUS_RATE_LIMIT must not be negative"},
+	} {
+		setEnv(t, tc.vars)
+		_, err := loadConfig()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: loadConfig error = %v, want it to mention %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigReportsEveryError(t *testing.T) {
+	setEnv(t, map[string]string{"VISUAL_CROSSING_API_KEY": "", "CACHE_STALE_GRACE": "-1"})
+	_, err := loadConfig()
+	if err == nil {
+		t.Fatal("loadConfig accepted an invalid configuration")
+	}
+	for _, want := range []string{"VISUAL_CROSSING_API_KEY", "CACHE_STALE_GRACE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("loadConfig error %q doesn't mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadConfigOptionalFallbacks(t *testing.T) {
+	setEnv(t, map[string]string{
+		"CACHE_CORRUPT_POLICY":    "shrug",
+		"AUTH_FAIL_MODE":          "sometimes",
+		"CACHE_CONTROL_DIRECTIVE": "everyone",
+		"UPSTREAM_RETRIES":        "many",
+	})
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("invalid optional settings failed loadConfig: %v", err)
+	}
+	if !c.RefetchOnCorruption {
+		t.Error("invalid CACHE_CORRUPT_POLICY didn't fall back to refetch")
+	}
+	if c.AuthFailOpen {
+		t.Error("invalid AUTH_FAIL_MODE didn't fall back to closed")
+	}
+	if c.CacheControlDirective != "public" {
+		t.Errorf("CacheControlDirective = %q, want the public default", c.CacheControlDirective)
+	}
+	if c.UpstreamRetries != 2 {
+		t.Errorf("UpstreamRetries = %d, want the default 2", c.UpstreamRetries)
+	}
+}
+
+func TestLoadConfigFakeUpstreamNeedsNoKey(t *testing.T) {
+	setEnv(t, map[string]string{"VISUAL_CROSSING_API_KEY": "", "VISUAL_CROSSING_API_URL": "", "MOCK_MODE": "true"})
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if c.APIURL != "" {
+		t.Errorf("mock mode kept upstream URL %q", c.APIURL)
+	}
+}
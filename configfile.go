@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile reads a YAML or JSON config file (JSON being a subset of YAML)
+// holding a flat mapping of environment variable names to values, e.g.
+//
+//	CACHE_EXPIRATION: 3600
+//	HEALTH_CHECKS: [redis, upstream]
+//	FIELD_RENAMES: {temp: temperature}
+//
+// Names are matched case-insensitively. Lists become comma-separated values and
+// objects become JSON, matching how the corresponding variables are written.
+func loadConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		s, err := configValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		values[strings.ToUpper(name)] = s
+	}
+	return values, nil
+}
+
+// configValue renders a config file value the way it would be written in the
+// environment.
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(s, ",") {
+				return "", fmt.Errorf("list item %q contains a comma", s)
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		b, err := json.Marshal(v)
+		return string(b), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// applyConfigFile loads path and sets each of its variables that isn't already
+// set in the environment, so environment variables override file values and
+// loadConfig parses both the same way. An empty path is a no-op.
+func applyConfigFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	values, err := loadConfigFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid CONFIG_FILE %s: %v", path, err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+		if err := os.Setenv(name, values[name]); err != nil {
+			return fmt.Errorf("invalid CONFIG_FILE %s: %s: %v", path, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// upstreamCostHeader carries the estimated record cost of the upstream call
+// made for a response.
+const upstreamCostHeader = "X-Upstream-Cost"
+
+// includeCostFactors are the records per day estimated for each include
+// section. Sections not listed, such as current conditions and alerts, come
+// with the days at no extra cost.
+var includeCostFactors = map[string]int64{
+	"days":         1,
+	"hours":        1,
+	normalsInclude: 1,
+}
+
+// estimateUpstreamCost estimates the Visual Crossing records consumed by a
+// fetch: the number of days returned (at least 1) times the sum of the cost
+// factors of the requested include sections (at least 1).
+func estimateUpstreamCost(q weatherQuery, data map[string]interface{}) int64 {
+	days, _ := data["days"].([]interface{})
+	n := int64(len(days))
+	if n == 0 {
+		n = 1
+	}
+	var factor int64
+	for section := range parseSet(q.upstreamInclude()) {
+		factor += includeCostFactors[section]
+	}
+	if factor == 0 {
+		factor = 1
+	}
+	return n * factor
+}
+
+// costTracker accumulates upstream costs, reported by /stats.
+type costTracker struct {
+	mu        sync.Mutex
+	estimated int64
+	reported  int64            // sum of the upstream's own queryCost, where given
+	byInclude map[string]int64 // estimated cost per upstream include set
+}
+
+var upstreamCosts = &costTracker{byInclude: make(map[string]int64)}
+
+// record adds a successful fetch with the given include set, estimated cost and
+// response data.
+func (t *costTracker) record(include string, estimated int64, data map[string]interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.estimated += estimated
+	if reported, ok := data["queryCost"].(float64); ok {
+		t.reported += int64(reported)
+	}
+	t.byInclude[include] += estimated
+}
+
+// snapshot returns the totals for /stats, with the include sets sorted by
+// estimated cost, most expensive first.
+func (t *costTracker) snapshot() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	type includeCost struct {
+		Include   string `json:"include"`
+		Estimated int64  `json:"estimated"`
+	}
+	byInclude := make([]includeCost, 0, len(t.byInclude))
+	for include, cost := range t.byInclude {
+		byInclude = append(byInclude, includeCost{include, cost})
+	}
+	sort.Slice(byInclude, func(i, j int) bool {
+		if byInclude[i].Estimated != byInclude[j].Estimated {
+			return byInclude[i].Estimated > byInclude[j].Estimated
+		}
+		return byInclude[i].Include < byInclude[j].Include
+	})
+	return map[string]interface{}{
+		"estimated": t.estimated,
+		"reported":  t.reported,
+		"byInclude": byInclude,
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Current conditions refresh counters, reported by /stats.
+var (
+	currentRefreshes       atomic.Int64 // cached current conditions replaced by fresher ones
+	currentRefreshFailures atomic.Int64 // stale current conditions served because the refresh failed
+)
+
+// currentInclude is the upstream include section holding current conditions.
+const currentInclude = "current"
+
+// splitsCurrent reports whether lookups of q keep their current conditions
+// fresher than the rest of the data, see CURRENT_CONDITIONS_TTL: the include set
+// has current conditions next to other sections, and the data can still change.
+func splitsCurrent(q weatherQuery) bool {
+	if cfg.CurrentConditionsTTL <= 0 || q.Immutable || q.include() == currentInclude {
+		return false
+	}
+	for _, section := range strings.Split(q.include(), ",") {
+		if section == currentInclude {
+			return true
+		}
+	}
+	return false
+}
+
+// currentQuery returns the lookup of q's current conditions alone. It is cached
+// under its own key, for CURRENT_CONDITIONS_TTL.
+func currentQuery(q weatherQuery) weatherQuery {
+	q.Include = currentInclude
+	q.Normals = false
+	q.Agri = false
+	return q
+}
+
+// currentStale reports whether current conditions fetched at fetchedAt are
+// older than CURRENT_CONDITIONS_TTL.
+func currentStale(fetchedAt time.Time, now time.Time) bool {
+	return now.Sub(fetchedAt) >= cfg.CurrentConditionsTTL
+}
+
+// decodeForCurrent decodes a raw cache hit whose current conditions are about to
+// be replaced.
+func decodeForCurrent(raw json.RawMessage) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	err := json.Unmarshal(raw, &data)
+	return data, err
+}
+
+// refreshCurrent replaces the current conditions of cached data with those of
+// currentQuery, served from their own cache entry or fetched alone, so only the
+// stale part of the data costs an upstream call. Data without current
+// conditions is left as is, and so are they when the lookup fails.
+func refreshCurrent(ctx context.Context, q weatherQuery, data map[string]interface{}) {
+	if _, ok := data["currentConditions"].(map[string]interface{}); !ok {
+		return
+	}
+	result, err := resolveWeather(ctx, currentQuery(q), lookupOptions{})
+	if err != nil {
+		currentRefreshFailures.Add(1)
+		log.Printf("Serving cached current conditions for location %s, refresh failed: %v", q.Location, err)
+		return
+	}
+	if current, ok := result.Data["currentConditions"].(map[string]interface{}); ok {
+		data["currentConditions"] = current
+		currentRefreshes.Add(1)
+	}
+}
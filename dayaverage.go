@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+// dayAverageFetches bounds how many years /weather/normal looks up at once.
+const dayAverageFetches = 4
+
+// dayAverageYear is the weather of the calendar day in one past year.
+type dayAverageYear struct {
+	Year    int     `json:"year"`
+	TempMax float64 `json:"tempmax"`
+	TempMin float64 `json:"tempmin"`
+	Precip  float64 `json:"precip"`
+}
+
+// dayAverage is the response of /weather/normal.
+type dayAverage struct {
+	Location string           `json:"location"`
+	Date     string           `json:"date"`
+	Years    int              `json:"years"` // years averaged
+	From     int              `json:"from,omitempty"`
+	To       int              `json:"to,omitempty"`
+	TempMax  *float64         `json:"tempmax"`
+	TempMin  *float64         `json:"tempmin"`
+	Precip   *float64         `json:"precip"`
+	History  []dayAverageYear `json:"history"`
+}
+
+// dayAverageYears parses the date (MM-DD) and years parameters of
+// /weather/normal into the dates of that calendar day in each of the past years,
+// most recent first. Years in which the day doesn't exist, i.e. February 29 of
+// non-leap years, and years before earliestHistoryYear are left out.
+func dayAverageYears(dateRaw, yearsRaw string, now time.Time) ([]time.Time, error) {
+	day, err := time.Parse(dateLayout, "2000-"+dateRaw)
+	if err != nil || len(dateRaw) != len("01-02") {
+		return nil, fmt.Errorf("date must be a calendar day in MM-DD format, e.g. 06-15")
+	}
+	years := 10
+	if yearsRaw != "" {
+		if years, err = strconv.Atoi(yearsRaw); err != nil || years < 1 || years > cfg.NormalMaxYears {
+			return nil, fmt.Errorf("years must be an integer between 1 and %d", cfg.NormalMaxYears)
+		}
+	}
+
+	var dates []time.Time
+	for year := now.UTC().Year() - 1; year > now.UTC().Year()-1-years && year >= earliestHistoryYear; year-- {
+		d := time.Date(year, day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if d.Day() == day.Day() {
+			dates = append(dates, d)
+		}
+	}
+	return dates, nil
+}
+
+// averageDays fills in the mean high, low and precipitation of the history, to
+// one decimal, leaving them nil when there is no history.
+func averageDays(avg *dayAverage) {
+	avg.Years = len(avg.History)
+	if avg.Years == 0 {
+		return
+	}
+	var tempMax, tempMin, precip float64
+	for _, y := range avg.History {
+		tempMax += y.TempMax
+		tempMin += y.TempMin
+		precip += y.Precip
+	}
+	mean := func(sum float64) *float64 {
+		v := math.Round(sum/float64(avg.Years)*10) / 10
+		return &v
+	}
+	avg.TempMax, avg.TempMin, avg.Precip = mean(tempMax), mean(tempMin), mean(precip)
+	avg.From, avg.To = avg.History[len(avg.History)-1].Year, avg.History[0].Year
+}
+
+// getNormalHandler handles GET /weather/normal requests, averaging the high, low
+// and precipitation of one calendar day (date=MM-DD) over the past years (10 by
+// default, at most NORMAL_MAX_YEARS). Each year is looked up as its own one-day
+// range, so it is cached for HISTORY_CACHE_TTL and shared by later requests for
+// the same day with any other number of years. Years without data are left out
+// of the average.
+func getNormalHandler(c *gin.Context) {
+	var p queryParams
+	if !bindQuery(c, &p) {
+		return
+	}
+	now := clock.Now()
+	dates, err := dayAverageYears(c.Query("date"), c.Query("years"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	base, ok := buildWeatherQuery(c, p)
+	if !ok {
+		return
+	}
+
+	history := make([]*dayAverageYear, len(dates))
+	queries := make([]weatherQuery, len(dates))
+	results := make([]*weatherResult, len(dates))
+	g, gctx := errgroup.WithContext(c.Request.Context())
+	g.SetLimit(dayAverageFetches)
+	for i, date := range dates {
+		i, date := i, date
+		g.Go(func() error {
+			q := base
+			q.Start, q.End, q.Period = date.Format(dateLayout), date.Format(dateLayout), ""
+			q.Include = endpointInclude("/weather/normal")
+			q = routeQuery(q, "/weather/normal", now)
+			q.Immutable = true
+			result, err := lookupWeather(gctx, q, lookupOptions{})
+			if err != nil {
+				return err
+			}
+			queries[i], results[i] = q, &result
+			days, err := decodeDays(result.Data)
+			if err != nil {
+				return err
+			}
+			if len(days) > 0 {
+				history[i] = &dayAverageYear{Year: date.Year(), TempMax: days[0].TempMax, TempMin: days[0].TempMin, Precip: days[0].Precip}
+			}
+			return nil
+		})
+	}
+	err = g.Wait()
+	// The context isn't safe for concurrent use, so lookups are noted afterwards.
+	for i, result := range results {
+		if result != nil {
+			noteLookup(c, queries[i], *result)
+		}
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	avg := dayAverage{Location: base.Location, Date: c.Query("date"), History: []dayAverageYear{}}
+	for _, y := range history {
+		if y != nil {
+			avg.History = append(avg.History, *y)
+		}
+	}
+	averageDays(&avg)
+	c.JSON(http.StatusOK, avg)
+}
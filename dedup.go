@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// With COORDINATE_DEDUP enabled the cache uses two levels of keys:
+//
+//	weather:<sha1(query)>       -> {"v":..,"key":"london","ref":"weather:<sha1(coords)>"}
+//	weather:<sha1(coords)>      -> {"v":..,"key":"51.5074,-0.1278","data":{...}}
+//
+// The data lives under the key of the resolved coordinates reported by the
+// upstream, and each query key is a small reference to it, so different
+// spellings of the same place share one copy of the data. Requests giving the
+// coordinates themselves hit the data entry directly.
+
+// coordinatePrecision is the number of decimals coordinates are rounded to in
+// coordinate keys (about 11 m).
+const coordinatePrecision = 4
+
+// coordinateQuery returns the query for the resolved coordinates in data, keeping
+// the date range and provider options of q. It returns false when the response
+// carries no coordinates.
+func coordinateQuery(q weatherQuery, data map[string]interface{}) (weatherQuery, bool) {
+	lat, okLat := data["latitude"].(float64)
+	lon, okLon := data["longitude"].(float64)
+	if !okLat || !okLon {
+		return weatherQuery{}, false
+	}
+	cq := q
+	cq.Location = fmt.Sprintf("%.*f,%.*f", coordinatePrecision, lat, coordinatePrecision, lon)
+	return cq, true
+}
+
+// contentPrefix follows cachePrefix in the keys of content-addressed entries.
+const contentPrefix = "content:"
+
+// contentKey returns the key content-addressed data is stored under, and the
+// canonical key recorded in its entry. Encoding a map sorts its keys, so equal
+// data always hashes alike.
+func contentKey(data map[string]interface{}) (key, canonical string, err error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(b)
+	canonical = contentPrefix + hex.EncodeToString(sum[:])
+	return cachePrefix + canonical, canonical, nil
+}
+
+// cacheReferences reports whether query keys may hold references to the
+// entry with their data, which lookups then follow.
+func cacheReferences() bool {
+	return cfg.CoordinateDedup || cfg.ContentAddressedCache
+}
+
+// refEntry is a cache entry pointing at the entry that holds the data.
+type refEntry struct {
+	Version int    `json:"v"`
+	Key     string `json:"key,omitempty"`
+	Ref     string `json:"ref"`
+}
+
+// encodeRef builds the reference entry stored under the query key.
+func encodeRef(key, ref string) ([]byte, error) {
+	return json.Marshal(refEntry{Version: cacheSchemaVersion, Key: key, Ref: ref})
+}
+
+// followCacheRef resolves a reference entry to the entry it points at; other
+// entries are returned unchanged. A dangling reference yields redis.Nil so the
+// lookup is treated as a miss.
+func followCacheRef(ctx context.Context, raw string) (string, error) {
+	var entry refEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil || entry.Ref == "" {
+		// Unreadable entries are left for decodeEntry to report.
+		return raw, nil
+	}
+	return cacheGet(ctx, entry.Ref)
+}
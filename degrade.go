@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// errorRateTracker keeps a rolling, time-bucketed count of upstream call outcomes.
+type errorRateTracker struct {
+	mu      sync.Mutex
+	width   time.Duration // duration covered by one bucket
+	buckets []outcomeBucket
+}
+
+type outcomeBucket struct {
+	start  int64 // bucket start, in units of width since the epoch
+	total  int
+	errors int
+}
+
+// newErrorRateTracker returns a tracker covering window, split into n buckets.
+func newErrorRateTracker(window time.Duration, n int) *errorRateTracker {
+	if n < 1 {
+		n = 1
+	}
+	width := window / time.Duration(n)
+	if width <= 0 {
+		width = time.Second
+	}
+	return &errorRateTracker{width: width, buckets: make([]outcomeBucket, n)}
+}
+
+// record adds the outcome of one upstream call.
+func (t *errorRateTracker) record(failed bool) {
+	slot := clock.Now().UnixNano() / int64(t.width)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.buckets[slot%int64(len(t.buckets))]
+	if b.start != slot {
+		*b = outcomeBucket{start: slot}
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+}
+
+// rate returns the error rate over the window and the number of calls it is
+// based on.
+func (t *errorRateTracker) rate() (float64, int) {
+	slot := clock.Now().UnixNano() / int64(t.width)
+	oldest := slot - int64(len(t.buckets)) + 1
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total, errors int
+	for _, b := range t.buckets {
+		if b.start >= oldest && b.start <= slot {
+			total += b.total
+			errors += b.errors
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(errors) / float64(total), total
+}
+
+// upstreamErrors tracks upstream failures for adaptive TTL degradation.
+var upstreamErrors = newErrorRateTracker(5*time.Minute, 10)
+
+// degradeMinSamples is the number of calls in the window needed before the error
+// rate is trusted, so a single early failure can't trigger degradation.
+const degradeMinSamples = 10
+
+// degraded reports whether the rolling upstream error rate currently exceeds the
+// configured threshold.
+func degraded() bool {
+	if cfg.DegradeErrorRate <= 0 {
+		return false
+	}
+	rate, samples := upstreamErrors.rate()
+	return samples >= degradeMinSamples && rate > cfg.DegradeErrorRate
+}
+
+// effectiveCacheTTL returns the TTL for new cache entries. While the upstream is
+// degraded, the base TTL is multiplied by DEGRADE_TTL_MULTIPLIER (capped at
+// DEGRADE_MAX_TTL) to take pressure off it; it reverts as soon as the error rate
+// falls back under the threshold.
+func effectiveCacheTTL(base time.Duration) time.Duration {
+	if !degraded() {
+		return base
+	}
+	ttl := time.Duration(float64(base) * cfg.DegradeTTLMultiplier)
+	if cfg.DegradeMaxTTL > 0 && ttl > cfg.DegradeMaxTTL {
+		ttl = cfg.DegradeMaxTTL
+	}
+	if ttl < base {
+		return base
+	}
+	return ttl
+}
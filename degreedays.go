@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultDegreeDayBase is the conventional base temperature (°C).
+	defaultDegreeDayBase = 18.0
+	// degreeDayBaseLimit bounds the accepted base temperature to plausible values.
+	degreeDayBaseLimit = 60.0
+)
+
+// degreeDay holds the heating and cooling degree days for one day.
+type degreeDay struct {
+	Date string  `json:"date"`
+	Temp float64 `json:"temp"`
+	HDD  float64 `json:"hdd"`
+	CDD  float64 `json:"cdd"`
+}
+
+// degreeDays computes heating (base minus mean temperature) and cooling (mean
+// temperature minus base) degree days per day, each floored at zero, along with
+// their totals.
+func degreeDays(days []weatherDay, base float64) (perDay []degreeDay, totalHDD, totalCDD float64) {
+	perDay = make([]degreeDay, 0, len(days))
+	for _, d := range days {
+		dd := degreeDay{
+			Date: d.Datetime,
+			Temp: d.Temp,
+			HDD:  math.Max(0, base-d.Temp),
+			CDD:  math.Max(0, d.Temp-base),
+		}
+		totalHDD += dd.HDD
+		totalCDD += dd.CDD
+		perDay = append(perDay, dd)
+	}
+	return perDay, totalHDD, totalCDD
+}
+
+// getDegreeDaysHandler handles GET /weather/degreedays requests, computing degree
+// days from the (cached) full response against an optional base temperature.
+func getDegreeDaysHandler(c *gin.Context) {
+	base := defaultDegreeDayBase
+	if raw := c.Query("base"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || math.Abs(v) > degreeDayBaseLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "base must be a number between -60 and 60"})
+			return
+		}
+		base = v
+	}
+
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	perDay, hdd, cdd := degreeDays(days, base)
+	c.JSON(http.StatusOK, gin.H{
+		"location": q.Location,
+		"base":     base,
+		"days":     perDay,
+		"totalHDD": hdd,
+		"totalCDD": cdd,
+	})
+}
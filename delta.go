@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// diffBaseHeader marks a delta=true response as a diff, naming the ETag of the
+// response it applies to.
+const diffBaseHeader = "X-Diff-Base"
+
+// snapshotKeyPrefix prefixes the Redis keys of the response bodies delta=true
+// diffs against, keyed by their ETag.
+const snapshotKeyPrefix = "snapshot:"
+
+// snapshotKey returns the Redis key of the response body with the given ETag.
+func snapshotKey(etag string) string {
+	return snapshotKeyPrefix + strings.Trim(etag, `"`)
+}
+
+// responseDiff is the body of a delta=true response whose data changed.
+type responseDiff struct {
+	Base    string      `json:"base"` // ETag of the response the changes apply to
+	Changes []fieldDiff `json:"changes"`
+}
+
+// writeDeltaJSON is writeDeltaBody for a value still to be encoded.
+func writeDeltaJSON(c *gin.Context, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+		return
+	}
+	writeDeltaBody(c, status, body)
+}
+
+// writeDeltaBody writes a JSON body for a delta=true request. The body is kept
+// for DIFF_SNAPSHOT_TTL under its ETag. When the request's If-None-Match names
+// an earlier response still kept, only the changes from it are sent, see
+// diffWeather, with the ETag of the full body and X-Diff-Base naming the
+// earlier one. Otherwise the body is written by writeJSONBody, answering 304
+// when it is unchanged.
+func writeDeltaBody(c *gin.Context, status int, body []byte) {
+	etag, _ := bodyDigest(body)
+	if err := redisClient.Set(ctx, snapshotKey(etag), body, cfg.DiffSnapshotTTL).Err(); err != nil {
+		log.Printf("Error storing response snapshot %s: %v", etag, err)
+	}
+
+	base := identityETag(strings.TrimSpace(c.GetHeader("If-None-Match")))
+	if base == "" || base == etag || strings.Contains(base, ",") || c.Request.Method == http.MethodHead {
+		writeJSONBody(c, status, body)
+		return
+	}
+	old, err := redisClient.Get(ctx, snapshotKey(base)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error reading response snapshot %s: %v", base, err)
+		}
+		writeJSONBody(c, status, body)
+		return
+	}
+	var oldData, newData interface{}
+	if json.Unmarshal(old, &oldData) != nil || json.Unmarshal(body, &newData) != nil {
+		writeJSONBody(c, status, body)
+		return
+	}
+
+	diff, err := json.Marshal(responseDiff{Base: base, Changes: diffWeather(oldData, newData)})
+	if err != nil {
+		writeJSONBody(c, status, body)
+		return
+	}
+	_, sum := bodyDigest(diff)
+	c.Header("ETag", etag)
+	c.Header(diffBaseHeader, base)
+	c.Header(contentSHA256Header, sum)
+	c.Data(status, "application/json; charset=utf-8", diff)
+}
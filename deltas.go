@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// dayDelta is how a day's forecast changed from the previous day, see
+// dayDeltas.
+type dayDelta struct {
+	TempMax    float64 `json:"tempmax"`
+	TempMin    float64 `json:"tempmin"`
+	PrecipProb float64 `json:"precipprob"`
+}
+
+// dayDeltas computes, for each day of the series, the change in tempmax,
+// tempmin and precipprob from the calendar day before it, to one decimal. The
+// first day has no delta (nil), and neither has a day whose previous calendar
+// day is missing from the series or any day with an unreadable date, so gaps
+// never yield a delta across several days.
+func dayDeltas(days []weatherDay) []*dayDelta {
+	deltas := make([]*dayDelta, len(days))
+	for i := 1; i < len(days); i++ {
+		prev, errPrev := time.Parse(dateLayout, days[i-1].Datetime)
+		cur, errCur := time.Parse(dateLayout, days[i].Datetime)
+		if errPrev != nil || errCur != nil || !prev.AddDate(0, 0, 1).Equal(cur) {
+			continue
+		}
+		deltas[i] = &dayDelta{
+			TempMax:    math.Round((days[i].TempMax-days[i-1].TempMax)*10) / 10,
+			TempMin:    math.Round((days[i].TempMin-days[i-1].TempMin)*10) / 10,
+			PrecipProb: math.Round((days[i].PrecipProb-days[i-1].PrecipProb)*10) / 10,
+		}
+	}
+	return deltas
+}
+
+// applyDeltas adds deltas, the change from the previous day computed by
+// dayDeltas, to every day that has one. Data whose days can't be decoded is
+// left as is.
+func applyDeltas(data map[string]interface{}) {
+	days, _ := data["days"].([]interface{})
+	typed, err := decodeDays(data)
+	if err != nil || len(typed) != len(days) {
+		return
+	}
+	for i, delta := range dayDeltas(typed) {
+		if day, ok := days[i].(map[string]interface{}); ok && delta != nil {
+			day["deltas"] = delta
+		}
+	}
+}
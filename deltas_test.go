@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDayDeltas(t *testing.T) {
+	day := func(date string, max, min, precip float64) weatherDay {
+		return weatherDay{Datetime: date, TempMax: max, TempMin: min, PrecipProb: precip}
+	}
+	for _, tc := range []struct {
+		name string
+		days []weatherDay
+		want []*dayDelta
+	}{
+		{"empty", nil, []*dayDelta{}},
+		{"single", []weatherDay{day("2026-10-14", 20, 10, 0)}, []*dayDelta{nil}},
+		{"consecutive", []weatherDay{
+			day("2026-10-14", 20, 10, 30),
+			day("2026-10-15", 17.6, 11.3, 80),
+			day("2026-10-16", 17.6, 9, 15.5),
+		}, []*dayDelta{nil, {TempMax: -2.4, TempMin: 1.3, PrecipProb: 50}, {TempMax: 0, TempMin: -2.3, PrecipProb: -64.5}}},
+		// No delta across a gap, a month end is still consecutive.
+		{"gap", []weatherDay{
+			day("2026-10-30", 10, 5, 0),
+			day("2026-10-31", 12, 6, 0),
+			day("2026-11-02", 14, 7, 0),
+			day("2026-11-03", 13, 7, 0),
+		}, []*dayDelta{nil, {TempMax: 2, TempMin: 1}, nil, {TempMax: -1}}},
+		{"unreadable date", []weatherDay{
+			day("2026-10-14", 20, 10, 0),
+			day("tomorrow", 21, 10, 0),
+			day("2026-10-15", 22, 10, 0),
+		}, []*dayDelta{nil, nil, nil}},
+		{"out of order", []weatherDay{day("2026-10-15", 20, 10, 0), day("2026-10-14", 21, 10, 0)}, []*dayDelta{nil, nil}},
+	} {
+		if got := dayDeltas(tc.days); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: dayDeltas = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestApplyDeltas(t *testing.T) {
+	first := map[string]interface{}{"datetime": "2026-10-14", "tempmax": 20.0, "tempmin": 10.0, "precipprob": 10.0}
+	second := map[string]interface{}{"datetime": "2026-10-15", "tempmax": 22.5, "tempmin": 9.0, "precipprob": 40.0}
+	applyDeltas(map[string]interface{}{"days": []interface{}{first, second}})
+
+	if _, ok := first["deltas"]; ok {
+		t.Error("first day got a delta")
+	}
+	if got, want := second["deltas"], (&dayDelta{TempMax: 2.5, TempMin: -1, PrecipProb: 30}); !reflect.DeepEqual(got, want) {
+		t.Errorf("second day deltas = %v, want %v", got, want)
+	}
+
+	// Undecodable days are left alone.
+	bad := map[string]interface{}{"datetime": "2026-10-15", "tempmax": "warm"}
+	applyDeltas(map[string]interface{}{"days": []interface{}{first, bad}})
+	if _, ok := bad["deltas"]; ok {
+		t.Error("undecodable day got a delta")
+	}
+}
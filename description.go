@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+// describePrecipProb is the precipitation probability, in percent, from which
+// a description mentions precipitation, for the day and for its hours.
+const describePrecipProb = 30
+
+// describeLocale is how /weather/description phrases a forecast in one
+// language. Sentences are built around nouns ("a 60 percent chance of rain"),
+// so they need no agreement with what is expected.
+type describeLocale struct {
+	// template renders a description; numbers come already spelled out.
+	template        *template.Template
+	degree, degrees string // unit word for one and for several degrees
+	percent         string
+	and             string
+	number          func(int) string // spells a number out, nil for digits
+	precipitation   string           // for precipitation of unknown type
+	// precipTypes name the Visual Crossing precipitation types.
+	precipTypes map[string]string
+	// periods phrase the parts of the day of describePeriods, with their
+	// preposition; allDay stands for all of them.
+	periods         map[string]string
+	allDay          string
+	lowerConditions bool
+}
+
+// describePeriods are the parts of the day precipitation is located in, by
+// the hour they start at.
+var describePeriods = []struct {
+	name  string
+	start int
+}{
+	{"night", 0},
+	{"morning", 6},
+	{"afternoon", 12},
+	{"evening", 18},
+}
+
+// newDescribeLocale parses a description template, panicking on a broken one,
+// as templates are fixed at build time.
+func newDescribeLocale(text string, l describeLocale) describeLocale {
+	l.template = template.Must(template.New("description").Parse(text))
+	return l
+}
+
+// describeLocales are the languages /weather/description has phrasing for, by
+// lang code. Other languages fall back to English phrasing around the
+// condition text Visual Crossing translated.
+var describeLocales = map[string]describeLocale{
+	"en": newDescribeLocale(`Today in {{.Location}}: {{.Temp}}{{with .Conditions}}, {{.}}{{end}}. Expect a high of {{.High}} and a low of {{.Low}}.{{with .Precip}} There is a {{$.Chance}} chance of {{.}}{{with $.Periods}} {{.}}{{end}}.{{end}}{{with .Tomorrow}} Tomorrow: a high of {{.High}} and a low of {{.Low}}{{with .Conditions}}, {{.}}{{end}}.{{end}}`, describeLocale{
+		degree: "degree", degrees: "degrees", percent: "percent", and: "and", number: spellEnglish,
+		precipitation:   "precipitation",
+		precipTypes:     map[string]string{"rain": "rain", "snow": "snow", "freezingrain": "freezing rain", "ice": "ice"},
+		periods:         map[string]string{"night": "overnight", "morning": "in the morning", "afternoon": "in the afternoon", "evening": "in the evening"},
+		allDay:          "throughout the day",
+		lowerConditions: true,
+	}),
+	"de": newDescribeLocale(`Heute in {{.Location}}: {{.Temp}}{{with .Conditions}}, {{.}}{{end}}. Höchstwert {{.High}}, Tiefstwert {{.Low}}.{{with .Precip}} Wahrscheinlichkeit für {{.}}{{with $.Periods}} {{.}}{{end}}: {{$.Chance}}.{{end}}{{with .Tomorrow}} Morgen: Höchstwert {{.High}}, Tiefstwert {{.Low}}{{with .Conditions}}, {{.}}{{end}}.{{end}}`, describeLocale{
+		degree: "Grad", degrees: "Grad", percent: "Prozent", and: "und",
+		precipitation: "Niederschlag",
+		precipTypes:   map[string]string{"rain": "Regen", "snow": "Schnee", "freezingrain": "gefrierenden Regen", "ice": "Eis"},
+		periods:       map[string]string{"night": "in der Nacht", "morning": "am Morgen", "afternoon": "am Nachmittag", "evening": "am Abend"},
+		allDay:        "den ganzen Tag",
+	}),
+	"fr": newDescribeLocale(`Aujourd'hui à {{.Location}} : {{.Temp}}{{with .Conditions}}, {{.}}{{end}}. Maximum {{.High}}, minimum {{.Low}}.{{with .Precip}} Risque de {{.}}{{with $.Periods}} {{.}}{{end}} : {{$.Chance}}.{{end}}{{with .Tomorrow}} Demain : maximum {{.High}}, minimum {{.Low}}{{with .Conditions}}, {{.}}{{end}}.{{end}}`, describeLocale{
+		degree: "degré", degrees: "degrés", percent: "pour cent", and: "et",
+		precipitation:   "précipitations",
+		precipTypes:     map[string]string{"rain": "pluie", "snow": "neige", "freezingrain": "pluie verglaçante", "ice": "glace"},
+		periods:         map[string]string{"night": "la nuit", "morning": "le matin", "afternoon": "l'après-midi", "evening": "le soir"},
+		allDay:          "toute la journée",
+		lowerConditions: true,
+	}),
+	"es": newDescribeLocale(`Hoy en {{.Location}}: {{.Temp}}{{with .Conditions}}, {{.}}{{end}}. Máxima de {{.High}} y mínima de {{.Low}}.{{with .Precip}} Probabilidad de {{.}}{{with $.Periods}} {{.}}{{end}}: {{$.Chance}}.{{end}}{{with .Tomorrow}} Mañana: máxima de {{.High}} y mínima de {{.Low}}{{with .Conditions}}, {{.}}{{end}}.{{end}}`, describeLocale{
+		degree: "grado", degrees: "grados", percent: "por ciento", and: "y",
+		precipitation:   "precipitaciones",
+		precipTypes:     map[string]string{"rain": "lluvia", "snow": "nieve", "freezingrain": "lluvia helada", "ice": "hielo"},
+		periods:         map[string]string{"night": "de madrugada", "morning": "por la mañana", "afternoon": "por la tarde", "evening": "por la noche"},
+		allDay:          "durante todo el día",
+		lowerConditions: true,
+	}),
+	"it": newDescribeLocale(`Oggi a {{.Location}}: {{.Temp}}{{with .Conditions}}, {{.}}{{end}}. Massima di {{.High}} e minima di {{.Low}}.{{with .Precip}} Probabilità di {{.}}{{with $.Periods}} {{.}}{{end}}: {{$.Chance}}.{{end}}{{with .Tomorrow}} Domani: massima di {{.High}} e minima di {{.Low}}{{with .Conditions}}, {{.}}{{end}}.{{end}}`, describeLocale{
+		degree: "grado", degrees: "gradi", percent: "per cento", and: "e",
+		precipitation:   "precipitazioni",
+		precipTypes:     map[string]string{"rain": "pioggia", "snow": "neve", "freezingrain": "pioggia gelata", "ice": "ghiaccio"},
+		periods:         map[string]string{"night": "di notte", "morning": "al mattino", "afternoon": "nel pomeriggio", "evening": "la sera"},
+		allDay:          "per tutta la giornata",
+		lowerConditions: true,
+	}),
+	"nl": newDescribeLocale(`Vandaag in {{.Location}}: {{.Temp}}{{with .Conditions}}, {{.}}{{end}}. Maximaal {{.High}} en minimaal {{.Low}}.{{with .Precip}} Kans op {{.}}{{with $.Periods}} {{.}}{{end}}: {{$.Chance}}.{{end}}{{with .Tomorrow}} Morgen: maximaal {{.High}} en minimaal {{.Low}}{{with .Conditions}}, {{.}}{{end}}.{{end}}`, describeLocale{
+		degree: "graad", degrees: "graden", percent: "procent", and: "en",
+		precipitation:   "neerslag",
+		precipTypes:     map[string]string{"rain": "regen", "snow": "sneeuw", "freezingrain": "ijzel", "ice": "ijs"},
+		periods:         map[string]string{"night": "in de nacht", "morning": "in de ochtend", "afternoon": "in de middag", "evening": "in de avond"},
+		allDay:          "de hele dag",
+		lowerConditions: true,
+	}),
+	"pt": newDescribeLocale(`Hoje em {{.Location}}: {{.Temp}}{{with .Conditions}}, {{.}}{{end}}. Máxima de {{.High}} e mínima de {{.Low}}.{{with .Precip}} Probabilidade de {{.}}{{with $.Periods}} {{.}}{{end}}: {{$.Chance}}.{{end}}{{with .Tomorrow}} Amanhã: máxima de {{.High}} e mínima de {{.Low}}{{with .Conditions}}, {{.}}{{end}}.{{end}}`, describeLocale{
+		degree: "grau", degrees: "graus", percent: "por cento", and: "e",
+		precipitation:   "precipitação",
+		precipTypes:     map[string]string{"rain": "chuva", "snow": "neve", "freezingrain": "chuva congelante", "ice": "gelo"},
+		periods:         map[string]string{"night": "de madrugada", "morning": "de manhã", "afternoon": "à tarde", "evening": "à noite"},
+		allDay:          "durante todo o dia",
+		lowerConditions: true,
+	}),
+}
+
+// description is what a description template renders. Numbers are spelled
+// out with their unit words.
+type description struct {
+	Location   string
+	Temp       string // with the temperature scale, e.g. "fourteen degrees Celsius"
+	High, Low  string
+	Conditions string
+	Precip     string // the expected precipitation types, empty when unlikely
+	Chance     string // its probability
+	Periods    string // the parts of the day it is expected in, if known
+	Tomorrow   *describedDay
+}
+
+// describedDay is the outlook for the following day.
+type describedDay struct {
+	High, Low  string
+	Conditions string
+}
+
+// describeScales name the temperature scales by unit symbol.
+var describeScales = map[string]string{"°C": "Celsius", "°F": "Fahrenheit"}
+
+// spell writes n in the locale's words, or as digits.
+func (l describeLocale) spell(n int) string {
+	if l.number == nil {
+		return strconv.Itoa(n)
+	}
+	return l.number(n)
+}
+
+// degreesOf writes a whole temperature with its unit word.
+func (l describeLocale) degreesOf(n int) string {
+	if n == 1 || n == -1 {
+		return l.spell(n) + " " + l.degree
+	}
+	return l.spell(n) + " " + l.degrees
+}
+
+// list joins items the way the locale lists them: "a, b and c".
+func (l describeLocale) list(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + l.and + " " + items[len(items)-1]
+}
+
+// conditions prepares condition text for mid-sentence use.
+func (l describeLocale) conditions(text string) string {
+	if l.lowerConditions {
+		return strings.ToLower(text)
+	}
+	return text
+}
+
+// precipPeriods lists the parts of the day with an hour at least
+// describePrecipProb likely to see precipitation, in order.
+func precipPeriods(hours []weatherHour) []string {
+	likely := make(map[string]bool)
+	for _, h := range hours {
+		hour, err := strconv.Atoi(strings.SplitN(h.Datetime, ":", 2)[0])
+		if err != nil || h.PrecipProb == nil || *h.PrecipProb < describePrecipProb {
+			continue
+		}
+		for i := len(describePeriods) - 1; i >= 0; i-- {
+			if hour >= describePeriods[i].start {
+				likely[describePeriods[i].name] = true
+				break
+			}
+		}
+	}
+	var periods []string
+	for _, p := range describePeriods {
+		if likely[p.name] {
+			periods = append(periods, p.name)
+		}
+	}
+	return periods
+}
+
+// renderDescription phrases the forecast of days, starting today, as a few
+// complete sentences in lang for screen readers and voice assistants: numbers
+// spelled out where the language has words for them, units written as words
+// instead of symbols, and precipitation with its probability and, when the
+// days have hours, when it is expected. The next day's outlook follows when
+// there is one. The current temperature and conditions are used when the data
+// has them, otherwise the day's mean.
+func renderDescription(location string, days []weatherDay, current *weatherCurrent, lang, unitGroup string) (string, error) {
+	locale, ok := describeLocales[lang]
+	if !ok {
+		locale = describeLocales[defaultLang]
+	}
+	today := days[0]
+	temp, conditions := today.Temp, today.Conditions
+	if current != nil && current.Temp != nil {
+		temp = *current.Temp
+		if current.Conditions != "" {
+			conditions = current.Conditions
+		}
+	}
+	whole := func(celsius float64) int { return int(math.Round(temperatureIn(celsius, unitGroup))) }
+	d := description{
+		Location:   location,
+		Temp:       locale.degreesOf(whole(temp)) + " " + describeScales[unitsFor(unitGroup).Temperature],
+		High:       locale.degreesOf(whole(today.TempMax)),
+		Low:        locale.degreesOf(whole(today.TempMin)),
+		Conditions: locale.conditions(conditions),
+	}
+	if today.PrecipProb >= describePrecipProb {
+		var types []string
+		for _, t := range today.PrecipType {
+			if name, ok := locale.precipTypes[t]; ok {
+				types = append(types, name)
+			}
+		}
+		if len(types) == 0 {
+			types = []string{locale.precipitation}
+		}
+		d.Precip = locale.list(types)
+		d.Chance = locale.spell(int(math.Round(today.PrecipProb))) + " " + locale.percent
+		var periods []string
+		for _, p := range precipPeriods(today.Hours) {
+			periods = append(periods, locale.periods[p])
+		}
+		if len(periods) == len(describePeriods) {
+			periods = []string{locale.allDay}
+		}
+		d.Periods = locale.list(periods)
+	}
+	if len(days) > 1 {
+		tomorrow := days[1]
+		d.Tomorrow = &describedDay{
+			High:       locale.degreesOf(whole(tomorrow.TempMax)),
+			Low:        locale.degreesOf(whole(tomorrow.TempMin)),
+			Conditions: locale.conditions(tomorrow.Conditions),
+		}
+	}
+	var b bytes.Buffer
+	if err := locale.template.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// englishOnes and englishTens are the words spellEnglish builds numbers from.
+var (
+	englishOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten",
+		"eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	englishTens = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+)
+
+// spellEnglish writes n in English words, e.g. "minus twenty-one". Numbers
+// beyond the hundreds, which no temperature or percentage reaches, stay
+// digits.
+func spellEnglish(n int) string {
+	switch {
+	case n < 0:
+		return "minus " + spellEnglish(-n)
+	case n < 20:
+		return englishOnes[n]
+	case n < 100:
+		if n%10 == 0 {
+			return englishTens[n/10]
+		}
+		return englishTens[n/10] + "-" + englishOnes[n%10]
+	case n < 1000:
+		if n%100 == 0 {
+			return englishOnes[n/100] + " hundred"
+		}
+		return englishOnes[n/100] + " hundred and " + spellEnglish(n%100)
+	}
+	return strconv.Itoa(n)
+}
+
+// getDescriptionHandler handles GET /weather/description requests, returning
+// the forecast as a few verbose, fully punctuated plain-text sentences for
+// screen readers and voice assistants, e.g. "Today in London: fourteen degrees
+// Celsius, partially cloudy. Expect a high of sixteen degrees and a low of
+// nine degrees. There is a sixty percent chance of rain in the afternoon."
+// Unlike /weather/text it is meant to be read out rather than glanced at. lang
+// selects the phrasing and the language of the conditions; units (metric, us
+// or uk) the temperature scale.
+func getDescriptionHandler(c *gin.Context) {
+	unitGroup := strings.ToLower(c.DefaultQuery("units", upstreamUnitGroup))
+	if !textUnitGroups[unitGroup] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "units must be one of: metric us uk"})
+		return
+	}
+	q, data, days, ok := loadWeather(c)
+	if !ok {
+		return
+	}
+	current, err := decodeCurrent(data)
+	if err != nil {
+		current = nil
+	}
+	lang := q.Lang
+	if lang == "" {
+		lang = defaultLang
+	}
+	text, err := renderDescription(q.Location, days, current, lang, unitGroup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to render description: %v", err)})
+		return
+	}
+	c.String(http.StatusOK, text+"\n")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpellEnglish(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{0, "zero"},
+		{7, "seven"},
+		{13, "thirteen"},
+		{20, "twenty"},
+		{21, "twenty-one"},
+		{99, "ninety-nine"},
+		{100, "one hundred"},
+		{115, "one hundred and fifteen"},
+		{340, "three hundred and forty"},
+		{-1, "minus one"},
+		{-21, "minus twenty-one"},
+		{1000, "1000"},
+		{-1500, "minus 1500"},
+	} {
+		if got := spellEnglish(tc.n); got != tc.want {
+			t.Errorf("spellEnglish(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestDescribeLocaleWords(t *testing.T) {
+	en, de := describeLocales["en"], describeLocales["de"]
+	for _, tc := range []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"en one", en.degreesOf(1), "one degree"},
+		{"en minus one", en.degreesOf(-1), "minus one degree"},
+		{"en zero", en.degreesOf(0), "zero degrees"},
+		{"en several", en.degreesOf(12), "twelve degrees"},
+		{"de digits", de.degreesOf(12), "12 Grad"},
+		{"en empty list", en.list(nil), ""},
+		{"en single", en.list([]string{"rain"}), "rain"},
+		{"en pair", en.list([]string{"rain", "snow"}), "rain and snow"},
+		{"en three", en.list([]string{"rain", "snow", "ice"}), "rain, snow and ice"},
+		{"de pair", de.list([]string{"Regen", "Schnee"}), "Regen und Schnee"},
+		{"en conditions", en.conditions("Partially cloudy"), "partially cloudy"},
+		{"de conditions", de.conditions("Teilweise bewölkt"), "Teilweise bewölkt"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestPrecipPeriods(t *testing.T) {
+	hour := func(datetime string, prob float64) weatherHour {
+		return weatherHour{Datetime: datetime, PrecipProb: &prob}
+	}
+	for _, tc := range []struct {
+		name  string
+		hours []weatherHour
+		want  []string
+	}{
+		{"no hours", nil, nil},
+		{"threshold", []weatherHour{hour("05:00:00", 30), hour("06:00:00", 29.9)}, []string{"night"}},
+		{"period starts", []weatherHour{hour("06:00:00", 50), hour("12:00:00", 50), hour("18:00:00", 50)}, []string{"morning", "afternoon", "evening"}},
+		{"in day order", []weatherHour{hour("21:00:00", 80), hour("09:00:00", 80), hour("10:00:00", 80)}, []string{"morning", "evening"}},
+		{"unknown probability", []weatherHour{{Datetime: "14:00:00"}}, nil},
+		{"unreadable hour", []weatherHour{hour("afternoon", 90)}, nil},
+	} {
+		if got := precipPeriods(tc.hours); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: precipPeriods = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRenderDescription(t *testing.T) {
+	prob := func(p float64) *float64 { return &p }
+	temp := -17.2
+	for _, tc := range []struct {
+		name, location, lang, unitGroup string
+		days                            []weatherDay
+		current                         *weatherCurrent
+		want                            string
+	}{
+		{"english with tomorrow", "London", "en", "metric", []weatherDay{
+			{Temp: 14, TempMax: 16.4, TempMin: 8.6, Conditions: "Partially cloudy", PrecipProb: 60, PrecipType: []string{"rain"},
+				Hours: []weatherHour{{Datetime: "13:00:00", PrecipProb: prob(70)}, {Datetime: "20:00:00", PrecipProb: prob(10)}}},
+			{TempMax: 18, TempMin: 10, Conditions: "Rain"},
+		}, nil, "Today in London: fourteen degrees Celsius, partially cloudy. Expect a high of sixteen degrees and a low of nine degrees. " +
+			"There is a sixty percent chance of rain in the afternoon. Tomorrow: a high of eighteen degrees and a low of ten degrees, rain."},
+		{"current conditions in fahrenheit", "Denver", "en", "us", []weatherDay{
+			{Temp: -10, TempMax: -15, TempMin: -20, Conditions: "Overcast", PrecipProb: 30, PrecipType: []string{"snow", "freezingrain"},
+				Hours: []weatherHour{
+					{Datetime: "02:00:00", PrecipProb: prob(40)},
+					{Datetime: "08:00:00", PrecipProb: prob(40)},
+					{Datetime: "14:00:00", PrecipProb: prob(40)},
+					{Datetime: "20:00:00", PrecipProb: prob(40)},
+				}},
+		}, &weatherCurrent{Temp: &temp, Conditions: "Snow"}, "Today in Denver: one degree Fahrenheit, snow. Expect a high of five degrees and a low of minus four degrees. " +
+			"There is a thirty percent chance of snow and freezing rain throughout the day."},
+		{"digits and unknown precipitation", "Berlin", "de", "metric", []weatherDay{
+			{Temp: 14, TempMax: 16, TempMin: 9, Conditions: "Teilweise bewölkt", PrecipProb: 60, PrecipType: []string{"hail"}},
+		}, nil, "Heute in Berlin: 14 Grad Celsius, Teilweise bewölkt. Höchstwert 16 Grad, Tiefstwert 9 Grad. Wahrscheinlichkeit für Niederschlag: 60 Prozent."},
+		{"unlikely precipitation in a fallback language", "Tokyo", "ja", "metric", []weatherDay{
+			{Temp: 20, TempMax: 25, TempMin: 15, Conditions: "晴れ", PrecipProb: 29, PrecipType: []string{"rain"}},
+		}, &weatherCurrent{Conditions: "曇り"}, "Today in Tokyo: twenty degrees Celsius, 晴れ. Expect a high of twenty-five degrees and a low of fifteen degrees."},
+	} {
+		got, err := renderDescription(tc.location, tc.days, tc.current, tc.lang, tc.unitGroup)
+		if err != nil {
+			t.Errorf("%s: renderDescription: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: renderDescription =\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Kinds of field differences reported by /admin/weather/diff.
+const (
+	diffChanged = "changed"
+	diffAdded   = "added"
+	diffRemoved = "removed"
+)
+
+// fieldDiff is one difference between cached and live weather data.
+type fieldDiff struct {
+	Path string      `json:"path"` // e.g. days[2026-10-14].tempmax
+	Kind string      `json:"kind"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// diffWeather compares old and new weather data field by field. Arrays of
+// objects that all have a datetime, like days and hours, are matched by it, so
+// a cached range that started a day earlier compares the days both have and
+// reports the others as added or removed; other arrays are matched by index.
+func diffWeather(old, new interface{}) []fieldDiff {
+	diffs := []fieldDiff{}
+	diffValue("", old, new, &diffs)
+	return diffs
+}
+
+// diffValue appends the differences between old and new at path to diffs.
+func diffValue(path string, old, new interface{}, diffs *[]fieldDiff) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			diffObjects(path, o, n, diffs)
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			diffArrays(path, o, n, diffs)
+			return
+		}
+	}
+	if !reflect.DeepEqual(old, new) {
+		*diffs = append(*diffs, fieldDiff{Path: path, Kind: diffChanged, Old: old, New: new})
+	}
+}
+
+// diffObjects diffs two objects key by key, in key order.
+func diffObjects(path string, old, new map[string]interface{}, diffs *[]fieldDiff) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+		o, inOld := old[k]
+		n, inNew := new[k]
+		diffEntry(child, o, inOld, n, inNew, diffs)
+	}
+}
+
+// diffEntry diffs the values at path in the old and new data, either of which
+// may be missing.
+func diffEntry(path string, o interface{}, inOld bool, n interface{}, inNew bool, diffs *[]fieldDiff) {
+	switch {
+	case !inNew:
+		*diffs = append(*diffs, fieldDiff{Path: path, Kind: diffRemoved, Old: o})
+	case !inOld:
+		*diffs = append(*diffs, fieldDiff{Path: path, Kind: diffAdded, New: n})
+	default:
+		diffValue(path, o, n, diffs)
+	}
+}
+
+// diffArrays diffs two arrays, matching elements by datetime when every element
+// of both has one and by index otherwise.
+func diffArrays(path string, old, new []interface{}, diffs *[]fieldDiff) {
+	oldByDate, okOld := byDatetime(old)
+	newByDate, okNew := byDatetime(new)
+	if okOld && okNew {
+		dates := make([]string, 0, len(oldByDate)+len(newByDate))
+		for d := range oldByDate {
+			dates = append(dates, d)
+		}
+		for d := range newByDate {
+			if _, ok := oldByDate[d]; !ok {
+				dates = append(dates, d)
+			}
+		}
+		sort.Strings(dates)
+		for _, d := range dates {
+			o, inOld := oldByDate[d]
+			n, inNew := newByDate[d]
+			diffEntry(path+"["+d+"]", o, inOld, n, inNew, diffs)
+		}
+		return
+	}
+
+	for i := 0; i < len(old) || i < len(new); i++ {
+		var o, n interface{}
+		if i < len(old) {
+			o = old[i]
+		}
+		if i < len(new) {
+			n = new[i]
+		}
+		diffEntry(path+"["+strconv.Itoa(i)+"]", o, i < len(old), n, i < len(new), diffs)
+	}
+}
+
+// byDatetime indexes an array of objects by their datetime, reporting false
+// when an element isn't an object with a unique string datetime.
+func byDatetime(values []interface{}) (map[string]interface{}, bool) {
+	out := make(map[string]interface{}, len(values))
+	for _, v := range values {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		d, ok := obj["datetime"].(string)
+		if _, dup := out[d]; !ok || dup {
+			return nil, false
+		}
+		out[d] = obj
+	}
+	return out, true
+}
+
+// weatherDiffHandler handles GET /admin/weather/diff, comparing the cached data
+// of a query with a live upstream fetch to show how stale the entry has become.
+// It takes the query parameters of the weather endpoints plus endpoint (default
+// /weather), whose include set and upstream route select the cache entry. The
+// live data is never written to the cache.
+func weatherDiffHandler(c *gin.Context) {
+	var p queryParams
+	if !bindQuery(c, &p) {
+		return
+	}
+	endpoint := c.DefaultQuery("endpoint", "/weather")
+	if _, ok := defaultEndpointIncludes[endpoint]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown endpoint %q", endpoint)})
+		return
+	}
+	q, ok := buildWeatherQueryFor(c, p, endpoint)
+	if !ok {
+		return
+	}
+
+	key := q.cacheKey()
+	raw, err := cacheGet(ctx, key)
+	if err == nil && cacheReferences() {
+		raw, err = followCacheRef(ctx, raw)
+	}
+	if err == redis.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "nothing is cached for this query"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	cached, cachedAt, err := decodeEntry(raw)
+	if err != nil || cached == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the cache entry for this query holds no readable weather data"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var live map[string]interface{}
+	var info upstreamInfo
+	err = upstreamQueue.do(ctx, func() error {
+		var err error
+		live, info, err = fetchWeatherData(ctx, q)
+		return err
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	diffs := diffWeather(cached, live)
+	body := gin.H{
+		"location":      q.Location,
+		"key":           q.canonicalKey(),
+		"liveFetchedAt": info.Fetched.UTC().Format(time.RFC3339),
+		"changed":       len(diffs),
+		"changes":       diffs,
+	}
+	if !cachedAt.IsZero() {
+		body["cachedAt"] = cachedAt.UTC().Format(time.RFC3339)
+		body["ageSeconds"] = int64(info.Fetched.Sub(cachedAt).Seconds())
+	}
+	if ttl, err := cacheTTL(ctx, key); err == nil && ttl > 0 {
+		body["ttlSeconds"] = int64(ttl.Seconds())
+	}
+	c.JSON(http.StatusOK, body)
+}
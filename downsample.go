@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// maxDownsamplePoints bounds the downsample parameter.
+const maxDownsamplePoints = 1000
+
+// parseDownsample reads the downsample parameter, the number of points a series
+// is reduced to; zero means it wasn't given.
+func parseDownsample(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 2 || n > maxDownsamplePoints {
+		return 0, fmt.Errorf("downsample must be an integer between 2 and %d", maxDownsamplePoints)
+	}
+	return n, nil
+}
+
+// downsampleIndices picks n representative points of the evenly spaced series
+// ys with the largest-triangle-three-buckets algorithm, returning their indices
+// in order. The first and last points are always kept; the points between are
+// split into n-2 buckets and from each the point forming the largest triangle
+// with the previously kept point and the mean of the next bucket is kept, which
+// preserves peaks and troughs where plain averaging would flatten them. Series
+// of at most n points are kept whole.
+func downsampleIndices(ys []float64, n int) []int {
+	if n >= len(ys) || n < 2 {
+		all := make([]int, len(ys))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+	kept := make([]int, 0, n)
+	kept = append(kept, 0)
+	every := float64(len(ys)-2) / float64(n-2)
+	a := 0
+	for i := 0; i < n-2; i++ {
+		// Mean of the next bucket; the last bucket's successor is the last point.
+		nextStart := int(float64(i+1)*every) + 1
+		nextEnd := int(float64(i+2)*every) + 1
+		if nextEnd > len(ys) {
+			nextEnd = len(ys)
+		}
+		var avgX, avgY float64
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += float64(j)
+			avgY += ys[j]
+		}
+		count := float64(nextEnd - nextStart)
+		avgX, avgY = avgX/count, avgY/count
+
+		start, end := int(float64(i)*every)+1, int(float64(i+1)*every)+1
+		best, bestArea := start, -1.0
+		for j := start; j < end; j++ {
+			area := math.Abs((float64(a)-avgX)*(ys[j]-ys[a]) - (float64(a)-float64(j))*(avgY-ys[a]))
+			if area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+		kept = append(kept, best)
+		a = best
+	}
+	return append(kept, len(ys)-1)
+}
+
+// seriesValues returns the values of a series with gaps filled by the last
+// known value (the first known one for leading gaps, zero for an empty series),
+// so samples without a value can still be placed by downsampleIndices.
+func seriesValues(values []*float64) []float64 {
+	ys := make([]float64, len(values))
+	last := 0.0
+	for _, v := range values {
+		if v != nil {
+			last = *v
+			break
+		}
+	}
+	for i, v := range values {
+		if v != nil {
+			last = *v
+		}
+		ys[i] = last
+	}
+	return ys
+}
+
+// applyDownsample reduces the days of /weather data to about n representative
+// days, chosen by their mean temperature.
+func applyDownsample(data map[string]interface{}, n int) {
+	days, ok := data["days"].([]interface{})
+	if !ok || len(days) <= n {
+		return
+	}
+	temps := make([]*float64, len(days))
+	for i, d := range days {
+		if day, ok := d.(map[string]interface{}); ok {
+			if t, ok := day["temp"].(float64); ok {
+				temps[i] = &t
+			}
+		}
+	}
+	kept := make([]interface{}, 0, n)
+	for _, i := range downsampleIndices(seriesValues(temps), n) {
+		kept = append(kept, days[i])
+	}
+	data["days"] = kept
+}
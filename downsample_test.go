@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDownsample(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want int
+		ok   bool
+	}{
+		{"", 0, true},
+		{"2", 2, true},
+		{"1000", 1000, true},
+		{"1", 0, false},
+		{"1001", 0, false},
+		{"-5", 0, false},
+		{"ten", 0, false},
+	} {
+		got, err := parseDownsample(tc.raw)
+		if got != tc.want || (err == nil) != tc.ok {
+			t.Errorf("parseDownsample(%q) = %d, %v; want %d, ok %v", tc.raw, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDownsampleIndices(t *testing.T) {
+	series := make([]float64, 100)
+	series[37], series[71] = 30, -20 // a peak and a trough in flat data
+
+	for _, tc := range []struct {
+		name  string
+		ys    []float64
+		n     int
+		want  int   // number of indices
+		keeps []int // indices that must survive
+	}{
+		{"peaks kept", series, 10, 10, []int{0, 37, 71, 99}},
+		{"minimum", series, 2, 2, []int{0, 99}},
+		{"short series whole", []float64{1, 2, 3}, 5, 3, []int{0, 1, 2}},
+		{"exact length whole", []float64{1, 2, 3}, 3, 3, []int{0, 1, 2}},
+		{"empty", nil, 5, 0, nil},
+	} {
+		got := downsampleIndices(tc.ys, tc.n)
+		if len(got) != tc.want {
+			t.Errorf("%s: %d indices, want %d", tc.name, len(got), tc.want)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i] <= got[i-1] {
+				t.Errorf("%s: indices %v not increasing", tc.name, got)
+				break
+			}
+		}
+		kept := make(map[int]bool)
+		for _, i := range got {
+			kept[i] = true
+		}
+		for _, i := range tc.keeps {
+			if !kept[i] {
+				t.Errorf("%s: index %d dropped from %v", tc.name, i, got)
+			}
+		}
+	}
+}
+
+func TestSeriesValues(t *testing.T) {
+	num := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		in   []*float64
+		want []float64
+	}{
+		{nil, []float64{}},
+		{[]*float64{nil, nil}, []float64{0, 0}},
+		{[]*float64{nil, num(3), nil, num(5), nil}, []float64{3, 3, 3, 5, 5}},
+		{[]*float64{num(1), num(2)}, []float64{1, 2}},
+	} {
+		if got := seriesValues(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("seriesValues = %v, want %v", got, tc.want)
+		}
+	}
+}
+
+func TestApplyDownsample(t *testing.T) {
+	var days []interface{}
+	for i := 0; i < 15; i++ {
+		temp := 10.0
+		if i == 6 {
+			temp = 25
+		}
+		days = append(days, map[string]interface{}{"datetime": i, "temp": temp})
+	}
+	data := map[string]interface{}{"days": days}
+	applyDownsample(data, 5)
+
+	var got []interface{}
+	for _, d := range data["days"].([]interface{}) {
+		got = append(got, d.(map[string]interface{})["datetime"])
+	}
+	if len(got) != 5 || got[0] != 0 || got[4] != 14 || !containsValue(got, 6) {
+		t.Errorf("kept days %v, want 5 including the first, last and the warm day 6", got)
+	}
+
+	short := map[string]interface{}{"days": days[:3]}
+	applyDownsample(short, 5)
+	if len(short["days"].([]interface{})) != 3 {
+		t.Error("short series downsampled")
+	}
+}
+
+// containsValue reports whether vs holds v.
+func containsValue(vs []interface{}, v interface{}) bool {
+	for _, x := range vs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiError is an error that maps onto a specific HTTP response, carrying a
+// machine-readable code alongside the human-readable message.
+type apiError struct {
+	Status         int
+	Code           string
+	Message        string
+	RetryAfter     time.Duration
+	UpstreamStatus int // non-200 upstream status the error reports, if any
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// errUpstreamEmpty is returned when the upstream answers 200 without any usable
+// weather data.
+var errUpstreamEmpty = &apiError{
+	Status:  http.StatusBadGateway,
+	Code:    "UPSTREAM_EMPTY",
+	Message: "upstream weather API returned no weather data for this location",
+}
+
+// errUpstreamTooLarge is returned when the upstream body exceeds
+// MAX_UPSTREAM_RESPONSE_BYTES.
+var errUpstreamTooLarge = &apiError{
+	Status:  http.StatusBadGateway,
+	Code:    "UPSTREAM_TOO_LARGE",
+	Message: "upstream weather API response exceeds the size limit",
+}
+
+// errUpstreamMalformed is returned when the upstream body is not valid JSON.
+var errUpstreamMalformed = &apiError{
+	Status:  http.StatusBadGateway,
+	Code:    "UPSTREAM_MALFORMED",
+	Message: "upstream weather API returned a malformed response",
+}
+
+// malformedLogBytes caps how much of a malformed upstream body is logged.
+const malformedLogBytes = 512
+
+// truncateBytes returns at most n bytes of b.
+func truncateBytes(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
+
+// statusClientClosedRequest is the de-facto (nginx) status for a request the
+// client abandoned before it was answered.
+const statusClientClosedRequest = 499
+
+// writeError renders err as a JSON error response. apiErrors use their own status
+// and code; a cancelled request context becomes 499 and an expired one 504;
+// anything else is reported as a 500.
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The client is gone; nobody will read the body.
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "timed out waiting for the upstream weather API"})
+		return
+	}
+
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if ae.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(ae.RetryAfter.Round(time.Second).Seconds())))
+	}
+	body := gin.H{"error": ae.Message}
+	if ae.Code != "" {
+		body["code"] = ae.Code
+	}
+	c.JSON(ae.Status, body)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// eventPublishTimeout bounds a single event publish so slow Redis never piles up
+// goroutines.
+const eventPublishTimeout = time.Second
+
+// weatherEvent is the analytics event published for every weather lookup.
+type weatherEvent struct {
+	Location  string    `json:"location"`
+	Cache     string    `json:"cache"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// publishEvent publishes a lookup event to EVENTS_CHANNEL when events are enabled.
+// It never blocks the caller; failures are only logged.
+func publishEvent(q weatherQuery, cache string) {
+	if !cfg.EventsEnabled {
+		return
+	}
+	payload, err := json.Marshal(weatherEvent{Location: q.Location, Cache: cache, Timestamp: clock.Now().UTC()})
+	if err != nil {
+		log.Printf("Error encoding weather event: %v", err)
+		return
+	}
+	go func() {
+		pctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+		defer cancel()
+		if err := redisClient.Publish(pctx, cfg.EventsChannel, payload).Err(); err != nil {
+			log.Printf("Error publishing weather event: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// failoverClient is an optional Redis in another region, set with
+// REDIS_FAILOVER_URL, that cache operations fall back to while the primary
+// fails. It isn't sharded: every cache entry lives in its one database.
+var failoverClient *redis.Client
+
+// cacheOnFailover reports whether the last cache operation had to use the
+// failover client, i.e. which region is active; cacheFailovers counts the
+// switches to it.
+var (
+	cacheOnFailover atomic.Bool
+	cacheFailovers  atomic.Int64
+)
+
+// failoverEligible reports whether a failed primary cache operation should be
+// retried against the failover client: misses and lost WATCH races are answers
+// from a working primary, and a cancelled or expired request context is the
+// caller's doing; anything else (connection errors, timeouts, an open breaker)
+// is not.
+func failoverEligible(err error) bool {
+	return failoverClient != nil && err != nil && err != redis.Nil && err != redis.TxFailedErr && !isContextError(err)
+}
+
+// useFailover notes that a cache operation failed over because of cause,
+// logging the switch when the primary was active until now.
+func useFailover(cause error) {
+	if cacheOnFailover.CompareAndSwap(false, true) {
+		cacheFailovers.Add(1)
+		log.Printf("Redis primary failed, cache operations fail over to REDIS_FAILOVER_URL: %v", cause)
+	}
+}
+
+// primarySucceeded notes a cache operation the primary answered, logging the
+// switch back when the failover was active until now.
+func primarySucceeded() {
+	if cacheOnFailover.Load() && cacheOnFailover.CompareAndSwap(true, false) {
+		log.Printf("Redis primary answered again, cache operations use it")
+	}
+}
+
+// dualWrite mirrors a successful primary cache write to the failover client
+// with CACHE_DUAL_WRITE, so it has the entries should the primary fail. A
+// failed mirror write is only logged.
+func dualWrite(key string, write func(client *redis.Client) error) {
+	if failoverClient == nil || !cfg.CacheDualWrite {
+		return
+	}
+	if err := write(failoverClient); err != nil {
+		log.Printf("Error mirroring cache write of %s to the failover Redis: %v", key, err)
+	}
+}
+
+// failoverReport describes the failover region for /health, nil without one.
+func failoverReport() gin.H {
+	if failoverClient == nil {
+		return nil
+	}
+	active := "primary"
+	if cacheOnFailover.Load() {
+		active = "failover"
+	}
+	return gin.H{"active": active, "failovers": cacheFailovers.Load(), "dualWrite": cfg.CacheDualWrite}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+// fakeUpstreamFixture is the canned weather of the fake upstream: location
+// fields, current conditions and a few template days that are repeated over the
+// requested range.
+//
+//go:embed fixtures/upstream.json
+var fakeUpstreamFixture []byte
+
+// fakeUpstreamFailure is a canned failure of the fake upstream.
+type fakeUpstreamFailure struct {
+	status int
+	body   string
+}
+
+// fakeUpstreamFailures are the locations for which the fake upstream fails
+// instead of returning weather. Every other location gets the fixture.
+var fakeUpstreamFailures = map[string]fakeUpstreamFailure{
+	"notfound":    {http.StatusNotFound, "Bad API Request:Invalid location parameter value."},
+	"ratelimited": {http.StatusTooManyRequests, "Too many requests, please slow down."},
+	"servererror": {http.StatusInternalServerError, "Internal server error"},
+	"malformed":   {http.StatusOK, `{"resolvedAddress": "Malformed", "days": [`},
+	"empty":       {http.StatusOK, `{}`},
+}
+
+// fakeUpstreamDays is the number of days returned for undated queries and
+// dynamic periods other than today and next24hours.
+const fakeUpstreamDays = 15
+
+// newFakeUpstream starts an in-memory stand-in for the Visual Crossing timeline
+// API, used with UPSTREAM_FAKE=true. Pointing cfg.APIURL at its URL runs
+// lookups end to end, handler to cache to upstream, without an API key or
+// network access. Tests pass fakeUpstreamHandler to setupTest instead.
+func newFakeUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(fakeUpstreamHandler))
+}
+
+// fakeUpstreamHandler answers timeline requests, {location}[/{start}[/{end}]]
+// or {location}/{period}, from the fixture. Days are dated from the requested
+// start (today by default) and hourly values are derived from each day's
+// minimum and maximum. Only the sections in the include parameter are returned,
+// gzipped when the request accepts it.
+func fakeUpstreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("key") == "" {
+		http.Error(w, "No API key or session found.", http.StatusUnauthorized)
+		return
+	}
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	location := segments[0]
+	if failure, ok := fakeUpstreamFailures[strings.ToLower(location)]; ok {
+		w.WriteHeader(failure.status)
+		w.Write([]byte(failure.body))
+		return
+	}
+
+	start, n, ok := fakeUpstreamRange(segments[1:], clock.Now())
+	if !ok {
+		http.Error(w, "Bad API Request:Invalid date parameter value.", http.StatusBadRequest)
+		return
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(fakeUpstreamFixture, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data["address"] = location
+	if !strings.EqualFold(location, "london") {
+		data["resolvedAddress"] = location
+	}
+
+	include := parseSet(r.URL.Query().Get("include"))
+	all := len(include) == 0
+	templates, _ := data["days"].([]interface{})
+	delete(data, "days")
+	if (all || include["days"] || include["hours"]) && len(templates) > 0 {
+		days := make([]interface{}, n)
+		for i := range days {
+			day := fakeUpstreamDay(templates[i%len(templates)], start.AddDate(0, 0, i), all || include["hours"])
+			if r.URL.Query().Get("elements") == agriElements {
+				fakeAgriElements(day)
+			}
+			days[i] = day
+		}
+		data["days"] = days
+	}
+	if !all && !include["current"] {
+		delete(data, "currentConditions")
+	}
+	if !all && !include["alerts"] {
+		delete(data, "alerts")
+	}
+
+	// Like Visual Crossing, compress the weather for clients that accept gzip.
+	w.Header().Set("Content-Type", "application/json")
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	json.NewEncoder(out).Encode(data)
+}
+
+// fakeUpstreamRange returns the first day and number of days of a request from
+// the path segments after the location.
+func fakeUpstreamRange(segments []string, now time.Time) (time.Time, int, bool) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	if len(segments) == 0 {
+		return today, fakeUpstreamDays, true
+	}
+	switch segments[0] {
+	case "today":
+		return today, 1, true
+	case "next24hours":
+		return today, 2, true
+	}
+	start, err := time.Parse(dateLayout, segments[0])
+	if err != nil {
+		// Any other dynamic period.
+		return today, fakeUpstreamDays, true
+	}
+	end := start
+	if len(segments) > 1 {
+		if end, err = time.Parse(dateLayout, segments[1]); err != nil || end.Before(start) {
+			return time.Time{}, 0, false
+		}
+	}
+	return start, int(end.Sub(start)/(24*time.Hour)) + 1, true
+}
+
+// fakeUpstreamDay dates a copy of a template day and, with hours, adds hourly
+// temperatures following a daily curve from tempmin at 04:00 to tempmax at 16:00.
+func fakeUpstreamDay(template interface{}, date time.Time, hours bool) map[string]interface{} {
+	src, _ := template.(map[string]interface{})
+	day := make(map[string]interface{}, len(src)+3)
+	for k, v := range src {
+		day[k] = v
+	}
+	day["datetime"] = date.Format(dateLayout)
+	day["datetimeEpoch"] = date.Unix()
+	if !hours {
+		return day
+	}
+	tempMin, _ := day["tempmin"].(float64)
+	tempMax, _ := day["tempmax"].(float64)
+	list := make([]interface{}, 24)
+	for h := range list {
+		curve := (1 - math.Cos(2*math.Pi*float64(h-4)/24)) / 2
+		list[h] = map[string]interface{}{
+			"datetime":      time.Date(0, 1, 1, h, 0, 0, 0, time.UTC).Format("15:04:05"),
+			"datetimeEpoch": date.Add(time.Duration(h) * time.Hour).Unix(),
+			"temp":          math.Round((tempMin+(tempMax-tempMin)*curve)*10) / 10,
+			"precipprob":    day["precipprob"],
+			"windgust":      day["windgust"],
+			"conditions":    day["conditions"],
+			"icon":          day["icon"],
+		}
+	}
+	day["hours"] = list
+	return day
+}
+
+// startFakeUpstream points the configuration at a new fake upstream and returns
+// a function stopping it.
+func startFakeUpstream(c *Config) func() {
+	fake := newFakeUpstream()
+	c.APIURL = fake.URL
+	log.Printf("WARNING: UPSTREAM_FAKE is set, serving canned weather from %s", fake.URL)
+	return fake.Close
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFakeUpstreamEndToEnd runs /weather through the cache to the fake
+// upstream, as UPSTREAM_FAKE=true does, for the fixture and each canned
+// failure.
+func TestFakeUpstreamEndToEnd(t *testing.T) {
+	for _, tc := range []struct {
+		location string
+		status   int
+		code     string
+		cached   bool
+	}{
+		{"London", http.StatusOK, "", true},
+		{"notfound", http.StatusNotFound, "UPSTREAM_REJECTED", true},
+		{"ratelimited", http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", false},
+		{"servererror", http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", false},
+		{"malformed", http.StatusBadGateway, "UPSTREAM_MALFORMED", false},
+		{"empty", http.StatusBadGateway, "UPSTREAM_EMPTY", false},
+	} {
+		c := testConfig(t)
+		c.UpstreamRetries = 0
+		mr := setupTest(t, c, http.HandlerFunc(fakeUpstreamHandler))
+		key := weatherQuery{Location: strings.ToLower(tc.location)}.cacheKey()
+
+		w := requestWeather("location=" + tc.location)
+		if w.Code != tc.status {
+			t.Errorf("%s: got %d %s, want %d", tc.location, w.Code, w.Body, tc.status)
+			continue
+		}
+		if tc.code != "" {
+			if code := errorCode(t, w); code != tc.code {
+				t.Errorf("%s: code %q, want %q", tc.location, code, tc.code)
+			}
+		}
+		if mr.Exists(key) != tc.cached {
+			t.Errorf("%s: cached %v, want %v", tc.location, !tc.cached, tc.cached)
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+
+		var body struct {
+			ResolvedAddress string        `json:"resolvedAddress"`
+			Days            []interface{} `json:"days"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decoding %q: %v", tc.location, w.Body, err)
+		}
+		if len(body.Days) != fakeUpstreamDays {
+			t.Errorf("%s: %d days, want %d", tc.location, len(body.Days), fakeUpstreamDays)
+		}
+		if w.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("%s: first lookup X-Cache %q, want MISS", tc.location, w.Header().Get("X-Cache"))
+		}
+		if again := requestWeather("location=" + tc.location); again.Header().Get("X-Cache") != "HIT" {
+			t.Errorf("%s: second lookup X-Cache %q, want HIT", tc.location, again.Header().Get("X-Cache"))
+		}
+	}
+}
+
+func TestFakeUpstreamRange(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	today := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		segments []string
+		start    time.Time
+		n        int
+		ok       bool
+	}{
+		{nil, today, fakeUpstreamDays, true},
+		{[]string{"today"}, today, 1, true},
+		{[]string{"next24hours"}, today, 2, true},
+		{[]string{"next7days"}, today, fakeUpstreamDays, true},
+		{[]string{"2026-10-20"}, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), 1, true},
+		{[]string{"2026-10-30", "2026-11-02"}, time.Date(2026, 10, 30, 0, 0, 0, 0, time.UTC), 4, true},
+		{[]string{"2026-10-20", "2026-10-19"}, time.Time{}, 0, false},
+		{[]string{"2026-10-20", "later"}, time.Time{}, 0, false},
+	} {
+		start, n, ok := fakeUpstreamRange(tc.segments, now)
+		if !start.Equal(tc.start) || n != tc.n || ok != tc.ok {
+			t.Errorf("fakeUpstreamRange(%q) = %s, %d, %v; want %s, %d, %v", tc.segments, start, n, ok, tc.start, tc.n, tc.ok)
+		}
+	}
+}
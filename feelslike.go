@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// windChillC returns the wind chill in °C for an air temperature in °C and wind
+// speed in km/h, using the North American (Environment Canada / NWS) formula.
+func windChillC(tempC, windKmh float64) float64 {
+	v := math.Pow(windKmh, 0.16)
+	return 13.12 + 0.6215*tempC - 11.37*v + 0.3965*tempC*v
+}
+
+// feelsLikeC estimates the apparent temperature in °C: wind chill when it is cold
+// (at most 10°C) and windy (over 4.8 km/h), the heat index when it is hot (at
+// least 26.7°C, i.e. 80°F) and the humidity is known, and the air temperature
+// otherwise. wind and rh may be nil when the upstream omitted them.
+func feelsLikeC(tempC float64, wind, rh *float64) float64 {
+	switch {
+	case tempC <= 10 && wind != nil && *wind > 4.8:
+		return windChillC(tempC, *wind)
+	case tempC >= 26.7 && rh != nil:
+		return heatIndexC(tempC, *rh)
+	}
+	return tempC
+}
+
+// feelsLikeFields pairs each apparent-temperature field with the temperature
+// field it is derived from.
+var feelsLikeFields = [][2]string{
+	{"feelslike", "temp"},
+	{"feelslikemax", "tempmax"},
+	{"feelslikemin", "tempmin"},
+}
+
+// temperatureC converts a temperature in the units of a Visual Crossing unit
+// group to °C: us reports °F and base kelvin. Unknown unit groups are taken as
+// metric, as in windSpeedKmh.
+func temperatureC(t float64, unitGroup string) float64 {
+	switch strings.ToLower(unitGroup) {
+	case "us":
+		return (t - 32) * 5 / 9
+	case "base":
+		return t - 273.15
+	}
+	return t
+}
+
+// fillFeelsLike computes feelslike, feelslikemax and feelslikemin for every day
+// and the current conditions when the upstream omitted them but the matching
+// temperature is present. The data is in the units of unitGroup, so the
+// temperature and wind speed are converted to °C and km/h for feelsLikeC and
+// the result back. Upstream values are kept as they are.
+func fillFeelsLike(data map[string]interface{}, unitGroup string) {
+	forEachPeriod(data, func(period map[string]interface{}) {
+		wind := numberField(period, "windspeed")
+		if wind != nil {
+			kmh := windSpeedKmh(*wind, unitGroup)
+			wind = &kmh
+		}
+		rh := numberField(period, "humidity")
+		for _, f := range feelsLikeFields {
+			if _, ok := period[f[0]].(float64); ok {
+				continue
+			}
+			if temp := numberField(period, f[1]); temp != nil {
+				feelsLike := feelsLikeC(temperatureC(*temp, unitGroup), wind, rh)
+				period[f[0]] = math.Round(temperatureIn(feelsLike, unitGroup)*10) / 10
+			}
+		}
+	})
+}
+
+// numberField returns the numeric field name of m, or nil when it is missing or
+// not a number.
+func numberField(m map[string]interface{}, name string) *float64 {
+	if v, ok := m[name].(float64); ok {
+		return &v
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFillFeelsLikeUnitGroups(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		unitGroup string
+		day       map[string]interface{}
+		want, tol float64
+	}{
+		// 85°F at 60% humidity is a heat index of about 90°F, not the 85°C the
+		// metric formula would see.
+		{"us heat", "us", map[string]interface{}{"temp": 85.0, "humidity": 60.0}, 90, 1},
+		// 23°F is -5°C; a 20 mph (32.2 km/h) wind makes it about 8°F.
+		{"us cold", "us", map[string]interface{}{"temp": 23.0, "windspeed": 20.0}, 8, 1},
+		// uk reports °C but mph: 2.5 mph is under the 4.8 km/h wind chill threshold.
+		{"uk light wind", "uk", map[string]interface{}{"temp": 5.0, "windspeed": 2.5}, 5, 0},
+		{"uk windy", "uk", map[string]interface{}{"temp": 5.0, "windspeed": 20.0}, 0, 1},
+		// 300 K is 26.85°C, in heat index range with the humidity known.
+		{"base heat", "base", map[string]interface{}{"temp": 300.0, "humidity": 80.0}, 303, 1.5},
+		{"metric mild", "metric", map[string]interface{}{"temp": 18.0, "windspeed": 30.0, "humidity": 50.0}, 18, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fillFeelsLike(map[string]interface{}{"days": []interface{}{tc.day}}, tc.unitGroup)
+			got, ok := tc.day["feelslike"].(float64)
+			if !ok {
+				t.Fatalf("feelslike not filled: %v", tc.day)
+			}
+			if got < tc.want-tc.tol || got > tc.want+tc.tol {
+				t.Errorf("feelslike = %v, want %v ± %v", got, tc.want, tc.tol)
+			}
+		})
+	}
+}
+
+// fahrenheitToC converts the NWS's °F reference values for comparison.
+func fahrenheitToC(f float64) float64 { return (f - 32) * 5 / 9 }
+
+func TestWindChillC(t *testing.T) {
+	// Values from the Environment Canada wind chill table.
+	for _, tc := range []struct{ temp, wind, want float64 }{
+		{5, 5, 4},
+		{0, 10, -3},
+		{-10, 20, -18},
+		{-20, 30, -33},
+		{-30, 60, -50},
+	} {
+		if got := windChillC(tc.temp, tc.wind); math.Abs(got-tc.want) > 0.5 {
+			t.Errorf("windChillC(%v, %v) = %.2f, want %v", tc.temp, tc.wind, got, tc.want)
+		}
+	}
+}
+
+func TestHeatIndexC(t *testing.T) {
+	// Values in °F from the NWS heat index chart.
+	for _, tc := range []struct{ tempF, rh, wantF float64 }{
+		{80, 40, 80},
+		{90, 50, 95},
+		{100, 40, 109},
+		{86, 90, 105},
+		{96, 65, 121},
+	} {
+		got := heatIndexC(fahrenheitToC(tc.tempF), tc.rh)
+		if want := fahrenheitToC(tc.wantF); math.Abs(got-want) > fahrenheitToC(33)-fahrenheitToC(32) {
+			t.Errorf("heatIndexC(%v°F, %v%%) = %.1f°F, want %v°F", tc.tempF, tc.rh, got*9/5+32, tc.wantF)
+		}
+	}
+}
+
+func TestFeelsLikeC(t *testing.T) {
+	num := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		name     string
+		temp     float64
+		wind, rh *float64
+		want     float64
+	}{
+		{"cold and windy", 10, num(20), nil, windChillC(10, 20)},
+		{"just too warm for wind chill", 10.5, num(20), nil, 10.5},
+		{"wind at the threshold", 5, num(4.8), nil, 5},
+		{"wind unknown", -5, nil, num(50), -5},
+		{"hot and humid", 30, nil, num(50), heatIndexC(30, 50)},
+		{"hot at the threshold", 26.7, num(20), num(50), heatIndexC(26.7, 50)},
+		{"just too cool for heat index", 26.6, nil, num(90), 26.6},
+		{"humidity unknown", 35, num(10), nil, 35},
+		{"mild", 18, num(30), num(50), 18},
+	} {
+		if got := feelsLikeC(tc.temp, tc.wind, tc.rh); got != tc.want {
+			t.Errorf("%s: feelsLikeC = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFillFeelsLikeOnlyWhenMissing(t *testing.T) {
+	day := map[string]interface{}{
+		"temp": -5.0, "tempmax": -2.0, "windspeed": 20.0,
+		"feelslike": 1.5, // upstream value, kept even though it disagrees
+		// feelslikemax missing: filled from tempmax
+		// tempmin missing: feelslikemin stays missing
+	}
+	current := map[string]interface{}{"temp": "n/a", "feelslikemin": nil, "tempmin": 30.0, "humidity": 50.0}
+	data := map[string]interface{}{"days": []interface{}{day}, "currentConditions": current}
+
+	fillFeelsLike(data, "metric")
+
+	if day["feelslike"] != 1.5 {
+		t.Errorf("upstream feelslike replaced by %v", day["feelslike"])
+	}
+	if want := math.Round(windChillC(-2, 20)*10) / 10; day["feelslikemax"] != want {
+		t.Errorf("feelslikemax = %v, want %v", day["feelslikemax"], want)
+	}
+	if _, ok := day["feelslikemin"]; ok {
+		t.Errorf("feelslikemin filled without a tempmin: %v", day["feelslikemin"])
+	}
+	if _, ok := current["feelslike"]; ok {
+		t.Errorf("feelslike filled from a non-numeric temp: %v", current["feelslike"])
+	}
+	if want := math.Round(heatIndexC(30, 50)*10) / 10; current["feelslikemin"] != want {
+		t.Errorf("null feelslikemin = %v, want it filled with %v", current["feelslikemin"], want)
+	}
+}
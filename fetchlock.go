@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fetchLockPrefix prefixes the Redis keys of FETCH_LOCK_TTL fetch locks, which
+// are followed by the cache key they guard.
+const fetchLockPrefix = "fetchlock:"
+
+// fetchLockPoll is how often a request waiting on another instance's fetch
+// looks for its result in the cache.
+const fetchLockPoll = 100 * time.Millisecond
+
+// Fetch lock counters, reported by /stats.
+var (
+	fetchLockWaited   atomic.Int64 // misses served by another instance's fetch
+	fetchLockTimeouts atomic.Int64
+)
+
+// errFetchLockTimeout is returned to a miss that waited FETCH_LOCK_WAIT for
+// another instance's fetch of the same query without the result showing up.
+var errFetchLockTimeout = &apiError{
+	Status:     http.StatusServiceUnavailable,
+	Code:       "FETCH_IN_PROGRESS",
+	Message:    "this weather data is being fetched by another request, try again shortly",
+	RetryAfter: time.Second,
+}
+
+// releaseFetchLockScript deletes the lock at KEYS[1] if it still holds the
+// token ARGV[1], so a fetch outlasting its lock never releases the next
+// holder's.
+var releaseFetchLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// lockedFetchAndCache is fetchAndCache with misses for the same key across all
+// instances sharing one upstream call, see FETCH_LOCK_TTL: the instance taking
+// the key's lock in Redis fetches, the others poll the cache for its result
+// for up to FETCH_LOCK_WAIT and then fail with errFetchLockTimeout. A lock
+// left by an instance that died expires after FETCH_LOCK_TTL, and the next
+// waiter to take it fetches itself. Only entries fetched within the last
+// FETCH_LOCK_TTL count as the holder's result, so a cached entry being
+// refreshed isn't mistaken for it. If Redis can't be asked, the request
+// fetches without a lock. Without FETCH_LOCK_TTL only the requests of one
+// instance share fetches, through coalescedFetchAndCache.
+func lockedFetchAndCache(ctx context.Context, q weatherQuery, cacheKey string) (map[string]interface{}, upstreamInfo, error) {
+	if cfg.FetchLockTTL <= 0 || redisClient == nil {
+		return fetchAndCache(ctx, q, cacheKey)
+	}
+	lockKey := fetchLockPrefix + cacheKey
+	token := newRequestID()
+	wctx, cancel := context.WithTimeout(ctx, cfg.FetchLockWait)
+	defer cancel()
+	for {
+		acquired, err := redisClient.SetNX(ctx, lockKey, token, cfg.FetchLockTTL).Result()
+		if err != nil {
+			log.Printf("Error taking the fetch lock for %s, fetching without it: %v", q.Location, err)
+			return fetchAndCache(ctx, q, cacheKey)
+		}
+		if acquired {
+			defer func() {
+				// Released even when the request was cancelled meanwhile.
+				if err := releaseFetchLockScript.Run(context.Background(), redisClient, []string{lockKey}, token).Err(); err != nil {
+					log.Printf("Error releasing the fetch lock for %s: %v", q.Location, err)
+				}
+			}()
+			return fetchAndCache(ctx, q, cacheKey)
+		}
+
+		select {
+		case <-wctx.Done():
+			if ctx.Err() != nil {
+				return nil, upstreamInfo{}, ctx.Err()
+			}
+			fetchLockTimeouts.Add(1)
+			return nil, upstreamInfo{}, errFetchLockTimeout
+		case <-time.After(fetchLockPoll):
+		}
+		raw, err := cacheGet(ctx, cacheKey)
+		if err == nil && cacheReferences() {
+			raw, err = followCacheRef(ctx, raw)
+		}
+		if err == redis.Nil {
+			// Not fetched yet; if the holder is gone, the next round takes over.
+			continue
+		}
+		if err != nil {
+			log.Printf("Error reading the cache while waiting on the fetch lock for %s, fetching: %v", q.Location, err)
+			return fetchAndCache(ctx, q, cacheKey)
+		}
+		data, fetchedAt, err := decodeEntry(raw)
+		var cachedErr *apiError
+		if err != nil && !errors.As(err, &cachedErr) {
+			// An outdated or unreadable entry the holder's fetch will replace.
+			continue
+		}
+		if clock.Now().Sub(fetchedAt) > cfg.FetchLockTTL {
+			// An older entry, e.g. one being refreshed; the holder's result is
+			// still to come.
+			continue
+		}
+		fetchLockWaited.Add(1)
+		info := upstreamInfo{Fetched: fetchedAt}
+		if err != nil {
+			return nil, info, err
+		}
+		if isEmptyWeather(data) {
+			return nil, info, errUpstreamEmpty
+		}
+		return data, info, nil
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestLockedFetchAndCacheSharesMisses runs concurrent misses for one key past
+// the per-instance coalescing, as separate instances would, and checks the
+// fetch lock lets only one of them call the upstream.
+func TestLockedFetchAndCacheSharesMisses(t *testing.T) {
+	var calls atomic.Int64
+	c := testConfig(t)
+	c.FetchLockTTL = 5 * time.Second
+	c.FetchLockWait = 5 * time.Second
+	mr := setupTest(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+	}))
+
+	q := weatherQuery{Location: "London"}
+	key := q.cacheKey()
+	const misses = 10
+	var wg sync.WaitGroup
+	for i := 0; i < misses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, _, err := lockedFetchAndCache(context.Background(), q, key)
+			if err != nil {
+				t.Errorf("lockedFetchAndCache: %v", err)
+				return
+			}
+			if data["resolvedAddress"] != "London" {
+				t.Errorf("unexpected data %v", data)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times for %d concurrent misses, want 1", n, misses)
+	}
+	if mr.Exists(fetchLockPrefix + key) {
+		t.Error("fetch lock not released")
+	}
+}
+
+// TestLockedFetchAndCacheWaitTimeout holds the lock as another instance that
+// never delivers would, and checks the miss gives up after FETCH_LOCK_WAIT.
+func TestLockedFetchAndCacheWaitTimeout(t *testing.T) {
+	var calls atomic.Int64
+	c := testConfig(t)
+	c.FetchLockTTL = time.Minute
+	c.FetchLockWait = 300 * time.Millisecond
+	mr := setupTest(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+	}))
+
+	q := weatherQuery{Location: "London"}
+	key := q.cacheKey()
+	mr.Set(fetchLockPrefix+key, "other-instance")
+	if _, _, err := lockedFetchAndCache(context.Background(), q, key); err != errFetchLockTimeout {
+		t.Fatalf("err = %v, want errFetchLockTimeout", err)
+	}
+	if calls.Load() != 0 {
+		t.Error("fetched while another instance held the lock")
+	}
+	if got, _ := mr.Get(fetchLockPrefix + key); got != "other-instance" {
+		t.Errorf("lock now holds %q, want the other instance's token kept", got)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// errUpstreamQueueFull is returned for cache misses arriving while the upstream
+// fetch queue is at its maximum depth.
+var errUpstreamQueueFull = &apiError{
+	Status:     http.StatusServiceUnavailable,
+	Code:       "UPSTREAM_QUEUE_FULL",
+	Message:    "too many requests waiting for the upstream, try again shortly",
+	RetryAfter: time.Second,
+}
+
+// fetchQueue bounds upstream fetches: at most workers run at once, up to depth
+// more wait their turn in arrival order and any beyond that are rejected. Nil
+// runs every fetch immediately.
+type fetchQueue struct {
+	slots chan struct{}
+	depth int64
+
+	waiting   atomic.Int64 // fetches currently queued
+	queued    atomic.Int64 // fetches that had to wait, since startup
+	rejected  atomic.Int64 // fetches rejected with a full queue, since startup
+	waitNanos atomic.Int64 // total time queued fetches waited
+}
+
+// upstreamQueue is the queue in front of the upstream, set by main when
+// UPSTREAM_QUEUE_WORKERS is configured.
+var upstreamQueue *fetchQueue
+
+// newFetchQueue returns a queue running workers fetches at a time with up to
+// depth waiting, or nil when workers is not positive.
+func newFetchQueue(workers, depth int) *fetchQueue {
+	if workers <= 0 {
+		return nil
+	}
+	return &fetchQueue{slots: make(chan struct{}, workers), depth: int64(depth)}
+}
+
+// do runs fetch once a worker slot is free. It gives up with errUpstreamQueueFull
+// when the queue is full and with the context's error if ctx ends while waiting.
+func (q *fetchQueue) do(ctx context.Context, fetch func() error) error {
+	if q == nil {
+		return fetch()
+	}
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		if q.waiting.Add(1) > q.depth {
+			q.waiting.Add(-1)
+			q.rejected.Add(1)
+			return errUpstreamQueueFull
+		}
+		q.queued.Add(1)
+		start := time.Now()
+		select {
+		case q.slots <- struct{}{}:
+			q.waiting.Add(-1)
+			q.waitNanos.Add(int64(time.Since(start)))
+		case <-ctx.Done():
+			q.waiting.Add(-1)
+			q.waitNanos.Add(int64(time.Since(start)))
+			return ctx.Err()
+		}
+	}
+	defer func() { <-q.slots }()
+	return fetch()
+}
+
+// busy reports whether fetches are waiting for a worker. A nil queue is never
+// busy.
+func (q *fetchQueue) busy() bool {
+	return q != nil && q.waiting.Load() > 0
+}
+
+// stats reports the queue's state for /stats.
+func (q *fetchQueue) stats() map[string]interface{} {
+	if q == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	queued := q.queued.Load()
+	var avgWait int64
+	if queued > 0 {
+		avgWait = time.Duration(q.waitNanos.Load() / queued).Milliseconds()
+	}
+	return map[string]interface{}{
+		"enabled":   true,
+		"workers":   cap(q.slots),
+		"maxDepth":  q.depth,
+		"depth":     q.waiting.Load(),
+		"queued":    queued,
+		"rejected":  q.rejected.Load(),
+		"avgWaitMs": avgWait,
+	}
+}
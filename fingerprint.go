@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys of fingerprint tracking: per-window request counts live under
+// fingerprint:<window start>:<fingerprint>, flagged fingerprints in one hash.
+const (
+	fingerprintKeyPrefix  = "fingerprint:"
+	fingerprintFlaggedKey = "fingerprints:flagged"
+)
+
+// requestFingerprint identifies a client by a hash of its IP and User-Agent, so
+// clients sharing an IP are told apart and the raw values stay out of Redis keys.
+func requestFingerprint(clientIP, userAgent string) string {
+	sum := sha256.Sum256([]byte(clientIP + "\x00" + userAgent))
+	return hex.EncodeToString(sum[:8])
+}
+
+// flaggedFingerprint is a fingerprint that exceeded FINGERPRINT_THRESHOLD, as
+// listed by GET /admin/fingerprints.
+type flaggedFingerprint struct {
+	Fingerprint string    `json:"fingerprint"`
+	ClientIP    string    `json:"clientIp"`
+	UserAgent   string    `json:"userAgent"`
+	Count       int64     `json:"count"` // requests in the window it was flagged in
+	Window      time.Time `json:"window"`
+	FlaggedAt   time.Time `json:"flaggedAt"`
+}
+
+// fingerprintMiddleware counts requests per fingerprint in fixed windows of
+// FINGERPRINT_WINDOW seconds. A fingerprint sending more than threshold requests
+// in one window is logged and flagged; with throttle set, its further requests
+// in that window are rejected with 429. Counting fails open: while Redis is
+// unreachable requests pass uncounted.
+func fingerprintMiddleware(window time.Duration, threshold int64, throttle bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := clock.Now()
+		start := now.Truncate(window)
+		fp := requestFingerprint(c.ClientIP(), c.Request.UserAgent())
+		key := fingerprintKeyPrefix + strconv.FormatInt(start.Unix(), 10) + ":" + fp
+
+		pipe := redisClient.TxPipeline()
+		incr := pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, window)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Error counting request fingerprint: %v", err)
+			c.Next()
+			return
+		}
+		count := incr.Val()
+		if count <= threshold {
+			c.Next()
+			return
+		}
+		if count == threshold+1 {
+			flagFingerprint(flaggedFingerprint{
+				Fingerprint: fp,
+				ClientIP:    c.ClientIP(),
+				UserAgent:   c.Request.UserAgent(),
+				Count:       count,
+				Window:      start.UTC(),
+				FlaggedAt:   now.UTC(),
+			})
+		}
+		if throttle {
+			retryAfter := int(start.Add(window).Sub(now).Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests from this client", "code": "FINGERPRINT_THROTTLED"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// flagFingerprint logs a fingerprint crossing the threshold and records it for
+// GET /admin/fingerprints.
+func flagFingerprint(f flaggedFingerprint) {
+	log.Printf("Request fingerprint %s (%s, %q) exceeded %d requests in the window from %s",
+		f.Fingerprint, f.ClientIP, f.UserAgent, cfg.FingerprintThreshold, f.Window.Format(time.RFC3339))
+	b, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	if err := redisClient.HSet(ctx, fingerprintFlaggedKey, f.Fingerprint, b).Err(); err != nil {
+		log.Printf("Error recording flagged fingerprint %s: %v", f.Fingerprint, err)
+	}
+}
+
+// fingerprintsHandler handles GET /admin/fingerprints, listing the fingerprints
+// flagged within FINGERPRINT_FLAG_TTL, most recently flagged first. Older flags
+// are dropped as the list is read.
+func fingerprintsHandler(c *gin.Context) {
+	entries, err := redisClient.HGetAll(ctx, fingerprintFlaggedKey).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("Error reading flagged fingerprints: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	cutoff := clock.Now().Add(-cfg.FingerprintFlagTTL)
+	flagged := []flaggedFingerprint{}
+	var expired []string
+	for fp, raw := range entries {
+		var f flaggedFingerprint
+		if err := json.Unmarshal([]byte(raw), &f); err != nil || f.FlaggedAt.Before(cutoff) {
+			expired = append(expired, fp)
+			continue
+		}
+		flagged = append(flagged, f)
+	}
+	if len(expired) > 0 {
+		if err := redisClient.HDel(ctx, fingerprintFlaggedKey, expired...).Err(); err != nil {
+			log.Printf("Error dropping expired fingerprint flags: %v", err)
+		}
+	}
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].FlaggedAt.After(flagged[j].FlaggedAt) })
+	c.JSON(http.StatusOK, gin.H{"fingerprints": flagged})
+}
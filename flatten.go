@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of flattened records, reported in their "period" field.
+const (
+	flatPeriodCurrent = "current"
+	flatPeriodDay     = "day"
+)
+
+// flattenWeather turns a weather response into flat records for tabular
+// consumers, used with flatten=true: one record for the current conditions, if
+// any, then one per day. Each record holds
+//
+//   - location: the requested location;
+//   - period: "current" or "day";
+//   - date: the day's date, or for current conditions the local date of their
+//     epoch (the first day's date when that is unknown);
+//   - the scalar fields of the response's top level (resolvedAddress,
+//     latitude, timezone, ...), then those of the period, which win on clashes.
+//
+// Arrays of scalars in a period, such as preciptype, become comma-separated
+// strings (null when empty). Objects and other arrays, such as a day's hours
+// and the top-level days and alerts, are dropped. Field renames are applied to
+// each record.
+func flattenWeather(data map[string]interface{}, location string, renames map[string]string) []interface{} {
+	top := map[string]interface{}{}
+	addScalars(top, data, false)
+
+	days, _ := data["days"].([]interface{})
+	var firstDate interface{}
+	if len(days) > 0 {
+		if day, ok := days[0].(map[string]interface{}); ok {
+			firstDate = day["datetime"]
+		}
+	}
+
+	records := make([]interface{}, 0, len(days)+1)
+	record := func(period string, date interface{}, fields map[string]interface{}) {
+		r := make(map[string]interface{}, len(top)+len(fields)+3)
+		for k, v := range top {
+			r[k] = v
+		}
+		addScalars(r, fields, true)
+		r["location"], r["period"], r["date"] = location, period, date
+		renameKeys(r, renames)
+		records = append(records, r)
+	}
+
+	if current, ok := data["currentConditions"].(map[string]interface{}); ok {
+		date := firstDate
+		if local, ok := localDate(current["datetimeEpoch"], data["tzoffset"]); ok {
+			date = local
+		}
+		record(flatPeriodCurrent, date, current)
+	}
+	for _, d := range days {
+		if day, ok := d.(map[string]interface{}); ok {
+			record(flatPeriodDay, day["datetime"], day)
+		}
+	}
+	return records
+}
+
+// addScalars copies the scalar fields of src into dst and, with joinArrays,
+// its arrays of scalars joined, see flattenWeather.
+func addScalars(dst, src map[string]interface{}, joinArrays bool) {
+	for k, v := range src {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			continue
+		case []interface{}:
+			if !joinArrays {
+				continue
+			}
+			if joined, ok := joinScalars(v); ok {
+				dst[k] = joined
+			}
+		default:
+			dst[k] = v
+		}
+	}
+}
+
+// joinScalars joins an array of scalars with commas, reporting false for arrays
+// holding objects or arrays. Empty arrays join to nil.
+func joinScalars(values []interface{}) (interface{}, bool) {
+	if len(values) == 0 {
+		return nil, true
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, false
+		}
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ","), true
+}
+
+// localDate returns the date of a Unix epoch at a UTC offset in hours, as
+// Visual Crossing reports them.
+func localDate(epoch, tzoffset interface{}) (string, bool) {
+	seconds, ok := epoch.(float64)
+	if !ok {
+		return "", false
+	}
+	offset, _ := tzoffset.(float64)
+	zone := time.FixedZone("", int(offset*3600))
+	return time.Unix(int64(seconds), 0).In(zone).Format(dateLayout), true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+)
+
+// geoJSONContentType is the media type of format=geojson responses (RFC 7946).
+const geoJSONContentType = "application/geo+json"
+
+// errNoCoordinates reports weather data without the resolved coordinates a
+// GeoJSON point needs.
+var errNoCoordinates = errors.New("the weather data has no coordinates to place a GeoJSON point at")
+
+// geoJSONFeature is a GeoJSON Feature with a point geometry.
+type geoJSONFeature struct {
+	Type       string                 `json:"type"` // always "Feature"
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONPoint is a GeoJSON Point; coordinates are [longitude, latitude].
+type geoJSONPoint struct {
+	Type        string     `json:"type"` // always "Point"
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// dataCoordinates returns the coordinates Visual Crossing resolved the location
+// to, as a GeoJSON position, or errNoCoordinates when the data lacks valid
+// ones.
+func dataCoordinates(data map[string]interface{}) ([2]float64, error) {
+	lat, okLat := data["latitude"].(float64)
+	lon, okLon := data["longitude"].(float64)
+	if !okLat || !okLon || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return [2]float64{}, errNoCoordinates
+	}
+	return [2]float64{lon, lat}, nil
+}
+
+// geoJSONView wraps weather data as a Feature at position, the data becoming
+// its properties.
+func geoJSONView(data map[string]interface{}, position [2]float64) geoJSONFeature {
+	return geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geoJSONPoint{Type: "Point", Coordinates: position},
+		Properties: data,
+	}
+}
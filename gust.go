@@ -0,0 +1,36 @@
+package main
+
+// gustSummary reports how many periods an applyGustThreshold call flagged.
+type gustSummary struct {
+	Threshold      float64 `json:"threshold"`
+	FlaggedPeriods int     `json:"flaggedPeriods"`
+}
+
+// applyGustThreshold marks every day, hour and the current conditions whose
+// windgust reaches threshold with highGust:true and adds a "gusts" summary
+// counting them. Both the threshold and the gusts are in the wind speed units of
+// unitGroup; periods without a numeric windgust are left unmarked.
+func applyGustThreshold(data map[string]interface{}, threshold float64, unitGroup string) {
+	limit := windSpeedKmh(threshold, unitGroup)
+	flagged := 0
+	mark := func(period map[string]interface{}) {
+		gust, ok := period["windgust"].(float64)
+		if !ok || windSpeedKmh(gust, unitGroup) < limit {
+			return
+		}
+		period["highGust"] = true
+		flagged++
+	}
+
+	forEachPeriod(data, func(period map[string]interface{}) {
+		mark(period)
+		if hours, ok := period["hours"].([]interface{}); ok {
+			for _, h := range hours {
+				if hour, ok := h.(map[string]interface{}); ok {
+					mark(hour)
+				}
+			}
+		}
+	})
+	data["gusts"] = gustSummary{Threshold: threshold, FlaggedPeriods: flagged}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// handoffBatch is how many entries the cache handoff reads or writes per Redis
+// round trip, bounding the memory either side holds at once.
+const handoffBatch = 100
+
+// maxHandoffLine caps the size of one imported NDJSON line.
+const maxHandoffLine = 16 << 20
+
+// handoffEntry is one line of a cache handoff: a cached weather entry under its
+// key without the cache prefix, and its remaining TTL in milliseconds (0 for
+// entries that don't expire).
+type handoffEntry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+	TTLMs int64           `json:"ttlMs"`
+}
+
+// cacheExportHandler handles GET /admin/cache/export, streaming every cached
+// weather entry with its remaining TTL as NDJSON, one handoffEntry per line.
+// Entries are read a batch at a time and flushed as they are written, so the hot
+// set of one instance can be piped into POST /admin/cache/import of another.
+func cacheExportHandler(c *gin.Context) {
+	c.Header("Content-Type", ndjsonContentType)
+	c.Status(http.StatusOK)
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+
+	exported := 0
+	for _, shard := range primaryShards() {
+		var batch []string
+		iter := shard.Scan(ctx, 0, cachePrefix+"*", handoffBatch).Iterator()
+		for iter.Next(ctx) {
+			if batch = append(batch, iter.Val()); len(batch) == handoffBatch {
+				n, err := exportBatch(c, shard, batch)
+				exported += n
+				if err != nil {
+					log.Printf("Cache export stopped after %d entries: %v", exported, err)
+					return
+				}
+				batch = batch[:0]
+			}
+		}
+		if err := iter.Err(); err != nil {
+			// The status is already sent; all that's left is to stop.
+			log.Printf("Cache export stopped after %d entries: failed to scan cache: %v", exported, err)
+			return
+		}
+		n, err := exportBatch(c, shard, batch)
+		exported += n
+		if err != nil {
+			log.Printf("Cache export stopped after %d entries: %v", exported, err)
+			return
+		}
+	}
+	log.Printf("Exported %d cache entries", exported)
+}
+
+// exportBatch reads the values and TTLs of keys from shard in one pipeline and
+// writes them as handoff lines. Keys that expired since they were scanned, and
+// values that aren't JSON, are skipped.
+func exportBatch(c *gin.Context, shard *redis.Client, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	pipe := shard.Pipeline()
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("failed to read cache entries: %v", err)
+	}
+
+	written := 0
+	for i, key := range keys {
+		val, err := gets[i].Bytes()
+		if err != nil || !json.Valid(val) {
+			continue
+		}
+		entry := handoffEntry{Key: strings.TrimPrefix(key, cachePrefix), Value: val}
+		if ttl := ttls[i].Val(); ttl > 0 {
+			entry.TTLMs = ttl.Milliseconds()
+		}
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return written, err
+		}
+		if _, err := c.Writer.Write(append(b, '\n')); err != nil {
+			return written, err
+		}
+		written++
+	}
+	c.Writer.Flush()
+	return written, nil
+}
+
+// cacheImportHandler handles POST /admin/cache/import, writing the NDJSON
+// produced by GET /admin/cache/export back into the cache with the TTL each
+// entry had left. The body is read line by line and written a batch at a time.
+// It answers with the number of entries imported and skipped; lines that aren't
+// a handoff entry are skipped.
+func cacheImportHandler(c *gin.Context) {
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxHandoffLine)
+
+	imported, skipped := 0, 0
+	var batch []handoffEntry
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var entry handoffEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.Key == "" || !json.Valid(entry.Value) || entry.TTLMs < 0 {
+			skipped++
+			continue
+		}
+		if batch = append(batch, entry); len(batch) == handoffBatch {
+			if err := importBatch(batch); err != nil {
+				log.Printf("Cache import stopped after %d entries: %v", imported, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "imported": imported})
+				return
+			}
+			imported += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read body: %v", err), "imported": imported})
+		return
+	}
+	if err := importBatch(batch); err != nil {
+		log.Printf("Cache import stopped after %d entries: %v", imported, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "imported": imported})
+		return
+	}
+	imported += len(batch)
+	log.Printf("Imported %d cache entries, skipped %d", imported, skipped)
+	c.JSON(http.StatusOK, gin.H{"imported": imported, "skipped": skipped})
+}
+
+// importBatch writes handoff entries to their shards, one pipeline per shard.
+func importBatch(entries []handoffEntry) error {
+	pipes := make(map[*redis.Client]redis.Pipeliner)
+	for _, entry := range entries {
+		key := cachePrefix + entry.Key
+		shard := primaryFor(key)
+		if pipes[shard] == nil {
+			pipes[shard] = shard.Pipeline()
+		}
+		pipes[shard].Set(ctx, key, []byte(entry.Value), time.Duration(entry.TTLMs)*time.Millisecond)
+	}
+	for _, pipe := range pipes {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to populate cache: %v", err)
+		}
+	}
+	return nil
+}
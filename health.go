@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health statuses, from best to worst.
+const (
+	healthOK   = "ok"
+	healthWarn = "warn"
+	healthFail = "fail"
+)
+
+// startTime is when the process started, set first thing in main; /health
+// reports the uptime since.
+var startTime time.Time
+
+// uptime returns how long the process has been running, to the second.
+func uptime() string {
+	return time.Since(startTime).Round(time.Second).String()
+}
+
+// healthProbe checks one dependency. A failing critical probe fails the whole
+// health check; a failing non-critical one only degrades it to warn, since the
+// service keeps serving without it.
+type healthProbe struct {
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// healthProbes are the probes HEALTH_CHECKS can select, by name.
+var healthProbes = map[string]healthProbe{
+	"redis": {critical: true, check: func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	}},
+	// Cache operations stay on the primary while the failover is down.
+	"failover": {check: func(ctx context.Context) error {
+		if failoverClient == nil {
+			return nil
+		}
+		return failoverClient.Ping(ctx).Err()
+	}},
+	// Reads fall back to the primary when the replica is down.
+	"replica": {check: func(ctx context.Context) error {
+		if replicaClient == nil {
+			return nil
+		}
+		return replicaClient.Ping(ctx).Err()
+	}},
+	// Cached data is still served while the provider is unreachable. Any HTTP
+	// answer counts as reachable; the request carries no key and costs no quota.
+	"upstream": {check: func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, cfg.APIURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := upstreamClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}},
+}
+
+// runHealthProbes runs the selected probes in parallel, each bounded by timeout,
+// and returns the overall status with per-dependency details.
+func runHealthProbes(parent context.Context, names map[string]bool, timeout time.Duration) (string, gin.H) {
+	type outcome struct {
+		name   string
+		detail gin.H
+	}
+	results := make(chan outcome, len(names))
+	for name := range names {
+		probe, ok := healthProbes[name]
+		if !ok {
+			continue
+		}
+		go func(name string, probe healthProbe) {
+			pctx, cancel := context.WithTimeout(parent, timeout)
+			defer cancel()
+			start := time.Now()
+			err := probe.check(pctx)
+			detail := gin.H{"status": healthOK, "latency_ms": time.Since(start).Milliseconds()}
+			if err != nil {
+				detail["status"] = healthWarn
+				// With a failover Redis, caching survives the primary.
+				if probe.critical && !(name == "redis" && failoverClient != nil) {
+					detail["status"] = healthFail
+				}
+				detail["error"] = err.Error()
+			}
+			results <- outcome{name, detail}
+		}(name, probe)
+	}
+
+	overall := healthOK
+	deps := gin.H{}
+	for name := range names {
+		if _, ok := healthProbes[name]; !ok {
+			continue
+		}
+		r := <-results
+		deps[r.name] = r.detail
+		overall = worseHealth(overall, r.detail["status"].(string))
+	}
+	return overall, deps
+}
+
+// worseHealth returns the worse of two health statuses.
+func worseHealth(a, b string) string {
+	rank := map[string]int{healthOK: 0, healthWarn: 1, healthFail: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// Formats of the /health response, see HEALTH_FORMAT.
+const (
+	healthFormatSimple     = "simple"
+	healthFormatDetailed   = "detailed"
+	healthFormatKubernetes = "kubernetes"
+)
+
+// parseHealthFormat reads HEALTH_FORMAT, defaulting to the detailed format.
+func parseHealthFormat(raw string) string {
+	switch raw {
+	case "":
+		return healthFormatDetailed
+	case healthFormatSimple, healthFormatDetailed, healthFormatKubernetes:
+		return raw
+	}
+	log.Printf("Invalid HEALTH_FORMAT %q, defaulting to %s", raw, healthFormatDetailed)
+	return healthFormatDetailed
+}
+
+// healthCheck is the outcome of one check behind /health, a dependency probe
+// or a state of the service itself, as listed by the kubernetes format.
+type healthCheck struct {
+	name   string
+	status string
+	reason string // why the check isn't ok
+}
+
+// healthReport runs the health checks, returning the overall status, the
+// detailed body and every check's outcome in name order. Every format of
+// /health is rendered from the same report.
+func healthReport(ctx context.Context) (string, gin.H, []healthCheck) {
+	status, deps := runHealthProbes(ctx, cfg.HealthChecks, cfg.HealthCheckTimeout)
+	var checks []healthCheck
+	for name, d := range deps {
+		detail := d.(gin.H)
+		reason, _ := detail["error"].(string)
+		checks = append(checks, healthCheck{name, detail["status"].(string), reason})
+	}
+
+	quota := gin.H{"status": "ok"}
+	quotaCheck := healthCheck{name: "upstreamQuota", status: healthOK}
+	if resetAt, exhausted := quotaExhaustedUntil(clock.Now()); exhausted {
+		quota = gin.H{"status": "exceeded", "resetAt": resetAt.UTC().Format(time.RFC3339)}
+		quotaCheck = healthCheck{"upstreamQuota", healthWarn, "exceeded until " + resetAt.UTC().Format(time.RFC3339)}
+	}
+	checks = append(checks, quotaCheck)
+
+	// An open Redis breaker means lookups bypass the cache and all hit the
+	// upstream.
+	breakerState, failures := redisBreaker.state()
+	breakerCheck := healthCheck{name: "redisBreaker", status: healthOK}
+	if breakerState != breakerClosed {
+		breakerCheck = healthCheck{"redisBreaker", healthWarn, "breaker " + breakerState}
+	}
+	checks = append(checks, breakerCheck)
+
+	// While the health poller finds the upstream down, cache misses fail fast.
+	upstream := upstreamHealth.report()
+	pollerCheck := healthCheck{name: "upstreamPoller", status: healthOK}
+	if upstream["state"] == "down" {
+		reason, _ := upstream["error"].(string)
+		pollerCheck = healthCheck{"upstreamPoller", healthWarn, "upstream down: " + reason}
+	}
+	checks = append(checks, pollerCheck)
+
+	// Serving from the failover region keeps the cache working, degraded.
+	redisRegion := failoverReport()
+	if redisRegion != nil {
+		regionCheck := healthCheck{name: "redisRegion", status: healthOK}
+		if redisRegion["active"] == "failover" {
+			regionCheck = healthCheck{"redisRegion", healthWarn, "serving from the failover region"}
+		}
+		checks = append(checks, regionCheck)
+	}
+
+	sort.Slice(checks, func(i, j int) bool { return checks[i].name < checks[j].name })
+	for _, check := range checks {
+		status = worseHealth(status, check.status)
+	}
+	body := gin.H{
+		"status":         status,
+		"dependencies":   deps,
+		"upstreamQuota":  quota,
+		"redisBreaker":   gin.H{"state": breakerState, "consecutiveFailures": failures},
+		"upstreamPoller": upstream,
+		"uptime":         uptime(),
+	}
+	// The primary's probe, when HEALTH_CHECKS runs one, also reads as up or down.
+	if detail, ok := deps["redis"].(gin.H); ok {
+		body["redis"] = "down"
+		if detail["status"] == healthOK {
+			body["redis"] = "up"
+		}
+	}
+	if redisRegion != nil {
+		body["redisRegion"] = redisRegion
+	}
+	return status, body, checks
+}
+
+// kubernetesHealth renders checks like the Kubernetes API server's verbose
+// health endpoints: a "[+]name ok" or "[-]name failed: reason" line per check
+// and a closing verdict. Checks that only warn pass, noting why.
+func kubernetesHealth(status string, checks []healthCheck) string {
+	var b strings.Builder
+	for _, check := range checks {
+		switch check.status {
+		case healthOK:
+			fmt.Fprintf(&b, "[+]%s ok\n", check.name)
+		case healthWarn:
+			fmt.Fprintf(&b, "[+]%s ok (warn: %s)\n", check.name, check.reason)
+		default:
+			fmt.Fprintf(&b, "[-]%s failed: %s\n", check.name, check.reason)
+		}
+	}
+	if status == healthFail {
+		b.WriteString("health check failed\n")
+	} else {
+		b.WriteString("health check passed\n")
+	}
+	return b.String()
+}
+
+// healthHandler handles GET /health requests, reporting the state of the service's
+// dependencies in HEALTH_FORMAT. It answers 503 when a critical dependency is
+// down and reports warn, with a 200, while only non-critical ones are.
+func healthHandler(c *gin.Context) {
+	status, body, checks := healthReport(c.Request.Context())
+	code := http.StatusOK
+	if status == healthFail {
+		code = http.StatusServiceUnavailable
+	}
+	switch cfg.HealthFormat {
+	case healthFormatSimple:
+		simple := gin.H{"status": status, "uptime": uptime()}
+		if redis, ok := body["redis"]; ok {
+			simple["redis"] = redis
+		}
+		c.JSON(code, simple)
+	case healthFormatKubernetes:
+		c.String(code, kubernetesHealth(status, checks))
+	default:
+		c.JSON(code, body)
+	}
+}
+
+// livezHandler handles GET /livez requests; the process is alive if it answers.
+func livezHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyzHandler handles GET /readyz requests, answering 503 once the service has
+// started draining so load balancers stop routing new requests to it.
+func readyzHandler(c *gin.Context) {
+	if !ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestHealthNotRateLimited checks that /health answers every probe however
+// often it is polled, while a limited route throttles the same client.
+func TestHealthNotRateLimited(t *testing.T) {
+	c := testConfig(t)
+	c.RateLimit = 1
+	setupTest(t, c, nil)
+	router := newRouter(cfg)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	for i := 0; i < 10; i++ {
+		if w := serve("/health"); w.Code != http.StatusOK {
+			t.Fatalf("/health request %d: status %d, want 200: %s", i+1, w.Code, w.Body)
+		}
+	}
+	// The same client is throttled on a limited route, so the limiter is live.
+	limited := false
+	for i := 0; i < 10 && !limited; i++ {
+		limited = serve("/stats").Code == http.StatusTooManyRequests
+	}
+	if !limited {
+		t.Fatal("/stats was never rate limited at RATE_LIMIT=1")
+	}
+}
+
+// TestHealthRedisField checks that every JSON format reports the primary
+// Redis as up or down, and leaves it out when its probe isn't run.
+func TestHealthRedisField(t *testing.T) {
+	for _, format := range []string{healthFormatDetailed, healthFormatSimple} {
+		t.Run(format, func(t *testing.T) {
+			c := testConfig(t)
+			c.HealthFormat = format
+			c.HealthChecks = map[string]bool{"redis": true}
+			mr := setupTest(t, c, nil)
+
+			redisField := func(wantCode int) any {
+				t.Helper()
+				w := serveRequest(httptest.NewRequest(http.MethodGet, "/health", nil))
+				if w.Code != wantCode {
+					t.Fatalf("status %d, want %d: %s", w.Code, wantCode, w.Body)
+				}
+				var body map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding %s: %v", w.Body, err)
+				}
+				return body["redis"]
+			}
+
+			if got := redisField(http.StatusOK); got != "up" {
+				t.Errorf("redis = %v with Redis running, want up", got)
+			}
+			cfg.HealthChecks = map[string]bool{}
+			if got := redisField(http.StatusOK); got != nil {
+				t.Errorf("redis = %v without the redis probe, want it left out", got)
+			}
+			cfg.HealthChecks = map[string]bool{"redis": true}
+			mr.Close()
+			if got := redisField(http.StatusServiceUnavailable); got != "down" {
+				t.Errorf("redis = %v with Redis stopped, want down", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// earliestHistoryYear is the first year Visual Crossing has history for.
+const earliestHistoryYear = 1970
+
+// historyMonth returns the date range of the given month, ending today for the
+// current month, and whether the month is over, so its weather can no longer
+// change.
+func historyMonth(yearRaw, monthRaw string, now time.Time) (start, end string, complete bool, err error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	year, err := strconv.Atoi(yearRaw)
+	if err != nil || year < earliestHistoryYear || year > today.Year() {
+		return "", "", false, fmt.Errorf("year must be an integer between %d and %d", earliestHistoryYear, today.Year())
+	}
+	month, err := strconv.Atoi(monthRaw)
+	if err != nil || month < 1 || month > 12 {
+		return "", "", false, fmt.Errorf("month must be an integer between 1 and 12")
+	}
+
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	if first.After(today) {
+		return "", "", false, fmt.Errorf("%04d-%02d is in the future", year, month)
+	}
+	last := first.AddDate(0, 1, -1)
+	complete = last.Before(today)
+	if !complete {
+		last = today
+	}
+	return first.Format(dateLayout), last.Format(dateLayout), complete, nil
+}
+
+// getHistoryHandler handles GET /weather/history requests, returning the daily
+// weather of one calendar month given by the year and month parameters. Months
+// that are over are cached for HISTORY_CACHE_TTL, since their weather no longer
+// changes; the current month is cached like any other lookup.
+func getHistoryHandler(c *gin.Context) {
+	var p queryParams
+	if !bindQuery(c, &p) {
+		return
+	}
+	start, end, complete, err := historyMonth(c.Query("year"), c.Query("month"), clock.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.Start, p.End = start, end
+	q, ok := buildWeatherQuery(c, p)
+	if !ok {
+		return
+	}
+	q.Immutable = complete
+
+	// Untransformed cache hits are written straight from the cached bytes.
+	raw := len(cfg.FieldRenames) == 0 && cfg.NullPolicy == nullPolicyKeep
+	result, err := lookupWeather(c.Request.Context(), q, lookupOptions{Raw: raw})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	noteLookup(c, q, result)
+	c.Header("X-Cache", result.Cache)
+	setCacheControl(c, result)
+	if result.Raw != nil {
+		writeJSONBody(c, http.StatusOK, result.Raw)
+		return
+	}
+	if result.Data == nil {
+		log.Printf("No history data for location %s, %s to %s", q.Location, start, end)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "no history data available"})
+		return
+	}
+	applyNullPolicy(result.Data, cfg.NullPolicy)
+	writeJSON(c, http.StatusOK, renameFields(result.Data, cfg.FieldRenames))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hourlyInclude is the upstream include set of /weather/hourly.
+const hourlyInclude = "days,hours"
+
+// trendWindowHours is how many hours /weather/hourly returns and computes the
+// temperature trend over, unless nextHours asks for fewer.
+const trendWindowHours = 24
+
+// Temperature trend labels.
+const (
+	trendRising  = "rising"
+	trendFalling = "falling"
+	trendSteady  = "steady"
+)
+
+// hourlyEntry is a single hour returned by /weather/hourly.
+type hourlyEntry struct {
+	Datetime   string   `json:"datetime"` // local date and time, e.g. 2026-10-14T13:00:00
+	Temp       *float64 `json:"temp"`
+	WindGust   *float64 `json:"windgust,omitempty"`
+	Conditions string   `json:"conditions,omitempty"`
+}
+
+// temperatureTrend labels a series of temperatures as rising, falling or
+// steady. The change is the rise of the least-squares line fitted through the
+// series from its first to its last sample, so a single outlier doesn't flip the
+// label the way comparing the endpoints would. Changes smaller than threshold in
+// either direction are steady. Series of fewer than two samples are steady with
+// no change.
+func temperatureTrend(temps []float64, threshold float64) (string, float64) {
+	n := float64(len(temps))
+	if len(temps) < 2 {
+		return trendSteady, 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, t := range temps {
+		x := float64(i)
+		sumX += x
+		sumY += t
+		sumXY += x * t
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	change := math.Round(slope*(n-1)*10) / 10
+	switch {
+	case change >= threshold:
+		return trendRising, change
+	case change <= -threshold:
+		return trendFalling, change
+	}
+	return trendSteady, change
+}
+
+// hourRange is a range of local hours of the day, from From to To inclusive.
+// A From after To wraps past midnight, so 22-2 is 22:00 to 02:59.
+type hourRange struct {
+	From, To int
+}
+
+// contains reports whether local hour h falls in the range. An unreadable hour
+// (-1, see localHour) falls in none.
+func (r hourRange) contains(h int) bool {
+	if h < 0 {
+		return false
+	}
+	if r.From <= r.To {
+		return h >= r.From && h <= r.To
+	}
+	return h >= r.From || h <= r.To
+}
+
+// localHour returns the hour of the day of a Visual Crossing local time such as
+// "13:00:00", or -1 when it can't be read.
+func localHour(datetime string) int {
+	hour, _, _ := strings.Cut(datetime, ":")
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 23 {
+		return -1
+	}
+	return h
+}
+
+// windowHours returns up to size hours across days, starting at the hour
+// containing now for undated queries and at the first hour of the range
+// otherwise. With a non-nil hours, only the hours of the window whose local hour
+// falls in it are kept. Hours without an epoch can't be placed relative to now,
+// so they are kept from the first one.
+func windowHours(days []weatherDay, now time.Time, dated bool, size int, hours *hourRange) []hourlyEntry {
+	// The hour containing now began less than an hour ago, whatever the
+	// location's offset from UTC.
+	from := now.Add(-time.Hour).Unix()
+	var out []hourlyEntry
+	seen := 0
+	for _, d := range days {
+		for _, h := range d.Hours {
+			if !dated && h.DatetimeEpoch != 0 && h.DatetimeEpoch <= from {
+				continue
+			}
+			if seen == size {
+				return out
+			}
+			seen++
+			if hours != nil && !hours.contains(localHour(h.Datetime)) {
+				continue
+			}
+			out = append(out, hourlyEntry{Datetime: d.Datetime + "T" + h.Datetime, Temp: h.Temp, WindGust: h.WindGust, Conditions: h.Conditions})
+		}
+	}
+	return out
+}
+
+// parseHourlyWindow reads the nextHours, fromHour and toHour parameters of
+// /weather/hourly, returning the window size and the optional local hour range.
+// A range given by only one of its ends runs to the end or from the start of the
+// day.
+func parseHourlyWindow(c *gin.Context) (int, *hourRange, error) {
+	size := trendWindowHours
+	if raw := c.Query("nextHours"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > trendWindowHours {
+			return 0, nil, fmt.Errorf("nextHours must be an integer between 1 and %d", trendWindowHours)
+		}
+		size = n
+	}
+
+	fromRaw, toRaw := c.Query("fromHour"), c.Query("toHour")
+	if fromRaw == "" && toRaw == "" {
+		return size, nil, nil
+	}
+	r := hourRange{From: 0, To: 23}
+	for _, p := range []struct {
+		name, raw string
+		dst       *int
+	}{{"fromHour", fromRaw, &r.From}, {"toHour", toRaw, &r.To}} {
+		if p.raw == "" {
+			continue
+		}
+		h, err := strconv.Atoi(p.raw)
+		if err != nil || h < 0 || h > 23 {
+			return 0, nil, fmt.Errorf("%s must be an integer between 0 and 23", p.name)
+		}
+		*p.dst = h
+	}
+	return size, &r, nil
+}
+
+// downsampleHours reduces hours to about n representative ones, chosen by their
+// temperature.
+func downsampleHours(hours []hourlyEntry, n int) []hourlyEntry {
+	temps := make([]*float64, len(hours))
+	for i, h := range hours {
+		temps[i] = h.Temp
+	}
+	kept := make([]hourlyEntry, 0, n)
+	for _, i := range downsampleIndices(seriesValues(temps), n) {
+		kept = append(kept, hours[i])
+	}
+	return kept
+}
+
+// getHourlyHandler handles GET /weather/hourly requests, returning the next 24
+// hours (or the first 24 of a date range), optionally narrowed by nextHours and
+// fromHour/toHour and reduced by downsample, with the temperature trend across
+// them.
+func getHourlyHandler(c *gin.Context) {
+	size, hourFilter, err := parseHourlyWindow(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	downsample, err := parseDownsample(c.Query("downsample"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	hours := windowHours(days, clock.Now(), q.Start != "", size, hourFilter)
+	temps := make([]float64, 0, len(hours))
+	for _, h := range hours {
+		if h.Temp != nil {
+			temps = append(temps, *h.Temp)
+		}
+	}
+	trend, change := temperatureTrend(temps, cfg.TrendSteadyThreshold)
+	// The trend is fitted to the evenly spaced hours, before they are thinned.
+	if downsample > 0 {
+		hours = downsampleHours(hours, downsample)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"location":       q.Location,
+		"hours":          hours,
+		"trend":          trend,
+		"trendMagnitude": math.Abs(change),
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTemperatureTrend(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		temps  []float64
+		trend  string
+		change float64
+	}{
+		{"empty", nil, trendSteady, 0},
+		{"single", []float64{12}, trendSteady, 0},
+		{"rising", []float64{10, 11, 12, 13}, trendRising, 3},
+		{"falling", []float64{20, 18, 16}, trendFalling, -4},
+		{"flat", []float64{15, 15, 15}, trendSteady, 0},
+		{"below threshold", []float64{15, 15.2, 15.4, 15.6}, trendSteady, 0.6},
+		{"at threshold", []float64{15, 16}, trendRising, 1},
+		// Comparing the endpoints would call this falling.
+		{"outlier end", []float64{10, 11, 12, 13, 14, 15, 16, 8}, trendRising, 1.8},
+	} {
+		trend, change := temperatureTrend(tc.temps, 1)
+		if trend != tc.trend || change != tc.change {
+			t.Errorf("%s: temperatureTrend = %s, %v; want %s, %v", tc.name, trend, change, tc.trend, tc.change)
+		}
+	}
+}
+
+// hoursFrom returns days of hourly data starting at start, one temperature per
+// hour equal to its index.
+func hoursFrom(start time.Time, n int) []weatherDay {
+	var days []weatherDay
+	for i := 0; i < n; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		date := at.Format(dateLayout)
+		if len(days) == 0 || days[len(days)-1].Datetime != date {
+			days = append(days, weatherDay{Datetime: date})
+		}
+		temp := float64(i)
+		d := &days[len(days)-1]
+		d.Hours = append(d.Hours, weatherHour{Datetime: at.Format("15:04:05"), DatetimeEpoch: at.Unix(), Temp: &temp})
+	}
+	return days
+}
+
+func TestWindowHours(t *testing.T) {
+	start := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	days := hoursFrom(start, 72)
+	now := start.Add(30*time.Hour + 20*time.Minute) // 2026-10-15 06:20
+
+	for _, tc := range []struct {
+		name        string
+		dated       bool
+		size        int
+		hours       *hourRange
+		first, last string
+		n           int
+	}{
+		{"rolling 24h", false, 24, nil, "2026-10-15T06:00:00", "2026-10-16T05:00:00", 24},
+		{"next 3h", false, 3, nil, "2026-10-15T06:00:00", "2026-10-15T08:00:00", 3},
+		{"dated range", true, 24, nil, "2026-10-14T00:00:00", "2026-10-14T23:00:00", 24},
+		{"afternoons", false, 24, &hourRange{12, 17}, "2026-10-15T12:00:00", "2026-10-15T17:00:00", 6},
+		{"overnight", false, 24, &hourRange{22, 2}, "2026-10-15T22:00:00", "2026-10-16T02:00:00", 5},
+	} {
+		got := windowHours(days, now, tc.dated, tc.size, tc.hours)
+		if len(got) != tc.n || got[0].Datetime != tc.first || got[len(got)-1].Datetime != tc.last {
+			t.Errorf("%s: %d hours from %s to %s, want %d from %s to %s", tc.name, len(got),
+				got[0].Datetime, got[len(got)-1].Datetime, tc.n, tc.first, tc.last)
+		}
+	}
+
+	// Past the end of the data the window just runs short.
+	if got := windowHours(days, start.Add(60*time.Hour), false, 24, nil); len(got) != 12 {
+		t.Errorf("near the end: %d hours, want 12", len(got))
+	}
+}
+
+func TestLocalHourAndRange(t *testing.T) {
+	for raw, want := range map[string]int{"00:00:00": 0, "13:00:00": 13, "23:59:59": 23, "24:00:00": -1, "": -1, "x:00": -1} {
+		if got := localHour(raw); got != want {
+			t.Errorf("localHour(%q) = %d, want %d", raw, got, want)
+		}
+	}
+	for _, tc := range []struct {
+		r    hourRange
+		h    int
+		want bool
+	}{
+		{hourRange{9, 17}, 9, true},
+		{hourRange{9, 17}, 17, true},
+		{hourRange{9, 17}, 18, false},
+		{hourRange{22, 2}, 23, true},
+		{hourRange{22, 2}, 0, true},
+		{hourRange{22, 2}, 3, false},
+		{hourRange{22, 2}, -1, false},
+	} {
+		if got := tc.r.contains(tc.h); got != tc.want {
+			t.Errorf("%+v.contains(%d) = %v, want %v", tc.r, tc.h, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultInclude is the upstream include set of lookups not tied to an endpoint
+// (the canary, cache commands) and of endpoints without an entry.
+const defaultInclude = "days"
+
+// defaultEndpointIncludes are the upstream sections each weather endpoint needs.
+// The derived endpoints compute on days alone, except /weather/hourly and
+// /weather/description.
+var defaultEndpointIncludes = map[string]string{
+	"/weather":             defaultInclude,
+	"/weather/summary":     defaultInclude,
+	"/weather/precip":      defaultInclude,
+	"/weather/degreedays":  defaultInclude,
+	"/weather/comfort":     defaultInclude,
+	"/weather/uv":          defaultInclude,
+	"/weather/sky":         defaultInclude,
+	"/weather/score":       defaultInclude,
+	"/weather/hourly":      hourlyInclude,
+	"/weather/history":     defaultInclude,
+	"/weather/normal":      defaultInclude,
+	"/weather/text":        defaultInclude,
+	"/weather/description": hourlyInclude,
+	"/weather/agri":        defaultInclude,
+	"/weather/temps":       defaultInclude,
+	"/weather/batch":       defaultInclude,
+	"/weather/jobs":        defaultInclude,
+	"/user/weather":        defaultInclude,
+}
+
+// normalizeInclude sorts and deduplicates a comma-separated include set, so
+// equivalent sets share cache entries.
+func normalizeInclude(raw string) string {
+	seen := make(map[string]bool)
+	var parts []string
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p != "" && !seen[p] {
+			seen[p] = true
+			parts = append(parts, p)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// parseEndpointIncludes builds the per-endpoint include sets from the defaults
+// and the ENDPOINT_INCLUDES overrides, a JSON object mapping endpoint paths to
+// comma-separated include sets.
+func parseEndpointIncludes(raw string) (map[string]string, error) {
+	includes := make(map[string]string, len(defaultEndpointIncludes))
+	for path, include := range defaultEndpointIncludes {
+		includes[path] = include
+	}
+	if raw == "" {
+		return includes, nil
+	}
+	var overrides map[string]string
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, err
+	}
+	for path, include := range overrides {
+		if _, ok := defaultEndpointIncludes[path]; !ok {
+			return nil, fmt.Errorf("unknown endpoint %q", path)
+		}
+		if include = normalizeInclude(include); include == "" {
+			return nil, fmt.Errorf("empty include set for %s", path)
+		}
+		includes[path] = include
+	}
+	return includes, nil
+}
+
+// endpointInclude returns the include set for the endpoint at path.
+func endpointInclude(path string) string {
+	if include, ok := cfg.EndpointIncludes[path]; ok {
+		return include
+	}
+	return defaultInclude
+}
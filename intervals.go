@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Derived interval widening for intervals=derived: the half-width of a
+// temperature interval is derivedTempBase °C plus derivedTempPerDay °C per day
+// of lead time; a precipitation interval spans the amount give or take
+// derivedPrecipBase plus derivedPrecipPerDay per day of it, as a fraction.
+const (
+	derivedTempBase     = 1.0
+	derivedTempPerDay   = 0.4
+	derivedPrecipBase   = 0.25
+	derivedPrecipPerDay = 0.1
+)
+
+// valueInterval is the range a day's value is expected to fall in.
+type valueInterval struct {
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+	Source string  `json:"source"` // "upstream" or "derived"
+}
+
+// intervalWidth returns the half-width of a derived interval lead days ahead,
+// growing linearly from base by perDay. Past days were observed, not
+// forecast, and get zero.
+func intervalWidth(lead int, base, perDay float64) float64 {
+	if lead < 0 {
+		return 0
+	}
+	return base + perDay*float64(lead)
+}
+
+// derivedTempInterval widens a point temperature forecast lead days ahead.
+func derivedTempInterval(temp float64, lead int) valueInterval {
+	w := intervalWidth(lead, derivedTempBase, derivedTempPerDay)
+	return valueInterval{Low: round1(temp - w), High: round1(temp + w), Source: "derived"}
+}
+
+// derivedPrecipInterval widens a point precipitation forecast lead days ahead,
+// never below zero. The width is relative to the amount, so a forecast of no
+// precipitation stays 0 to 0.
+func derivedPrecipInterval(precip float64, lead int) valueInterval {
+	w := math.Min(intervalWidth(lead, derivedPrecipBase, derivedPrecipPerDay), 1)
+	return valueInterval{Low: round1(precip * (1 - w)), High: round1(precip * (1 + w)), Source: "derived"}
+}
+
+// applyIntervals adds an intervals object to every day. The day's temperature
+// range is the one interval Visual Crossing provides, from tempmin to tempmax.
+// With derived, point forecasts of tempmax, tempmin and precip also get a naive
+// interval widening with the day's distance from today; past days keep their
+// observed values as zero-width intervals.
+func applyIntervals(data map[string]interface{}, today time.Time, derived bool) {
+	days, _ := data["days"].([]interface{})
+	for _, d := range days {
+		day, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		intervals := map[string]valueInterval{}
+		tempMax, hasMax := day["tempmax"].(float64)
+		tempMin, hasMin := day["tempmin"].(float64)
+		if hasMax && hasMin {
+			intervals["temp"] = valueInterval{Low: tempMin, High: tempMax, Source: "upstream"}
+		}
+		date, _ := day["datetime"].(string)
+		if lead, ok := leadDays(date, today); ok && derived {
+			if hasMax {
+				intervals["tempmax"] = derivedTempInterval(tempMax, lead)
+			}
+			if hasMin {
+				intervals["tempmin"] = derivedTempInterval(tempMin, lead)
+			}
+			if precip, ok := day["precip"].(float64); ok {
+				intervals["precip"] = derivedPrecipInterval(precip, lead)
+			}
+		}
+		if len(intervals) > 0 {
+			day["intervals"] = intervals
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDerivedIntervals(t *testing.T) {
+	for _, tc := range []struct {
+		value      float64
+		lead       int
+		temp, prec valueInterval
+	}{
+		{10, -2, valueInterval{10, 10, "derived"}, valueInterval{10, 10, "derived"}},
+		{10, 0, valueInterval{9, 11, "derived"}, valueInterval{7.5, 12.5, "derived"}},
+		{10, 5, valueInterval{7, 13, "derived"}, valueInterval{2.5, 17.5, "derived"}},
+		// The precipitation width is capped at the whole amount, never below zero.
+		{10, 14, valueInterval{3.4, 16.6, "derived"}, valueInterval{0, 20, "derived"}},
+		{0, 3, valueInterval{-2.2, 2.2, "derived"}, valueInterval{0, 0, "derived"}},
+	} {
+		if got := derivedTempInterval(tc.value, tc.lead); got != tc.temp {
+			t.Errorf("derivedTempInterval(%v, %d) = %+v, want %+v", tc.value, tc.lead, got, tc.temp)
+		}
+		if got := derivedPrecipInterval(tc.value, tc.lead); got != tc.prec {
+			t.Errorf("derivedPrecipInterval(%v, %d) = %+v, want %+v", tc.value, tc.lead, got, tc.prec)
+		}
+	}
+}
+
+func TestApplyIntervals(t *testing.T) {
+	today := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	newDays := func() []interface{} {
+		return []interface{}{
+			map[string]interface{}{"datetime": "2026-10-16", "tempmax": 20.0, "tempmin": 10.0, "precip": 4.0},
+			map[string]interface{}{"datetime": "2026-10-13", "tempmax": 18.0},
+			map[string]interface{}{"datetime": "someday"},
+		}
+	}
+	intervalsOf := func(days []interface{}, i int) interface{} {
+		return days[i].(map[string]interface{})["intervals"]
+	}
+
+	days := newDays()
+	applyIntervals(map[string]interface{}{"days": days}, today, false)
+	want := map[string]valueInterval{"temp": {10, 20, "upstream"}}
+	if got := intervalsOf(days, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("upstream intervals = %v, want %v", got, want)
+	}
+	if got := intervalsOf(days, 1); got != nil {
+		t.Errorf("day without a range got %v", got)
+	}
+
+	days = newDays()
+	applyIntervals(map[string]interface{}{"days": days}, today, true)
+	want = map[string]valueInterval{
+		"temp":    {10, 20, "upstream"},
+		"tempmax": {18.2, 21.8, "derived"},
+		"tempmin": {8.2, 11.8, "derived"},
+		"precip":  {2.2, 5.8, "derived"},
+	}
+	if got := intervalsOf(days, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("derived intervals = %v, want %v", got, want)
+	}
+	// Past days were observed: zero-width.
+	if got, want := intervalsOf(days, 1), map[string]valueInterval{"tempmax": {18, 18, "derived"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("past day intervals = %v, want %v", got, want)
+	}
+	if got := intervalsOf(days, 2); got != nil {
+		t.Errorf("undated day got %v", got)
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Job statuses.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// Redis keys of the job API: each job's state lives under job:<id>, its result
+// under job:<id>:result, the IDs of jobs waiting for a worker in a list and the
+// ID of the job a worker is running in a list of its own, see
+// jobProcessingKey.
+const (
+	jobKeyPrefix        = "job:"
+	jobQueueKey         = "jobs:queue"
+	jobProcessingPrefix = "jobs:processing:"
+)
+
+// jobPollTimeout is how long an idle worker blocks waiting for a job before
+// checking for shutdown.
+const jobPollTimeout = 5 * time.Second
+
+// weatherJob is the state of one bulk weather extraction, see POST /weather/jobs.
+type weatherJob struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Location    string     `json:"location"`
+	Start       string     `json:"start"`
+	End         string     `json:"end"`
+	Lang        string     `json:"lang,omitempty"`
+	ChunksDone  int        `json:"chunksDone"`
+	ChunksTotal int        `json:"chunksTotal"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// jobKey returns the Redis key of a job's state.
+func jobKey(id string) string {
+	return jobKeyPrefix + id
+}
+
+// jobResultKey returns the Redis key of a finished job's data.
+func jobResultKey(id string) string {
+	return jobKeyPrefix + id + ":result"
+}
+
+// jobProcessingKey returns the Redis list holding the job that worker n of the
+// instance with the given JOB_WORKER_ID is running. A job stays in it until the
+// worker is done with it, so one whose worker died is found there.
+func jobProcessingKey(instance string, n int) string {
+	return jobProcessingPrefix + instance + ":" + strconv.Itoa(n)
+}
+
+// saveJob stores a job's state for JOB_TTL.
+func saveJob(ctx context.Context, job *weatherJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return redisClient.Set(ctx, jobKey(job.ID), b, cfg.JobTTL).Err()
+}
+
+// loadJob reads a job's state, returning redis.Nil for unknown or expired jobs.
+func loadJob(ctx context.Context, id string) (*weatherJob, error) {
+	raw, err := redisClient.Get(ctx, jobKey(id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var job weatherJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// jobChunk is one date range of a job, fetched as a single lookup.
+type jobChunk struct {
+	Start, End string
+}
+
+// jobChunks splits the days from start to end into ranges of at most size days.
+func jobChunks(start, end time.Time, size int) []jobChunk {
+	var chunks []jobChunk
+	for from := start; !from.After(end); from = from.AddDate(0, 0, size) {
+		to := from.AddDate(0, 0, size-1)
+		if to.After(end) {
+			to = end
+		}
+		chunks = append(chunks, jobChunk{Start: from.Format(dateLayout), End: to.Format(dateLayout)})
+	}
+	return chunks
+}
+
+// jobRange validates the date range of a new job: start is required, end
+// defaults to start, the range may reach back to 1970 and forward as far as
+// MAX_FORECAST_DAYS, and it may span at most JOB_MAX_DAYS days.
+func jobRange(startRaw, endRaw string, now time.Time) (time.Time, time.Time, error) {
+	if startRaw == "" {
+		return time.Time{}, time.Time{}, errors.New("start must be provided")
+	}
+	start, err := time.Parse(dateLayout, startRaw)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q, expected YYYY-MM-DD", startRaw)
+	}
+	end := start
+	if endRaw != "" {
+		end, err = time.Parse(dateLayout, endRaw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q, expected YYYY-MM-DD", endRaw)
+		}
+	}
+	switch latest := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, cfg.MaxForecastDays); {
+	case end.Before(start):
+		return time.Time{}, time.Time{}, fmt.Errorf("end date %s is before start date %s", endRaw, startRaw)
+	case start.Year() < earliestHistoryYear:
+		return time.Time{}, time.Time{}, fmt.Errorf("start must not be before %d", earliestHistoryYear)
+	case end.After(latest):
+		return time.Time{}, time.Time{}, fmt.Errorf("end must not be after %s", latest.Format(dateLayout))
+	case int(end.Sub(start).Hours()/24)+1 > cfg.JobMaxDays:
+		return time.Time{}, time.Time{}, fmt.Errorf("jobs may span at most %d days", cfg.JobMaxDays)
+	}
+	return start, end, nil
+}
+
+// createJobHandler handles POST /weather/jobs, queueing the extraction of a
+// long date range for a location from a {"location","start","end","lang"} body.
+// It answers 202 with the new job, whose progress GET /weather/jobs/:id reports.
+func createJobHandler(c *gin.Context) {
+	var body struct {
+		Location string `json:"location"`
+		Start    string `json:"start"`
+		End      string `json:"end"`
+		Lang     string `json:"lang"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Location == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `expected a JSON body like {"location":"London","start":"2020-01-01","end":"2023-12-31"}`})
+		return
+	}
+	location, err := parseLocation(queryParams{Location: body.Location})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if !locationAllowed(location.Upstream) {
+		writeError(c, errLocationNotAllowed)
+		return
+	}
+	start, end, err := jobRange(body.Start, body.End, clock.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	lang := cfg.DefaultLang
+	if body.Lang != "" {
+		if lang, err = parseLang(body.Lang); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job := &weatherJob{
+		ID:          newRequestID(),
+		Status:      jobQueued,
+		Location:    location.Upstream,
+		Start:       start.Format(dateLayout),
+		End:         end.Format(dateLayout),
+		Lang:        lang,
+		ChunksTotal: len(jobChunks(start, end, cfg.JobChunkDays)),
+		CreatedAt:   clock.Now().UTC(),
+	}
+	if err := saveJob(ctx, job); err != nil {
+		log.Printf("Error saving job %s: %v", job.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if err := redisClient.LPush(ctx, jobQueueKey, job.ID).Err(); err != nil {
+		log.Printf("Error queueing job %s: %v", job.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Header("Location", "/weather/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// getJobHandler handles GET /weather/jobs/:id, returning the job's state and,
+// once it is done, the aggregated weather data under "data".
+func getJobHandler(c *gin.Context) {
+	job, err := loadJob(ctx, c.Param("id"))
+	if err == redis.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading job %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	resp := struct {
+		*weatherJob
+		Data json.RawMessage `json:"data,omitempty"`
+	}{weatherJob: job}
+	if job.Status == jobDone {
+		resp.Data, err = redisClient.Get(ctx, jobResultKey(job.ID)).Bytes()
+		if err != nil {
+			log.Printf("Error reading the result of job %s: %v", job.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// startJobWorkers runs n workers taking queued jobs from Redis until ctx is
+// done. Jobs left in the processing lists of instance by an earlier run that
+// died mid-job are queued again first, see recoverJobs. The returned channel is
+// closed once the workers have all stopped.
+func startJobWorkers(ctx context.Context, instance string, n int) <-chan struct{} {
+	if recovered, err := recoverJobs(ctx, instance); err != nil {
+		log.Printf("Error recovering interrupted jobs: %v", err)
+	} else if recovered > 0 {
+		log.Printf("Queued %d jobs interrupted by an earlier run of %s again", recovered, instance)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		processing := jobProcessingKey(instance, i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				id, err := redisClient.BLMove(ctx, jobQueueKey, processing, "RIGHT", "LEFT", jobPollTimeout).Result()
+				switch {
+				case ctx.Err() != nil || err == redis.Nil:
+					continue
+				case err != nil:
+					log.Printf("Error waiting for jobs: %v", err)
+					select {
+					case <-ctx.Done():
+					case <-time.After(time.Second):
+					}
+					continue
+				}
+				runJob(ctx, id, processing)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// recoverJobs moves the jobs left in the processing lists of instance back to
+// the front of the queue, marked queued again, and returns how many it moved.
+// It runs before the instance's workers start, so every job still in their
+// lists was interrupted.
+func recoverJobs(ctx context.Context, instance string) (int, error) {
+	recovered := 0
+	iter := redisClient.Scan(ctx, 0, jobProcessingPrefix+instance+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		for {
+			id, err := redisClient.LMove(ctx, iter.Val(), jobQueueKey, "LEFT", "RIGHT").Result()
+			if err == redis.Nil {
+				break
+			}
+			if err != nil {
+				return recovered, err
+			}
+			recovered++
+			if job, err := loadJob(ctx, id); err == nil {
+				job.Status = jobQueued
+				if err := saveJob(ctx, job); err != nil {
+					log.Printf("Error saving job %s: %v", id, err)
+				}
+			}
+		}
+	}
+	return recovered, iter.Err()
+}
+
+// runJob processes one queued job, taken into the worker's processing list. A
+// job interrupted by shutdown goes back to the front of the queue; every chunk
+// it completed is in the weather cache, so the next run resumes without
+// refetching them. Once the job is settled it leaves the processing list.
+func runJob(ctx context.Context, id, processing string) {
+	job, err := loadJob(ctx, id)
+	if err != nil {
+		log.Printf("Skipping job %s: %v", id, err)
+		releaseJob(id, processing)
+		return
+	}
+	job.Status, job.ChunksDone = jobRunning, 0
+	if err := saveJob(ctx, job); err != nil {
+		log.Printf("Error saving job %s: %v", id, err)
+	}
+
+	data, err := collectJob(ctx, job)
+	if ctx.Err() != nil {
+		// Use a fresh context: ctx is already cancelled.
+		bg := context.Background()
+		job.Status = jobQueued
+		if err := saveJob(bg, job); err != nil {
+			log.Printf("Error saving job %s: %v", id, err)
+		}
+		if err := redisClient.LMove(bg, processing, jobQueueKey, "LEFT", "RIGHT").Err(); err != nil {
+			log.Printf("Error requeueing job %s: %v", id, err)
+		}
+		return
+	}
+	defer releaseJob(id, processing)
+
+	var encoded []byte
+	if err == nil {
+		encoded, err = json.Marshal(data)
+	}
+	if err == nil {
+		err = redisClient.Set(ctx, jobResultKey(id), encoded, cfg.JobTTL).Err()
+	}
+	completed := clock.Now().UTC()
+	job.CompletedAt = &completed
+	job.Status = jobDone
+	if err != nil {
+		job.Status = jobFailed
+		job.Error = err.Error()
+		var ae *apiError
+		if errors.As(err, &ae) {
+			job.Error = ae.Message
+		}
+		log.Printf("Job %s for %s failed: %v", id, job.Location, err)
+	}
+	if err := saveJob(ctx, job); err != nil {
+		log.Printf("Error saving job %s: %v", id, err)
+	}
+}
+
+// releaseJob removes a settled job from the worker's processing list. It
+// doesn't use the worker's context, as a job finished just before shutdown
+// must not be run again.
+func releaseJob(id, processing string) {
+	if err := redisClient.LRem(context.Background(), processing, 1, id).Err(); err != nil {
+		log.Printf("Error releasing job %s: %v", id, err)
+	}
+}
+
+// collectJob looks up every chunk of a job as /weather would, so chunks are
+// served from the cache when possible and fetched through the upstream queue
+// otherwise, and concatenates their days under the location fields of the
+// first chunk. It pauses JOB_CHUNK_DELAY_MS after every chunk fetched
+// upstream, keeping bulk extractions from crowding out interactive traffic.
+func collectJob(ctx context.Context, job *weatherJob) (map[string]interface{}, error) {
+	start, _ := time.Parse(dateLayout, job.Start)
+	end, _ := time.Parse(dateLayout, job.End)
+	chunks := jobChunks(start, end, cfg.JobChunkDays)
+	now := clock.Now()
+	today := now.UTC().Format(dateLayout)
+
+	var out map[string]interface{}
+	days := []interface{}{}
+	for i, chunk := range chunks {
+		q := weatherQuery{Location: job.Location, Start: chunk.Start, End: chunk.End, Include: endpointInclude("/weather/jobs"), Lang: job.Lang}
+		q = routeQuery(q, "/weather/jobs", now)
+		q.Immutable = chunk.End < today
+		result, err := lookupWeather(ctx, q, lookupOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = make(map[string]interface{}, len(result.Data))
+			for k, v := range result.Data {
+				out[k] = v
+			}
+		}
+		if chunkDays, ok := result.Data["days"].([]interface{}); ok {
+			days = append(days, chunkDays...)
+		}
+
+		job.ChunksDone = i + 1
+		if err := saveJob(ctx, job); err != nil {
+			log.Printf("Error saving job %s: %v", job.ID, err)
+		}
+		if result.Upstream != nil && i < len(chunks)-1 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(cfg.JobChunkDelay):
+			}
+		}
+	}
+	out["days"] = days
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// queueInterruptedJob stores a job as running in worker 0's processing list of
+// instance, as a worker that died mid-job leaves it.
+func queueInterruptedJob(t *testing.T, instance, id string) {
+	t.Helper()
+	job := &weatherJob{ID: id, Status: jobRunning, Location: "london", Start: "2026-10-01", End: "2026-10-02", ChunksTotal: 1, CreatedAt: time.Now().UTC()}
+	if err := saveJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := redisClient.LPush(ctx, jobProcessingKey(instance, 0), id).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverJobs(t *testing.T) {
+	mr := setupTest(t, testConfig(t), nil)
+	queueInterruptedJob(t, "worker-a", "interrupted")
+	queueInterruptedJob(t, "worker-b", "other")
+	mr.Lpush(jobQueueKey, "waiting")
+
+	n, err := recoverJobs(ctx, "worker-a")
+	if err != nil || n != 1 {
+		t.Fatalf("recoverJobs = %d, %v; want 1", n, err)
+	}
+	// Workers take jobs from the right, so the recovered one runs next.
+	if queue, _ := mr.List(jobQueueKey); !reflect.DeepEqual(queue, []string{"waiting", "interrupted"}) {
+		t.Errorf("queue %v, want the interrupted job at the front", queue)
+	}
+	if mr.Exists(jobProcessingKey("worker-a", 0)) {
+		t.Error("recovered job left in the processing list")
+	}
+	if job, err := loadJob(ctx, "interrupted"); err != nil || job.Status != jobQueued {
+		t.Errorf("recovered job %+v, %v; want it queued", job, err)
+	}
+	// Another instance's jobs are left to it.
+	if list, _ := mr.List(jobProcessingKey("worker-b", 0)); !reflect.DeepEqual(list, []string{"other"}) {
+		t.Errorf("worker-b's processing list %v, want it untouched", list)
+	}
+}
+
+func TestJobWorkersResumeInterruptedJob(t *testing.T) {
+	c := testConfig(t)
+	c.JobChunkDelay = 0
+	mr := setupTest(t, c, http.HandlerFunc(fakeUpstreamHandler))
+	queueInterruptedJob(t, "worker-a", "interrupted")
+
+	wctx, cancel := context.WithCancel(context.Background())
+	done := startJobWorkers(wctx, "worker-a", 1)
+	defer func() { cancel(); <-done }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := loadJob(ctx, "interrupted")
+		if err == nil && job.Status == jobDone && !mr.Exists(jobProcessingKey("worker-a", 0)) {
+			break
+		}
+		if err == nil && job.Status == jobFailed {
+			t.Fatalf("resumed job failed: %s", job.Error)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("interrupted job not done and released after 10s: %+v, %v", job, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !mr.Exists(jobResultKey("interrupted")) {
+		t.Error("resumed job stored no result")
+	}
+}
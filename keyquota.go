@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keyQuotas holds the configured daily request limits per API key. Keys without an
+// explicit limit use defaultDailyQuota; a limit of zero means unlimited.
+type keyQuotas struct {
+	limits       map[string]int64
+	defaultLimit int64
+}
+
+// limitFor returns the daily limit for key.
+func (q keyQuotas) limitFor(key string) int64 {
+	if l, ok := q.limits[key]; ok {
+		return l
+	}
+	return q.defaultLimit
+}
+
+// parseKeyQuotas parses comma-separated key:limit pairs.
+func parseKeyQuotas(raw string, defaultLimit int64) keyQuotas {
+	q := keyQuotas{limits: make(map[string]int64), defaultLimit: defaultLimit}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, ":")
+		if i <= 0 {
+			log.Printf("Ignoring malformed API_KEY_QUOTAS entry %q", pair)
+			continue
+		}
+		limit, err := strconv.ParseInt(pair[i+1:], 10, 64)
+		if err != nil || limit < 0 {
+			log.Printf("Ignoring malformed API_KEY_QUOTAS entry %q", pair)
+			continue
+		}
+		q.limits[pair[:i]] = limit
+	}
+	return q
+}
+
+// quotaCounterKey returns the Redis key counting today's requests for an API key.
+// The key is hashed so raw API keys never appear in Redis key names.
+func quotaCounterKey(apiKey string, now time.Time) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "quota:" + hex.EncodeToString(sum[:8]) + ":" + now.UTC().Format(dateLayout)
+}
+
+// keyQuotaMiddleware enforces the daily per-key quota for authenticated requests,
+// counting them in Redis with a counter that expires at the next UTC midnight.
+// It must run after authMiddleware. Redis errors are logged and the request is
+// allowed, so a cache outage doesn't take the API down with it.
+func keyQuotaMiddleware(quotas keyQuotas) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetString("apiKey")
+		limit := quotas.limitFor(apiKey)
+		if apiKey == "" || limit == 0 {
+			c.Next()
+			return
+		}
+
+		now := clock.Now()
+		reset := nextMidnightUTC(now)
+		counterKey := quotaCounterKey(apiKey, now)
+
+		pipe := redisClient.TxPipeline()
+		incr := pipe.Incr(ctx, counterKey)
+		pipe.ExpireAt(ctx, counterKey, reset)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Error updating API key quota: %v", err)
+			c.Next()
+			return
+		}
+
+		used := incr.Val()
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if used > limit {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "daily quota exceeded for this API key",
+				"code":  "QUOTA_EXCEEDED",
+			})
+			return
+		}
+		c.Next()
+	}
+}
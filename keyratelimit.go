@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gin-gonic/gin"
+)
+
+// parseKeyRateLimits parses comma-separated key:limit pairs of
+// API_KEY_RATE_LIMITS, limits in requests per second.
+func parseKeyRateLimits(raw string) map[string]float64 {
+	limits := make(map[string]float64)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, ":")
+		if i <= 0 {
+			log.Printf("Ignoring malformed API_KEY_RATE_LIMITS entry %q", pair)
+			continue
+		}
+		limit, err := strconv.ParseFloat(pair[i+1:], 64)
+		if err != nil || limit <= 0 {
+			log.Printf("Ignoring malformed API_KEY_RATE_LIMITS entry %q", pair)
+			continue
+		}
+		limits[pair[:i]] = limit
+	}
+	return limits
+}
+
+// keyRateLimiter limits the weather endpoints per API key instead of per
+// client IP, see API_KEY_RATE_LIMITS: each key in rates has a bucket of its
+// own, shared by all its clients and endpoints, created on the key's first
+// request. Requests without a listed key are limited per client IP at the
+// anonymous rate, or rejected without one.
+type keyRateLimiter struct {
+	rates     map[string]float64
+	anonymous *limiter.Limiter // nil rejects requests without a listed key
+
+	mu       sync.Mutex
+	limiters map[string]*limiter.Limiter
+}
+
+// newKeyRateLimiter returns a keyRateLimiter for rates, with anonymous
+// requests allowed anonymous requests per second per client IP; zero rejects
+// them.
+func newKeyRateLimiter(rates map[string]float64, anonymous float64) *keyRateLimiter {
+	l := &keyRateLimiter{rates: rates, limiters: make(map[string]*limiter.Limiter)}
+	if anonymous > 0 {
+		l.anonymous = tollbooth.NewLimiter(anonymous, nil)
+		// Keyed like the route limiters, see routeLimiter.
+		l.anonymous.SetIPLookups([]string{"X-Real-IP", "RemoteAddr"})
+	}
+	return l
+}
+
+// limiterFor returns the limiter of key, creating it on first use, or nil for
+// keys without a rate.
+func (l *keyRateLimiter) limiterFor(key string) *limiter.Limiter {
+	rate, ok := l.rates[key]
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lmt, ok := l.limiters[key]
+	if !ok {
+		lmt = tollbooth.NewLimiter(rate, nil)
+		l.limiters[key] = lmt
+	}
+	return lmt
+}
+
+// middleware enforces the key's limit, reporting its bucket in the
+// X-RateLimit-* headers like the route limiters. Requests without a listed
+// key get a 401 when there is no anonymous rate.
+func (l *keyRateLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(apiKeyHeader)
+		if lmt := l.limiterFor(key); lmt != nil {
+			if limitByKeys(c, lmt, [][]string{{key}}) {
+				c.Next()
+			}
+			return
+		}
+		if l.anonymous == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "a known " + apiKeyHeader + " header is required"})
+			return
+		}
+		if limitByKeys(c, l.anonymous, tollbooth.BuildKeys(l.anonymous, c.Request)) {
+			c.Next()
+		}
+	}
+}
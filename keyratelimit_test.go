@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseKeyRateLimits(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want map[string]float64
+	}{
+		{"", map[string]float64{}},
+		{"alpha:5, beta:0.5", map[string]float64{"alpha": 5, "beta": 0.5}},
+		{"a:b:2", map[string]float64{"a:b": 2}},
+		{"nolimit,:3,gamma:0,delta:-1,eps:x,zeta:1", map[string]float64{"zeta": 1}},
+	} {
+		if got := parseKeyRateLimits(tc.raw); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseKeyRateLimits(%q) = %v, want %v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+// keyLimitedRouter serves 200 on / behind the limiter.
+func keyLimitedRouter(l *keyRateLimiter) *gin.Engine {
+	router := gin.New()
+	router.GET("/", l.middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// keyLimitedGet sends a request with key (none when empty) from remoteAddr.
+func keyLimitedGet(router *gin.Engine, key, remoteAddr string) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestKeyRateLimiterIndependentKeys(t *testing.T) {
+	router := keyLimitedRouter(newKeyRateLimiter(map[string]float64{"alpha": 1, "beta": 3}, 0))
+
+	if code := keyLimitedGet(router, "alpha", "192.0.2.1:1"); code != http.StatusOK {
+		t.Fatalf("alpha's first request: %d", code)
+	}
+	// alpha's bucket is empty, whichever client uses it.
+	if code := keyLimitedGet(router, "alpha", "192.0.2.2:1"); code != http.StatusTooManyRequests {
+		t.Errorf("alpha's second request: %d, want 429", code)
+	}
+	// beta's own bucket is untouched by alpha's requests.
+	for i := 0; i < 3; i++ {
+		if code := keyLimitedGet(router, "beta", "192.0.2.1:1"); code != http.StatusOK {
+			t.Errorf("beta's request %d: %d", i, code)
+		}
+	}
+	if code := keyLimitedGet(router, "beta", "192.0.2.1:1"); code != http.StatusTooManyRequests {
+		t.Errorf("beta's fourth request: %d, want 429", code)
+	}
+}
+
+func TestKeyRateLimiterAnonymous(t *testing.T) {
+	router := keyLimitedRouter(newKeyRateLimiter(map[string]float64{"alpha": 1}, 1))
+
+	// Requests without a listed key are limited per client IP.
+	for _, key := range []string{"", "unknown"} {
+		addr := "192.0.2.1:1"
+		if key != "" {
+			addr = "192.0.2.2:1"
+		}
+		if code := keyLimitedGet(router, key, addr); code != http.StatusOK {
+			t.Errorf("key %q: first anonymous request %d", key, code)
+		}
+		if code := keyLimitedGet(router, key, addr); code != http.StatusTooManyRequests {
+			t.Errorf("key %q: second anonymous request %d, want 429", key, code)
+		}
+	}
+	if code := keyLimitedGet(router, "alpha", "192.0.2.1:1"); code != http.StatusOK {
+		t.Errorf("alpha limited by the anonymous bucket of its client: %d", code)
+	}
+}
+
+func TestKeyRateLimiterRejectsAnonymous(t *testing.T) {
+	router := keyLimitedRouter(newKeyRateLimiter(map[string]float64{"alpha": 1}, 0))
+	for _, key := range []string{"", "unknown"} {
+		if code := keyLimitedGet(router, key, "192.0.2.1:1"); code != http.StatusUnauthorized {
+			t.Errorf("key %q: %d, want 401", key, code)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultLang is Visual Crossing's own default language, sent without a lang
+// parameter and left out of cache keys.
+const defaultLang = "en"
+
+// supportedLangs are the languages Visual Crossing translates condition
+// descriptions into; "id" returns language-independent condition ids.
+var supportedLangs = map[string]bool{
+	"ar": true, "bg": true, "cs": true, "da": true, "de": true, "el": true,
+	"en": true, "es": true, "fa": true, "fi": true, "fr": true, "he": true,
+	"hu": true, "id": true, "it": true, "ja": true, "ko": true, "nl": true,
+	"pl": true, "pt": true, "ru": true, "sk": true, "sr": true, "sv": true,
+	"tr": true, "uk": true, "vi": true, "zh": true,
+}
+
+// parseLang validates a language code, case-insensitively.
+func parseLang(raw string) (string, error) {
+	lang := strings.ToLower(strings.TrimSpace(raw))
+	if !supportedLangs[lang] {
+		codes := make([]string, 0, len(supportedLangs))
+		for code := range supportedLangs {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return "", fmt.Errorf("unsupported lang %q, expected one of %s", raw, strings.Join(codes, ", "))
+	}
+	return lang, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// locationTimezoneHeader names the time zone of the location a /weather
+// response is for.
+const locationTimezoneHeader = "X-Location-Timezone"
+
+// locationZone returns the time zone of a weather response and its name: the
+// IANA timezone when the upstream reported one this system knows, which gets
+// daylight saving time right at any instant, and otherwise a fixed zone of
+// tzoffset hours named like UTC+05:30. ok is false when the response has
+// neither.
+func locationZone(timezone string, tzoffset interface{}) (loc *time.Location, name string, ok bool) {
+	if timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			return loc, timezone, true
+		}
+	}
+	offset, ok := tzoffset.(float64)
+	if !ok {
+		return nil, "", false
+	}
+	seconds := int(offset * 3600)
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	name = fmt.Sprintf("UTC%c%02d:%02d", sign, seconds/3600, seconds%3600/60)
+	return time.FixedZone(name, int(offset*3600)), name, true
+}
+
+// dataZone is locationZone for decoded weather data.
+func dataZone(data map[string]interface{}) (*time.Location, string, bool) {
+	timezone, _ := data["timezone"].(string)
+	return locationZone(timezone, data["tzoffset"])
+}
+
+// rawZone is locationZone for an encoded cache hit, decoding only the two
+// fields it needs.
+func rawZone(raw json.RawMessage) (*time.Location, string, bool) {
+	var fields struct {
+		Timezone string      `json:"timezone"`
+		TZOffset interface{} `json:"tzoffset"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, "", false
+	}
+	return locationZone(fields.Timezone, fields.TZOffset)
+}
+
+// applyLocalTime adds localTime, the current time at the location in RFC 3339
+// with its UTC offset, to a weather response that reports its time zone.
+func applyLocalTime(data map[string]interface{}, now time.Time) {
+	if loc, _, ok := dataZone(data); ok {
+		data["localTime"] = now.In(loc).Format(time.RFC3339)
+	}
+}
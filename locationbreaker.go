@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// locationBreaker tracks the upstream failures of a single location.
+type locationBreaker struct {
+	consecutive int
+	openUntil   time.Time
+	lastUsed    time.Time
+	probing     bool // a trial lookup is in flight
+}
+
+// locationTrialWait is the Retry-After of lookups refused while the trial
+// lookup of their location is in flight.
+const locationTrialWait = time.Second
+
+// locationBreakerSet holds a circuit breaker per normalised location. After
+// LOCATION_BREAKER_THRESHOLD consecutive upstream failures for a location its
+// lookups fail fast for LOCATION_BREAKER_COOLDOWN; the first lookup after that is
+// let through as a trial while the others keep failing fast, and another
+// failure reopens the breaker straight away.
+// A success forgets the location. A threshold of zero disables the breakers.
+type locationBreakerSet struct {
+	mu        sync.Mutex
+	breakers  map[string]*locationBreaker
+	lastSweep time.Time
+}
+
+var locationBreakers = &locationBreakerSet{breakers: make(map[string]*locationBreaker)}
+
+// allow reports whether an upstream fetch for location may go ahead, and if not
+// how long until its breaker lets a trial through.
+func (s *locationBreakerSet) allow(location string) (bool, time.Duration) {
+	if cfg.LocationBreakerThreshold <= 0 {
+		return true, 0
+	}
+	now := clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[normalizeLocation(location)]
+	if !ok {
+		return true, 0
+	}
+	b.lastUsed = now
+	if now.Before(b.openUntil) {
+		return false, b.openUntil.Sub(now)
+	}
+	if b.consecutive >= cfg.LocationBreakerThreshold {
+		if b.probing {
+			return false, locationTrialWait
+		}
+		b.probing = true
+	}
+	return true, 0
+}
+
+// record notes the outcome of an upstream fetch for location. Only failures the
+// location itself may be responsible for count; see locationFailure.
+func (s *locationBreakerSet) record(location string, err error, info upstreamInfo) {
+	if cfg.LocationBreakerThreshold <= 0 {
+		return
+	}
+	key := normalizeLocation(location)
+	now := clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	if err == nil {
+		if b, ok := s.breakers[key]; ok && b.consecutive >= cfg.LocationBreakerThreshold {
+			log.Printf("Circuit breaker for location %s closed", location)
+		}
+		delete(s.breakers, key)
+		return
+	}
+	b, ok := s.breakers[key]
+	if ok {
+		b.probing = false
+	}
+	if !locationFailure(err, info) {
+		return
+	}
+	if !ok {
+		b = &locationBreaker{}
+		s.breakers[key] = b
+	}
+	b.consecutive++
+	b.lastUsed = now
+	if b.consecutive >= cfg.LocationBreakerThreshold {
+		b.openUntil = now.Add(cfg.LocationBreakerCooldown)
+		log.Printf("Circuit breaker for location %s open for %s after %d consecutive failures, last: %v",
+			location, cfg.LocationBreakerCooldown, b.consecutive, err)
+	}
+}
+
+// sweep drops breakers that haven't been used for LOCATION_BREAKER_IDLE, at most
+// once per that period, so one-off bad locations don't accumulate. The caller
+// holds s.mu.
+func (s *locationBreakerSet) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < cfg.LocationBreakerIdle {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.breakers {
+		if now.Sub(b.lastUsed) >= cfg.LocationBreakerIdle && !now.Before(b.openUntil) {
+			delete(s.breakers, key)
+		}
+	}
+}
+
+// reset closes and forgets every breaker.
+func (s *locationBreakerSet) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakers = make(map[string]*locationBreaker)
+}
+
+// stats returns the number of open breakers and of tracked locations.
+func (s *locationBreakerSet) stats() (open, tracked int) {
+	now := clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.breakers {
+		if now.Before(b.openUntil) {
+			open++
+		}
+	}
+	return open, len(s.breakers)
+}
+
+// locationFailure reports whether a failed fetch says something about the
+// location: an upstream 4xx other than 429, or an empty, malformed or oversized
+// body. Upstream 5xx and 429, transport errors, cancellations, the upstream
+// quota and the fetch queue affect every location alike and don't count.
+func locationFailure(err error, info upstreamInfo) bool {
+	var ae *apiError
+	if errors.As(err, &ae) && ae.UpstreamStatus == 0 {
+		return ae == errUpstreamEmpty || ae == errUpstreamMalformed || ae == errUpstreamTooLarge
+	}
+	return info.Status >= 400 && info.Status < 500 && info.Status != http.StatusTooManyRequests
+}
+
+// locationCircuitError is returned for cache misses on a location whose breaker
+// is open, retryAfter before it lets a trial lookup through.
+func locationCircuitError(retryAfter time.Duration) *apiError {
+	return &apiError{
+		Status:     http.StatusServiceUnavailable,
+		Code:       "LOCATION_CIRCUIT_OPEN",
+		Message:    "upstream lookups for this location keep failing, try again later",
+		RetryAfter: retryAfter,
+	}
+}
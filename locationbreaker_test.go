@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLocationFailure(t *testing.T) {
+	upstream := func(status int) (error, upstreamInfo) {
+		return &apiError{Status: http.StatusBadGateway, UpstreamStatus: status}, upstreamInfo{Status: status}
+	}
+	for _, tc := range []struct {
+		name string
+		err  error
+		info upstreamInfo
+		want bool
+	}{
+		{"empty", errUpstreamEmpty, upstreamInfo{Status: http.StatusOK}, true},
+		{"malformed", errUpstreamMalformed, upstreamInfo{Status: http.StatusOK}, true},
+		{"too large", errUpstreamTooLarge, upstreamInfo{Status: http.StatusOK}, true},
+		{"queue full", errUpstreamQueueFull, upstreamInfo{}, false},
+		{"transport", errors.New("connection reset"), upstreamInfo{}, false},
+		{"canceled", context.Canceled, upstreamInfo{}, false},
+	} {
+		if got := locationFailure(tc.err, tc.info); got != tc.want {
+			t.Errorf("%s: locationFailure = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	for status, want := range map[int]bool{400: true, 404: true, 422: true, 401: true, 429: false, 500: false, 502: false, 503: false} {
+		if got := locationFailure(upstream(status)); got != want {
+			t.Errorf("upstream %d: locationFailure = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestLocationBreaker(t *testing.T) {
+	c := testConfig(t)
+	c.LocationBreakerThreshold = 2
+	c.LocationBreakerCooldown = time.Minute
+	c.LocationBreakerIdle = time.Hour
+	setupTest(t, c, nil)
+	oldClock := clock
+	t.Cleanup(func() { clock = oldClock })
+	start := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) { clock = fixedClock{start.Add(d)} }
+
+	notFound := &apiError{Status: http.StatusNotFound, UpstreamStatus: http.StatusNotFound}
+	missing := upstreamInfo{Status: http.StatusNotFound}
+	s := &locationBreakerSet{breakers: make(map[string]*locationBreaker)}
+
+	// Each step acts at an offset from start; "fail" and "ok" record an outcome,
+	// "allow" checks a lookup may go ahead.
+	for i, step := range []struct {
+		at     time.Duration
+		action string
+		want   bool // for allow
+		open   int
+	}{
+		{0, "fail", false, 0},
+		{0, "allow", true, 0},
+		{time.Second, "fail", false, 1},
+		{2 * time.Second, "allow", false, 1},
+		// After the cooldown one trial goes ahead, concurrent lookups don't.
+		{2 * time.Minute, "allow", true, 0},
+		{2 * time.Minute, "allow", false, 0},
+		{2 * time.Minute, "fail", false, 1},
+		{2*time.Minute + time.Second, "allow", false, 1},
+		// A trial ending in a failure that says nothing about the location
+		// lets the next one through.
+		{4 * time.Minute, "allow", true, 0},
+		{4 * time.Minute, "outage", false, 0},
+		{4 * time.Minute, "allow", true, 0},
+		{4 * time.Minute, "ok", false, 0},
+		{4 * time.Minute, "allow", true, 0},
+	} {
+		at(step.at)
+		switch step.action {
+		case "fail":
+			s.record("London", notFound, missing)
+		case "outage":
+			s.record("London", &apiError{Status: http.StatusServiceUnavailable, UpstreamStatus: 503}, upstreamInfo{Status: 503})
+		case "ok":
+			s.record("London", nil, upstreamInfo{Status: http.StatusOK})
+		case "allow":
+			if ok, retryAfter := s.allow("london"); ok != step.want {
+				t.Errorf("step %d: allow = %v (retry after %s), want %v", i, ok, retryAfter, step.want)
+			}
+		}
+		if open, _ := s.stats(); open != step.open {
+			t.Errorf("step %d (%s): %d open breakers, want %d", i, step.action, open, step.open)
+		}
+	}
+	if _, tracked := s.stats(); tracked != 0 {
+		t.Errorf("%d breakers tracked after a success, want 0", tracked)
+	}
+}
+
+func TestLocationBreakerOutageDoesNotTrip(t *testing.T) {
+	c := testConfig(t)
+	c.LocationBreakerThreshold = 1
+	setupTest(t, c, nil)
+	s := &locationBreakerSet{breakers: make(map[string]*locationBreaker)}
+
+	for _, status := range []int{500, 502, 503, 429} {
+		s.record("London", &apiError{Status: http.StatusServiceUnavailable, UpstreamStatus: status}, upstreamInfo{Status: status})
+	}
+	if ok, _ := s.allow("London"); !ok {
+		t.Error("upstream outage opened the location's breaker")
+	}
+}
+
+func TestLocationBreakerSweep(t *testing.T) {
+	c := testConfig(t)
+	c.LocationBreakerThreshold = 3
+	c.LocationBreakerCooldown = time.Minute
+	c.LocationBreakerIdle = 10 * time.Minute
+	setupTest(t, c, nil)
+	oldClock := clock
+	t.Cleanup(func() { clock = oldClock })
+	start := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	notFound := &apiError{Status: http.StatusNotFound, UpstreamStatus: http.StatusNotFound}
+	missing := upstreamInfo{Status: http.StatusNotFound}
+	s := &locationBreakerSet{breakers: make(map[string]*locationBreaker)}
+
+	clock = fixedClock{start}
+	s.record("Paris", notFound, missing)
+	s.record("Nowhere", notFound, missing)
+	for i := 0; i < 3; i++ {
+		s.record("Atlantis", notFound, missing)
+	}
+	// Paris is looked up again, Nowhere not; Atlantis' breaker stays open.
+	clock = fixedClock{start.Add(8 * time.Minute)}
+	s.allow("Paris")
+	s.breakers["atlantis"].openUntil = start.Add(time.Hour)
+
+	for _, tc := range []struct {
+		at      time.Duration
+		tracked int
+	}{
+		{11 * time.Minute, 2}, // Nowhere idle for 11 minutes
+		{15 * time.Minute, 2}, // no sweep within LOCATION_BREAKER_IDLE of the last
+		{22 * time.Minute, 1}, // Paris idle too, Atlantis still open
+		{2 * time.Hour, 0},    // Atlantis closed and idle
+	} {
+		clock = fixedClock{start.Add(tc.at)}
+		s.record("Elsewhere", nil, upstreamInfo{Status: http.StatusOK})
+		if _, tracked := s.stats(); tracked != tc.tracked {
+			t.Errorf("at %s: %d breakers tracked, want %d", tc.at, tracked, tc.tracked)
+		}
+	}
+}
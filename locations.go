@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLocationBytes caps the length of a location after whitespace is collapsed.
+const maxLocationBytes = 256
+
+// errInvalidLocation is returned for locations that are empty, too long, not
+// valid UTF-8 or contain control characters.
+var errInvalidLocation = &apiError{
+	Status:  http.StatusBadRequest,
+	Code:    "INVALID_LOCATION",
+	Message: fmt.Sprintf("location must be 1 to %d bytes of printable UTF-8 text", maxLocationBytes),
+}
+
+// parsedLocation is a location query parameter ready for lookup.
+type parsedLocation struct {
+	Upstream string   // location sent to the upstream
+	Key      string   // cache key component, see normalizeLocation
+	Airport  *airport // set when the location was an IATA airport code
+}
+
+// parseLocation turns the location query parameter into the location sent to
+// the upstream and its cache key component. Aliases are resolved, runs of
+// whitespace collapse to single spaces, known IATA airport codes become the
+// airport's coordinates, and "lat,lon" pairs within range are rewritten in a
+// canonical form ("51.50, -0.12" becomes "51.5,-0.12"). Anything else is free
+// text, passed on as typed; its key component is the lower-cased form. A zip
+// query parameter replaces the location, see postalLocation.
+func parseLocation(p queryParams) (parsedLocation, error) {
+	if p.Zip != "" {
+		location, err := postalLocation(p.Zip, p.Country)
+		if err != nil {
+			return parsedLocation{}, err
+		}
+		return parsedLocation{Upstream: location, Key: normalizeLocation(location)}, nil
+	}
+	if !utf8.ValidString(p.Location) {
+		return parsedLocation{}, errInvalidLocation
+	}
+	location := resolveLocation(strings.Join(strings.Fields(p.Location), " "))
+	if location == "" || len(location) > maxLocationBytes {
+		return parsedLocation{}, errInvalidLocation
+	}
+	for _, r := range location {
+		if !unicode.IsPrint(r) {
+			return parsedLocation{}, errInvalidLocation
+		}
+	}
+
+	var parsed parsedLocation
+	if a, ok := lookupAirport(location); ok {
+		parsed.Airport = &a
+		location = fmt.Sprintf("%g,%g", a.Lat, a.Lon)
+	}
+	if coords, ok := canonicalCoordinates(location); ok {
+		location = coords
+	}
+	parsed.Upstream, parsed.Key = location, normalizeLocation(location)
+	return parsed, nil
+}
+
+// canonicalCoordinates rewrites a "lat,lon" location with each number in its
+// shortest form, reporting false for anything that isn't a pair of in-range
+// coordinates.
+func canonicalCoordinates(location string) (string, bool) {
+	latRaw, lonRaw, ok := strings.Cut(location, ",")
+	if !ok {
+		return "", false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latRaw), 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return "", false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonRaw), 64)
+	if err != nil || math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return "", false
+	}
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64), true
+}
+
+// loadLocationAliases reads the alias map from raw, which is either a JSON object
+// or the path of a file containing one. Alias keys are lower-cased.
+func loadLocationAliases(raw string) (map[string]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	data := []byte(raw)
+	if !strings.HasPrefix(raw, "{") {
+		b, err := os.ReadFile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read alias file: %v", err)
+		}
+		data = b
+	}
+
+	var parsed map[string]string
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse aliases: %v", err)
+	}
+	aliases := make(map[string]string, len(parsed))
+	for alias, target := range parsed {
+		aliases[normalizeLocation(alias)] = target
+	}
+	return aliases, nil
+}
+
+// resolveLocation substitutes a configured alias with its target location, so
+// aliases pointing at the same place share upstream calls and cache entries.
+func resolveLocation(location string) string {
+	if target, ok := cfg.LocationAliases[normalizeLocation(location)]; ok {
+		return target
+	}
+	return location
+}
+
+// normalizeLocation is the comparison form of a location: trimmed and lower-cased,
+// so "London" and " london" name the same cache entry.
+func normalizeLocation(location string) string {
+	return strings.ToLower(strings.TrimSpace(location))
+}
+
+// loadLocationWhitelist reads the allowed locations from raw, which is either a
+// comma-separated list or the path of a file with one location per line.
+// Entries are normalised; an empty raw disables the whitelist.
+func loadLocationWhitelist(raw string) (map[string]bool, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	entries := strings.Split(raw, ",")
+	if info, err := os.Stat(raw); err == nil && info.Mode().IsRegular() {
+		b, err := os.ReadFile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read whitelist file: %v", err)
+		}
+		entries = strings.Split(string(b), "\n")
+	}
+
+	allowed := make(map[string]bool)
+	for _, e := range entries {
+		if e = normalizeLocation(e); e != "" {
+			allowed[e] = true
+		}
+	}
+	return allowed, nil
+}
+
+// errLocationNotAllowed is returned for locations outside LOCATION_WHITELIST.
+var errLocationNotAllowed = &apiError{
+	Status:  http.StatusForbidden,
+	Code:    "LOCATION_NOT_ALLOWED",
+	Message: "location is not allowed",
+}
+
+// locationAllowed reports whether the (alias-resolved) location may be served.
+func locationAllowed(location string) bool {
+	return cfg.LocationWhitelist == nil || cfg.LocationWhitelist[normalizeLocation(location)]
+}
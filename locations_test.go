@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FuzzParseLocation checks parseLocation never panics, fails only with
+// errInvalidLocation, and otherwise returns a collapsed, printable location
+// whose key is its normalised form.
+func FuzzParseLocation(f *testing.F) {
+	for _, seed := range []string{
+		"London", "  new   york ", "51.50, -0.12", "91,0", "LHR", "lhr",
+		"", " ", "\x00", "a\tb", "\xff", "Zürich", strings.Repeat("a", maxLocationBytes+1),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, location string) {
+		parsed, err := parseLocation(queryParams{Location: location})
+		if err != nil {
+			if err != errInvalidLocation {
+				t.Fatalf("parseLocation(%q) error %v, want errInvalidLocation", location, err)
+			}
+			if parsed != (parsedLocation{}) {
+				t.Fatalf("parseLocation(%q) failed but returned %+v", location, parsed)
+			}
+			return
+		}
+
+		up := parsed.Upstream
+		if up == "" || len(up) > maxLocationBytes || !utf8.ValidString(up) {
+			t.Fatalf("parseLocation(%q) upstream %q is empty, too long or invalid UTF-8", location, up)
+		}
+		if strings.TrimSpace(up) != up || strings.Contains(up, "  ") {
+			t.Fatalf("parseLocation(%q) upstream %q has uncollapsed whitespace", location, up)
+		}
+		for _, r := range up {
+			if !unicode.IsPrint(r) {
+				t.Fatalf("parseLocation(%q) upstream %q contains %U", location, up, r)
+			}
+		}
+		if parsed.Key != normalizeLocation(up) {
+			t.Fatalf("parseLocation(%q) key %q, want %q", location, parsed.Key, normalizeLocation(up))
+		}
+
+		// Parsing is idempotent: the upstream form parses to itself.
+		again, err := parseLocation(queryParams{Location: up})
+		if err != nil || again.Upstream != up || again.Key != parsed.Key {
+			t.Fatalf("parseLocation(%q) = %+v, %v; want upstream %q again", up, again, err, up)
+		}
+	})
+}
+
+func TestParseLocation(t *testing.T) {
+	for _, tc := range []struct {
+		in, upstream, key string
+		airport           bool
+	}{
+		{"London", "London", "london", false},
+		{"  New   York ", "New York", "new york", false},
+		{"51.50, -0.12", "51.5,-0.12", "51.5,-0.12", false},
+		{"91,0", "91,0", "91,0", false},
+		{"LHR", "51.47,-0.4543", "51.47,-0.4543", true},
+		{"lhr", "lhr", "lhr", false},
+	} {
+		parsed, err := parseLocation(queryParams{Location: tc.in})
+		if err != nil {
+			t.Errorf("parseLocation(%q): %v", tc.in, err)
+			continue
+		}
+		if parsed.Upstream != tc.upstream || parsed.Key != tc.key || (parsed.Airport != nil) != tc.airport {
+			t.Errorf("parseLocation(%q) = %+v, want upstream %q, key %q, airport %v", tc.in, parsed, tc.upstream, tc.key, tc.airport)
+		}
+	}
+	for _, in := range []string{"", "   ", "a\x00b", "\xff", strings.Repeat("a", maxLocationBytes+1)} {
+		if _, err := parseLocation(queryParams{Location: in}); err != errInvalidLocation {
+			t.Errorf("parseLocation(%q) error %v, want errInvalidLocation", in, err)
+		}
+	}
+}
@@ -3,180 +3,1137 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/didip/tollbooth/v7"
-	tollbooth_gin "github.com/didip/tollbooth_gin"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// Global variables for the Redis client and configuration
+// Global variables for the Redis client
 var (
-	ctx             = context.Background()
-	redisClient     *redis.Client
-	apiKey          string
-	apiUrl          string
-	cacheExpiration time.Duration
+	ctx         = context.Background()
+	redisClient *redis.Client
 )
 
-// init loads configuration settings and initializes the Redis client.
-func init() {
-	// Load environment variables from .env if available
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, proceeding with system environment variables")
+// connectRedis initializes the Redis client (and optional read replica, failover
+// and cache shards) from c and verifies the primary connections.
+func connectRedis(c Config) error {
+	opt, err := redis.ParseURL(c.RedisURL)
+	if err != nil {
+		return fmt.Errorf("invalid REDIS_URL: %v", err)
+	}
+	if c.RedisDB >= 0 {
+		opt.DB = c.RedisDB
+	}
+	// Calls end at their context's deadline, such as a request's timeout, not
+	// only at the client's read and write timeouts.
+	opt.ContextTimeoutEnabled = true
+	redisClient = redis.NewClient(opt)
+	// Test Redis connection
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to Redis at %s: %v", c.RedisURL, err)
+	}
+	log.Printf("Connected to Redis at %s (db %d)", c.RedisURL, opt.DB)
+	cacheShards = newShards(redisClient, opt, opt.DB, c.CacheShards)
+	if len(cacheShards) > 0 {
+		for i := 1; i < len(cacheShards); i++ {
+			if err := cacheShards[i].Ping(ctx).Err(); err != nil {
+				return fmt.Errorf("failed to connect to Redis cache shard %d (db %d): %v", i, opt.DB+i, err)
+			}
+		}
+		log.Printf("Sharding the cache over %d Redis databases (db %d to %d)", len(cacheShards), opt.DB, opt.DB+len(cacheShards)-1)
+	}
+
+	// Optionally offload cache reads to a read replica.
+	if c.RedisReplicaURL != "" {
+		replicaOpt, err := redis.ParseURL(c.RedisReplicaURL)
+		if err != nil {
+			return fmt.Errorf("invalid REDIS_REPLICA_URL: %v", err)
+		}
+		replicaOpt.DB = opt.DB
+		replicaOpt.ContextTimeoutEnabled = true
+		replicaClient = redis.NewClient(replicaOpt)
+		replicaShards = newShards(replicaClient, replicaOpt, opt.DB, c.CacheShards)
+		if err := replicaClient.Ping(ctx).Err(); err != nil {
+			log.Printf("Redis replica at %s is unreachable, reads will fall back to the primary: %v", c.RedisReplicaURL, err)
+		} else {
+			log.Println("Connected to Redis replica at", c.RedisReplicaURL)
+		}
+	}
+
+	// Optionally keep caching through an outage of the primary's region.
+	if c.RedisFailoverURL != "" {
+		failoverOpt, err := redis.ParseURL(c.RedisFailoverURL)
+		if err != nil {
+			return fmt.Errorf("invalid REDIS_FAILOVER_URL: %v", err)
+		}
+		failoverOpt.DB = opt.DB
+		failoverOpt.ContextTimeoutEnabled = true
+		failoverClient = redis.NewClient(failoverOpt)
+		if err := failoverClient.Ping(ctx).Err(); err != nil {
+			log.Printf("Failover Redis at %s is unreachable: %v", c.RedisFailoverURL, err)
+		} else {
+			log.Println("Connected to failover Redis at", c.RedisFailoverURL)
+		}
+	}
+	return nil
+}
+
+// upstreamUnitGroup is the Visual Crossing unit system weather is fetched, and
+// cached, in unless a /weather request asks for another with units. The derived
+// endpoints always use it.
+const upstreamUnitGroup = "metric"
+
+// fetchWeatherData constructs the API URL using the provided query and fetches data
+// from the third-party weather API (Visual Crossing). The returned upstreamInfo
+// describes the call for debugging, with the API key masked.
+func fetchWeatherData(ctx context.Context, q weatherQuery) (map[string]interface{}, upstreamInfo, error) {
+	var info upstreamInfo
+	// While the daily quota is known to be exhausted, fail fast instead of
+	// spending another upstream call on a guaranteed rejection.
+	if resetAt, exhausted := quotaExhaustedUntil(clock.Now()); exhausted {
+		return nil, info, quotaError(clock.Now(), resetAt)
 	}
 
-	// Retrieve environment configurations
-	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		redisURL = "localhost:6379"
+	// Build the Visual Crossing API URL.
+	// Example: {API_URL}/{location}[/{start}[/{end}]]?key={API_KEY}&unitGroup=metric&include=days
+	url := fmt.Sprintf("%s/%s?key=%s&unitGroup=%s&include=%s", cfg.APIURL, q.path(), cfg.APIKey, q.unitGroup(), q.upstreamInclude())
+	if q.Lang != "" && q.Lang != defaultLang {
+		url += "&lang=" + q.Lang
 	}
-	apiKey = os.Getenv("VISUAL_CROSSING_API_KEY")
-	if apiKey == "" {
-		log.Fatal("VISUAL_CROSSING_API_KEY must be set")
+	if q.Agri {
+		url += "&elements=" + agriElements
 	}
-	apiUrl = os.Getenv("VISUAL_CROSSING_API_URL")
-	if apiUrl == "" {
-		log.Fatal("VISUAL_CROSSING_API_URL must be set")
+	if len(q.Passthrough) > 0 {
+		url += "&" + q.Passthrough.Encode()
 	}
-	expirationStr := os.Getenv("CACHE_EXPIRATION")
-	if expirationStr == "" {
-		expirationStr = "43200" // Default: 12 hours in seconds
+	info.URL = strings.ReplaceAll(url, cfg.APIKey, "***")
+	team := teamFromContext(ctx)
+	if team != "" {
+		log.Printf("Fetching weather data for team %s from: %s", team, info.URL)
+	} else {
+		log.Println("Fetching weather data from:", info.URL)
 	}
-	expirationSec, err := strconv.Atoi(expirationStr)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Printf("Invalid CACHE_EXPIRATION, defaulting to 43200 seconds")
-		expirationSec = 43200
+		return nil, info, fmt.Errorf("failed to build weather request: %v", err)
 	}
-	cacheExpiration = time.Duration(expirationSec) * time.Second
+	req.Header.Set("Accept-Encoding", upstreamAcceptEncoding)
 
-	// Initialize the Redis client
-	opt, err := redis.ParseURL(redisURL)
+	info.Fetched = clock.Now()
+	upstreamCallsByTeam.record(team)
+	start := time.Now()
+	succeeded := false
+	defer func() { observeUpstreamCall(succeeded, time.Since(start)) }()
+	resp, err := upstreamClient.Do(req)
+	info.Latency = time.Since(start)
 	if err != nil {
-		// Changed from log.Fatal to log.Fatalf to properly apply the format directive.
-		log.Fatalf("failed to fetch weather data: %v", err)
+		// A cancelled or expired request context is the caller's doing, not an
+		// upstream failure.
+		if ctx.Err() == nil {
+			upstreamErrors.record(true)
+		}
+		return nil, info, fmt.Errorf("failed to fetch weather data: %w", err)
 	}
-	redisClient = redis.NewClient(opt)
-	// Test Redis connection
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis at %s: %v", redisURL, err)
+	defer resp.Body.Close()
+	info.Status = resp.StatusCode
+
+	reader, err := decodeUpstreamBody(resp)
+	if err != nil {
+		upstreamErrors.record(true)
+		log.Printf("Undecodable upstream response for location %s: %v", q.Location, err)
+		return nil, info, errUpstreamMalformed
 	}
-	log.Println("Connected to Redis at", redisURL)
+	defer reader.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(reader, cfg.MaxUpstreamResponseBytes))
+		// Client errors such as an unknown location say nothing about upstream health.
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			upstreamErrors.record(true)
+		}
+		if isQuotaExceeded(resp.StatusCode, string(bodyBytes)) {
+			now := clock.Now()
+			markQuotaExceeded(now)
+			log.Printf("Upstream quota exceeded: %s", string(bodyBytes))
+			return nil, info, quotaError(now, nextMidnightUTC(now))
+		}
+		log.Printf("Upstream returned status %d for location %s: %s", resp.StatusCode, q.Location, truncateBytes(bodyBytes, malformedLogBytes))
+		ae := upstreamStatusError(resp.StatusCode, bodyBytes)
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), clock.Now()); ok && ae.Code == "UPSTREAM_UNAVAILABLE" {
+			ae.RetryAfter = retryAfter
+		}
+		return nil, info, ae
+	}
+
+	// Read one byte past the limit so an oversized body is detected rather than
+	// silently truncated into malformed JSON. The limit applies to the decoded
+	// body, so a small compressed body can't expand without bound.
+	body, err := io.ReadAll(io.LimitReader(reader, cfg.MaxUpstreamResponseBytes+1))
+	if err != nil {
+		if ctx.Err() == nil {
+			upstreamErrors.record(true)
+		}
+		return nil, info, fmt.Errorf("failed to read weather data: %w", err)
+	}
+	if int64(len(body)) > cfg.MaxUpstreamResponseBytes {
+		upstreamErrors.record(true)
+		log.Printf("Upstream response for location %s exceeds %d bytes", q.Location, cfg.MaxUpstreamResponseBytes)
+		return nil, info, errUpstreamTooLarge
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		upstreamErrors.record(true)
+		log.Printf("Malformed upstream response for location %s: %v; body starts: %q", q.Location, err, truncateBytes(body, malformedLogBytes))
+		return nil, info, errUpstreamMalformed
+	}
+
+	upstreamErrors.record(false)
+	succeeded = true
+
+	if isEmptyWeather(data) {
+		log.Printf("Upstream returned an empty response for location: %s", q.Location)
+		return nil, info, errUpstreamEmpty
+	}
+
+	info.Cost = estimateUpstreamCost(q, data)
+	upstreamCosts.record(q.upstreamInclude(), info.Cost, data)
+
+	fillFeelsLike(data, q.unitGroup())
+	return data, info, nil
 }
 
-// fetchWeatherData constructs the API URL using the provided location and fetches data
-// from the third-party weather API (Visual Crossing).
-func fetchWeatherData(location string) (map[string]interface{}, error) {
-	// Build the Visual Crossing API URL.
-	// Example: {API_URL}/{location}?key={API_KEY}&unitGroup=metric&include=days
-	url := fmt.Sprintf("%s/%s?key=%s&unitGroup=metric&include=days", apiUrl, location, apiKey)
-	log.Println("Fetching weather data from:", url)
+// parseWeatherQuery reads the location and optional date range shared by every
+// weather endpoint. On invalid input it writes a 400 response and returns false.
+func parseWeatherQuery(c *gin.Context) (weatherQuery, bool) {
+	var p queryParams
+	if !bindQuery(c, &p) {
+		return weatherQuery{}, false
+	}
+	return buildWeatherQuery(c, p)
+}
 
-	resp, err := http.Get(url)
+// buildWeatherQuery is buildWeatherQueryFor the request's own endpoint.
+func buildWeatherQuery(c *gin.Context, p queryParams) (weatherQuery, bool) {
+	return buildWeatherQueryFor(c, p, c.FullPath())
+}
+
+// buildWeatherQueryFor turns validated query parameters into a weatherQuery with the
+// include set and upstream route of the endpoint at path, enforcing the location whitelist, checking the
+// date range against the configured horizons, validating the language and
+// collecting passthrough options. On invalid input it writes a 400 response and returns false.
+func buildWeatherQueryFor(c *gin.Context, p queryParams, path string) (weatherQuery, bool) {
+	location, err := parseLocation(p)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch weather data: %v", err)
+		writeError(c, err)
+		return weatherQuery{}, false
 	}
-	defer resp.Body.Close()
+	q := weatherQuery{Location: location.Upstream, Airport: location.Airport, Start: p.Start, End: p.End, Include: endpointInclude(path)}
+	if !locationAllowed(q.Location) {
+		writeError(c, errLocationNotAllowed)
+		return weatherQuery{}, false
+	}
+	if p.Disambiguate == "true" && q.Airport == nil {
+		if candidates := placeCandidates(q.Location); candidates != nil {
+			writeAmbiguous(c, q.Location, candidates)
+			return weatherQuery{}, false
+		}
+	}
+	if err := validateDateRange(q.Start, q.End, clock.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return weatherQuery{}, false
+	}
+	q = routeQuery(q, path, clock.Now())
+	q.Lang = cfg.DefaultLang
+	if p.Lang != "" {
+		lang, err := parseLang(p.Lang)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return weatherQuery{}, false
+		}
+		q.Lang = lang
+	}
+	passthrough, err := passthroughParams(c.Request.URL.Query(), cfg.PassthroughParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return weatherQuery{}, false
+	}
+	q.Passthrough = passthrough
+	return q, true
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch weather data: status %d, response: %s", resp.StatusCode, string(bodyBytes))
+// loadDays resolves the request's query and returns the typed daily data from
+// the (cached) full response, writing an error response and returning false when
+// that isn't possible. It backs the derived /weather/* endpoints.
+func loadDays(c *gin.Context) (weatherQuery, []weatherDay, bool) {
+	q, _, days, ok := loadWeather(c)
+	return q, days, ok
+}
+
+// loadWeather is loadDays also returning the looked up data itself, for
+// endpoints using more of it than the days.
+func loadWeather(c *gin.Context) (weatherQuery, map[string]interface{}, []weatherDay, bool) {
+	q, ok := parseWeatherQuery(c)
+	if !ok {
+		return q, nil, nil, false
 	}
+	data, days, ok := loadQuery(c, q)
+	return q, data, days, ok
+}
 
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode weather data: %v", err)
+// loadQuery is loadWeather for a query the endpoint has already resolved and
+// adjusted.
+func loadQuery(c *gin.Context, q weatherQuery) (map[string]interface{}, []weatherDay, bool) {
+	result, err := getWeather(c.Request.Context(), q)
+	if err != nil {
+		writeError(c, err)
+		return nil, nil, false
+	}
+	noteLookup(c, q, result)
+
+	days, err := decodeDays(result.Data)
+	if err != nil {
+		log.Printf("Error decoding daily data: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to decode daily weather data"})
+		return nil, nil, false
+	}
+	if len(days) == 0 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "no daily weather data available"})
+		return nil, nil, false
+	}
+	return result.Data, days, true
+}
+
+// noteLookup records a successful lookup: it publishes the analytics event,
+// counts the location, stores the lookup details in the request context for
+// the slow request log and, for fresh fetches, sets X-Upstream-Cost.
+func noteLookup(c *gin.Context, q weatherQuery, result weatherResult) {
+	publishEvent(q, result.Cache)
+	topLocations.record(q.Location)
+	c.Set("location", q.Location)
+	c.Set("cacheStatus", result.Cache)
+	if result.Upstream != nil {
+		c.Set("upstreamLatency", result.Upstream.Latency)
+		if result.Upstream.Cost > 0 {
+			c.Header(upstreamCostHeader, strconv.FormatInt(result.Upstream.Cost, 10))
+		}
+	}
+}
+
+// weatherResult is the outcome of a weather lookup.
+type weatherResult struct {
+	Data  map[string]interface{}
+	Raw   json.RawMessage // cached JSON of the data, set instead of Data by lookupWeather(raw)
+	Cache string          // "HIT" or "STALE" when served from Redis, "MISS" or "BYPASS" when fetched upstream
+	TTL   time.Duration   // remaining fresh cache lifetime on hits, zero otherwise
+
+	// FetchedAt is when the data was fetched from the upstream, preserved across
+	// cache hits; zero for entries cached before it was recorded.
+	FetchedAt time.Time
+
+	// Upstream describes the upstream call made to serve a miss; nil on hits.
+	Upstream *upstreamInfo
+}
+
+// upstreamInfo describes a single upstream call for debugging.
+type upstreamInfo struct {
+	Status  int           // HTTP status, zero if the request failed
+	URL     string        // request URL with the API key masked
+	Latency time.Duration // time until response headers were received
+	Fetched time.Time     // when the request was sent
+	Cost    int64         // estimated records consumed, see estimateUpstreamCost
+}
+
+// getWeather returns the weather data for the query, serving it from Redis when
+// cached and otherwise fetching it from the weather API and caching the result.
+// ctx bounds the upstream fetch, so a client disconnect cancels it.
+func getWeather(ctx context.Context, q weatherQuery) (weatherResult, error) {
+	return lookupWeather(ctx, q, lookupOptions{})
+}
+
+// lookupOptions adjust how lookupWeather uses the cache.
+type lookupOptions struct {
+	// Raw returns cache hits as the cached JSON in weatherResult.Raw, leaving Data
+	// nil, for callers that serve the data untransformed.
+	Raw bool
+	// Bypass skips the cache read and always fetches, still caching the result.
+	Bypass bool
+	// Cached is the lookup's cache entry when it was read ahead, as by
+	// lookupLocations for many lookups at once; "" is a miss. Nil reads it.
+	Cached *string
+}
+
+// lookupWeather implements getWeather with the given options, counting the
+// outcome in lookupCounters. Successful lookups of today may prefetch the
+// forecast, see prefetchForecast.
+func lookupWeather(ctx context.Context, q weatherQuery, opts lookupOptions) (weatherResult, error) {
+	result, err := resolveWeather(ctx, q, opts)
+	lookupCounters.record(result, err)
+	if err == nil {
+		prefetchForecast(q)
+	}
+	return result, err
+}
+
+// resolveWeather serves a lookup from the cache or the upstream.
+func resolveWeather(ctx context.Context, q weatherQuery, opts lookupOptions) (weatherResult, error) {
+	if cfg.MockMode {
+		return mockResult(q), nil
+	}
+	cacheKey := q.cacheKey()
+	raw := opts.Raw
+
+	if opts.Bypass {
+		weatherData, info, err := coalescedFetchAndCache(ctx, q, cacheKey)
+		if err != nil {
+			return weatherResult{}, err
+		}
+		return weatherResult{Data: weatherData, Cache: "BYPASS", FetchedAt: info.Fetched, Upstream: &info}, nil
+	}
+
+	// Attempt to retrieve cached weather data from Redis.
+	var cachedData string
+	var err error
+	switch {
+	case opts.Cached == nil:
+		cachedData, err = cacheGet(ctx, cacheKey)
+	case *opts.Cached == "":
+		err = redis.Nil
+	default:
+		cachedData = *opts.Cached
+	}
+	if err == nil && cacheReferences() {
+		cachedData, err = followCacheRef(ctx, cachedData)
+	}
+	if err == errRedisCircuitOpen {
+		// Redis is known to be down; go to the upstream rather than fail.
+		err = redis.Nil
+	}
+	if isContextError(err) {
+		// The request ran out of time or went away while reading the cache.
+		return weatherResult{}, err
+	}
+	if err != nil && err != redis.Nil {
+		log.Printf("Error retrieving data from Redis: %v", err)
+		return weatherResult{}, errors.New("internal server error")
+	}
+
+	if err == nil {
+		// Cache hit: unwrap the versioned entry from the cache.
+		var weatherData map[string]interface{}
+		var rawData json.RawMessage
+		var fetchedAt time.Time
+		if raw {
+			rawData, fetchedAt, err = decodeEntryRaw(cachedData)
+		} else {
+			weatherData, fetchedAt, err = decodeEntry(cachedData)
+		}
+		var cachedErr *apiError
+		if errors.As(err, &cachedErr) {
+			log.Printf("Serving negatively cached upstream status %d for location: %s", cachedErr.UpstreamStatus, q.Location)
+			return weatherResult{}, cachedErr
+		} else if err == errSchemaMismatch {
+			// Written by a deploy with a different transform; refetch and overwrite.
+			log.Printf("Cache entry %s has an outdated schema version, refetching", cacheKey)
+		} else if err != nil {
+			// A corrupt entry would fail on every hit until it expires, so drop it
+			// and treat the lookup as a miss.
+			cacheCorruptions.Add(1)
+			log.Printf("Cache corruption: discarding unreadable entry %s: %v", cacheKey, err)
+			if err := cacheDelete(cacheKey); err != nil {
+				log.Printf("Error deleting corrupt cache entry %s: %v", cacheKey, err)
+			}
+			if !cfg.RefetchOnCorruption {
+				return weatherResult{}, errors.New("internal server error")
+			}
+		} else if (raw && rawData == nil) || (!raw && isEmptyWeather(weatherData)) {
+			log.Printf("Serving negatively cached empty response for location: %s", q.Location)
+			return weatherResult{}, errUpstreamEmpty
+		} else {
+			if splitsCurrent(q) && currentStale(fetchedAt, clock.Now()) {
+				if raw {
+					if weatherData, err = decodeForCurrent(rawData); err == nil {
+						rawData = nil
+					}
+				}
+				if rawData == nil {
+					refreshCurrent(ctx, q, weatherData)
+				}
+			}
+			result := weatherResult{Data: weatherData, Raw: rawData, Cache: "HIT", FetchedAt: fetchedAt}
+			var freshUntil time.Time
+			if cfg.CacheStaleGrace > 0 {
+				freshUntil = entryFreshUntil(cachedData)
+			}
+			switch {
+			case !freshUntil.IsZero() && !clock.Now().Before(freshUntil):
+				// Past its fresh window but within the grace: answer with it now
+				// and refresh it behind the response.
+				log.Printf("Serving stale weather data for location: %s", q.Location)
+				result.Cache = "STALE"
+				revalidateStale(q, cacheKey)
+			case !freshUntil.IsZero():
+				log.Printf("Serving cached weather data for location: %s", q.Location)
+				result.TTL = freshUntil.Sub(clock.Now())
+				refreshAheadOf(q, cacheKey, fetchedAt, result.TTL)
+			default:
+				log.Printf("Serving cached weather data for location: %s", q.Location)
+				if ttl, err := cacheTTL(ctx, cacheKey); err == nil && ttl > 0 {
+					result.TTL = ttl
+					refreshAheadOf(q, cacheKey, fetchedAt, ttl)
+				}
+			}
+			return result, nil
+		}
+	}
+
+	// Cache miss: fetch the weather data from the API.
+	weatherData, info, err := coalescedFetchAndCache(ctx, q, cacheKey)
+	if err != nil {
+		return weatherResult{}, err
+	}
+	return weatherResult{Data: weatherData, Cache: "MISS", FetchedAt: info.Fetched, Upstream: &info}, nil
+}
+
+// fetchAndCache fetches fresh weather data from the API and stores it in Redis
+// under cacheKey.
+func fetchAndCache(ctx context.Context, q weatherQuery, cacheKey string) (map[string]interface{}, upstreamInfo, error) {
+	var weatherData map[string]interface{}
+	var info upstreamInfo
+	if !upstreamHealth.allow() {
+		return nil, info, errUpstreamDown()
+	}
+	if ok, retryAfter := locationBreakers.allow(q.Location); !ok {
+		return nil, info, locationCircuitError(retryAfter)
+	}
+	err := upstreamQueue.do(ctx, func() error {
+		var err error
+		weatherData, info, err = fetchWithRetries(ctx, q)
+		return err
+	})
+	locationBreakers.record(q.Location, err, info)
+	if err == errUpstreamEmpty && cfg.EmptyResponseTTL > 0 {
+		// Negative-cache the empty result so repeated lookups for the same odd
+		// location don't keep hitting the upstream.
+		if marker, err := encodeEntry(q.canonicalKey(), nil, info.Fetched, time.Time{}); err == nil {
+			if err := cacheSetIfNewer(ctx, cacheKey, marker, info.Fetched, cfg.EmptyResponseTTL); err != nil {
+				log.Printf("Error caching empty weather marker: %v", err)
+			}
+		}
+	}
+	var ae *apiError
+	if errors.As(err, &ae) && negativelyCacheable(ae) && cfg.ClientErrorCacheTTL > 0 {
+		// The same query would be rejected again, so remember the answer.
+		if marker, err := encodeErrorEntry(q.canonicalKey(), ae, info.Fetched); err == nil {
+			if err := cacheSetIfNewer(ctx, cacheKey, marker, info.Fetched, cfg.ClientErrorCacheTTL); err != nil {
+				log.Printf("Error caching upstream error marker: %v", err)
+			}
+		}
+	}
+	if err != nil {
+		return nil, info, err
+	}
+
+	base := cacheExpiration()
+	if cfg.AdaptiveTTL {
+		base = adaptiveTTL(weatherData, base, cfg.AdaptiveTTLMin, cfg.AdaptiveTTLMax)
+	}
+	ttl := effectiveCacheTTL(base)
+	if q.Immutable {
+		ttl = cfg.HistoryCacheTTL
+	}
+	if q.include() == currentInclude && cfg.CurrentConditionsTTL > 0 {
+		ttl = cfg.CurrentConditionsTTL
+	}
+	if reason := incompleteReason(q, weatherData); reason != "" {
+		partialResponses.Add(1)
+		switch cfg.PartialCachePolicy {
+		case partialCacheNever:
+			log.Printf("Not caching partial weather data for location %s: %s", q.Location, reason)
+			return weatherData, info, nil
+		case partialCacheShort:
+			if cfg.PartialCacheTTL < ttl {
+				ttl = cfg.PartialCacheTTL
+			}
+		}
+		log.Printf("Caching partial weather data for location %s for %s: %s", q.Location, ttl, reason)
+	}
+
+	// Marshal the retrieved data into a versioned entry and store it in Redis.
+	// With coordinate dedup the data goes under the resolved coordinates, with
+	// content-addressed caching under its hash, and the query key only
+	// references it.
+	dataKey, dataCanonical := cacheKey, q.canonicalKey()
+	if cfg.ContentAddressedCache {
+		if key, canonical, err := contentKey(weatherData); err == nil {
+			dataKey, dataCanonical = key, canonical
+		}
+	} else if cfg.CoordinateDedup {
+		if cq, ok := coordinateQuery(q, weatherData); ok {
+			dataKey, dataCanonical = cq.cacheKey(), cq.canonicalKey()
+		}
+	}
+	freshUntil, storedTTL := staleWindow(info.Fetched, ttl)
+	jsonData, err := encodeEntry(dataCanonical, weatherData, info.Fetched, freshUntil)
+	if err != nil {
+		log.Printf("Error marshaling weather data: %v", err)
+	} else if cfg.MaxCacheEntryBytes > 0 && len(jsonData) > cfg.MaxCacheEntryBytes {
+		// Outliers are still served, just not kept in Redis.
+		cacheEntriesOversized.Add(1)
+		log.Printf("Not caching weather data for location %s: entry of %d bytes exceeds MAX_CACHE_ENTRY_BYTES (%d)",
+			q.Location, len(jsonData), cfg.MaxCacheEntryBytes)
+	} else {
+		if err := cacheSetIfNewer(ctx, dataKey, jsonData, info.Fetched, storedTTL); err != nil {
+			log.Printf("Error caching weather data: %v", err)
+		}
+		if dataKey != cacheKey {
+			if ref, err := encodeRef(q.canonicalKey(), dataKey); err == nil {
+				if err := cacheSet(ctx, cacheKey, ref, storedTTL); err != nil {
+					log.Printf("Error caching weather reference: %v", err)
+				}
+			}
+		}
 	}
+	log.Printf("Fetched fresh weather data for location: %s", q.Location)
+	return weatherData, info, nil
+}
 
-	return data, nil
+// debugInfo describes how a lookup was served, for debug=true.
+func debugInfo(result weatherResult) gin.H {
+	debug := gin.H{"cache": result.Cache}
+	if result.Upstream != nil {
+		debug["upstreamStatus"] = result.Upstream.Status
+		debug["upstreamURL"] = result.Upstream.URL
+		debug["upstreamLatencyMs"] = result.Upstream.Latency.Milliseconds()
+	}
+	return debug
 }
 
-// getWeatherHandler handles GET /weather requests.
+// getWeatherHandler handles GET and HEAD /weather requests.
 // It determines whether cached data exists for the requested location, and if not,
 // it fetches the data from the weather API, caches it in Redis, and returns the result.
 func getWeatherHandler(c *gin.Context) {
-	location := c.Query("location")
-	if location == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "location query parameter is required"})
+	var params weatherParams
+	if !bindQuery(c, &params) {
+		return
+	}
+	q, ok := buildWeatherQuery(c, params.queryParams)
+	if !ok {
 		return
 	}
 
-	cacheKey := "weather:" + location
+	page, err := parseDayPage(params.Offset, params.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Attempt to retrieve cached weather data from Redis.
-	cachedData, err := redisClient.Get(ctx, cacheKey).Result()
-	var weatherData map[string]interface{}
+	// normals=true adds climate normals, cached separately from plain lookups.
+	normals := params.Normals == "true"
+	if normals && !cfg.NormalsEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "normals are not enabled"})
+		return
+	}
+	q.Normals = normals
+
+	// units picks the unit group the data is fetched, and cached, in.
+	q.UnitGroup = params.Units
+
+	// agri=true adds the soil and evapotranspiration elements, see /weather/agri.
+	if params.Agri == "true" {
+		if q, ok = withAgri(c, q); !ok {
+			return
+		}
+	}
+
+	// nocache=true forces a fresh fetch; by default only admins may use it, since
+	// every bypass costs an upstream call.
+	bypass := params.NoCache == "true"
+	if bypass && cfg.CacheBypassAdminOnly && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "nocache requires the admin token"})
+		return
+	}
 
-	if err == redis.Nil {
-		// Cache miss: fetch the weather data from the API.
-		weatherData, err = fetchWeatherData(location)
+	// profile=mobile needs the current conditions as well as the days.
+	mobile := params.Profile == "mobile"
+	if mobile {
+		q.Include = normalizeInclude(q.include() + ",current")
+	}
+	// profile=compact returns a typed subset, include picking its sections.
+	compact := params.Profile == "compact"
+	if params.Include != "" && !compact {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include requires profile=compact"})
+		return
+	}
+	if _, withCurrent := compactIncludes(params.Include); compact && withCurrent {
+		q.Include = normalizeInclude(q.include() + ",current")
+	}
+
+	// Accept: application/x-protobuf selects the protobuf encoding of the
+	// response, see weatherpb/weather.proto.
+	// format=ndjson, which streams the days line by line, format=geojson and
+	// flatten=true take precedence.
+	c.Writer.Header().Add("Vary", "Accept")
+	ndjson := params.Format == "ndjson"
+	geojson := params.Format == "geojson"
+	flatten := params.Flatten == "true"
+	if geojson && flatten {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "flatten can't be combined with format=geojson"})
+		return
+	}
+	protobuf := wantsProtobuf(c) && !ndjson && !flatten && !geojson
+
+	// delta=true sends polling clients only what changed since the response
+	// they name in If-None-Match.
+	delta := params.Delta == "true"
+
+	debugMode := params.Debug == "true"
+	if debugMode && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "debug mode requires the admin token"})
+		return
+	}
+	var gustThreshold float64
+	if params.GustThreshold != "" {
+		threshold, err := strconv.ParseFloat(params.GustThreshold, 64)
+		if err != nil || threshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gustThreshold must be a non-negative number"})
+			return
+		}
+		gustThreshold = threshold
+	}
+	downsample, err := parseDownsample(params.Downsample)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	numbers, err := parseNumberFormat(params.NumberFormat)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pipeline := weatherPipeline(params, debugMode, gustThreshold, downsample, numbers)
+	transformed := mobile || compact || protobuf || ndjson || geojson || flatten || page.active() || len(pipeline) > 0
+
+	// Untransformed cache hits are written straight from the cached bytes,
+	// skipping a decode/encode round trip.
+	result, err := lookupWeather(c.Request.Context(), q, lookupOptions{Raw: !transformed, Bypass: bypass})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	noteLookup(c, q, result)
+	if result.Raw != nil {
+		if _, zone, ok := rawZone(result.Raw); ok {
+			c.Header(locationTimezoneHeader, zone)
+		}
+		c.Header("X-Cache", result.Cache)
+		setCacheControl(c, result)
+		if delta {
+			writeDeltaBody(c, http.StatusOK, result.Raw)
+		} else {
+			writeJSONBody(c, http.StatusOK, result.Raw)
+		}
+		return
+	}
+	weatherData := result.Data
+	if _, zone, ok := dataZone(weatherData); ok {
+		c.Header(locationTimezoneHeader, zone)
+	}
+
+	// The mobile and compact profiles have a fixed shape, so none of the other
+	// options apply.
+	if mobile {
+		current, err := decodeCurrent(weatherData)
+		var days []weatherDay
+		if err == nil {
+			days, err = decodeDays(weatherData)
+		}
+		if err != nil {
+			log.Printf("Error decoding weather data for the mobile profile: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to decode weather data"})
+			return
+		}
+		c.Header("X-Cache", result.Cache)
+		setCacheControl(c, result)
+		writeJSON(c, http.StatusOK, mobileView(q, current, days))
+		return
+	}
+	if compact {
+		current, err := decodeCurrent(weatherData)
+		var days []weatherDay
+		if err == nil {
+			days, err = decodeDays(weatherData)
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			log.Printf("Error decoding weather data for the compact profile: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to decode weather data"})
 			return
 		}
+		c.Header("X-Cache", result.Cache)
+		setCacheControl(c, result)
+		writeJSON(c, http.StatusOK, compactView(weatherData, current, days, params.Include))
+		return
+	}
 
-		// Marshal the retrieved data into JSON and store it in Redis.
-		jsonData, err := json.Marshal(weatherData)
+	// The transforms run in stages around paging and the output format, see
+	// transformStage.
+	in := transformInput{q: q, result: result, sourceKeys: upstreamKeys(weatherData)}
+	weatherData = pipeline.run(weatherData, in, stageAnnotate, stageFilter)
+
+	status := http.StatusOK
+	if page.active() {
+		paged, total, partial, err := paginateDays(weatherData, page)
 		if err != nil {
-			log.Printf("Error marshaling weather data: %v", err)
-		} else {
-			if err := redisClient.Set(ctx, cacheKey, jsonData, cacheExpiration).Err(); err != nil {
-				log.Printf("Error caching weather data: %v", err)
-			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		log.Printf("Fetched fresh weather data for location: %s", location)
-	} else if err != nil {
-		log.Printf("Error retrieving data from Redis: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		c.Header("X-Total-Days", strconv.Itoa(total))
+		if partial {
+			status = http.StatusPartialContent
+		}
+		weatherData = paged
+	}
+
+	// The protobuf schema is fixed, so cleaning, decorating and renaming don't
+	// apply.
+	if protobuf {
+		body, err := encodeProtobuf(weatherData)
+		if err != nil {
+			log.Printf("Error encoding protobuf response: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to decode weather data"})
+			return
+		}
+		c.Header("X-Cache", result.Cache)
+		setCacheControl(c, result)
+		writeBody(c, status, protobufContentType, body)
 		return
-	} else {
-		// Cache hit: unmarshal the JSON data from the cache.
-		if err := json.Unmarshal([]byte(cachedData), &weatherData); err != nil {
-			log.Printf("Error unmarshaling cached data: %v", err)
-			// Optionally, fetch fresh data if unmarshaling fails.
-			weatherData, err = fetchWeatherData(location)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
+	}
+
+	// The coordinates are read before cleaning and renaming can change them.
+	var position [2]float64
+	if geojson {
+		if position, err = dataCoordinates(weatherData); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	weatherData = pipeline.run(weatherData, in, stageClean, stageClean)
+
+	// Flat records have no top-level object to carry decorations either; with
+	// format=ndjson they are streamed one per line.
+	if flatten {
+		records := flattenWeather(weatherData, q.Location, cfg.FieldRenames)
+		c.Header("X-Cache", result.Cache)
+		setCacheControl(c, result)
+		if ndjson {
+			writeNDJSON(c, status, records)
 		} else {
-			log.Printf("Serving cached weather data for location: %s", location)
+			writeJSON(c, status, records)
+		}
+		return
+	}
+
+	// NDJSON has no top-level object to carry decorations. Day and current
+	// condition fields are renamed in place.
+	if ndjson {
+		current := weatherData["currentConditions"]
+		days, _ := weatherData["days"].([]interface{})
+		renameFields(weatherData, cfg.FieldRenames)
+		currentKey := "currentConditions"
+		if renamed, ok := cfg.FieldRenames[currentKey]; ok {
+			currentKey = renamed
 		}
+		c.Header("X-Cache", result.Cache)
+		setCacheControl(c, result)
+		writeNDJSON(c, status, ndjsonLines(current, currentKey, days))
+		return
 	}
 
-	c.JSON(http.StatusOK, weatherData)
+	weatherData = pipeline.run(weatherData, in, stageDecorate, stageRename)
+
+	c.Header("X-Cache", result.Cache)
+	setCacheControl(c, result)
+	// A GeoJSON Feature carries the decorated data as its properties.
+	if geojson {
+		body, err := json.Marshal(geoJSONView(weatherData, position))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+			return
+		}
+		writeBody(c, status, geoJSONContentType, body)
+		return
+	}
+	if delta {
+		writeDeltaJSON(c, status, weatherData)
+	} else {
+		writeJSON(c, status, weatherData)
+	}
 }
 
 func main() {
-	router := gin.Default()
+	startTime = time.Now()
+
+	// Load environment variables from .env if available
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, proceeding with system environment variables")
+	}
+
+	var err error
+	cfg, err = loadConfig()
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	upstreamClient = newUpstreamClient(cfg.UpstreamTransport)
+	if cfg.UpstreamFake {
+		stopFake := startFakeUpstream(&cfg)
+		defer stopFake()
+	}
+	if cfg.MockMode {
+		log.Println("WARNING: MOCK_MODE is set, weather endpoints serve synthetic weather without calling the upstream or Redis")
+	}
+	if err := connectRedis(cfg); err != nil {
+		// Mock lookups don't need Redis; features that do will log their errors.
+		if !cfg.MockMode || redisClient == nil {
+			log.Fatal(err)
+		}
+		log.Printf("Continuing in MOCK_MODE without Redis: %v", err)
+	}
+
+	// Cache snapshot subcommands run against Redis and exit without serving.
+	if runCacheCommand(os.Args[1:]) {
+		return
+	}
+
+	topLocations = newLocationCounter(cfg.TopLocationsCapacity)
+	upstreamQueue = newFetchQueue(cfg.UpstreamQueueWorkers, cfg.UpstreamQueueDepth)
+	upstreamRetryBudget = newRetryBudget(cfg.RetryBudgetRate, cfg.RetryBudgetBurst)
+	if cfg.UpstreamWarmup {
+		go warmUpUpstream(cfg.APIURL)
+	}
+
+	// Replicas deployed together would otherwise all warm their caches and
+	// become ready at the same moment.
+	jitter := startupJitter(cfg.StartupJitter)
+	if cfg.StartupJitter > 0 {
+		log.Printf("Startup jitter: reporting ready and warming the cache in %s", jitter)
+	}
+
+	var stopWarmer func()
+	if len(cfg.WarmLocations) > 0 {
+		warmCtx, cancel := context.WithCancel(context.Background())
+		done := startCacheWarmer(warmCtx, cfg, jitter)
+		stopWarmer = func() { cancel(); <-done }
+	}
+
+	var stopJobs func()
+	if cfg.JobWorkers > 0 {
+		jobCtx, cancel := context.WithCancel(context.Background())
+		done := startJobWorkers(jobCtx, cfg.JobWorkerID, cfg.JobWorkers)
+		stopJobs = func() { cancel(); <-done }
+	}
+
+	var stopMigration func()
+	if cfg.CacheMigrateFromPrefix != "" {
+		migrateCtx, cancel := context.WithCancel(context.Background())
+		done := startCacheMigration(migrateCtx, cfg.CacheMigrateFromPrefix, cfg.CacheMigrateInterval)
+		stopMigration = func() { cancel(); <-done }
+	}
+
+	var stopAdaptiveRate func()
+	if cfg.AdaptiveRateLimit {
+		rateCtx, cancel := context.WithCancel(context.Background())
+		done := startAdaptiveRateLimit(rateCtx, cfg.AdaptiveRateInterval, adaptiveRateBounds{
+			min:            cfg.AdaptiveRateMinFactor,
+			max:            cfg.AdaptiveRateMaxFactor,
+			targetInFlight: cfg.AdaptiveRateTargetInFlight,
+			errorRate:      cfg.AdaptiveRateErrorRate,
+		})
+		stopAdaptiveRate = func() { cancel(); <-done }
+	}
+
+	var stopHealthPoller func()
+	if cfg.UpstreamHealthInterval > 0 && !cfg.MockMode {
+		pollCtx, cancel := context.WithCancel(context.Background())
+		done := startUpstreamHealthPoller(pollCtx, cfg.UpstreamHealthInterval, cfg.HealthCheckTimeout)
+		stopHealthPoller = func() { cancel(); <-done }
+	}
+
+	router := newRouter(cfg)
+
+	// Sidecar deployments can talk to the service over a Unix domain socket
+	// (UNIX_SOCKET) instead of TCP.
+	ln, err := listen(cfg.UnixSocket, cfg.Port)
+	if err != nil {
+		log.Fatalf("failed to start the server: %v", err)
+	}
+	if err := serve(ln, router, cfg.DrainPeriod, jitter, cfg.ShutdownTimeout); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server error: %v", err)
+	}
+
+	// Stop warming, jobs, migration sweeps and health polls before the cache is flushed or Redis is closed
+	// under them.
+	if stopWarmer != nil {
+		stopWarmer()
+	}
+	if stopJobs != nil {
+		stopJobs()
+	}
+	if stopMigration != nil {
+		stopMigration()
+	}
+	if stopHealthPoller != nil {
+		stopHealthPoller()
+	}
+	if stopAdaptiveRate != nil {
+		stopAdaptiveRate()
+	}
+
+	// Ephemeral deployments can start every run with an empty cache.
+	if cfg.FlushCacheOnShutdown {
+		n, err := flushCache()
+		if err != nil {
+			log.Printf("Error flushing cache on shutdown: %v", err)
+		}
+		log.Printf("Flushed %d cache entries on shutdown", n)
+	}
+	closeShards()
+	if replicaClient != nil {
+		replicaClient.Close()
+	}
+	if failoverClient != nil {
+		failoverClient.Close()
+	}
+	if err := redisClient.Close(); err != nil {
+		log.Printf("Error closing Redis client: %v", err)
+	}
+	log.Printf("Shutdown complete")
+}
+
+// probePaths are the endpoints of liveness, readiness and health probes.
+var probePaths = map[string]bool{"/health": true, "/canary": true, "/livez": true, "/readyz": true}
+
+// newRouter builds the Gin engine with its middleware and routes.
+func newRouter(c Config) *gin.Engine {
+	// gin.Default's logger is replaced by the structured access log below.
+	router := gin.New()
+	// Forwarding headers are only honoured from TRUSTED_PROXIES; with none
+	// configured the connection's address is always used. The list was
+	// validated by loadConfig.
+	if err := router.SetTrustedProxies(c.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.Use(gin.Recovery(), clientIPMiddleware(), requestIDMiddleware(), accessLogMiddleware(c.AccessLogFormat, c.AccessLogSkip))
+	router.Use(slowRequestMiddleware(c.SlowRequestThreshold), concurrencyLimitMiddleware(c.MaxConcurrentRequests, probePaths))
+	router.Use(queryLimitMiddleware(c.MaxQueryParams, c.MaxQueryLength))
+	if c.Gzip {
+		router.Use(gzipMiddleware(c.GzipMinBytes))
+	}
 
 	// --------------------------------------------------------------
 	// RATE LIMITING SETUP:
-	// Create a new limiter that allows, for example, 1 request per second.
-	// Adjust the parameter to suit your needs.
-	limiter := tollbooth.NewLimiter(1, nil)
-
-	// Attach Tollbooth's Gin middleware to limit all incoming requests.
-	router.Use(tollbooth_gin.LimitHandler(limiter))
+	// Every route gets its own limiter allowing RATE_LIMIT requests per second
+	// per client (default 1), overridable per route with RATE_LIMIT_<ROUTE>.
+	// Limiters are attached per route so monitoring endpoints such as /canary can
+	// be exempted.
+	limit := func(path string) gin.HandlerFunc { return routeLimiter(c, path) }
 	// --------------------------------------------------------------
 
-	// Define the /weather endpoint.
-	router.GET("/weather", getWeatherHandler)
+	// Define the endpoints.
+	// Probes must never be throttled, or a busy prober would see the service flap.
+	router.GET("/health", healthHandler)
+	router.GET("/canary", canaryHandler)
+	router.GET("/livez", livezHandler)
+	router.GET("/readyz", readyzHandler)
+	// Scrapes come at a fixed pace and must not be throttled either.
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/stats", limit("/stats"), statsHandler)
+	router.GET("/stats/top", limit("/stats/top"), topLocationsHandler)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	adminHandlers := []gin.HandlerFunc{limit("/admin")}
+	if c.AuditLog {
+		adminHandlers = append(adminHandlers, auditMiddleware(c.AuditStream, c.AuditStreamMaxLen))
 	}
-	log.Printf("Server listening on port %s", port)
-	// Check the error returned from router.Run.
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("failed to start the server: %v", err)
+	adminHandlers = append(adminHandlers, adminMiddleware())
+	if c.AdminReplayProtection {
+		adminHandlers = append(adminHandlers, replayProtectionMiddleware(c.AdminReplayWindow))
 	}
+	admin := router.Group("/admin", adminHandlers...)
+	admin.GET("/cache/keys", cacheKeysHandler)
+	admin.GET("/cache/export", cacheExportHandler)
+	admin.GET("/cache/consistency", cacheConsistencyHandler)
+	admin.POST("/cache/import", cacheImportHandler)
+	admin.POST("/config/ttl", cacheTTLHandler)
+	admin.GET("/weather/diff", weatherDiffHandler)
+	admin.GET("/fingerprints", fingerprintsHandler)
+	admin.GET("/audit", auditHandler)
+
+	// Weather endpoints, optionally protected by API-key authentication. The
+	// rate limit runs first so rejected clients never reach the key store.
+	var auth []gin.HandlerFunc
+	if c.AuthEnabled {
+		var keys keyStore = c.APIKeys
+		if c.APIKeysRedisSet != "" {
+			keys = redisKeyStore{set: c.APIKeysRedisSet}
+		}
+		auth = []gin.HandlerFunc{authMiddleware(keys, c.AuthFailOpen), keyQuotaMiddleware(c.APIKeyQuotas)}
+	}
+	// With API_KEY_RATE_LIMITS the weather endpoints are limited per API key
+	// instead of per route and client IP.
+	var keyLimit gin.HandlerFunc
+	if len(c.APIKeyRateLimits) > 0 {
+		keyLimit = newKeyRateLimiter(c.APIKeyRateLimits, c.AnonymousRateLimit).middleware()
+	}
+	weather := func(methods []string, path string, handler gin.HandlerFunc) {
+		handlers := []gin.HandlerFunc{keyLimit}
+		if keyLimit == nil {
+			handlers[0] = limit(path)
+		}
+		if c.FingerprintTracking {
+			handlers = append(handlers, fingerprintMiddleware(c.FingerprintWindow, c.FingerprintThreshold, c.FingerprintThrottle))
+		}
+		handlers = append(append(handlers, attributionMiddleware(c.AttributionText)), auth...)
+		if c.MockMode {
+			handlers = append(handlers, mockMiddleware())
+		}
+		handlers = append(handlers, teamMiddleware(c.APIKeyTeams), upstreamTimeoutMiddleware(c.UpstreamTimeout, c.UpstreamTimeoutMax), handler)
+		for _, m := range methods {
+			router.Handle(m, path, handlers...)
+		}
+	}
+	get := []string{http.MethodGet}
+	weather([]string{http.MethodGet, http.MethodHead}, "/weather", getWeatherHandler)
+	weather(get, "/weather/summary", getSummaryHandler)
+	weather(get, "/weather/precip", getPrecipHandler)
+	weather(get, "/weather/degreedays", getDegreeDaysHandler)
+	weather(get, "/weather/comfort", getComfortHandler)
+	weather(get, "/weather/uv", getUVHandler)
+	weather(get, "/weather/sky", getSkyHandler)
+	weather(get, "/weather/score", getScoreHandler)
+	weather(get, "/weather/hourly", getHourlyHandler)
+	weather(get, "/weather/history", getHistoryHandler)
+	weather(get, "/weather/normal", getNormalHandler)
+	weather(get, "/weather/text", getTextHandler)
+	weather(get, "/weather/description", getDescriptionHandler)
+	weather(get, "/weather/agri", getAgriHandler)
+	weather([]string{http.MethodPost}, "/weather/temps", getTempsHandler)
+	weather([]string{http.MethodGet, http.MethodPost}, "/weather/batch", getBatchHandler)
+	weather([]string{http.MethodPost}, "/weather/jobs", createJobHandler)
+	weather(get, "/weather/jobs/:id", getJobHandler)
+	weather([]string{http.MethodPut}, "/user/locations", putSavedLocationsHandler)
+	weather(get, "/user/locations", getSavedLocationsHandler)
+	weather(get, "/user/weather", getUserWeatherHandler)
+
+	return router
 }
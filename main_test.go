@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+	accessLogger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testConfig returns the configuration of an environment holding only the
+// required settings, with rate limits high enough not to get in the way and
+// without degraded mode, which the upstream failures of one test would
+// otherwise switch on for the next.
+func testConfig(t testing.TB) Config {
+	t.Helper()
+	t.Setenv("VISUAL_CROSSING_API_KEY", "testkey")
+	t.Setenv("VISUAL_CROSSING_API_URL", "http://upstream.invalid")
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	c.RateLimit = 1000
+	c.DegradeErrorRate = 0
+	return c
+}
+
+// setupTest installs c as cfg, with Redis served by a fresh miniredis and the
+// upstream by upstream when it isn't nil, and restores the globals it replaces
+// when the test ends. It returns the miniredis so tests can inspect and seed
+// the cache.
+func setupTest(t testing.TB, c Config, upstream http.Handler) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	oldCfg, oldRedis, oldShards, oldQueue, oldClient := cfg, redisClient, cacheShards, upstreamQueue, upstreamClient
+	t.Cleanup(func() {
+		redisClient.Close()
+		cfg, redisClient, cacheShards, upstreamQueue, upstreamClient = oldCfg, oldRedis, oldShards, oldQueue, oldClient
+	})
+
+	if upstream != nil {
+		srv := httptest.NewServer(upstream)
+		t.Cleanup(srv.Close)
+		c.APIURL = srv.URL
+	}
+	c.RedisURL = "redis://" + mr.Addr()
+	cfg = c
+	if err := connectRedis(cfg); err != nil {
+		t.Fatalf("connectRedis: %v", err)
+	}
+	upstreamClient = newUpstreamClient(cfg.UpstreamTransport)
+	upstreamQueue = newFetchQueue(cfg.UpstreamQueueWorkers, cfg.UpstreamQueueDepth)
+	return mr
+}
+
+// serveRequest sends req through a router built from cfg and returns the
+// recorded response.
+func serveRequest(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newRouter(cfg).ServeHTTP(w, req)
+	return w
+}
+
+// fixtureWeather is a minimal successful upstream response.
+const fixtureWeather = `{"resolvedAddress":"London","timezone":"Europe/London","days":[{"datetime":"2026-10-14","tempmax":20,"tempmin":10,"temp":15,"feelslike":15,"feelslikemax":20,"feelslikemin":10}]}`
+
+// respondWith returns an upstream handler answering every request with status
+// and body.
+func respondWith(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	})
+}
+
+// requestWeather serves GET /weather?<query>.
+func requestWeather(query string) *httptest.ResponseRecorder {
+	return serveRequest(httptest.NewRequest(http.MethodGet, "/weather?"+query, nil))
+}
+
+// londonKey is the cache key of requestWeather("location=London").
+var londonKey = weatherQuery{Location: "london"}.cacheKey()
+
+// errorCode returns the code of a JSON error response.
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error response %q: %v", w.Body, err)
+	}
+	return body.Code
+}
+
+func TestEmptyUpstreamResponse(t *testing.T) {
+	for _, body := range []string{`{}`, `null`, `{"resolvedAddress":"Nowhere","days":[],"currentConditions":{}}`} {
+		mr := setupTest(t, testConfig(t), respondWith(http.StatusOK, body))
+		w := requestWeather("location=London")
+		if w.Code != http.StatusBadGateway || errorCode(t, w) != "UPSTREAM_EMPTY" {
+			t.Errorf("%s: got %d %s, want 502 UPSTREAM_EMPTY", body, w.Code, w.Body)
+		}
+		if mr.Exists(londonKey) {
+			t.Errorf("%s: empty response cached", body)
+		}
+	}
+}
+
+func TestEmptyUpstreamResponseNegativelyCached(t *testing.T) {
+	var calls atomic.Int64
+	c := testConfig(t)
+	c.EmptyResponseTTL = time.Minute
+	mr := setupTest(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		respondWith(http.StatusOK, `{}`).ServeHTTP(w, r)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if w := requestWeather("location=London"); w.Code != http.StatusBadGateway || errorCode(t, w) != "UPSTREAM_EMPTY" {
+			t.Errorf("lookup %d: got %d %s, want 502 UPSTREAM_EMPTY", i, w.Code, w.Body)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times, want the empty answer served from the cache", n)
+	}
+	if ttl := mr.TTL(londonKey); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("empty marker TTL %s, want EMPTY_RESPONSE_CACHE_TTL", ttl)
+	}
+}
+
+func TestMalformedUpstreamResponse(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(io.Discard) })
+
+	truncated := fixtureWeather[:len(fixtureWeather)/2]
+	huge := `{"days":[` + strings.Repeat(`"x",`, 1000)
+	for _, body := range []string{truncated, `<html>Bad gateway</html>`, `[1,2,3]`, huge} {
+		logged.Reset()
+		mr := setupTest(t, testConfig(t), respondWith(http.StatusOK, body))
+		w := requestWeather("location=London")
+		if w.Code != http.StatusBadGateway || errorCode(t, w) != "UPSTREAM_MALFORMED" {
+			t.Errorf("%.20s: got %d %s, want 502 UPSTREAM_MALFORMED", body, w.Code, w.Body)
+		}
+		if keys := mr.Keys(); len(keys) != 0 {
+			t.Errorf("%.20s: cached %v", body, keys)
+		}
+		if want := strconv.Quote(string(truncateBytes([]byte(body), malformedLogBytes))); !strings.Contains(logged.String(), want) {
+			t.Errorf("%.20s: log %q lacks the body's first %d bytes", body, logged.String(), malformedLogBytes)
+		}
+	}
+}
+
+func TestCorruptCacheEntryReplaced(t *testing.T) {
+	mr := setupTest(t, testConfig(t), respondWith(http.StatusOK, fixtureWeather))
+	mr.Set(londonKey, `{"version":`)
+	corruptions := cacheCorruptions.Load()
+
+	w := requestWeather("location=London")
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("got %d, X-Cache %q; want a fresh fetch", w.Code, w.Header().Get("X-Cache"))
+	}
+	if got := cacheCorruptions.Load() - corruptions; got != 1 {
+		t.Errorf("counted %d corruptions, want 1", got)
+	}
+	raw, err := mr.Get(londonKey)
+	if err != nil {
+		t.Fatalf("corrupt entry not replaced: %v", err)
+	}
+	if data, _, err := decodeEntry(raw); err != nil || data["resolvedAddress"] != "London" {
+		t.Errorf("replacement entry decodes to %v, %v", data, err)
+	}
+	if w := requestWeather("location=London"); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("next lookup X-Cache %q, want HIT", w.Header().Get("X-Cache"))
+	}
+}
+
+func TestCorruptCacheEntryErrorPolicy(t *testing.T) {
+	t.Setenv("CACHE_CORRUPT_POLICY", "error")
+	mr := setupTest(t, testConfig(t), respondWith(http.StatusOK, fixtureWeather))
+	mr.Set(londonKey, `garbage`)
+
+	if w := requestWeather("location=London"); w.Code != http.StatusInternalServerError {
+		t.Errorf("got %d, want 500", w.Code)
+	}
+	if mr.Exists(londonKey) {
+		t.Error("corrupt entry kept")
+	}
+}
+
+// largeWeather is an upstream response of 15 days of 24 hours each, the size
+// of a typical forecast.
+func largeWeather() string {
+	hour := `{"datetime":"12:00:00","temp":15.2,"feelslike":14.8,"humidity":71.3,"windspeed":12.1,"winddir":240,"conditions":"Partially cloudy"}`
+	day := `{"datetime":"2026-10-14","tempmax":20,"tempmin":10,"temp":15,"feelslike":15,"feelslikemax":20,"feelslikemin":10,"hours":[` +
+		strings.TrimSuffix(strings.Repeat(hour+",", 24), ",") + `]}`
+	return `{"resolvedAddress":"London","timezone":"Europe/London","days":[` + strings.TrimSuffix(strings.Repeat(day+",", 15), ",") + `]}`
+}
+
+// TestCacheHitRawMatchesDecoded checks a hit served from the cached bytes
+// carries the same data as one decoded for a transformation.
+func TestCacheHitRawMatchesDecoded(t *testing.T) {
+	setupTest(t, testConfig(t), respondWith(http.StatusOK, largeWeather()))
+	requestWeather("location=London")
+
+	raw := requestWeather("location=London")
+	decoded := requestWeather("location=London&windLabel=true")
+	if raw.Code != http.StatusOK || raw.Header().Get("X-Cache") != "HIT" || decoded.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("got %d, X-Cache %q and %q; want two hits", raw.Code, raw.Header().Get("X-Cache"), decoded.Header().Get("X-Cache"))
+	}
+	var fromRaw, fromDecoded map[string]interface{}
+	if err := json.Unmarshal(raw.Body.Bytes(), &fromRaw); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(decoded.Body.Bytes(), &fromDecoded); err != nil {
+		t.Fatal(err)
+	}
+	applyWindLabels(fromRaw)
+	if !reflect.DeepEqual(fromRaw, fromDecoded) {
+		t.Error("raw and decoded hits differ")
+	}
+}
+
+// BenchmarkCacheHit compares serving a hit straight from the cached bytes with
+// the decode and re-encode a transformation needs.
+func BenchmarkCacheHit(b *testing.B) {
+	setupTest(b, testConfig(b), respondWith(http.StatusOK, largeWeather()))
+	router := newRouter(cfg)
+	for _, bc := range []struct{ name, query string }{
+		{"raw", "location=London"},
+		{"decoded", "location=London&windLabel=true"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/weather?"+bc.query, nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				router.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeEntry isolates the entry decoding of the two hit paths.
+func BenchmarkDecodeEntry(b *testing.B) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(largeWeather()), &data); err != nil {
+		b.Fatal(err)
+	}
+	entry, err := encodeEntry("weather:bench", data, time.Now(), time.Time{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	raw := string(entry)
+	b.Run("raw", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := decodeEntryRaw(raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("decoded", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, _, err := decodeEntry(raw)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := json.Marshal(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestOversizedUpstreamResponse(t *testing.T) {
+	c := testConfig(t)
+	c.MaxUpstreamResponseBytes = int64(len(fixtureWeather))
+	mr := setupTest(t, c, respondWith(http.StatusOK, largeWeather()))
+	w := requestWeather("location=London")
+	if w.Code != http.StatusBadGateway || errorCode(t, w) != "UPSTREAM_TOO_LARGE" {
+		t.Errorf("got %d %s, want 502 UPSTREAM_TOO_LARGE", w.Code, w.Body)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("cached %v", keys)
+	}
+
+	// A body of exactly the limit is accepted.
+	setupTest(t, c, respondWith(http.StatusOK, fixtureWeather))
+	if w := requestWeather("location=London"); w.Code != http.StatusOK {
+		t.Errorf("body at the limit: got %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestOversizedCacheEntryServedNotCached(t *testing.T) {
+	c := testConfig(t)
+	c.MaxCacheEntryBytes = 4096
+	mr := setupTest(t, c, respondWith(http.StatusOK, largeWeather()))
+	oversized := cacheEntriesOversized.Load()
+
+	for i := 0; i < 2; i++ {
+		w := requestWeather("location=London")
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+			t.Fatalf("lookup %d: got %d, X-Cache %q; want the data fetched every time", i, w.Code, w.Header().Get("X-Cache"))
+		}
+		if w.Body.Len() <= c.MaxCacheEntryBytes {
+			t.Fatalf("lookup %d: response of %d bytes, want the full data", i, w.Body.Len())
+		}
+	}
+	if mr.Exists(londonKey) {
+		t.Error("oversized entry cached")
+	}
+	if got := cacheEntriesOversized.Load() - oversized; got != 2 {
+		t.Errorf("counted %d oversized entries, want 2", got)
+	}
+
+	// Entries within the limit are cached as usual.
+	setupTest(t, c, respondWith(http.StatusOK, fixtureWeather))
+	requestWeather("location=London")
+	if w := requestWeather("location=London"); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("small entry: X-Cache %q, want HIT", w.Header().Get("X-Cache"))
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics, served on /metrics next to the Go runtime and process
+// metrics of the default registry.
+var (
+	cacheLookupsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_cache_lookups_total",
+		Help: "Weather lookups by cache result (hit or miss) and where the data came from (redis or api).",
+	}, []string{"result", "source"})
+	upstreamCallsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_upstream_requests_total",
+		Help: "Calls to the Visual Crossing API by outcome (success or error).",
+	}, []string{"outcome"})
+	upstreamLatencyMetric = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "weather_upstream_request_duration_seconds",
+		Help:    "Duration of calls to the Visual Crossing API, including reading the response.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// observeLookup counts a successful lookup served with the given X-Cache
+// value: stale entries are hits from Redis, bypassed ones misses like any
+// fetch from the API.
+func observeLookup(cache string) {
+	switch cache {
+	case "HIT", "STALE":
+		cacheLookupsMetric.WithLabelValues("hit", "redis").Inc()
+	default:
+		cacheLookupsMetric.WithLabelValues("miss", "api").Inc()
+	}
+}
+
+// observeUpstreamCall counts an upstream call that took d, successful when the
+// upstream answered with weather data.
+func observeUpstreamCall(success bool, d time.Duration) {
+	outcome := "error"
+	if success {
+		outcome = "success"
+	}
+	upstreamCallsMetric.WithLabelValues(outcome).Inc()
+	upstreamLatencyMetric.Observe(d.Seconds())
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDHeader carries the request ID in both directions.
+const requestIDHeader = "X-Request-ID"
+
+// accessLogger writes one structured line per request, without the standard
+// logger's timestamp prefix (the entry carries its own).
+var accessLogger = log.New(os.Stdout, "", 0)
+
+// requestIDMiddleware propagates an incoming X-Request-ID or generates a new one,
+// storing it in the context and echoing it on the response.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		c.Set("requestID", id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// newRequestID returns a random 128-bit hex identifier.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessLogEntry is the structured form of a single access log line.
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	ClientIP  string  `json:"client_ip"`
+	RequestID string  `json:"request_id"`
+}
+
+// Access log formats, selected by ACCESS_LOG_FORMAT.
+const (
+	accessLogJSON     = "json"
+	accessLogCombined = "combined"
+)
+
+// parseAccessLogFormat validates ACCESS_LOG_FORMAT, defaulting to JSON lines.
+func parseAccessLogFormat(raw string) string {
+	switch raw {
+	case "":
+		return accessLogJSON
+	case accessLogJSON, accessLogCombined:
+		return raw
+	}
+	log.Printf("Invalid ACCESS_LOG_FORMAT %q, defaulting to %s", raw, accessLogJSON)
+	return accessLogJSON
+}
+
+// clfTimeLayout is the timestamp format of the Common Log Format.
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// combinedLogLine formats a request in the Apache Combined Log Format:
+//
+//	host ident authuser [time] "request line" status bytes "referer" "user-agent"
+//
+// ident and authuser are always "-" so API keys never reach the log, and bytes is
+// "-" for responses without a body.
+func combinedLogLine(c *gin.Context, start time.Time) string {
+	bytes := "-"
+	if n := c.Writer.Size(); n > 0 {
+		bytes = strconv.Itoa(n)
+	}
+	requestLine := c.Request.Method + " " + c.Request.URL.RequestURI() + " " + c.Request.Proto
+	return fmt.Sprintf("%s - - [%s] %s %d %s %s %s",
+		c.ClientIP(), start.Format(clfTimeLayout), clfQuote(requestLine), c.Writer.Status(), bytes,
+		clfQuote(c.Request.Referer()), clfQuote(c.Request.UserAgent()))
+}
+
+// clfQuote quotes a Combined Log Format field, escaping quotes, backslashes and
+// control characters so a crafted header can't forge log lines. Empty values are
+// written as "-".
+func clfQuote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch < 0x20 || ch == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// accessLogMiddleware logs every request in the given format, except for paths in
+// skip, which are typically noisy probe endpoints.
+func accessLogMiddleware(format string, skip map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.Request.URL.Path
+		if skip[path] {
+			return
+		}
+		if format == accessLogCombined {
+			accessLogger.Println(combinedLogLine(c, start))
+			return
+		}
+		entry := accessLogEntry{
+			Time:      start.UTC().Format(time.RFC3339),
+			Method:    c.Request.Method,
+			Path:      path,
+			Status:    c.Writer.Status(),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			ClientIP:  c.ClientIP(),
+			RequestID: c.GetString("requestID"),
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		accessLogger.Println(string(line))
+	}
+}
+
+// parseSet splits a comma-separated list (of paths, names, ...) into a lookup set.
+func parseSet(raw string) map[string]bool {
+	set := make(map[string]bool)
+	for _, p := range strings.Split(raw, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			set[p] = true
+		}
+	}
+	return set
+}
+
+// clientIPMiddleware pins the client IP for the rest of the chain. Gin resolves it
+// from X-Forwarded-For/X-Real-IP only when the immediate peer is a trusted proxy
+// (see TRUSTED_PROXIES) and from the connection otherwise; the forwarding headers
+// are then replaced by a single X-Real-IP carrying that address, so components
+// that read the headers themselves, like the rate limiter, can't be spoofed.
+func clientIPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		c.Request.Header.Del("X-Forwarded-For")
+		c.Request.Header.Set("X-Real-IP", ip)
+		c.Next()
+	}
+}
+
+// slowRequestMiddleware logs a warning with lookup details for every request
+// taking longer than threshold. A zero threshold disables it.
+func slowRequestMiddleware(threshold time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if threshold <= 0 {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+
+		latency := time.Since(start)
+		if latency <= threshold {
+			return
+		}
+		upstream := "-"
+		if d, ok := c.Get("upstreamLatency"); ok {
+			upstream = d.(time.Duration).String()
+		}
+		log.Printf("WARNING: slow request: %s %s took %s (status %d, location %q, cache %s, upstream %s, request_id %s)",
+			c.Request.Method, c.Request.URL.Path, latency, c.Writer.Status(),
+			c.GetString("location"), valueOr(c.GetString("cacheStatus"), "-"), upstream, c.GetString("requestID"))
+	}
+}
+
+// valueOr returns v, or def when v is empty.
+func valueOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+)
+
+// mobileSchemaVersion identifies the profile=mobile response shape. Bump it on
+// any change clients could notice; fields are only ever added within a version.
+const mobileSchemaVersion = 1
+
+// mobileResponse is the compact, fixed-shape payload returned for
+// profile=mobile. Values the upstream didn't provide are null, never omitted.
+type mobileResponse struct {
+	Schema   int           `json:"schema"`
+	Location string        `json:"location"`
+	Current  mobileCurrent `json:"current"`
+	Today    mobileHighLow `json:"today"`
+	Tomorrow mobileHighLow `json:"tomorrow"`
+}
+
+// mobileCurrent is the current conditions part of mobileResponse.
+type mobileCurrent struct {
+	Temp      *float64 `json:"temp"`
+	Condition *string  `json:"condition"` // icon without its -day/-night suffix, e.g. "partly-cloudy"
+	Icon      *string  `json:"icon"`
+}
+
+// mobileHighLow is a day's forecast high and low in mobileResponse.
+type mobileHighLow struct {
+	High *float64 `json:"high"`
+	Low  *float64 `json:"low"`
+}
+
+// conditionCode derives a stable condition code from a Visual Crossing icon name
+// by dropping the time-of-day suffix, so day and night share a code.
+func conditionCode(icon string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(icon, "-day"), "-night")
+}
+
+// mobileView builds the profile=mobile payload for q from the typed model: the
+// current conditions and the first two days of the response.
+func mobileView(q weatherQuery, current *weatherCurrent, days []weatherDay) mobileResponse {
+	out := mobileResponse{Schema: mobileSchemaVersion, Location: q.Location}
+	if current != nil {
+		out.Current.Temp = current.Temp
+		if current.Icon != "" {
+			icon, code := current.Icon, conditionCode(current.Icon)
+			out.Current.Icon, out.Current.Condition = &icon, &code
+		}
+	}
+	for i, hl := range []*mobileHighLow{&out.Today, &out.Tomorrow} {
+		if i < len(days) {
+			high, low := days[i].TempMax, days[i].TempMin
+			hl.High, hl.Low = &high, &low
+		}
+	}
+	return out
+}
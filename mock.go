@@ -0,0 +1,166 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockClimate is the deterministic climate MOCK_MODE derives from a location.
+type mockClimate struct {
+	seed     uint64
+	lat, lon float64
+	baseTemp float64 // mean daily temperature, °C
+	wetness  float64 // 0-1, scales the chance of rain
+}
+
+// newMockClimate derives the climate of a location from a hash of its
+// normalised form. Coordinates are used as given; other locations get
+// coordinates from the hash. Warmer climates sit nearer the equator, so the
+// numbers look plausible together.
+func newMockClimate(location string) mockClimate {
+	h := fnv.New64a()
+	h.Write([]byte(normalizeLocation(location)))
+	seed := h.Sum64()
+
+	m := mockClimate{seed: seed}
+	if coords, ok := canonicalCoordinates(location); ok {
+		latRaw, lonRaw, _ := strings.Cut(coords, ",")
+		m.lat, _ = strconv.ParseFloat(latRaw, 64)
+		m.lon, _ = strconv.ParseFloat(lonRaw, 64)
+	} else {
+		m.lat = float64(seed%12000)/100 - 60
+		m.lon = float64(seed>>16%36000)/100 - 180
+	}
+	m.baseTemp = 28 - math.Abs(m.lat)*0.45 + float64(seed>>32%600)/100 - 3
+	m.wetness = float64(seed>>40%100) / 100
+	return m
+}
+
+// round1 rounds v to one decimal place, as the upstream reports most values.
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// day generates the weather of one date. Each date has its own random source,
+// so a day's weather doesn't depend on the range it was requested in.
+func (m mockClimate) day(date time.Time) map[string]interface{} {
+	r := rand.New(rand.NewSource(int64(m.seed) ^ date.Unix()/86400))
+	mean := m.baseTemp + r.Float64()*6 - 3
+	spread := 4 + r.Float64()*5
+	tempMax, tempMin := round1(mean+spread/2), round1(mean-spread/2)
+	cloud := math.Round(r.Float64() * 100)
+	precipProb := math.Round(math.Min(100, r.Float64()*100*(0.3+m.wetness)*cloud/60))
+	precip := 0.0
+	if precipProb >= 50 {
+		precip = round1(r.Float64() * 12 * m.wetness)
+	}
+	uv := math.Round(math.Max(0, (10-math.Abs(m.lat)/9)*(1-cloud/150)))
+
+	conditions, icon := "Clear", "clear-day"
+	var precipType interface{}
+	switch {
+	case precip > 0 && tempMax < 1:
+		conditions, icon, precipType = "Snow, Overcast", "snow", []string{"snow"}
+	case precip > 0:
+		conditions, icon, precipType = "Rain, Partially cloudy", "rain", []string{"rain"}
+	case cloud > 70:
+		conditions, icon = "Overcast", "cloudy"
+	case cloud > 30:
+		conditions, icon = "Partially cloudy", "partly-cloudy-day"
+	}
+	wind := round1(3 + r.Float64()*35)
+	return map[string]interface{}{
+		"tempmax":    tempMax,
+		"tempmin":    tempMin,
+		"temp":       round1((tempMax + tempMin) / 2),
+		"feelslike":  round1((tempMax+tempMin)/2 - r.Float64()*2),
+		"humidity":   round1(40 + r.Float64()*30 + m.wetness*25),
+		"dew":        round1(tempMin - r.Float64()*4),
+		"precip":     precip,
+		"precipprob": precipProb,
+		"preciptype": precipType,
+		"windspeed":  wind,
+		"windgust":   round1(wind * (1.3 + r.Float64()*0.5)),
+		"winddir":    math.Round(r.Float64() * 360),
+		"cloudcover": cloud,
+		"uvindex":    uv,
+		"conditions": conditions,
+		"icon":       icon,
+		"visibility": round1(5 + r.Float64()*20 - m.wetness*5),
+	}
+}
+
+// mockWeather generates the response MOCK_MODE serves for q: a Visual Crossing
+// shaped body covering the query's range, with only its include sections.
+// The same location and dates always give the same weather.
+func mockWeather(q weatherQuery, now time.Time) map[string]interface{} {
+	m := newMockClimate(q.Location)
+	segments := strings.Split(q.path(), "/")
+	start, n, ok := fakeUpstreamRange(segments[1:], now)
+	if !ok {
+		start, n = now.UTC().Truncate(24*time.Hour), 1
+	}
+
+	data := map[string]interface{}{
+		"address":         q.Location,
+		"resolvedAddress": q.Location,
+		"latitude":        m.lat,
+		"longitude":       m.lon,
+		"timezone":        "UTC",
+		"tzoffset":        0,
+	}
+	include := parseSet(q.upstreamInclude())
+	all := len(include) == 0
+	if all || include["days"] || include["hours"] {
+		days := make([]interface{}, n)
+		for i := range days {
+			date := start.AddDate(0, 0, i)
+			day := fakeUpstreamDay(m.day(date), date, all || include["hours"])
+			if q.Agri {
+				fakeAgriElements(day)
+			}
+			days[i] = day
+		}
+		data["days"] = days
+	}
+	if all || include["current"] {
+		today := m.day(now.UTC().Truncate(24 * time.Hour))
+		data["currentConditions"] = map[string]interface{}{
+			"datetime":   now.UTC().Truncate(time.Hour).Format("15:04:05"),
+			"temp":       today["temp"],
+			"feelslike":  today["feelslike"],
+			"humidity":   today["humidity"],
+			"windspeed":  today["windspeed"],
+			"windgust":   today["windgust"],
+			"cloudcover": today["cloudcover"],
+			"conditions": today["conditions"],
+			"icon":       today["icon"],
+		}
+	}
+	if all || include["alerts"] {
+		data["alerts"] = []interface{}{}
+	}
+	return data
+}
+
+// mockResult answers a lookup in MOCK_MODE, touching neither the cache nor the
+// upstream.
+func mockResult(q weatherQuery) weatherResult {
+	now := clock.Now()
+	return weatherResult{Data: mockWeather(q, now), Cache: "MOCK", FetchedAt: now}
+}
+
+// mockMiddleware marks every response of the weather endpoints as mock data
+// with X-Mock: true.
+func mockMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Mock", "true")
+		c.Next()
+	}
+}
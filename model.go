@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// weatherDay holds the typed subset of a Visual Crossing day object that the
+// derived endpoints compute on. Missing fields decode to their zero values, or to
+// nil for pointer fields whose absence matters.
+type weatherDay struct {
+	Datetime   string        `json:"datetime"`
+	TempMax    float64       `json:"tempmax"`
+	TempMin    float64       `json:"tempmin"`
+	Temp       float64       `json:"temp"`
+	FeelsLike  *float64      `json:"feelslike"`
+	Precip     float64       `json:"precip"`
+	PrecipProb float64       `json:"precipprob"`
+	PrecipType []string      `json:"preciptype"`
+	Conditions string        `json:"conditions"`
+	Humidity   *float64      `json:"humidity"`
+	Dew        *float64      `json:"dew"`
+	UVIndex    *float64      `json:"uvindex"`
+	WindSpeed  *float64      `json:"windspeed"`
+	CloudCover *float64      `json:"cloudcover"`
+	Visibility *float64      `json:"visibility"`
+	Icon       string        `json:"icon"`
+	Hours      []weatherHour `json:"hours"`
+}
+
+// weatherHour holds the typed subset of a Visual Crossing hour object.
+type weatherHour struct {
+	Datetime      string   `json:"datetime"` // local time of day, e.g. 13:00:00
+	DatetimeEpoch int64    `json:"datetimeEpoch"`
+	Temp          *float64 `json:"temp"`
+	PrecipProb    *float64 `json:"precipprob"`
+	WindGust      *float64 `json:"windgust"`
+	Conditions    string   `json:"conditions"`
+}
+
+// weatherCurrent holds the typed subset of a Visual Crossing currentConditions
+// object.
+type weatherCurrent struct {
+	Datetime   string   `json:"datetime"`
+	Temp       *float64 `json:"temp"`
+	FeelsLike  *float64 `json:"feelslike"`
+	Humidity   *float64 `json:"humidity"`
+	Conditions string   `json:"conditions"`
+	Icon       string   `json:"icon"`
+}
+
+// decodeCurrent converts the current conditions of a weather response, returning
+// nil when the response has none.
+func decodeCurrent(data map[string]interface{}) (*weatherCurrent, error) {
+	raw, ok := data["currentConditions"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var current weatherCurrent
+	if err := json.Unmarshal(b, &current); err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+// decodeDays converts the "days" array of a weather response into typed days.
+func decodeDays(data map[string]interface{}) ([]weatherDay, error) {
+	raw, ok := data["days"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var days []weatherDay
+	if err := json.Unmarshal(b, &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// isEmptyWeather reports whether an upstream response carries neither daily data
+// nor current conditions, which Visual Crossing occasionally returns with a 200.
+func isEmptyWeather(data map[string]interface{}) bool {
+	if days, ok := data["days"].([]interface{}); ok && len(days) > 0 {
+		return false
+	}
+	if current, ok := data["currentConditions"].(map[string]interface{}); ok && len(current) > 0 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ndjsonContentType is the media type of format=ndjson responses.
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonLines returns the lines of an NDJSON /weather response: a leading
+// {"currentConditions": {...}} line when the response has current conditions,
+// then one line per day. currentKey is the (possibly renamed) name of the
+// current conditions field.
+func ndjsonLines(current interface{}, currentKey string, days []interface{}) []interface{} {
+	lines := make([]interface{}, 0, len(days)+1)
+	if current != nil {
+		lines = append(lines, map[string]interface{}{currentKey: current})
+	}
+	return append(lines, days...)
+}
+
+// writeNDJSON streams lines as newline-delimited JSON, flushing after each one so
+// consumers can process them as they arrive. Streamed responses carry no ETag or
+// Content-Length; HEAD requests get the headers only.
+func writeNDJSON(c *gin.Context, status int, lines []interface{}) {
+	c.Header("Content-Type", ndjsonContentType)
+	c.Status(status)
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+	for _, line := range lines {
+		b, err := json.Marshal(line)
+		if err != nil {
+			// The status is already sent; all that's left is to stop.
+			log.Printf("Error encoding NDJSON line: %v", err)
+			return
+		}
+		if _, err := c.Writer.Write(append(b, '\n')); err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+)
+
+// normalsInclude is the upstream include section holding climate normals.
+const normalsInclude = "normal"
+
+// normalMean returns the typical value of a normal element. Visual Crossing
+// reports normals as [min, mean, max]; a plain number is taken as the mean.
+func normalMean(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case []interface{}:
+		if len(v) == 3 {
+			mean, ok := v[1].(float64)
+			return mean, ok
+		}
+	}
+	return 0, false
+}
+
+// applyNormals adds departureFromNormal to every day carrying normals: how far
+// the day's tempmax and tempmin are above (or below) their normal means, to one
+// decimal. Days without normals, or without the temperature, are left as is.
+func applyNormals(data map[string]interface{}) {
+	days, _ := data["days"].([]interface{})
+	for _, d := range days {
+		day, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		normal, ok := day["normal"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		departure := make(map[string]interface{})
+		for _, field := range []string{"tempmax", "tempmin"} {
+			value, okValue := day[field].(float64)
+			mean, okMean := normalMean(normal[field])
+			if okValue && okMean {
+				departure[field] = math.Round((value-mean)*10) / 10
+			}
+		}
+		if len(departure) > 0 {
+			day["departureFromNormal"] = departure
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+)
+
+// Null policies for optional fields, selected by NULL_POLICY.
+const (
+	nullPolicyKeep = "null" // pass upstream nulls through unchanged
+	nullPolicyOmit = "omit" // drop fields whose value is null
+	nullPolicyZero = "zero" // replace nulls by their field's zero value
+)
+
+// optionalFieldZeros lists the per-period fields Visual Crossing may report as
+// null, with the value they take under NULL_POLICY=zero.
+var optionalFieldZeros = map[string]interface{}{
+	"cloudcover":     0.0,
+	"conditions":     "",
+	"description":    "",
+	"dew":            0.0,
+	"feelslike":      0.0,
+	"feelslikemax":   0.0,
+	"feelslikemin":   0.0,
+	"humidity":       0.0,
+	"icon":           "",
+	"moonphase":      0.0,
+	"precip":         0.0,
+	"precipcover":    0.0,
+	"precipprob":     0.0,
+	"preciptype":     []interface{}{},
+	"pressure":       0.0,
+	"severerisk":     0.0,
+	"snow":           0.0,
+	"snowdepth":      0.0,
+	"solarenergy":    0.0,
+	"solarradiation": 0.0,
+	"stations":       []interface{}{},
+	"sunrise":        "",
+	"sunset":         "",
+	"temp":           0.0,
+	"tempmax":        0.0,
+	"tempmin":        0.0,
+	"uvindex":        0.0,
+	"visibility":     0.0,
+	"windchill":      0.0,
+	"winddir":        0.0,
+	"windgust":       0.0,
+	"windspeed":      0.0,
+}
+
+// parseNullPolicy validates NULL_POLICY, defaulting to passing nulls through.
+func parseNullPolicy(raw string) string {
+	switch raw {
+	case "":
+		return nullPolicyKeep
+	case nullPolicyKeep, nullPolicyOmit, nullPolicyZero:
+		return raw
+	}
+	log.Printf("Invalid NULL_POLICY %q, defaulting to %s", raw, nullPolicyKeep)
+	return nullPolicyKeep
+}
+
+// applyNullPolicy rewrites the null fields of every day, hour and the current
+// conditions according to policy. Under the zero policy, null fields without a
+// known zero value are dropped, so no null remains either way.
+func applyNullPolicy(data map[string]interface{}, policy string) {
+	if policy == nullPolicyKeep {
+		return
+	}
+	forEachPeriod(data, func(period map[string]interface{}) {
+		replaceNulls(period, policy)
+		hours, _ := period["hours"].([]interface{})
+		for _, h := range hours {
+			if hour, ok := h.(map[string]interface{}); ok {
+				replaceNulls(hour, policy)
+			}
+		}
+	})
+}
+
+// replaceNulls applies policy to the null fields of a single period.
+func replaceNulls(period map[string]interface{}, policy string) {
+	for field, v := range period {
+		if v != nil {
+			continue
+		}
+		if zero, ok := optionalFieldZeros[field]; ok && policy == nullPolicyZero {
+			period[field] = zero
+			continue
+		}
+		delete(period, field)
+	}
+}
+
+// pruneEmpty recursively drops object fields whose value is null, an empty
+// string or an empty array, for prune=true. Zero numbers and false are kept, as
+// are array elements, whose positions may carry meaning. Objects are pruned in
+// place.
+func pruneEmpty(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if isEmptyValue(field) {
+				delete(v, k)
+				continue
+			}
+			pruneEmpty(field)
+		}
+	case []interface{}:
+		for _, item := range v {
+			pruneEmpty(item)
+		}
+	}
+}
+
+// isEmptyValue reports whether v is null, "" or [].
+func isEmptyValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPruneEmpty(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{`{"a":null,"b":"","c":[],"d":"x"}`, `{"d":"x"}`},
+		// Meaningful zero values survive.
+		{`{"precip":0,"snow":false,"temp":-0.5,"icon":"rain"}`, `{"precip":0,"snow":false,"temp":-0.5,"icon":"rain"}`},
+		{`{"days":[{"temp":1,"stations":[],"hours":[{"precip":null,"dew":0}]}]}`, `{"days":[{"temp":1,"hours":[{"dew":0}]}]}`},
+		// Array elements keep their positions; emptied objects stay.
+		{`{"preciptype":[null,"","rain"],"alerts":[{"event":null}]}`, `{"preciptype":[null,"","rain"],"alerts":[{}]}`},
+	} {
+		var got, want interface{}
+		if err := json.Unmarshal([]byte(tc.in), &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		pruneEmpty(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pruneEmpty(%s) = %v, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+// TestPruneAfterCache checks prune=true trims the response while the cache
+// keeps the full data for other requests.
+func TestPruneAfterCache(t *testing.T) {
+	upstream := `{"resolvedAddress":"London","description":"","alerts":[],"days":[{"datetime":"2026-10-14","temp":0,"precip":0,"snow":null,"preciptype":null}]}`
+	mr := setupTest(t, testConfig(t), respondWith(http.StatusOK, upstream))
+
+	for _, cache := range []string{"MISS", "HIT"} {
+		w := requestWeather("location=London&prune=true")
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != cache {
+			t.Fatalf("got %d, X-Cache %q; want %s", w.Code, w.Header().Get("X-Cache"), cache)
+		}
+		body := w.Body.String()
+		for _, pruned := range []string{`"description"`, `"alerts"`, `"snow"`, `"preciptype"`, "null"} {
+			if strings.Contains(body, pruned) {
+				t.Errorf("%s: %s not pruned from %s", cache, pruned, body)
+			}
+		}
+		if !strings.Contains(body, `"temp":0`) || !strings.Contains(body, `"precip":0`) {
+			t.Errorf("%s: zero values pruned from %s", cache, body)
+		}
+	}
+
+	raw, _ := mr.Get(londonKey)
+	if data, _, err := decodeEntry(raw); err != nil || data["alerts"] == nil {
+		t.Errorf("cache entry pruned: %v, %v", data, err)
+	}
+	if w := requestWeather("location=London"); !strings.Contains(w.Body.String(), `"snow":null`) {
+		t.Errorf("unpruned response lost its nulls: %s", w.Body)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// parseNumberFormat reads the numberFormat parameter, a BCP 47 locale such as
+// "de-DE", returning a printer formatting numbers the way that locale writes
+// them; nil means it wasn't given and numbers stay JSON numbers.
+func parseNumberFormat(raw string) (*message.Printer, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	tag, err := language.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("numberFormat must be a locale such as de-DE, got %q", raw)
+	}
+	return message.NewPrinter(tag), nil
+}
+
+// formatNumber writes v in the printer's locale with as many fraction digits
+// as it has, so formatting never rounds.
+func formatNumber(p *message.Printer, v float64) string {
+	digits := 0
+	if s := strconv.FormatFloat(v, 'f', -1, 64); strings.Contains(s, ".") {
+		digits = len(s) - strings.Index(s, ".") - 1
+	}
+	return p.Sprint(number.Decimal(v, number.MinFractionDigits(digits), number.MaxFractionDigits(digits)))
+}
+
+// applyNumberFormat replaces every number in v, at any depth, by its formatted
+// string, except Unix timestamps (fields ending in "Epoch"), which are
+// identifiers rather than quantities to display. It returns the rewritten
+// value.
+func applyNumberFormat(v interface{}, p *message.Printer) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if _, ok := field.(float64); ok && strings.HasSuffix(k, "Epoch") {
+				continue
+			}
+			v[k] = applyNumberFormat(field, p)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = applyNumberFormat(elem, p)
+		}
+	case float64:
+		return formatNumber(p, v)
+	}
+	return v
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// dayPage describes the requested slice of the days array.
+type dayPage struct {
+	Offset int
+	Limit  int // 0 means "until the end"
+}
+
+// parseDayPage reads the optional offset and limit query parameters.
+func parseDayPage(offsetStr, limitStr string) (dayPage, error) {
+	var p dayPage
+	if offsetStr != "" {
+		v, err := strconv.Atoi(offsetStr)
+		if err != nil || v < 0 {
+			return p, fmt.Errorf("offset must be a non-negative integer")
+		}
+		p.Offset = v
+	}
+	if limitStr != "" {
+		v, err := strconv.Atoi(limitStr)
+		if err != nil || v < 1 {
+			return p, fmt.Errorf("limit must be a positive integer")
+		}
+		p.Limit = v
+	}
+	return p, nil
+}
+
+// active reports whether any pagination was requested.
+func (p dayPage) active() bool {
+	return p.Offset > 0 || p.Limit > 0
+}
+
+// paginateDays returns a shallow copy of data whose "days" array is restricted to
+// the page, along with the total number of days and whether the result is a
+// strict subset of them. The cached map itself is never modified.
+func paginateDays(data map[string]interface{}, p dayPage) (map[string]interface{}, int, bool, error) {
+	days, _ := data["days"].([]interface{})
+	total := len(days)
+	if p.Offset > 0 && p.Offset >= total {
+		return nil, total, false, fmt.Errorf("offset %d is out of range, %d days available", p.Offset, total)
+	}
+
+	end := total
+	if p.Limit > 0 && p.Offset+p.Limit < total {
+		end = p.Offset + p.Limit
+	}
+
+	page := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		page[k] = v
+	}
+	if days != nil {
+		page["days"] = days[p.Offset:end]
+	}
+	return page, total, end-p.Offset < total, nil
+}
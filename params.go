@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// queryParams are the query parameters shared by every weather endpoint.
+type queryParams struct {
+	Location string `form:"location" binding:"required_without=Zip,excluded_with=Zip"`
+	Zip      string `form:"zip"`                                 // validated by postalLocation
+	Country  string `form:"country" binding:"required_with=Zip"` // validated by postalLocation
+	Start    string `form:"start" binding:"omitempty,datetime=2006-01-02"`
+	End      string `form:"end" binding:"omitempty,datetime=2006-01-02"`
+	Lang     string `form:"lang"` // validated by parseLang
+
+	Disambiguate string `form:"disambiguate" binding:"omitempty,oneof=true false"`
+}
+
+// weatherParams are the query parameters accepted by /weather.
+type weatherParams struct {
+	queryParams
+	Offset        string `form:"offset" binding:"omitempty,number"`
+	Limit         string `form:"limit" binding:"omitempty,number"`
+	Debug         string `form:"debug" binding:"omitempty,oneof=true false"`
+	WindLabel     string `form:"windLabel" binding:"omitempty,oneof=true false"`
+	Beaufort      string `form:"beaufort" binding:"omitempty,oneof=true false"`
+	GustThreshold string `form:"gustThreshold" binding:"omitempty,numeric"`
+	NoCache       string `form:"nocache" binding:"omitempty,oneof=true false"`
+
+	Confidence        string `form:"confidence" binding:"omitempty,oneof=true false"`
+	MinConfidence     string `form:"minConfidence" binding:"omitempty,oneof=low medium high"`
+	Normals           string `form:"normals" binding:"omitempty,oneof=true false"`
+	Agri              string `form:"agri" binding:"omitempty,oneof=true false"`
+	Profile           string `form:"profile" binding:"omitempty,oneof=full mobile compact"`
+	Format            string `form:"format" binding:"omitempty,oneof=json ndjson geojson"`
+	Flatten           string `form:"flatten" binding:"omitempty,oneof=true false"`
+	IncludeProvenance string `form:"includeProvenance" binding:"omitempty,oneof=true false"`
+	Prune             string `form:"prune" binding:"omitempty,oneof=true false"`
+	LocalTime         string `form:"localTime" binding:"omitempty,oneof=true false"`
+	IncludeUnits      string `form:"includeUnits" binding:"omitempty,oneof=true false"`
+	Delta             string `form:"delta" binding:"omitempty,oneof=true false"`
+	Downsample        string `form:"downsample"`   // validated by parseDownsample
+	NumberFormat      string `form:"numberFormat"` // validated by parseNumberFormat
+	Intervals         string `form:"intervals" binding:"omitempty,oneof=true derived false"`
+	Deltas            string `form:"deltas" binding:"omitempty,oneof=true false"`
+	Units             string `form:"units" binding:"omitempty,oneof=metric us uk base"`
+	Include           string `form:"include" binding:"omitempty,oneof=days current all"`
+}
+
+// paramError describes one invalid query parameter.
+type paramError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// bindQuery binds and validates the request's query parameters into v. On failure
+// it writes a 400 listing every invalid parameter and returns false.
+func bindQuery(c *gin.Context, v interface{}) bool {
+	err := c.ShouldBindQuery(v)
+	if err == nil {
+		return true
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	details := make([]paramError, 0, len(verrs))
+	for _, fe := range verrs {
+		details = append(details, paramError{Param: formName(v, fe), Message: paramMessage(fe)})
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters", "details": details})
+	return false
+}
+
+// formName returns the query parameter name of the field a validation error is
+// about, falling back to the Go field name.
+func formName(v interface{}, fe validator.FieldError) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if f, ok := t.FieldByName(fe.StructField()); ok {
+		if name := strings.Split(f.Tag.Get("form"), ",")[0]; name != "" {
+			return name
+		}
+	}
+	return fe.Field()
+}
+
+// paramMessage turns a validation failure into a human-readable message.
+func paramMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return "is required unless " + strings.ToLower(fe.Param()) + " is given"
+	case "required_with":
+		return "is required with " + strings.ToLower(fe.Param())
+	case "excluded_with":
+		return "must not be given with " + strings.ToLower(fe.Param())
+	case "datetime":
+		return "must be a date in YYYY-MM-DD format"
+	case "number":
+		return "must be a non-negative integer"
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	}
+	return "is invalid"
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Cache policies for partial upstream responses, selected by
+// CACHE_PARTIAL_RESPONSES.
+const (
+	partialCacheFull  = "full"  // cache like any other response
+	partialCacheShort = "short" // cache for PARTIAL_RESPONSE_CACHE_TTL
+	partialCacheNever = "never" // serve but don't cache
+)
+
+// partialResponses counts upstream responses that failed validation but were
+// still served.
+var partialResponses atomic.Int64
+
+// parsePartialPolicy validates CACHE_PARTIAL_RESPONSES, defaulting to caching
+// partial responses like complete ones.
+func parsePartialPolicy(raw string) string {
+	switch raw {
+	case "":
+		return partialCacheFull
+	case partialCacheFull, partialCacheShort, partialCacheNever:
+		return raw
+	}
+	log.Printf("Invalid CACHE_PARTIAL_RESPONSES %q, defaulting to %s", raw, partialCacheFull)
+	return partialCacheFull
+}
+
+// requiredDayFields are the day fields every endpoint relies on.
+var requiredDayFields = []string{"datetime", "tempmax", "tempmin", "temp"}
+
+// incompleteReason validates a non-empty upstream response against the query
+// that fetched it and describes why it's partial, or returns "" when it's
+// complete. A response is partial when:
+//
+//   - resolvedAddress is missing or empty;
+//   - days were requested but the days array is missing or empty;
+//   - a day lacks one of requiredDayFields, or has it as null;
+//   - hours were requested but a day has no hours;
+//   - current conditions were requested for an undated query but are missing.
+func incompleteReason(q weatherQuery, data map[string]interface{}) string {
+	if address, _ := data["resolvedAddress"].(string); address == "" {
+		return "missing resolvedAddress"
+	}
+	include := parseSet(q.include())
+
+	days, _ := data["days"].([]interface{})
+	if (include["days"] || include["hours"]) && len(days) == 0 {
+		return "missing days"
+	}
+	for i, raw := range days {
+		day, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("day %d is not an object", i)
+		}
+		for _, field := range requiredDayFields {
+			if day[field] == nil {
+				return fmt.Sprintf("day %d missing %s", i, field)
+			}
+		}
+		if include["hours"] {
+			if hours, _ := day["hours"].([]interface{}); len(hours) == 0 {
+				return fmt.Sprintf("day %d missing hours", i)
+			}
+		}
+	}
+
+	// Visual Crossing only reports current conditions for undated queries.
+	if include["current"] && q.Start == "" {
+		if current, _ := data["currentConditions"].(map[string]interface{}); len(current) == 0 {
+			return "missing currentConditions"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// placesCSV is the bundled table of ambiguous place names: name, address,
+// latitude, longitude, with one row per candidate.
+//
+//go:embed places.csv
+var placesCSV string
+
+// placeCandidate is one of the places an ambiguous location may refer to.
+type placeCandidate struct {
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// ambiguousPlaces maps normalised place names to their candidates, loaded from
+// placesCSV.
+var ambiguousPlaces = loadPlaces(placesCSV)
+
+// loadPlaces parses the ambiguous place table, skipping the header and logging
+// malformed rows. Names with a single candidate aren't ambiguous and are dropped.
+func loadPlaces(raw string) map[string][]placeCandidate {
+	rows, err := csv.NewReader(strings.NewReader(raw)).ReadAll()
+	if err != nil {
+		log.Printf("Error reading the place table: %v", err)
+		return nil
+	}
+	out := make(map[string][]placeCandidate)
+	for _, row := range rows[1:] {
+		if len(row) != 4 {
+			log.Printf("Ignoring malformed place row %q", row)
+			continue
+		}
+		lat, errLat := strconv.ParseFloat(row[2], 64)
+		lon, errLon := strconv.ParseFloat(row[3], 64)
+		if errLat != nil || errLon != nil {
+			log.Printf("Ignoring malformed place row %q", row)
+			continue
+		}
+		name := normalizeLocation(row[0])
+		out[name] = append(out[name], placeCandidate{Address: row[1], Latitude: lat, Longitude: lon})
+	}
+	for name, candidates := range out {
+		if len(candidates) < 2 {
+			delete(out, name)
+		}
+	}
+	return out
+}
+
+// placeCandidates returns the candidates for a free-text location that is
+// exactly one of the bundled ambiguous names, like "springfield". Qualified
+// locations such as "Springfield, IL" and coordinates never match.
+func placeCandidates(location string) []placeCandidate {
+	return ambiguousPlaces[normalizeLocation(location)]
+}
+
+// writeAmbiguous answers a disambiguate=true request for an ambiguous location
+// with 300 Multiple Choices, listing the candidates so the client can retry with
+// one of their addresses or coordinates.
+func writeAmbiguous(c *gin.Context, location string, candidates []placeCandidate) {
+	c.JSON(http.StatusMultipleChoices, gin.H{
+		"error":      "location " + strconv.Quote(location) + " is ambiguous",
+		"code":       "AMBIGUOUS_LOCATION",
+		"candidates": candidates,
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// postalFormats are the postal code formats checked for common countries, keyed
+// by ISO 3166-1 alpha-2 code. Codes are matched upper-cased with whitespace
+// collapsed; other countries only get the generic check of genericPostalFormat.
+var postalFormats = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+}
+
+// genericPostalFormat is the check for countries without an entry in
+// postalFormats: letters and digits, optionally split by a space or hyphen.
+var genericPostalFormat = regexp.MustCompile(`^[A-Z\d][A-Z\d -]{1,9}$`)
+
+// countryCodeFormat matches an ISO 3166-1 alpha-2 country code, upper-cased.
+var countryCodeFormat = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// errInvalidCountry is returned for a country parameter that isn't two letters.
+var errInvalidCountry = &apiError{
+	Status:  http.StatusBadRequest,
+	Code:    "INVALID_COUNTRY",
+	Message: "country must be an ISO 3166-1 alpha-2 code such as US or GB",
+}
+
+// postalLocation combines the zip and country query parameters into the
+// location sent to the upstream, e.g. "10001,US". Both are upper-cased and runs
+// of whitespace collapse to single spaces, so "sw1a  1aa" and "SW1A 1AA" share
+// a cache entry. The code must match the country's format in postalFormats.
+func postalLocation(zip, country string) (string, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if !countryCodeFormat.MatchString(country) {
+		return "", errInvalidCountry
+	}
+	zip = strings.ToUpper(strings.Join(strings.Fields(zip), " "))
+	format, ok := postalFormats[country]
+	if !ok {
+		format = genericPostalFormat
+	}
+	if !format.MatchString(zip) {
+		return "", &apiError{
+			Status:  http.StatusBadRequest,
+			Code:    "INVALID_POSTAL_CODE",
+			Message: fmt.Sprintf("zip %q is not a valid postal code for %s", zip, country),
+		}
+	}
+	return zip + "," + country, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// precipDay is the per-day precipitation detail returned by /weather/precip.
+type precipDay struct {
+	Date       string   `json:"date"`
+	Precip     float64  `json:"precip"`
+	PrecipProb float64  `json:"precipprob"`
+	PrecipType []string `json:"preciptype"`
+}
+
+// precipDays extracts precipitation fields from days. Missing amounts and
+// probabilities are zero and a missing type is reported as an empty list.
+func precipDays(days []weatherDay) []precipDay {
+	out := make([]precipDay, 0, len(days))
+	for _, d := range days {
+		types := d.PrecipType
+		if types == nil {
+			types = []string{}
+		}
+		out = append(out, precipDay{
+			Date:       d.Datetime,
+			Precip:     d.Precip,
+			PrecipProb: d.PrecipProb,
+			PrecipType: types,
+		})
+	}
+	return out
+}
+
+// getPrecipHandler handles GET /weather/precip requests, returning only the
+// per-day precipitation data from the (cached) full response.
+func getPrecipHandler(c *gin.Context) {
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"location": q.Location,
+		"days":     precipDays(days),
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// prefetchTimeout bounds one predictive prefetch.
+const prefetchTimeout = 30 * time.Second
+
+// Predictive prefetch counters, reported by /stats.
+var (
+	prefetchesStarted atomic.Int64 // forecasts fetched ahead of a follow-up request
+	prefetchesSkipped atomic.Int64 // prefetches left out because the upstream was busy or out of quota
+)
+
+// isTodayQuery reports whether q only asks about today: the current conditions,
+// or a date range that starts and ends today.
+func isTodayQuery(q weatherQuery, now time.Time) bool {
+	if q.Start == "" {
+		return queryType(q, "", now) == queryCurrent
+	}
+	today := now.UTC().Format(dateLayout)
+	return q.Start == today && (q.End == "" || q.End == today)
+}
+
+// forecastQuery returns the undated /weather query for q's location, which a
+// client asking about today typically requests next.
+func forecastQuery(q weatherQuery, now time.Time) weatherQuery {
+	f := weatherQuery{Location: q.Location, Airport: q.Airport, Include: endpointInclude("/weather"), Lang: q.Lang, UnitGroup: q.UnitGroup}
+	return routeQuery(f, "/weather", now)
+}
+
+// prefetchForecast warms the cache with the full forecast for a successful
+// lookup of today, see PREDICTIVE_PREFETCH, without delaying the request. The
+// fetch goes through the upstream queue and the coalescing group like any miss,
+// and is skipped when the forecast is already cached, queued fetches are
+// waiting or the upstream quota is exhausted.
+func prefetchForecast(q weatherQuery) {
+	now := clock.Now()
+	if !cfg.PredictivePrefetch || cfg.MockMode || !isTodayQuery(q, now) {
+		return
+	}
+	f := forecastQuery(q, now)
+	go func() {
+		cacheKey := f.cacheKey()
+		if ttl, err := cacheTTL(ctx, cacheKey); err != nil || ttl > 0 {
+			return
+		}
+		if _, exhausted := quotaExhaustedUntil(clock.Now()); exhausted || upstreamQueue.busy() {
+			prefetchesSkipped.Add(1)
+			return
+		}
+		prefetchesStarted.Add(1)
+		pctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+		defer cancel()
+		if _, _, err := coalescedFetchAndCache(pctx, f, cacheKey); err != nil {
+			log.Printf("Predictive prefetch failed for %s: %v", f.Location, err)
+		}
+	}()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+
+	"weather-api/weatherpb"
+)
+
+// protobufContentType is the media type of protobuf-encoded /weather responses,
+// see weatherpb/weather.proto.
+const protobufContentType = "application/x-protobuf"
+
+// wantsProtobuf reports whether the client asked for a protobuf response. Only
+// an explicit application/x-protobuf in Accept selects it; anything else,
+// including wildcards, gets JSON.
+func wantsProtobuf(c *gin.Context) bool {
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == protobufContentType && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeProtobuf converts a weather response to its protobuf message through the
+// typed model, dropping fields the schema doesn't carry.
+func encodeProtobuf(data map[string]interface{}) ([]byte, error) {
+	current, err := decodeCurrent(data)
+	if err != nil {
+		return nil, err
+	}
+	days, err := decodeDays(data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &weatherpb.WeatherResponse{}
+	msg.Address, _ = data["address"].(string)
+	msg.ResolvedAddress, _ = data["resolvedAddress"].(string)
+	msg.Latitude, _ = data["latitude"].(float64)
+	msg.Longitude, _ = data["longitude"].(float64)
+	msg.Timezone, _ = data["timezone"].(string)
+	if current != nil {
+		msg.CurrentConditions = &weatherpb.CurrentConditions{
+			Datetime:   current.Datetime,
+			Temp:       current.Temp,
+			Feelslike:  current.FeelsLike,
+			Humidity:   current.Humidity,
+			Conditions: current.Conditions,
+			Icon:       current.Icon,
+		}
+	}
+	for _, d := range days {
+		msg.Days = append(msg.Days, &weatherpb.Day{
+			Datetime:   d.Datetime,
+			Tempmax:    d.TempMax,
+			Tempmin:    d.TempMin,
+			Temp:       d.Temp,
+			Feelslike:  d.FeelsLike,
+			Precip:     d.Precip,
+			Precipprob: d.PrecipProb,
+			Preciptype: d.PrecipType,
+			Humidity:   d.Humidity,
+			Dew:        d.Dew,
+			Uvindex:    d.UVIndex,
+			Conditions: d.Conditions,
+			Icon:       d.Icon,
+		})
+	}
+	// Deterministic output keeps ETags stable across cache hits.
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+
+	"weather-api/weatherpb"
+)
+
+func TestEncodeProtobufRoundTrip(t *testing.T) {
+	var data map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"address": "london", "resolvedAddress": "London, England", "latitude": 51.5, "longitude": -0.12,
+		"timezone": "Europe/London", "unknownField": true,
+		"currentConditions": {"datetime": "12:00:00", "temp": 14.5, "humidity": 80, "conditions": "Rain", "icon": "rain"},
+		"days": [
+			{"datetime": "2026-10-14", "tempmax": 16, "tempmin": 9, "temp": 12.5, "feelslike": 11, "precip": 2.4,
+			 "precipprob": 90, "preciptype": ["rain"], "humidity": 85, "dew": 10, "uvindex": 0, "conditions": "Rain", "icon": "rain"},
+			{"datetime": "2026-10-15", "tempmax": 18, "tempmin": 8, "temp": 13}
+		]}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &weatherpb.WeatherResponse{
+		Address: "london", ResolvedAddress: "London, England", Latitude: 51.5, Longitude: -0.12, Timezone: "Europe/London",
+		CurrentConditions: &weatherpb.CurrentConditions{
+			Datetime: "12:00:00", Temp: proto.Float64(14.5), Humidity: proto.Float64(80), Conditions: "Rain", Icon: "rain",
+		},
+		Days: []*weatherpb.Day{
+			{
+				Datetime: "2026-10-14", Tempmax: 16, Tempmin: 9, Temp: 12.5, Feelslike: proto.Float64(11), Precip: 2.4,
+				Precipprob: 90, Preciptype: []string{"rain"}, Humidity: proto.Float64(85), Dew: proto.Float64(10),
+				Uvindex: proto.Float64(0), Conditions: "Rain", Icon: "rain",
+			},
+			// Fields the upstream omitted stay unset rather than zero.
+			{Datetime: "2026-10-15", Tempmax: 18, Tempmin: 8, Temp: 13},
+		},
+	}
+
+	encoded, err := encodeProtobuf(data)
+	if err != nil {
+		t.Fatalf("encodeProtobuf: %v", err)
+	}
+	var got weatherpb.WeatherResponse
+	if err := proto.Unmarshal(encoded, &got); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if !proto.Equal(&got, want) {
+		t.Errorf("round trip = %v, want %v", &got, want)
+	}
+	if again, _ := encodeProtobuf(data); string(again) != string(encoded) {
+		t.Error("encoding isn't deterministic")
+	}
+}
+
+func TestWantsProtobuf(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                       false,
+		"*/*":                    false,
+		"application/json":       false,
+		"application/x-protobuf": true,
+		"application/json, application/x-protobuf;q=0.5": true,
+		"application/x-protobuf;q=0":                     false,
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/weather", nil)
+		c.Request.Header.Set("Accept", accept)
+		if got := wantsProtobuf(c); got != want {
+			t.Errorf("wantsProtobuf(%q) = %v, want %v", accept, got, want)
+		}
+	}
+}
+
+func TestWeatherProtobufResponse(t *testing.T) {
+	setupTest(t, testConfig(t), respondWith(http.StatusOK, fixtureWeather))
+	for _, cache := range []string{"MISS", "HIT"} {
+		req := httptest.NewRequest(http.MethodGet, "/weather?location=London", nil)
+		req.Header.Set("Accept", protobufContentType)
+		w := serveRequest(req)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != protobufContentType || w.Header().Get("X-Cache") != cache {
+			t.Fatalf("got %d, Content-Type %q, X-Cache %q", w.Code, w.Header().Get("Content-Type"), w.Header().Get("X-Cache"))
+		}
+		var got weatherpb.WeatherResponse
+		if err := proto.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: decoding: %v", cache, err)
+		}
+		if got.ResolvedAddress != "London" || len(got.Days) != 1 || got.Days[0].Tempmax != 20 {
+			t.Errorf("%s: got %v", cache, &got)
+		}
+	}
+}
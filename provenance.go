@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Data sources named in includeProvenance responses.
+const (
+	provenanceUpstream = "visualcrossing" // the configured upstream
+	provenanceMock     = "mock"           // MOCK_MODE's synthetic weather
+	provenanceDerived  = "derived"        // computed by the service from upstream fields
+)
+
+// provenanceSource describes one data source that contributed to a response.
+type provenanceSource struct {
+	Provider  string `json:"provider"`
+	FetchedAt string `json:"fetchedAt,omitempty"`
+	Cache     string `json:"cache"`
+}
+
+// provenance is the includeProvenance=true annotation of a response: the data
+// sources it was built from and which of them supplied each top-level field.
+type provenance struct {
+	Sources []provenanceSource     `json:"sources"`
+	Fields  map[string]interface{} `json:"fields"`
+}
+
+// resultProvider returns the data source of a lookup.
+func resultProvider(result weatherResult) string {
+	if result.Cache == "MOCK" {
+		return provenanceMock
+	}
+	return provenanceUpstream
+}
+
+// upstreamKeys returns the sorted top-level keys of data as served by the
+// lookup, before any response option adds fields of its own.
+func upstreamKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// withProvenance returns a shallow copy of data with a "provenance" object
+// attributing each top-level field to the lookup's provider when it is one of
+// fromSource, and as derived otherwise. Field names follow renames.
+func withProvenance(data map[string]interface{}, fromSource []string, result weatherResult, renames map[string]string) map[string]interface{} {
+	provider := resultProvider(result)
+	source := provenanceSource{Provider: provider, Cache: result.Cache}
+	if !result.FetchedAt.IsZero() {
+		source.FetchedAt = result.FetchedAt.UTC().Format(time.RFC3339)
+	}
+
+	fields := make(map[string]interface{}, len(data))
+	for k := range data {
+		fields[k] = provenanceDerived
+	}
+	for _, k := range fromSource {
+		if _, ok := data[k]; ok {
+			fields[k] = provider
+		}
+	}
+	renameKeys(fields, renames)
+
+	out := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		out[k] = v
+	}
+	out["provenance"] = provenance{Sources: []provenanceSource{source}, Fields: fields}
+	return out
+}
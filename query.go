@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dateLayout is the date format accepted for the start/end query parameters and
+// expected by the Visual Crossing timeline API.
+const dateLayout = "2006-01-02"
+
+// weatherQuery describes a single upstream weather lookup. It is used both to build
+// the upstream request URL and to derive the Redis cache key.
+type weatherQuery struct {
+	Location  string
+	Start     string   // optional, YYYY-MM-DD
+	End       string   // optional, YYYY-MM-DD, requires Start
+	Period    string   // dynamic period of undated queries, e.g. "today"; empty means the 15-day forecast
+	Include   string   // upstream include set, normalised; empty means defaultInclude
+	Lang      string   // condition text language; empty means defaultLang
+	Normals   bool     // also request climate normals, see NORMALS_ENABLED
+	Agri      bool     // also request the soil and evapotranspiration elements, see agriElements
+	UnitGroup string   // Visual Crossing unit group; empty means upstreamUnitGroup
+	Airport   *airport // airport the location was resolved from; not part of the key
+	// Immutable marks past data that can no longer change, cached for
+	// HISTORY_CACHE_TTL; not part of the key.
+	Immutable bool
+
+	// Passthrough holds safelisted provider options forwarded to the upstream
+	// as-is, keyed by their upstream name.
+	Passthrough url.Values
+}
+
+// passthroughPrefix marks query parameters forwarded to the upstream, e.g.
+// vc.elements=datetime,temp becomes elements=datetime,temp.
+const passthroughPrefix = "vc."
+
+// reservedUpstreamParams are set by the service itself and can't be passed through.
+var reservedUpstreamParams = map[string]bool{"key": true, "unitGroup": true, "include": true, "lang": true}
+
+// parsePassthroughParams parses the comma-separated ALLOWED_PASSTHROUGH_PARAMS
+// safelist, dropping parameters the service sets itself.
+func parsePassthroughParams(raw string) map[string]bool {
+	allowed := make(map[string]bool)
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if reservedUpstreamParams[p] {
+			log.Printf("Ignoring reserved ALLOWED_PASSTHROUGH_PARAMS entry %q", p)
+			continue
+		}
+		allowed[p] = true
+	}
+	return allowed
+}
+
+// passthroughParams extracts the prefixed passthrough parameters from a request's
+// query, rejecting any that aren't safelisted.
+func passthroughParams(query url.Values, allowed map[string]bool) (url.Values, error) {
+	var params url.Values
+	var rejected []string
+	for name, values := range query {
+		if !strings.HasPrefix(name, passthroughPrefix) {
+			continue
+		}
+		upstream := strings.TrimPrefix(name, passthroughPrefix)
+		if !allowed[upstream] {
+			rejected = append(rejected, name)
+			continue
+		}
+		if params == nil {
+			params = make(url.Values)
+		}
+		params[upstream] = values
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return nil, fmt.Errorf("passthrough parameters not allowed: %s", strings.Join(rejected, ", "))
+	}
+	return params, nil
+}
+
+// cacheKey returns the Redis key under which the result of the query is cached:
+// the prefix followed by the hashed canonical key, so keys have a fixed length
+// whatever the location looks like.
+func (q weatherQuery) cacheKey() string {
+	return cachePrefix + cacheKeyHash(q.canonicalKey())
+}
+
+// canonicalKey returns the query parameters identifying a cache entry, e.g.
+// "london:2024-06-01:2024-06-07", with ":<period>" for undated queries with a
+// dynamic period, "+include=<set>" for include sets other
+// than defaultInclude, "+lang=<code>" for languages other than defaultLang and
+// "+normals" when normals are requested, "+agri" when the agricultural elements
+// are and "+units=<group>" for unit groups other than upstreamUnitGroup.
+// The location is normalised so differently
+// cased spellings share an entry. It is stored in the entry so hashed keys can be
+// mapped back to what they cache.
+func (q weatherQuery) canonicalKey() string {
+	key := normalizeLocation(q.Location)
+	if q.Start != "" {
+		key += ":" + q.Start
+	}
+	if q.End != "" {
+		key += ":" + q.End
+	}
+	if q.Period != "" {
+		key += ":" + q.Period
+	}
+	if include := q.include(); include != defaultInclude {
+		key += "+include=" + include
+	}
+	if q.Lang != "" && q.Lang != defaultLang {
+		key += "+lang=" + q.Lang
+	}
+	if q.Normals {
+		key += "+normals"
+	}
+	if q.Agri {
+		key += "+agri"
+	}
+	if unitGroup := q.unitGroup(); unitGroup != upstreamUnitGroup {
+		key += "+units=" + unitGroup
+	}
+	if len(q.Passthrough) > 0 {
+		// Encode sorts by name, so equivalent requests share a key.
+		key += "?" + q.Passthrough.Encode()
+	}
+	return key
+}
+
+// unitGroup returns the unit group the query's data is fetched in.
+func (q weatherQuery) unitGroup() string {
+	if q.UnitGroup == "" {
+		return upstreamUnitGroup
+	}
+	return q.UnitGroup
+}
+
+// include returns the query's upstream include set.
+func (q weatherQuery) include() string {
+	if q.Include == "" {
+		return defaultInclude
+	}
+	return q.Include
+}
+
+// upstreamInclude returns the include value sent to the upstream: the query's
+// include set plus normals when requested.
+func (q weatherQuery) upstreamInclude() string {
+	if q.Normals {
+		return normalizeInclude(q.include() + "," + normalsInclude)
+	}
+	return q.include()
+}
+
+// cacheKeyHash turns a canonical key into the suffix of its Redis key. Replacing
+// it changes the key scheme; existing entries then simply stop being hit.
+var cacheKeyHash = func(canonical string) string {
+	sum := sha1.Sum([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// path returns the upstream path segment for the query, e.g.
+// "London/2024-06-01/2024-06-07" or "London/today".
+func (q weatherQuery) path() string {
+	p := url.PathEscape(q.Location)
+	if q.Period != "" {
+		return p + "/" + q.Period
+	}
+	if q.Start != "" {
+		p += "/" + q.Start
+	}
+	if q.End != "" {
+		p += "/" + q.End
+	}
+	return p
+}
+
+// validateDateRange checks the optional start/end dates against the configured
+// forecast and history horizons so that out-of-range requests are rejected with an
+// actionable error before they reach the upstream API.
+func validateDateRange(start, end string, now time.Time) error {
+	if start == "" && end == "" {
+		return nil
+	}
+	if start == "" {
+		return fmt.Errorf("start must be provided when end is set")
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	earliest := today.AddDate(0, 0, -cfg.MaxHistoryDays)
+	latest := today.AddDate(0, 0, cfg.MaxForecastDays)
+
+	startDate, err := time.Parse(dateLayout, start)
+	if err != nil {
+		return fmt.Errorf("invalid start date %q, expected YYYY-MM-DD", start)
+	}
+	endDate := startDate
+	if end != "" {
+		endDate, err = time.Parse(dateLayout, end)
+		if err != nil {
+			return fmt.Errorf("invalid end date %q, expected YYYY-MM-DD", end)
+		}
+		if endDate.Before(startDate) {
+			return fmt.Errorf("end date %s is before start date %s", end, start)
+		}
+	}
+
+	if startDate.Before(earliest) || endDate.After(latest) {
+		return fmt.Errorf("requested date range %s to %s is outside the allowed range %s to %s (%d days of history, %d days of forecast)",
+			startDate.Format(dateLayout), endDate.Format(dateLayout),
+			earliest.Format(dateLayout), latest.Format(dateLayout),
+			cfg.MaxHistoryDays, cfg.MaxForecastDays)
+	}
+	return nil
+}
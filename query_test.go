@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestUnitGroupCacheKeys(t *testing.T) {
+	keys := make(map[string]string)
+	for _, unitGroup := range []string{"metric", "us", "uk", "base"} {
+		key := weatherQuery{Location: "London", UnitGroup: unitGroup}.cacheKey()
+		if other, ok := keys[key]; ok {
+			t.Errorf("unit groups %s and %s share the cache key %s", other, unitGroup, key)
+		}
+		keys[key] = unitGroup
+	}
+	if metric, def := (weatherQuery{Location: "London", UnitGroup: "metric"}).cacheKey(), (weatherQuery{Location: "London"}).cacheKey(); metric != def {
+		t.Errorf("units=metric key %s differs from the default %s", metric, def)
+	}
+}
+
+func TestFetchWeatherDataUnitGroup(t *testing.T) {
+	var got string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("unitGroup")
+		respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+	})
+	setupTest(t, testConfig(t), upstream)
+
+	for _, tc := range []struct{ unitGroup, want string }{
+		{"", "metric"},
+		{"metric", "metric"},
+		{"us", "us"},
+		{"uk", "uk"},
+		{"base", "base"},
+	} {
+		if _, _, err := fetchWeatherData(context.Background(), weatherQuery{Location: "London", UnitGroup: tc.unitGroup}); err != nil {
+			t.Fatalf("units %q: %v", tc.unitGroup, err)
+		}
+		if got != tc.want {
+			t.Errorf("units %q: upstream asked for unitGroup=%s, want %s", tc.unitGroup, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryLimitMiddleware rejects requests whose query string carries more than
+// maxParams parameters or more than maxLength bytes with 400, before any handler,
+// cache or upstream work runs. Repeated names count once per occurrence, so
+// ?a=1&a=2 is two parameters. A non-positive limit disables that check.
+func queryLimitMiddleware(maxParams, maxLength int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.Request.URL.RawQuery
+		if maxLength > 0 && len(raw) > maxLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "query string is too long",
+				"code":  "QUERY_TOO_LONG",
+			})
+			return
+		}
+		if maxParams > 0 && countQueryParams(raw) > maxParams {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "too many query parameters",
+				"code":  "TOO_MANY_PARAMS",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// countQueryParams counts the non-empty &-separated pairs of a raw query string
+// without decoding them.
+func countQueryParams(raw string) int {
+	n := 0
+	for _, pair := range strings.Split(raw, "&") {
+		if pair != "" {
+			n++
+		}
+	}
+	return n
+}
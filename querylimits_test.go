@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCountQueryParams(t *testing.T) {
+	for raw, want := range map[string]int{
+		"":                      0,
+		"location=London":       1,
+		"a=1&a=2&b":             3,
+		"&&a=1&&":               1,
+		"a=1%26b%3D2&c=3":       2,
+		strings.Repeat("x&", 5): 5,
+	} {
+		if got := countQueryParams(raw); got != want {
+			t.Errorf("countQueryParams(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
+
+// TestQueryLimitsRejectEarly checks excessive queries are rejected before
+// reaching the cache or the upstream.
+func TestQueryLimitsRejectEarly(t *testing.T) {
+	var calls atomic.Int64
+	c := testConfig(t)
+	c.MaxQueryParams, c.MaxQueryLength = 5, 100
+	mr := setupTest(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+	}))
+
+	for _, tc := range []struct{ query, code string }{
+		{"location=London" + strings.Repeat("&a=1", 5), "TOO_MANY_PARAMS"},
+		{"location=" + strings.Repeat("x", 100), "QUERY_TOO_LONG"},
+	} {
+		w := requestWeather(tc.query)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != tc.code {
+			t.Errorf("%.30s: got %d %s, want 400 %s", tc.query, w.Code, w.Body, tc.code)
+		}
+	}
+	if calls.Load() != 0 || len(mr.Keys()) != 0 {
+		t.Errorf("rejected queries made %d upstream calls and cached %v", calls.Load(), mr.Keys())
+	}
+
+	if w := requestWeather("location=London" + strings.Repeat("&a=1", 4)); w.Code != http.StatusOK {
+		t.Errorf("query at the limit: got %d %s", w.Code, w.Body)
+	}
+}
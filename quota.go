@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// quotaResetAt holds the Unix time at which the exhausted upstream quota resets, or
+// zero while the quota is available. Visual Crossing quotas reset at midnight UTC.
+var quotaResetAt atomic.Int64
+
+// isQuotaExceeded reports whether an upstream response signals that the daily
+// record quota for the API key is used up.
+func isQuotaExceeded(status int, body string) bool {
+	if status != http.StatusTooManyRequests {
+		return false
+	}
+	lower := strings.ToLower(body)
+	return strings.Contains(lower, "exceeded") && (strings.Contains(lower, "daily") || strings.Contains(lower, "maximum number"))
+}
+
+// nextMidnightUTC returns the start of the next UTC day after now.
+func nextMidnightUTC(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// markQuotaExceeded records that the quota is exhausted until the next reset.
+func markQuotaExceeded(now time.Time) {
+	quotaResetAt.Store(nextMidnightUTC(now).Unix())
+}
+
+// quotaExhaustedUntil returns the reset time if the quota is currently known to be
+// exhausted, clearing the state once the reset time has passed.
+func quotaExhaustedUntil(now time.Time) (time.Time, bool) {
+	reset := quotaResetAt.Load()
+	if reset == 0 {
+		return time.Time{}, false
+	}
+	resetAt := time.Unix(reset, 0)
+	if !now.Before(resetAt) {
+		quotaResetAt.CompareAndSwap(reset, 0)
+		return time.Time{}, false
+	}
+	return resetAt, true
+}
+
+// quotaError builds the 503 returned while the upstream quota is exhausted.
+func quotaError(now, resetAt time.Time) *apiError {
+	return &apiError{
+		Status:     http.StatusServiceUnavailable,
+		Code:       "UPSTREAM_QUOTA_EXCEEDED",
+		Message:    "upstream weather API quota exceeded, resets at " + resetAt.UTC().Format(time.RFC3339),
+		RetryAfter: resetAt.Sub(now),
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitEnvPrefix prefixes the per-route rate limit variables, e.g.
+// RATE_LIMIT_WEATHER_SUMMARY for /weather/summary.
+const rateLimitEnvPrefix = "RATE_LIMIT_"
+
+// parseRouteRateLimits collects the RATE_LIMIT_<ROUTE> variables from environ,
+// keyed by route name. Invalid values are logged and skipped so the route keeps
+// the global limit.
+func parseRouteRateLimits(environ []string) map[string]float64 {
+	limits := make(map[string]float64)
+	for _, kv := range environ {
+		name, raw, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, rateLimitEnvPrefix) || name == "RATE_LIMIT_BACKEND" {
+			continue
+		}
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate <= 0 {
+			log.Printf("Invalid %s, using RATE_LIMIT", name)
+			continue
+		}
+		limits[strings.TrimPrefix(name, rateLimitEnvPrefix)] = rate
+	}
+	return limits
+}
+
+// rateLimitName derives the route name used in RATE_LIMIT_<ROUTE> from a path:
+// "/weather/summary" becomes "WEATHER_SUMMARY".
+func rateLimitName(path string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.Trim(path, "/"), "/", "_"))
+}
+
+// routeLimiter returns rate limiting middleware for path: RATE_LIMIT_<ROUTE>
+// requests per second per client IP when configured, RATE_LIMIT otherwise. Each
+// route gets its own limiter so expensive endpoints can be throttled harder.
+func routeLimiter(c Config, path string) gin.HandlerFunc {
+	rate := c.RateLimit
+	if r, ok := c.RouteRateLimits[rateLimitName(path)]; ok {
+		rate = r
+	}
+	var options *limiter.ExpirableOptions
+	if c.AdaptiveRateLimit {
+		// Buckets keep the rate they were created with, so they are let expire
+		// to pick up adjustments.
+		options = &limiter.ExpirableOptions{DefaultExpirationTTL: c.AdaptiveRateInterval}
+	}
+	lmt := tollbooth.NewLimiter(rate, options)
+	// Key on the client IP resolved by clientIPMiddleware rather than the peer,
+	// which behind a proxy would put every client in one bucket.
+	lmt.SetIPLookups([]string{"X-Real-IP", "RemoteAddr"})
+	if c.AdaptiveRateLimit {
+		adaptiveRate.register(lmt, rate)
+	}
+	if c.RateLimitBackend == rateLimitRedis {
+		return redisLimitWithHeaders(rateLimitName(path), lmt)
+	}
+	return limitWithHeaders(lmt)
+}
+
+// limitWithHeaders enforces lmt like tollbooth_gin.LimitHandler and reports the
+// client's bucket in X-RateLimit-Limit (burst size), X-RateLimit-Remaining
+// (tokens left after this request) and X-RateLimit-Reset (Unix time at which the
+// next token is available). The headers are set on 429s too.
+func limitWithHeaders(lmt *limiter.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tollbooth.ShouldSkipLimiter(lmt, c.Request) {
+			c.Next()
+			return
+		}
+		if limitByKeys(c, lmt, tollbooth.BuildKeys(lmt, c.Request)) {
+			c.Next()
+		}
+	}
+}
+
+// limitByKeys takes a token for every key set from lmt, sets the
+// X-RateLimit-* headers of the emptiest bucket and reports whether the request
+// may go on; otherwise it has answered with lmt's 429.
+func limitByKeys(c *gin.Context, lmt *limiter.Limiter, keySets [][]string) bool {
+	// The rate may be adjusted at runtime, see adaptiveRateLimiter.
+	refill := time.Duration(math.Ceil(float64(time.Second) / lmt.GetMax()))
+
+	remaining := math.MaxInt32
+	limited := false
+	for _, keys := range keySets {
+		httpError, tokens := tollbooth.LimitByKeysAndReturn(lmt, keys)
+		if tokens < remaining {
+			remaining = tokens
+		}
+		if httpError != nil {
+			limited = true
+			break
+		}
+	}
+	if remaining == math.MaxInt32 {
+		remaining = lmt.GetBurst()
+	}
+
+	reset := clock.Now()
+	if remaining < lmt.GetBurst() {
+		reset = reset.Add(refill)
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(lmt.GetBurst()))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(reset.UnixNano())/1e9)), 10))
+
+	if limited {
+		c.Data(lmt.GetStatusCode(), lmt.GetMessageContentType(), []byte(lmt.GetMessage()))
+		c.Abort()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis breaker states, as reported by /health.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// errRedisCircuitOpen is returned by cache operations short-circuited by
+// redisBreaker.
+var errRedisCircuitOpen = errors.New("redis circuit breaker is open")
+
+// redisCircuit is a circuit breaker around the cache's Redis calls. After
+// REDIS_BREAKER_THRESHOLD consecutive failures it opens: cache reads and writes
+// fail fast with errRedisCircuitOpen instead of each waiting for Redis to time
+// out, and lookups go straight to the upstream. Once REDIS_BREAKER_COOLDOWN has
+// passed it is half-open and lets one call through as a probe; success closes
+// it, failure reopens it for another cooldown. A threshold of zero disables it.
+type redisCircuit struct {
+	mu          sync.Mutex
+	consecutive int
+	openUntil   time.Time
+	probing     bool
+}
+
+var redisBreaker redisCircuit
+
+// allow reports whether a Redis call may be made now. In the half-open state
+// only the one probe call is allowed.
+func (b *redisCircuit) allow() bool {
+	if cfg.RedisBreakerThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.consecutive < cfg.RedisBreakerThreshold {
+		return true
+	}
+	if clock.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record notes the outcome of an allowed Redis call. A cache miss is a success.
+// Calls the client gave up on say nothing about Redis: they end a probe but
+// leave the failure count alone. A deadline running out while Redis answers is
+// a failure, as that is how a hung Redis shows.
+func (b *redisCircuit) record(err error) {
+	if cfg.RedisBreakerThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasProbe := b.probing
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil || err == redis.Nil {
+		if b.consecutive >= cfg.RedisBreakerThreshold {
+			log.Printf("Redis circuit breaker closed after %d consecutive failures", b.consecutive)
+		}
+		b.consecutive = 0
+		return
+	}
+
+	b.consecutive++
+	if b.consecutive >= cfg.RedisBreakerThreshold && (wasProbe || b.consecutive == cfg.RedisBreakerThreshold) {
+		b.openUntil = clock.Now().Add(cfg.RedisBreakerCooldown)
+		log.Printf("WARNING: Redis circuit breaker open for %s after %d consecutive failures, last: %v",
+			cfg.RedisBreakerCooldown, b.consecutive, err)
+	}
+}
+
+// state returns the breaker state and the consecutive failure count.
+func (b *redisCircuit) state() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case cfg.RedisBreakerThreshold <= 0 || b.consecutive < cfg.RedisBreakerThreshold:
+		return breakerClosed, b.consecutive
+	case clock.Now().Before(b.openUntil):
+		return breakerOpen, b.consecutive
+	}
+	return breakerHalfOpen, b.consecutive
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/redis/go-redis/v9"
+)
+
+// resetRedisBreaker closes redisBreaker for a test and again when it ends.
+func resetRedisBreaker(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		redisBreaker.mu.Lock()
+		defer redisBreaker.mu.Unlock()
+		redisBreaker.consecutive, redisBreaker.openUntil, redisBreaker.probing = 0, time.Time{}, false
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// stallGets makes mr hold every GET until the test ends.
+func stallGets(t *testing.T, mr *miniredis.Miniredis) {
+	release := make(chan struct{})
+	mr.Server().SetPreHook(func(_ *server.Peer, cmd string, _ ...string) bool {
+		if cmd == "GET" {
+			<-release
+		}
+		return false
+	})
+	t.Cleanup(func() { close(release) })
+}
+
+func TestRedisBreaker(t *testing.T) {
+	failure := errors.New("connection refused")
+	type step struct {
+		name         string
+		at           time.Duration // since the test started
+		allow        bool          // call allow instead of record
+		err          error         // the outcome recorded
+		wantAllowed  bool
+		wantState    string
+		wantFailures int
+	}
+	steps := []step{
+		{name: "first failure", err: failure, wantState: breakerClosed, wantFailures: 1},
+		{name: "closed allows", allow: true, wantAllowed: true, wantState: breakerClosed, wantFailures: 1},
+		{name: "miss is a success", err: redis.Nil, wantState: breakerClosed},
+		{name: "failure", err: failure, wantState: breakerClosed, wantFailures: 1},
+		{name: "threshold opens", err: failure, wantState: breakerOpen, wantFailures: 2},
+		{name: "open refuses", at: 30 * time.Second, allow: true, wantState: breakerOpen, wantFailures: 2},
+		{name: "half-open probe", at: 61 * time.Second, allow: true, wantAllowed: true, wantState: breakerHalfOpen, wantFailures: 2},
+		{name: "one probe at a time", at: 61 * time.Second, allow: true, wantState: breakerHalfOpen, wantFailures: 2},
+		{name: "failed probe reopens", at: 61 * time.Second, err: failure, wantState: breakerOpen, wantFailures: 3},
+		{name: "reopened refuses", at: 2 * time.Minute, allow: true, wantState: breakerOpen, wantFailures: 3},
+		{name: "second probe", at: 2*time.Minute + 2*time.Second, allow: true, wantAllowed: true, wantState: breakerHalfOpen, wantFailures: 3},
+		{name: "successful probe closes", at: 2*time.Minute + 2*time.Second, wantState: breakerClosed},
+		{name: "closed again", at: 2*time.Minute + 2*time.Second, allow: true, wantAllowed: true, wantState: breakerClosed},
+	}
+
+	c := testConfig(t)
+	c.RedisBreakerThreshold = 2
+	c.RedisBreakerCooldown = time.Minute
+	setupTest(t, c, nil)
+	resetRedisBreaker(t)
+	oldClock := clock
+	t.Cleanup(func() { clock = oldClock })
+	start := time.Now()
+
+	for _, s := range steps {
+		clock = fixedClock{start.Add(s.at)}
+		if s.allow {
+			if got := redisBreaker.allow(); got != s.wantAllowed {
+				t.Fatalf("%s: allow() = %v, want %v", s.name, got, s.wantAllowed)
+			}
+		} else {
+			redisBreaker.record(s.err)
+		}
+		if state, failures := redisBreaker.state(); state != s.wantState || failures != s.wantFailures {
+			t.Fatalf("%s: breaker %s with %d failures, want %s with %d", s.name, state, failures, s.wantState, s.wantFailures)
+		}
+	}
+}
+
+func TestRedisBreakerDisabled(t *testing.T) {
+	c := testConfig(t)
+	c.RedisBreakerThreshold = 0
+	setupTest(t, c, nil)
+	resetRedisBreaker(t)
+
+	for i := 0; i < 5; i++ {
+		redisBreaker.record(errors.New("connection refused"))
+	}
+	if !redisBreaker.allow() {
+		t.Error("disabled breaker refused a call")
+	}
+	if state, failures := redisBreaker.state(); state != breakerClosed || failures != 0 {
+		t.Errorf("disabled breaker is %s with %d failures, want closed with 0", state, failures)
+	}
+}
+
+func TestRedisBreakerOpensOnStalledRedis(t *testing.T) {
+	c := testConfig(t)
+	c.RedisBreakerThreshold = 2
+	c.RedisBreakerCooldown = time.Minute
+	mr := setupTest(t, c, nil)
+	resetRedisBreaker(t)
+	oldClock := clock
+	t.Cleanup(func() { clock = oldClock })
+	stallGets(t, mr)
+
+	get := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := cacheGet(ctx, londonKey)
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := get(); err == nil {
+			t.Fatalf("read %d from the stalled Redis succeeded", i)
+		}
+	}
+	if state, failures := redisBreaker.state(); state != breakerOpen || failures != 2 {
+		t.Fatalf("after two timed-out reads the breaker is %s with %d failures, want open with 2", state, failures)
+	}
+	if err := get(); err != errRedisCircuitOpen {
+		t.Errorf("read with the breaker open: %v, want errRedisCircuitOpen", err)
+	}
+
+	// The half-open probe times out as well, which reopens the breaker.
+	clock = fixedClock{time.Now().Add(2 * time.Minute)}
+	if err := get(); err == nil || err == errRedisCircuitOpen {
+		t.Fatalf("probe read: %v, want it to reach the stalled Redis and time out", err)
+	}
+	if state, _ := redisBreaker.state(); state != breakerOpen {
+		t.Errorf("after a timed-out probe the breaker is %s, want open", state)
+	}
+}
+
+func TestRedisBreakerContextErrors(t *testing.T) {
+	c := testConfig(t)
+	c.RedisBreakerThreshold = 2
+	c.RedisBreakerCooldown = time.Minute
+	setupTest(t, c, nil)
+	resetRedisBreaker(t)
+	oldClock := clock
+	t.Cleanup(func() { clock = oldClock })
+
+	redisBreaker.record(errors.New("connection refused"))
+	redisBreaker.record(context.Canceled)
+	if _, failures := redisBreaker.state(); failures != 1 {
+		t.Errorf("a canceled call changed the failure count to %d, want 1", failures)
+	}
+	redisBreaker.record(context.DeadlineExceeded)
+	if state, _ := redisBreaker.state(); state != breakerOpen {
+		t.Fatalf("after a failure and a deadline the breaker is %s, want open", state)
+	}
+
+	// A canceled probe leaves the breaker half-open for the next one.
+	clock = fixedClock{time.Now().Add(2 * time.Minute)}
+	if !redisBreaker.allow() {
+		t.Fatal("half-open breaker refused the probe")
+	}
+	redisBreaker.record(fmt.Errorf("get: %w", context.Canceled))
+	if state, _ := redisBreaker.state(); state != breakerHalfOpen {
+		t.Errorf("after a canceled probe the breaker is %s, want half-open", state)
+	}
+	if !redisBreaker.allow() {
+		t.Error("the canceled probe wasn't ended")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate limiter backends, selected by RATE_LIMIT_BACKEND.
+const (
+	rateLimitMemory = "memory" // per-instance tollbooth buckets
+	rateLimitRedis  = "redis"  // buckets shared through Redis
+)
+
+// parseRateLimitBackend validates RATE_LIMIT_BACKEND, defaulting to in-memory
+// limiting.
+func parseRateLimitBackend(raw string) string {
+	switch raw {
+	case "":
+		return rateLimitMemory
+	case rateLimitMemory, rateLimitRedis:
+		return raw
+	}
+	log.Printf("Invalid RATE_LIMIT_BACKEND %q, defaulting to %s", raw, rateLimitMemory)
+	return rateLimitMemory
+}
+
+// rateLimitKeyPrefix prefixes the Redis keys holding rate limit buckets.
+const rateLimitKeyPrefix = "ratelimit:"
+
+// tokenBucketScript atomically takes a token from the bucket at KEYS[1], which
+// holds up to ARGV[2] tokens refilled at ARGV[1] per second, at time ARGV[3] in
+// milliseconds. A missing bucket is full. It returns whether a token was taken
+// and how many whole tokens are left. Idle buckets expire once they would have
+// refilled.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(math.max(now, ts)))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, math.floor(tokens)}
+`)
+
+// redisLimitWithHeaders enforces the route limit of lmt with a token bucket per
+// client IP kept in Redis, so every instance shares it and it survives restarts.
+// The bucket size, refill rate, headers and 429 response are those of
+// limitWithHeaders. When Redis fails, the request is limited by lmt in memory
+// instead, so an outage neither blocks nor unthrottles clients.
+func redisLimitWithHeaders(route string, lmt *limiter.Limiter) gin.HandlerFunc {
+	fallback := limitWithHeaders(lmt)
+	return func(c *gin.Context) {
+		if tollbooth.ShouldSkipLimiter(lmt, c.Request) {
+			c.Next()
+			return
+		}
+		refill := time.Duration(math.Ceil(float64(time.Second) / lmt.GetMax()))
+
+		now := clock.Now()
+		key := rateLimitKeyPrefix + route + ":" + c.ClientIP()
+		res, err := tokenBucketScript.Run(ctx, redisClient, []string{key}, lmt.GetMax(), lmt.GetBurst(), now.UnixMilli()).Int64Slice()
+		if err != nil || len(res) != 2 {
+			log.Printf("Error checking rate limit in Redis, limiting in memory: %v", err)
+			fallback(c)
+			return
+		}
+		allowed, remaining := res[0] == 1, int(res[1])
+
+		reset := now
+		if remaining < lmt.GetBurst() {
+			reset = reset.Add(refill)
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(lmt.GetBurst()))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(reset.UnixNano())/1e9)), 10))
+
+		if !allowed {
+			c.Data(lmt.GetStatusCode(), lmt.GetMessageContentType(), []byte(lmt.GetMessage()))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// refreshAheadTracked bounds the keys refreshAhead counts hits for; past it
+// the counts start over.
+const refreshAheadTracked = 10000
+
+// Refresh-ahead counters, reported by /stats.
+var (
+	refreshAheadStarted atomic.Int64 // background refreshes of entries near expiry
+	refreshAheadCold    atomic.Int64 // hits near expiry on entries not hit often enough to refresh
+	refreshAheadSkipped atomic.Int64 // refreshes left out because the upstream was busy or out of quota
+	refreshAheadFailed  atomic.Int64
+)
+
+// inRefreshWindow reports whether an entry fetched at fetchedAt with ttl left
+// at now is within the last ratio of its lifetime.
+func inRefreshWindow(fetchedAt time.Time, ttl time.Duration, now time.Time, ratio float64) bool {
+	if ratio <= 0 || fetchedAt.IsZero() || ttl <= 0 {
+		return false
+	}
+	lifetime := now.Sub(fetchedAt) + ttl
+	return lifetime > 0 && float64(ttl) <= ratio*float64(lifetime)
+}
+
+// refreshAheadTracker counts the hits of entries in their refresh window and
+// the refreshes in flight.
+type refreshAheadTracker struct {
+	mu       sync.Mutex
+	hits     map[string]int
+	inFlight map[string]bool
+}
+
+var refreshAhead = &refreshAheadTracker{hits: make(map[string]int), inFlight: make(map[string]bool)}
+
+// claim counts a hit of key in its refresh window and reports whether it
+// should start the refresh: the key has had minHits such hits and isn't
+// already being refreshed. A claimed key must be released.
+func (t *refreshAheadTracker) claim(key string, minHits int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inFlight[key] {
+		return false
+	}
+	if len(t.hits) >= refreshAheadTracked {
+		t.hits = make(map[string]int)
+	}
+	t.hits[key]++
+	if t.hits[key] < minHits {
+		refreshAheadCold.Add(1)
+		return false
+	}
+	delete(t.hits, key)
+	t.inFlight[key] = true
+	return true
+}
+
+// release ends the refresh of key.
+func (t *refreshAheadTracker) release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.inFlight, key)
+}
+
+// refreshAheadOf refreshes the cache entry of q in the background when a hit
+// finds it in the last REFRESH_AHEAD_RATIO of its lifetime, so hot entries are
+// replaced before they expire and their requests never miss. The hit itself is
+// served from the cache without waiting. Only entries hit REFRESH_AHEAD_MIN_HITS
+// times within the window are refreshed, so rarely requested entries expire as
+// usual. One refresh runs per key at a time; it goes through the upstream queue
+// and shares the fetch with concurrent misses, and is skipped while fetches
+// are queued or the upstream quota is exhausted. Past data never changes and
+// isn't refreshed.
+func refreshAheadOf(q weatherQuery, cacheKey string, fetchedAt time.Time, ttl time.Duration) {
+	if q.Immutable || !inRefreshWindow(fetchedAt, ttl, clock.Now(), cfg.RefreshAheadRatio) {
+		return
+	}
+	if !refreshAhead.claim(cacheKey, cfg.RefreshAheadMinHits) {
+		return
+	}
+	go func() {
+		defer refreshAhead.release(cacheKey)
+		if _, exhausted := quotaExhaustedUntil(clock.Now()); exhausted || upstreamQueue.busy() {
+			refreshAheadSkipped.Add(1)
+			return
+		}
+		refreshAheadStarted.Add(1)
+		rctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+		defer cancel()
+		if _, _, err := coalescedFetchAndCache(rctx, q, cacheKey); err != nil {
+			refreshAheadFailed.Add(1)
+			log.Printf("Refresh-ahead failed for %s: %v", q.Location, err)
+		}
+	}()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestInRefreshWindow(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		fetchedAt time.Time
+		ttl       time.Duration
+		ratio     float64
+		want      bool
+	}{
+		{"early in its lifetime", now.Add(-2 * time.Minute), 8 * time.Minute, 0.2, false},
+		{"just outside the window", now.Add(-7 * time.Minute), 3 * time.Minute, 0.2, false},
+		{"at the window's start", now.Add(-8 * time.Minute), 2 * time.Minute, 0.2, true},
+		{"near expiry", now.Add(-9 * time.Minute), time.Minute, 0.2, true},
+		{"whole lifetime", now.Add(-time.Minute), 9 * time.Minute, 1, true},
+		{"disabled", now.Add(-9 * time.Minute), time.Minute, 0, false},
+		{"unknown fetch time", time.Time{}, time.Minute, 0.2, false},
+		{"expired", now.Add(-10 * time.Minute), 0, 0.2, false},
+		{"no expiry", now.Add(-10 * time.Minute), -1, 0.2, false},
+		{"fetched in the future", now.Add(5 * time.Minute), 2 * time.Minute, 0.2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inRefreshWindow(tt.fetchedAt, tt.ttl, now, tt.ratio); got != tt.want {
+				t.Errorf("inRefreshWindow(%s ago, %s, %v) = %v, want %v", now.Sub(tt.fetchedAt), tt.ttl, tt.ratio, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshAheadTrackerClaim(t *testing.T) {
+	type claim struct {
+		key     string
+		release bool // release key instead of claiming it
+		want    bool
+	}
+	tests := []struct {
+		name    string
+		minHits int
+		claims  []claim
+	}{
+		{"first hit with one required", 1, []claim{{key: "a", want: true}}},
+		{"no minimum", 0, []claim{{key: "a", want: true}}},
+		{"cold until min hits", 3, []claim{{key: "a"}, {key: "a"}, {key: "a", want: true}}},
+		{"hits counted per key", 2, []claim{{key: "a"}, {key: "b"}, {key: "a", want: true}, {key: "b", want: true}}},
+		{"one refresh in flight", 1, []claim{{key: "a", want: true}, {key: "a"}, {key: "a"}}},
+		{"released key counts again from zero", 2, []claim{
+			{key: "a"}, {key: "a", want: true}, {key: "a"}, {key: "a", release: true}, {key: "a"}, {key: "a", want: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &refreshAheadTracker{hits: make(map[string]int), inFlight: make(map[string]bool)}
+			for i, c := range tt.claims {
+				if c.release {
+					tracker.release(c.key)
+					continue
+				}
+				if got := tracker.claim(c.key, tt.minHits); got != c.want {
+					t.Fatalf("claim %d of %q = %v, want %v", i+1, c.key, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+// TestRefreshAheadTrackerReset checks that the hit counts start over once
+// refreshAheadTracked keys are counted.
+func TestRefreshAheadTrackerReset(t *testing.T) {
+	tracker := &refreshAheadTracker{hits: make(map[string]int), inFlight: make(map[string]bool)}
+	if tracker.claim("hot", 2) {
+		t.Fatal("first hit of hot was claimed")
+	}
+	for i := 1; i < refreshAheadTracked; i++ {
+		tracker.claim(fmt.Sprintf("key%d", i), 2)
+	}
+	if len(tracker.hits) != refreshAheadTracked {
+		t.Fatalf("tracking %d keys, want %d", len(tracker.hits), refreshAheadTracked)
+	}
+	if tracker.claim("hot", 2) {
+		t.Error("second hit of hot was claimed though the counts had started over")
+	}
+	if len(tracker.hits) != 1 {
+		t.Errorf("tracking %d keys after the reset, want 1", len(tracker.hits))
+	}
+	if !tracker.claim("hot", 2) {
+		t.Error("second hit of hot after the reset wasn't claimed")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultUpstreamRegions maps UPSTREAM_REGION values to Visual Crossing timeline
+// base URLs. Deployments can add regional endpoints via UPSTREAM_REGIONS.
+var defaultUpstreamRegions = map[string]string{
+	"global": "https://weather.visualcrossing.com/VisualCrossingWebServices/rest/services/timeline",
+}
+
+// upstreamRegions merges the built-in regions with extra ones given as a JSON
+// object of region name to base URL.
+func upstreamRegions(extra string) (map[string]string, error) {
+	regions := make(map[string]string, len(defaultUpstreamRegions))
+	for name, url := range defaultUpstreamRegions {
+		regions[name] = url
+	}
+	if strings.TrimSpace(extra) == "" {
+		return regions, nil
+	}
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(extra), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid UPSTREAM_REGIONS: %v", err)
+	}
+	for name, url := range parsed {
+		regions[strings.ToLower(name)] = url
+	}
+	return regions, nil
+}
+
+// resolveUpstreamURL picks the upstream base URL. An explicit URL always wins;
+// otherwise the region must be one of the known regions.
+func resolveUpstreamURL(explicitURL, region string, regions map[string]string) (string, error) {
+	if explicitURL != "" {
+		return explicitURL, nil
+	}
+	if region == "" {
+		return "", fmt.Errorf("VISUAL_CROSSING_API_URL or UPSTREAM_REGION must be set")
+	}
+	url, ok := regions[strings.ToLower(region)]
+	if !ok {
+		known := make([]string, 0, len(regions))
+		for name := range regions {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return "", fmt.Errorf("unknown UPSTREAM_REGION %q, expected one of %s", region, strings.Join(known, ", "))
+	}
+	return url, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contentSHA256Header carries the hex SHA-256 of the response body as the
+// handler wrote it. With Content-Encoding: gzip that is the decoded body, not
+// the bytes on the wire.
+const contentSHA256Header = "X-Content-SHA256"
+
+// writeJSON serialises v and writes it with an ETag derived from the body,
+// answering 304 Not Modified when the client already holds that version. HEAD
+// requests receive identical headers without a body.
+func writeJSON(c *gin.Context, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+		return
+	}
+	writeJSONBody(c, status, body)
+}
+
+// writeJSONBody writes an already encoded JSON body the same way writeJSON does,
+// so pre-serialised cache hits get identical ETags and conditional handling.
+func writeJSONBody(c *gin.Context, status int, body []byte) {
+	writeBody(c, status, "application/json; charset=utf-8", body)
+}
+
+// writeBody writes an encoded body of the given content type with an ETag
+// derived from it, handling If-None-Match, If-Modified-Since and HEAD requests.
+// If-None-Match may hold the ETag of the gzipped body too, see gzipETag.
+// The full SHA-256 of the body is sent as X-Content-SHA256 so clients can
+// verify stored copies; it covers the body before any content coding.
+func writeBody(c *gin.Context, status int, contentType string, body []byte) {
+	etag, sum := bodyDigest(body)
+	c.Header("ETag", etag)
+	c.Header(contentSHA256Header, sum)
+
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		if identityETag(match) == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if status == http.StatusOK && notModifiedSince(c) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(body)))
+	if c.Request.Method == http.MethodHead {
+		c.Header("Content-Type", contentType)
+		c.Status(status)
+		return
+	}
+	c.Data(status, contentType, body)
+}
+
+// bodyDigest returns the ETag of a response body, quoted, and its full hex SHA-256.
+func bodyDigest(body []byte) (etag, sum string) {
+	digest := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(digest[:16]) + `"`, hex.EncodeToString(digest[:])
+}
+
+// notModifiedSince reports whether the response's Last-Modified time is no later
+// than the request's If-Modified-Since, i.e. the client's copy is current. It is
+// false when either header is missing or unparseable. As with If-None-Match, the
+// comparison is at the one-second precision of HTTP dates.
+func notModifiedSince(c *gin.Context) bool {
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(c.Writer.Header().Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// setCacheControl advertises how long intermediaries may cache the response: the
+// remaining Redis TTL for cache hits, nothing for stale hits being revalidated,
+// and a shorter fixed max-age for fresh fetches.
+// Last-Modified carries when the data was fetched from the upstream, for
+// If-Modified-Since revalidation.
+func setCacheControl(c *gin.Context, result weatherResult) {
+	if !result.FetchedAt.IsZero() {
+		c.Header("Last-Modified", result.FetchedAt.UTC().Format(http.TimeFormat))
+	}
+	maxAge := cfg.CacheControlFreshMaxAge
+	if result.Cache == "HIT" && result.TTL > 0 {
+		maxAge = result.TTL
+	}
+	if result.Cache == "STALE" {
+		maxAge = 0
+	}
+	c.Header("Cache-Control", cfg.CacheControlDirective+", max-age="+strconv.Itoa(int(maxAge/time.Second)))
+}
+
+// withMeta returns a shallow copy of data with a "meta" object recording when the
+// data was fetched from the upstream, the data source attribution, the airport
+// for IATA code lookups and, with RESPONSE_META_SERVED_AT, when this response
+// was produced.
+func withMeta(data map[string]interface{}, result weatherResult, q weatherQuery) map[string]interface{} {
+	meta := gin.H{}
+	if q.Airport != nil {
+		meta["airport"] = q.Airport
+	}
+	if !result.FetchedAt.IsZero() {
+		meta["fetchedAt"] = result.FetchedAt.UTC().Format(time.RFC3339)
+	}
+	if cfg.ResponseMetaServedAt {
+		meta["servedAt"] = clock.Now().UTC().Format(time.RFC3339)
+	}
+	if cfg.AttributionText != "" {
+		meta["attribution"] = cfg.AttributionText
+	}
+	out := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		out[k] = v
+	}
+	out["meta"] = meta
+	return out
+}
+
+// dataSourceHeader carries the attribution required by the data provider.
+const dataSourceHeader = "X-Data-Source"
+
+// attributionMiddleware sets X-Data-Source on weather responses. All data comes
+// from the one configured upstream, so the attribution is the same for every
+// response.
+func attributionMiddleware(text string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if text != "" {
+			c.Header(dataSourceHeader, text)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retryBudget is a token bucket shared by every request, capping the rate of
+// upstream retries so a struggling upstream isn't buried under them: each retry
+// takes a token, tokens refill at rate per second up to burst, and a fetch that
+// finds the bucket empty fails with its last error instead of retrying.
+type retryBudget struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	retries   atomic.Int64 // retries made, since startup
+	exhausted atomic.Int64 // retries refused for an empty bucket, since startup
+}
+
+// newRetryBudget returns a full budget.
+func newRetryBudget(rate float64, burst int) *retryBudget {
+	return &retryBudget{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// refill adds the tokens accrued since the last call. b.mu must be held.
+func (b *retryBudget) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// take spends a token for one retry, reporting false when none is left.
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		b.exhausted.Add(1)
+		return false
+	}
+	b.tokens--
+	b.retries.Add(1)
+	return true
+}
+
+// stats reports the budget's state for /stats.
+func (b *retryBudget) stats() gin.H {
+	b.mu.Lock()
+	b.refill(time.Now())
+	tokens := b.tokens
+	b.mu.Unlock()
+	return gin.H{
+		"ratePerSecond": b.rate,
+		"burst":         b.burst,
+		"tokens":        tokens,
+		"retries":       b.retries.Load(),
+		"exhausted":     b.exhausted.Load(),
+	}
+}
+
+// upstreamRetryBudget is the retry budget of fetchWithRetries, set by main.
+var upstreamRetryBudget = newRetryBudget(1, 10)
+
+// maxRetryAfter bounds how long fetchWithRetries waits for an upstream
+// Retry-After; a longer one fails the fetch at once.
+const maxRetryAfter = 30 * time.Second
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as an HTTP
+// date, into the wait it asks for from now.
+func parseRetryAfter(raw string, now time.Time) (time.Duration, bool) {
+	if raw == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	at, err := http.ParseTime(raw)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
+
+// retryableFetch reports whether a failed fetch may succeed when repeated: a
+// network error, or an upstream 5xx, 408 or 429. Rejections, an exhausted
+// quota, bad responses and the caller giving up aren't retried.
+func retryableFetch(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		return true
+	}
+	if ae.Code != "UPSTREAM_UNAVAILABLE" {
+		return false
+	}
+	return ae.UpstreamStatus >= 500 || ae.UpstreamStatus == http.StatusRequestTimeout || ae.UpstreamStatus == http.StatusTooManyRequests
+}
+
+// fetchWithRetries is fetchWeatherData retried up to UPSTREAM_RETRIES times on
+// retryable failures, waiting UPSTREAM_RETRY_BACKOFF_MS before the first retry
+// and twice as long before each further one, or as long as the upstream's
+// Retry-After asks if that is longer. A Retry-After beyond maxRetryAfter or
+// the request's deadline ends the retries. Every retry spends a token of
+// upstreamRetryBudget; when it is empty the last error is returned at once.
+func fetchWithRetries(ctx context.Context, q weatherQuery) (map[string]interface{}, upstreamInfo, error) {
+	backoff := cfg.UpstreamRetryBackoff
+	for attempt := 0; ; attempt++ {
+		data, info, err := fetchWeatherData(ctx, q)
+		if err == nil || attempt >= cfg.UpstreamRetries || !retryableFetch(ctx, err) {
+			return data, info, err
+		}
+		wait := backoff
+		var ae *apiError
+		if errors.As(err, &ae) && ae.RetryAfter > wait {
+			wait = ae.RetryAfter
+			deadline, ok := ctx.Deadline()
+			if wait > maxRetryAfter || (ok && clock.Now().Add(wait).After(deadline)) {
+				log.Printf("Not retrying fetch for %s, the upstream asks to wait %s: %v", q.Location, wait, err)
+				return data, info, err
+			}
+		}
+		if !upstreamRetryBudget.take() {
+			log.Printf("Retry budget exhausted, not retrying fetch for %s: %v", q.Location, err)
+			return data, info, err
+		}
+		log.Printf("Retrying fetch for %s in %s (retry %d of %d): %v", q.Location, wait, attempt+1, cfg.UpstreamRetries, err)
+		select {
+		case <-ctx.Done():
+			return data, info, err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedUpstream answers the nth request with statuses[n], and with the
+// fixture once they run out. It counts the requests in calls.
+func scriptedUpstream(calls *atomic.Int64, statuses []int, header http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		if n >= len(statuses) {
+			respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+			return
+		}
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(statuses[n])
+	})
+}
+
+// setupRetries is setupTest with retries made quick and the retry budget
+// replaced by an ample one.
+func setupRetries(t *testing.T, upstream http.Handler) {
+	t.Helper()
+	c := testConfig(t)
+	c.UpstreamRetries = 3
+	c.UpstreamRetryBackoff = time.Millisecond
+	setupTest(t, c, upstream)
+	old := upstreamRetryBudget
+	upstreamRetryBudget = newRetryBudget(1000, 1000)
+	t.Cleanup(func() { upstreamRetryBudget = old })
+}
+
+func TestFetchWithRetriesRecovers(t *testing.T) {
+	var calls atomic.Int64
+	setupRetries(t, scriptedUpstream(&calls, []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}, nil))
+
+	data, _, err := fetchWithRetries(context.Background(), weatherQuery{Location: "London"})
+	if err != nil {
+		t.Fatalf("fetchWithRetries: %v", err)
+	}
+	if data["resolvedAddress"] != "London" {
+		t.Errorf("unexpected data %v", data)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("upstream called %d times, want 3", n)
+	}
+}
+
+func TestFetchWithRetriesGivesUp(t *testing.T) {
+	var calls atomic.Int64
+	statuses := []int{500, 502, 503, 504, 500}
+	setupRetries(t, scriptedUpstream(&calls, statuses, nil))
+
+	_, _, err := fetchWithRetries(context.Background(), weatherQuery{Location: "London"})
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.UpstreamStatus != 504 {
+		t.Fatalf("err = %v, want the last attempt's 504", err)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("upstream called %d times, want 1 + UPSTREAM_RETRIES = 4", n)
+	}
+}
+
+func TestFetchWithRetriesFailsFast(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound} {
+		var calls atomic.Int64
+		setupRetries(t, scriptedUpstream(&calls, []int{status}, nil))
+		if _, _, err := fetchWithRetries(context.Background(), weatherQuery{Location: "London"}); err == nil {
+			t.Errorf("%d: fetch succeeded", status)
+		}
+		if n := calls.Load(); n != 1 {
+			t.Errorf("%d: upstream called %d times, want 1", status, n)
+		}
+	}
+}
+
+func TestFetchWithRetriesHonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int64
+	setupRetries(t, scriptedUpstream(&calls, []int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"1"}}))
+
+	start := time.Now()
+	if _, _, err := fetchWithRetries(context.Background(), weatherQuery{Location: "London"}); err != nil {
+		t.Fatalf("fetchWithRetries: %v", err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %s, before the upstream's Retry-After of 1s", waited)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times, want 2", n)
+	}
+}
+
+func TestFetchWithRetriesRetryAfterBeyondDeadline(t *testing.T) {
+	var calls atomic.Int64
+	setupRetries(t, scriptedUpstream(&calls, []int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"10"}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, _, err := fetchWithRetries(ctx, weatherQuery{Location: "London"})
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.RetryAfter != 10*time.Second {
+		t.Fatalf("err = %v, want the 429 carrying its Retry-After", err)
+	}
+	if time.Since(start) > time.Second || calls.Load() != 1 {
+		t.Errorf("waited %s and made %d calls, want to give up at once", time.Since(start), calls.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		raw  string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-3", 0, true},
+		{"Wed, 14 Oct 2026 12:00:30 GMT", 30 * time.Second, true},
+		{"Wed, 14 Oct 2026 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	} {
+		got, ok := parseRetryAfter(tc.raw, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tc.raw, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestUpstreamUnwell(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusNotFound, false},
+	} {
+		err := upstreamStatusError(tc.status, nil)
+		if got := upstreamUnwell(ctx, err); got != tc.want {
+			t.Errorf("upstreamUnwell(%d) = %v, want %v", tc.status, got, tc.want)
+		}
+	}
+	if !retryableFetch(ctx, upstreamStatusError(http.StatusTooManyRequests, nil)) {
+		t.Error("429s are no longer retried")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scoreWeights weighs the components of the daily weather score. Only their
+// ratios matter.
+type scoreWeights struct {
+	Temp   float64 `json:"temp"`
+	Precip float64 `json:"precip"`
+	Wind   float64 `json:"wind"`
+	Cloud  float64 `json:"cloud"`
+}
+
+// defaultScoreWeights favour dry, comfortably warm days over calm or sunny ones.
+var defaultScoreWeights = scoreWeights{Temp: 0.35, Precip: 0.35, Wind: 0.15, Cloud: 0.15}
+
+// parseScoreWeights reads SCORE_WEIGHTS, a JSON object overriding some or all of
+// defaultScoreWeights, e.g. {"cloud":0.3}.
+func parseScoreWeights(raw string) (scoreWeights, error) {
+	w := defaultScoreWeights
+	if raw == "" {
+		return w, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &w); err != nil {
+		return scoreWeights{}, err
+	}
+	if w.Temp < 0 || w.Precip < 0 || w.Wind < 0 || w.Cloud < 0 {
+		return scoreWeights{}, fmt.Errorf("weights must not be negative")
+	}
+	if w.Temp+w.Precip+w.Wind+w.Cloud == 0 {
+		return scoreWeights{}, fmt.Errorf("at least one weight must be positive")
+	}
+	return w, nil
+}
+
+// Score component bounds. Each component is 1 at or inside its ideal bound and
+// falls linearly to 0 at its limit.
+const (
+	scoreIdealTempLow  = 18.0 // °C daily high
+	scoreIdealTempHigh = 25.0
+	scoreTempFalloff   = 12.0 // °C beyond the ideal range at which temp scores 0
+	scoreCalmWind      = 10.0 // km/h
+	scoreGaleWind      = 50.0
+)
+
+// scoreDay is the per-day score returned by /weather/score. The components
+// are on the same 0-100 scale; those without data are omitted.
+type scoreDay struct {
+	Date       string             `json:"date"`
+	Score      *int               `json:"score"`
+	Components map[string]float64 `json:"components"`
+}
+
+// weatherScore rates a day from 0 (poor) to 100 (ideal) for being outdoors, as
+// the weighted mean of four components, each between 0 and 1:
+//
+//   - temp: 1 for a daily high of 18-25 °C, falling to 0 at 12 °C outside that;
+//   - precip: 1 minus the precipitation probability;
+//   - wind: 1 up to 10 km/h, falling to 0 at 50 km/h;
+//   - cloud: 1 minus the cloud cover.
+//
+// Components without data are left out and the remaining weights rescaled. It
+// returns nil when no weighted component has data, along with the components
+// (scaled to 0-100) that went into the score.
+func weatherScore(d weatherDay, w scoreWeights) (*int, map[string]float64) {
+	components := map[string]float64{
+		"temp":   tempComfort(d.TempMax),
+		"precip": 1 - clamp01(d.PrecipProb/100),
+	}
+	if d.WindSpeed != nil {
+		components["wind"] = 1 - clamp01((*d.WindSpeed-scoreCalmWind)/(scoreGaleWind-scoreCalmWind))
+	}
+	if d.CloudCover != nil {
+		components["cloud"] = 1 - clamp01(*d.CloudCover/100)
+	}
+
+	weights := map[string]float64{"temp": w.Temp, "precip": w.Precip, "wind": w.Wind, "cloud": w.Cloud}
+	var sum, total float64
+	for name, v := range components {
+		sum += v * weights[name]
+		total += weights[name]
+		components[name] = math.Round(v * 100)
+	}
+	if total == 0 {
+		return nil, components
+	}
+	score := int(math.Round(sum / total * 100))
+	return &score, components
+}
+
+// tempComfort scores a daily high, see weatherScore.
+func tempComfort(t float64) float64 {
+	switch {
+	case t < scoreIdealTempLow:
+		return 1 - clamp01((scoreIdealTempLow-t)/scoreTempFalloff)
+	case t > scoreIdealTempHigh:
+		return 1 - clamp01((t-scoreIdealTempHigh)/scoreTempFalloff)
+	}
+	return 1
+}
+
+// clamp01 bounds v to [0, 1].
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// scoreDays scores every day.
+func scoreDays(days []weatherDay, w scoreWeights) []scoreDay {
+	out := make([]scoreDay, 0, len(days))
+	for _, d := range days {
+		score, components := weatherScore(d, w)
+		out = append(out, scoreDay{Date: d.Datetime, Score: score, Components: components})
+	}
+	return out
+}
+
+// getScoreHandler handles GET /weather/score requests, returning a 0-100
+// "good weather" score per day from the (cached) full response.
+func getScoreHandler(c *gin.Context) {
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"location": q.Location,
+		"days":     scoreDays(days, cfg.ScoreWeights),
+	})
+}
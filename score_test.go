@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWeatherScore(t *testing.T) {
+	num := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		name       string
+		day        weatherDay
+		w          scoreWeights
+		score      *int
+		components map[string]float64
+	}{
+		{"ideal", weatherDay{TempMax: 20, WindSpeed: num(5), CloudCover: num(0)}, defaultScoreWeights,
+			intPtr(100), map[string]float64{"temp": 100, "precip": 100, "wind": 100, "cloud": 100}},
+		// Missing components drop out and the rest are reweighted.
+		{"no wind or cloud", weatherDay{TempMax: 20, PrecipProb: 50}, defaultScoreWeights,
+			intPtr(75), map[string]float64{"temp": 100, "precip": 50}},
+		{"cold gale", weatherDay{TempMax: 6, WindSpeed: num(50), CloudCover: num(100)}, defaultScoreWeights,
+			intPtr(35), map[string]float64{"temp": 0, "precip": 100, "wind": 0, "cloud": 0}},
+		{"hot wet", weatherDay{TempMax: 31, PrecipProb: 100, WindSpeed: num(30), CloudCover: num(40)}, defaultScoreWeights,
+			intPtr(34), map[string]float64{"temp": 50, "precip": 0, "wind": 50, "cloud": 60}},
+		{"out of range inputs", weatherDay{TempMax: 20, PrecipProb: 150, WindSpeed: num(-5), CloudCover: num(-10)}, defaultScoreWeights,
+			intPtr(65), map[string]float64{"temp": 100, "precip": 0, "wind": 100, "cloud": 100}},
+		{"only unscored data", weatherDay{TempMax: 20}, scoreWeights{Cloud: 1},
+			nil, map[string]float64{"temp": 100, "precip": 100}},
+	} {
+		score, components := weatherScore(tc.day, tc.w)
+		if !reflect.DeepEqual(score, tc.score) || !reflect.DeepEqual(components, tc.components) {
+			t.Errorf("%s: weatherScore = %v, %v; want %v, %v", tc.name, deref(score), components, deref(tc.score), tc.components)
+		}
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+// deref shows an optional score in failure messages.
+func deref(p *int) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func TestTempComfort(t *testing.T) {
+	for _, tc := range []struct{ temp, want float64 }{
+		{-10, 0}, {6, 0}, {12, 0.5}, {18, 1}, {21, 1}, {25, 1}, {31, 0.5}, {37, 0}, {45, 0},
+	} {
+		if got := tempComfort(tc.temp); got != tc.want {
+			t.Errorf("tempComfort(%v) = %v, want %v", tc.temp, got, tc.want)
+		}
+	}
+}
+
+func TestParseScoreWeights(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want scoreWeights
+		ok   bool
+	}{
+		{"", defaultScoreWeights, true},
+		{`{"cloud":0.3}`, scoreWeights{Temp: 0.35, Precip: 0.35, Wind: 0.15, Cloud: 0.3}, true},
+		{`{"temp":1,"precip":0,"wind":0,"cloud":0}`, scoreWeights{Temp: 1}, true},
+		{`{"wind":-1}`, scoreWeights{}, false},
+		{`{"temp":0,"precip":0,"wind":0,"cloud":0}`, scoreWeights{}, false},
+		{`not json`, scoreWeights{}, false},
+	} {
+		got, err := parseScoreWeights(tc.raw)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("parseScoreWeights(%s) = %+v, %v; want %+v, ok %v", tc.raw, got, err, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ready reports whether the service should receive traffic; /readyz reflects it.
+var ready atomic.Bool
+
+// listen opens the configured listener: a Unix domain socket when socketPath is
+// set, otherwise TCP on port.
+func listen(socketPath, port string) (net.Listener, error) {
+	if socketPath == "" {
+		log.Printf("Server listening on port %s", port)
+		return net.Listen("tcp", ":"+port)
+	}
+
+	// A socket file left behind by a previous crash would make Listen fail.
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// The Unix listener unlinks the socket file when the server closes it.
+	log.Printf("Server listening on unix socket %s", socketPath)
+	return net.Listen("unix", socketPath)
+}
+
+// startupJitter picks a random delay of up to max, spreading out the first
+// upstream calls of replicas started together. A max of zero means no delay.
+func startupJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// serve runs handler on ln until SIGINT or SIGTERM, then drains: it first marks
+// the service not ready so load balancers stop routing to it, waits drainPeriod
+// for them to notice, and finally shuts the HTTP server down gracefully, giving
+// in-flight requests up to shutdownTimeout to finish before their connections
+// are closed under them. The service only reports ready once readyAfter has
+// passed.
+func serve(ln net.Listener, handler http.Handler, drainPeriod, readyAfter, shutdownTimeout time.Duration) error {
+	srv := &http.Server{Handler: handler}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+	becomeReady := time.AfterFunc(readyAfter, func() { ready.Store(true) })
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	select {
+	case err := <-errCh:
+		return err
+	case s := <-sig:
+		log.Printf("Received %s, marking service not ready", s)
+	}
+
+	becomeReady.Stop()
+	ready.Store(false)
+	if drainPeriod > 0 {
+		log.Printf("Draining for %s before shutting down", drainPeriod)
+		time.Sleep(drainPeriod)
+	}
+
+	log.Printf("Shutting down HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("Requests still running after the %s shutdown timeout, closing their connections", shutdownTimeout)
+		err = srv.Close()
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("HTTP server stopped")
+	return nil
+}
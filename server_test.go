@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// shutdownDuringRequest serves handler through serve with shutdownTimeout,
+// sends a request and, once the handler has started, SIGTERM. It returns the
+// request's status (zero if the connection failed), serve's error and how long
+// serve took to return after the signal.
+func shutdownDuringRequest(t *testing.T, handler http.HandlerFunc, started <-chan struct{}, shutdownTimeout time.Duration) (int, error, time.Duration) {
+	t.Helper()
+	// Keep our own subscription so a SIGTERM arriving before serve's never
+	// kills the test binary.
+	sig := make(chan os.Signal, 16)
+	signal.Notify(sig, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- serve(ln, handler, 0, 0, shutdownTimeout) }()
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	select {
+	case <-started:
+	case err := <-served:
+		t.Fatalf("serve returned before the request arrived: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("request never reached the handler")
+	}
+
+	// serve may not have subscribed yet, so signal until it shuts down.
+	signalled := time.Now()
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(10 * time.Second)
+	for {
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {
+		case err := <-served:
+			return <-status, err, time.Since(signalled)
+		case <-tick.C:
+		case <-timeout:
+			t.Fatal("serve didn't return after SIGTERM")
+		}
+	}
+}
+
+func TestServeDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}
+	status, err, took := shutdownDuringRequest(t, handler, started, 5*time.Second)
+	if err != nil {
+		t.Errorf("serve: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("in-flight request got %d, want it completed with 200", status)
+	}
+	if took < 200*time.Millisecond {
+		t.Errorf("serve returned after %s, before the request finished", took)
+	}
+}
+
+func TestServeForceClosesAfterShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}
+	status, err, took := shutdownDuringRequest(t, handler, started, 200*time.Millisecond)
+	if err != nil {
+		t.Errorf("serve: %v", err)
+	}
+	if status != 0 {
+		t.Errorf("request outliving SHUTDOWN_TIMEOUT got %d, want its connection closed", status)
+	}
+	if took > 2*time.Second {
+		t.Errorf("serve returned after %s, want soon after the 200ms timeout", took)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// skyDay is the per-day sky detail returned by /weather/sky. Fields the
+// upstream didn't report are omitted.
+type skyDay struct {
+	Date       string   `json:"date"`
+	CloudCover *float64 `json:"cloudcover,omitempty"`
+	Visibility *float64 `json:"visibility,omitempty"`
+	Conditions string   `json:"conditions,omitempty"`
+}
+
+// skyDays extracts the cloud cover, visibility and conditions of each day.
+func skyDays(days []weatherDay) []skyDay {
+	out := make([]skyDay, 0, len(days))
+	for _, d := range days {
+		out = append(out, skyDay{Date: d.Datetime, CloudCover: d.CloudCover, Visibility: d.Visibility, Conditions: d.Conditions})
+	}
+	return out
+}
+
+// getSkyHandler handles GET /weather/sky requests, returning the per-day cloud
+// cover, visibility and conditions from the (cached) full response.
+func getSkyHandler(c *gin.Context) {
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"location": q.Location,
+		"days":     skyDays(days),
+	})
+}
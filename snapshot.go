@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cachePrefix is the key prefix shared by every cached weather entry.
+const cachePrefix = "weather:"
+
+// exportCache dumps every cached weather entry into a JSON file mapping the cache
+// key without its prefix to its versioned cache entry.
+func exportCache(path string) (int, error) {
+	snapshot := make(map[string]json.RawMessage)
+
+	err := scanCacheKeys(func(key string) {
+		val, err := cacheGet(ctx, key)
+		if err != nil {
+			// The key may have expired between SCAN and GET; skip it.
+			return
+		}
+		if !json.Valid([]byte(val)) {
+			return
+		}
+		snapshot[strings.TrimPrefix(key, cachePrefix)] = json.RawMessage(val)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan cache: %v", err)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return len(snapshot), nil
+}
+
+// flushCache deletes every cached weather entry, returning how many were removed.
+func flushCache() (int, error) {
+	var keys []string
+	if err := scanCacheKeys(func(key string) { keys = append(keys, key) }); err != nil {
+		return 0, fmt.Errorf("failed to scan cache: %v", err)
+	}
+
+	flushed := 0
+	for shard, keys := range keysByShard(keys) {
+		for len(keys) > 0 {
+			n := min(len(keys), 100)
+			deleted, err := shard.Del(ctx, keys[:n]...).Result()
+			flushed += int(deleted)
+			if err != nil {
+				return flushed, fmt.Errorf("failed to delete cache entries: %v", err)
+			}
+			keys = keys[n:]
+		}
+	}
+	return flushed, nil
+}
+
+// importCache reads a JSON snapshot produced by exportCache and writes every entry
+// back into Redis using the configured cache expiration.
+func importCache(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+
+	var snapshot map[string]json.RawMessage
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("failed to decode snapshot: %v", err)
+	}
+
+	pipes := make(map[*redis.Client]redis.Pipeliner)
+	for location, entry := range snapshot {
+		key := cachePrefix + location
+		shard := primaryFor(key)
+		if pipes[shard] == nil {
+			pipes[shard] = shard.Pipeline()
+		}
+		pipes[shard].Set(ctx, key, []byte(entry), cacheExpiration())
+	}
+	for _, pipe := range pipes {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, fmt.Errorf("failed to populate cache: %v", err)
+		}
+	}
+	return len(snapshot), nil
+}
+
+// runCacheCommand handles the "cache-export" and "cache-import" subcommands. It
+// reports whether a subcommand was recognised so main can skip starting the server.
+func runCacheCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var (
+		n   int
+		err error
+	)
+	switch args[0] {
+	case "cache-export":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: weather-api cache-export <file>")
+			os.Exit(2)
+		}
+		n, err = exportCache(args[1])
+	case "cache-import":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: weather-api cache-import <file>")
+			os.Exit(2)
+		}
+		n, err = importCache(args[1])
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d entries\n", args[0], n)
+	return true
+}
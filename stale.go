@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Stale-while-revalidate counters, reported by /stats.
+var (
+	staleRevalidations       atomic.Int64 // background refreshes of stale entries
+	staleRevalidationsFailed atomic.Int64
+)
+
+// staleRevalidating tracks the stale entries being refreshed, one refresh per
+// key at a time.
+var staleRevalidating = &refreshAheadTracker{hits: make(map[string]int), inFlight: make(map[string]bool)}
+
+// staleWindow returns when data fetched at fetchedAt and cached for ttl stops
+// being fresh, and how long to keep it in Redis: CACHE_STALE_GRACE longer, so
+// it can be served stale while it is refreshed. Without a grace the entry
+// records no fresh window and expires after ttl.
+func staleWindow(fetchedAt time.Time, ttl time.Duration) (time.Time, time.Duration) {
+	if cfg.CacheStaleGrace <= 0 {
+		return time.Time{}, ttl
+	}
+	return fetchedAt.Add(ttl), ttl + cfg.CacheStaleGrace
+}
+
+// entryFreshUntil returns the end of a raw cache entry's fresh window, or the
+// zero time for entries that don't record one.
+func entryFreshUntil(raw string) time.Time {
+	var entry struct {
+		FreshUntil *time.Time `json:"freshUntil"`
+	}
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil || entry.FreshUntil == nil {
+		return time.Time{}
+	}
+	return *entry.FreshUntil
+}
+
+// revalidateStale refreshes the stale cache entry of q in the background, so
+// the hit that found it is answered at once. Concurrent hits of the same entry
+// start one refresh; it goes through the upstream queue, shares the fetch
+// with concurrent misses and is skipped while the upstream quota is exhausted,
+// leaving the entry to be served stale until its grace runs out.
+func revalidateStale(q weatherQuery, cacheKey string) {
+	if !staleRevalidating.claim(cacheKey, 1) {
+		return
+	}
+	go func() {
+		defer staleRevalidating.release(cacheKey)
+		if _, exhausted := quotaExhaustedUntil(clock.Now()); exhausted {
+			return
+		}
+		staleRevalidations.Add(1)
+		rctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+		defer cancel()
+		if _, _, err := coalescedFetchAndCache(rctx, q, cacheKey); err != nil {
+			staleRevalidationsFailed.Add(1)
+			log.Printf("Revalidating stale weather data failed for %s: %v", q.Location, err)
+		}
+	}()
+}
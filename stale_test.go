@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock stopped at one instant.
+type fixedClock struct{ now time.Time }
+
+func (c fixedClock) Now() time.Time { return c.now }
+
+// TestStaleHitRevalidatesInBackground checks a hit past its fresh window is
+// answered from the cache without waiting on the upstream, and that repeated
+// stale hits start a single background refresh.
+func TestStaleHitRevalidatesInBackground(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	c := testConfig(t)
+	c.CacheStaleGrace = time.Hour
+	setupTest(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			<-release // the revalidation hangs until the stale hits are served
+		}
+		respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+	}))
+	// Registered after the upstream's, so it runs first and a failed test
+	// doesn't leave the server's Close waiting on the held request.
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+	oldClock := clock
+	t.Cleanup(func() { clock = oldClock })
+	get := func() *httptest.ResponseRecorder {
+		return serveRequest(httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+	}
+
+	if w := get(); w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first lookup: %d, X-Cache %q", w.Code, w.Header().Get("X-Cache"))
+	}
+	clock = fixedClock{time.Now().Add(cacheExpiration() + time.Minute)}
+	revalidated := staleRevalidations.Load()
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		w := get()
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "STALE" {
+			t.Fatalf("stale lookup %d: %d, X-Cache %q", i, w.Code, w.Header().Get("X-Cache"))
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("stale lookup %d took %s, waiting on the refresh", i, elapsed)
+		}
+	}
+	unblock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for revalidating(t) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := staleRevalidations.Load() - revalidated; got != 1 {
+		t.Errorf("started %d revalidations for 5 stale hits, want 1", got)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times, want the miss and one revalidation", n)
+	}
+}
+
+// revalidating reports whether a stale revalidation is still running.
+func revalidating(t *testing.T) bool {
+	t.Helper()
+	staleRevalidating.mu.Lock()
+	defer staleRevalidating.mu.Unlock()
+	return len(staleRevalidating.inFlight) > 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// All counters reported by /stats are atomics or guarded by their own mutex, as
+// they are updated from concurrent handlers. They count from process start, are
+// never reset and are per instance: a restart starts them again from zero.
+
+// cacheCorruptions counts cached entries discarded because they couldn't be decoded.
+var cacheCorruptions atomic.Int64
+
+// lookupCounters counts weather lookups by outcome.
+var lookupCounters lookupStats
+
+// lookupStats counts lookups served from the cache, served stale from it while
+// revalidating, fetched on a miss, fetched with the cache bypassed, and failed
+// (including negatively cached responses).
+type lookupStats struct {
+	hits, stale, misses, bypassed, errors atomic.Int64
+}
+
+// record counts the outcome of one lookup.
+func (s *lookupStats) record(result weatherResult, err error) {
+	if err == nil {
+		observeLookup(result.Cache)
+	}
+	switch {
+	case err != nil:
+		s.errors.Add(1)
+	case result.Cache == "HIT":
+		s.hits.Add(1)
+	case result.Cache == "STALE":
+		s.stale.Add(1)
+	case result.Cache == "BYPASS":
+		s.bypassed.Add(1)
+	default:
+		s.misses.Add(1)
+	}
+}
+
+// statsHandler handles GET /stats requests, reporting the service's runtime
+// counters.
+func statsHandler(c *gin.Context) {
+	errorRate, samples := upstreamErrors.rate()
+	openBreakers, trackedBreakers := locationBreakers.stats()
+	c.JSON(http.StatusOK, gin.H{
+		"degradation": gin.H{
+			"active":            degraded(),
+			"upstreamErrorRate": errorRate,
+			"samples":           samples,
+			"effectiveTTL":      int(effectiveCacheTTL(cacheExpiration()).Seconds()),
+		},
+		"inFlightRequests":      inFlightRequests.Load(),
+		"maxConcurrentRequests": cfg.MaxConcurrentRequests,
+		"cacheCorruptions":      cacheCorruptions.Load(),
+		"partialResponses":      partialResponses.Load(),
+		"cacheExpiration":       int(cacheExpiration().Seconds()),
+		"lookups": gin.H{
+			"hits":     lookupCounters.hits.Load(),
+			"stale":    lookupCounters.stale.Load(),
+			"misses":   lookupCounters.misses.Load(),
+			"bypassed": lookupCounters.bypassed.Load(),
+			"errors":   lookupCounters.errors.Load(),
+		},
+		"upstreamFetches": gin.H{
+			"led":          upstreamFetchesLed.Load(),
+			"coalesced":    coalescedRequests.Load(),
+			"lockWaited":   fetchLockWaited.Load(),
+			"lockTimeouts": fetchLockTimeouts.Load(),
+			"byTeam":       upstreamCallsByTeam.snapshot(),
+			"cost":         upstreamCosts.snapshot(),
+		},
+		"upstreamQueue": upstreamQueue.stats(),
+		"retryBudget":   upstreamRetryBudget.stats(),
+		"rateLimit":     adaptiveRate.stats(),
+		"prefetches": gin.H{
+			"enabled": cfg.PredictivePrefetch,
+			"started": prefetchesStarted.Load(),
+			"skipped": prefetchesSkipped.Load(),
+		},
+		"refreshAhead": gin.H{
+			"ratio":   cfg.RefreshAheadRatio,
+			"started": refreshAheadStarted.Load(),
+			"cold":    refreshAheadCold.Load(),
+			"skipped": refreshAheadSkipped.Load(),
+			"failed":  refreshAheadFailed.Load(),
+		},
+		"staleWhileRevalidate": gin.H{
+			"graceSeconds": int(cfg.CacheStaleGrace.Seconds()),
+			"revalidated":  staleRevalidations.Load(),
+			"failed":       staleRevalidationsFailed.Load(),
+		},
+		"currentConditions": gin.H{
+			"ttlSeconds": int(cfg.CurrentConditionsTTL.Seconds()),
+			"refreshed":  currentRefreshes.Load(),
+			"failed":     currentRefreshFailures.Load(),
+		},
+		"locationBreakers": gin.H{
+			"open":    openBreakers,
+			"tracked": trackedBreakers,
+		},
+		"cacheWrites": gin.H{
+			"failures":  cacheWriteFailures.Load(),
+			"skipped":   cacheWritesSkipped.Load(),
+			"oversized": cacheEntriesOversized.Load(),
+			"stale":     cacheWritesStale.Load(),
+			"suspended": writeGuard.open(),
+		},
+	})
+}
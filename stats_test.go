@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestLookupCountersConcurrent serves lookups and /stats reads from many
+// goroutines at once; run with -race it checks the counters are updated
+// safely, and that every lookup is counted exactly once.
+func TestLookupCountersConcurrent(t *testing.T) {
+	c := testConfig(t)
+	c.MaxConcurrentRequests = 0
+	setupTest(t, c, respondWith(http.StatusOK, fixtureWeather))
+	router := newRouter(cfg)
+
+	lookups := func() int64 {
+		return lookupCounters.hits.Load() + lookupCounters.stale.Load() + lookupCounters.misses.Load() +
+			lookupCounters.bypassed.Load() + lookupCounters.errors.Load()
+	}
+	before, errorsBefore := lookups(), lookupCounters.errors.Load()
+
+	const workers, perWorker = 8, 25
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather?location=London", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("GET /weather: %d %s", w.Code, w.Body)
+				}
+				if j%5 == 0 {
+					w = httptest.NewRecorder()
+					router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+					if w.Code != http.StatusOK {
+						t.Errorf("GET /stats: %d", w.Code)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := lookups() - before; got != workers*perWorker {
+		t.Errorf("counted %d lookups, want %d", got, workers*perWorker)
+	}
+	if got := lookupCounters.errors.Load() - errorsBefore; got != 0 {
+		t.Errorf("counted %d failed lookups, want 0", got)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var stats struct {
+		Lookups map[string]int64 `json:"lookups"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decoding /stats: %v", err)
+	}
+	if stats.Lookups["hits"] != lookupCounters.hits.Load() || stats.Lookups["misses"] != lookupCounters.misses.Load() {
+		t.Errorf("/stats lookups %v disagree with the counters", stats.Lookups)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// summaryDays is the number of days aggregated into a weekly summary.
+const summaryDays = 7
+
+// weeklySummary is the compact aggregate returned by /weather/summary.
+type weeklySummary struct {
+	Location           string  `json:"location"`
+	From               string  `json:"from"`
+	To                 string  `json:"to"`
+	Days               int     `json:"days"`
+	AvgHigh            float64 `json:"avgHigh"`
+	AvgLow             float64 `json:"avgLow"`
+	TotalPrecip        float64 `json:"totalPrecip"`
+	DominantConditions string  `json:"dominantConditions"`
+}
+
+// summarizeDays aggregates up to summaryDays days into a weekly summary. Fewer days
+// are summarised as-is; an empty slice yields a zero summary.
+func summarizeDays(days []weatherDay) weeklySummary {
+	if len(days) > summaryDays {
+		days = days[:summaryDays]
+	}
+	var s weeklySummary
+	if len(days) == 0 {
+		return s
+	}
+
+	counts := make(map[string]int)
+	best := 0
+	for _, d := range days {
+		s.AvgHigh += d.TempMax
+		s.AvgLow += d.TempMin
+		s.TotalPrecip += d.Precip
+		if d.Conditions != "" {
+			counts[d.Conditions]++
+			// Strictly greater keeps the earliest condition on ties.
+			if counts[d.Conditions] > best {
+				best = counts[d.Conditions]
+				s.DominantConditions = d.Conditions
+			}
+		}
+	}
+
+	s.Days = len(days)
+	s.From = days[0].Datetime
+	s.To = days[len(days)-1].Datetime
+	s.AvgHigh /= float64(len(days))
+	s.AvgLow /= float64(len(days))
+	return s
+}
+
+// getSummaryHandler handles GET /weather/summary requests, returning aggregate
+// statistics over the next week computed from the (cached) full response.
+func getSummaryHandler(c *gin.Context) {
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	summary := summarizeDays(days)
+	summary.Location = q.Location
+	c.JSON(http.StatusOK, summary)
+}
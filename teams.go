@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// teamHeader lets internal clients sharing the upstream key tag their requests.
+const teamHeader = "X-Team"
+
+// untaggedTeam counts upstream calls made for requests without a team, such as
+// the cache warmer's.
+const untaggedTeam = "untagged"
+
+// maxTrackedTeams bounds the per-team counters, since X-Team is client supplied.
+// Calls for teams beyond it are counted under otherTeam.
+const (
+	maxTrackedTeams = 100
+	otherTeam       = "other"
+)
+
+// teamNamePattern restricts team names so they are safe to log and report.
+var teamNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// parseKeyTeams parses comma-separated key:team pairs.
+func parseKeyTeams(raw string) map[string]string {
+	teams := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, ":")
+		if i <= 0 || !teamNamePattern.MatchString(pair[i+1:]) {
+			log.Printf("Ignoring malformed API_KEY_TEAMS entry %q", pair)
+			continue
+		}
+		teams[pair[:i]] = pair[i+1:]
+	}
+	return teams
+}
+
+// teamKey is the context key under which the request's team is stored.
+type teamKey struct{}
+
+// teamFromContext returns the team of the request ctx belongs to, or "".
+func teamFromContext(ctx context.Context) string {
+	team, _ := ctx.Value(teamKey{}).(string)
+	return team
+}
+
+// teamMiddleware tags the request with its team: the team mapped to its API key
+// in API_KEY_TEAMS, else the X-Team header when well-formed. The team travels in
+// the request context so upstream fetches can be attributed to it. It must run
+// after authMiddleware.
+func teamMiddleware(keyTeams map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		team, ok := keyTeams[c.GetString("apiKey")]
+		if !ok {
+			if h := c.GetHeader(teamHeader); teamNamePattern.MatchString(h) {
+				team = h
+			}
+		}
+		if team != "" {
+			c.Set("team", team)
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), teamKey{}, team))
+		}
+		c.Next()
+	}
+}
+
+// teamCounter counts upstream calls per team.
+type teamCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// upstreamCallsByTeam counts the upstream calls made on behalf of each team.
+// Cache hits and coalesced requests make no call and aren't counted.
+var upstreamCallsByTeam = &teamCounter{counts: make(map[string]int64)}
+
+// record counts one upstream call for team.
+func (t *teamCounter) record(team string) {
+	if team == "" {
+		team = untaggedTeam
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.counts[team]; !ok && len(t.counts) >= maxTrackedTeams {
+		team = otherTeam
+	}
+	t.counts[team]++
+}
+
+// snapshot returns a copy of the counts.
+func (t *teamCounter) snapshot() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]int64, len(t.counts))
+	for team, n := range t.counts {
+		out[team] = n
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTempLocations bounds the locations of one POST /weather/temps request.
+const maxTempLocations = 100
+
+// tempFetches bounds how many locations /weather/temps looks up at once.
+const tempFetches = 8
+
+// tempsRequest is the body of POST /weather/temps.
+type tempsRequest struct {
+	Locations []string `json:"locations" binding:"required"`
+}
+
+// currentTemp returns the current temperature in data, in °C: that of the
+// current conditions when the data has them, otherwise the first day's mean.
+func currentTemp(data map[string]interface{}) (float64, bool) {
+	if current, err := decodeCurrent(data); err == nil && current != nil && current.Temp != nil {
+		return *current.Temp, true
+	}
+	days, err := decodeDays(data)
+	if err != nil || len(days) == 0 {
+		return 0, false
+	}
+	return days[0].Temp, true
+}
+
+// getTempsHandler handles POST /weather/temps, returning just the current
+// temperature of every location in the body, {"locations":[...]}, as a map
+// from the locations as given to their temperature, for dashboards showing
+// many places at a glance. units=us gives °F. Locations are looked up
+// concurrently like any /weather request, so they share its cache entries; a
+// location whose lookup failed maps to null.
+func getTempsHandler(c *gin.Context) {
+	unitGroup := strings.ToLower(c.DefaultQuery("units", upstreamUnitGroup))
+	if !textUnitGroups[unitGroup] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "units must be one of: metric us uk"})
+		return
+	}
+	var req tempsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `body must be a JSON object like {"locations":["London","Paris"]}`})
+		return
+	}
+	var locations []string
+	seen := make(map[string]bool, len(req.Locations))
+	for _, location := range req.Locations {
+		if !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+	if len(locations) == 0 || len(locations) > maxTempLocations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("between 1 and %d locations are required", maxTempLocations)})
+		return
+	}
+
+	temps := make(map[string]*float64, len(locations))
+	for i, lookup := range lookupLocations(c, locations, "/weather/temps", cfg.DefaultLang, tempFetches) {
+		temps[locations[i]] = nil
+		if lookup.err != nil {
+			continue
+		}
+		if t, ok := currentTemp(lookup.result.Data); ok {
+			t = math.Round(temperatureIn(t, unitGroup)*10) / 10
+			temps[locations[i]] = &t
+		}
+	}
+	c.JSON(http.StatusOK, temps)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+// textLocale is how /weather/text phrases a summary in one language. Each
+// language needs only its own entry in textLocales.
+type textLocale struct {
+	// template renders a textSummary. Temperatures are whole numbers.
+	template *template.Template
+	// lowerConditions lower-cases the condition text mid-sentence, for
+	// languages that don't capitalise such nouns.
+	lowerConditions bool
+}
+
+// newTextLocale parses a summary template, panicking on a broken one, as
+// templates are fixed at build time.
+func newTextLocale(text string, lowerConditions bool) textLocale {
+	return textLocale{template: template.Must(template.New("summary").Parse(text)), lowerConditions: lowerConditions}
+}
+
+// textLocales are the languages /weather/text has phrasing for, by lang code.
+// Other languages fall back to English phrasing around the condition text
+// Visual Crossing translated.
+var textLocales = map[string]textLocale{
+	"en": newTextLocale(`{{.Location}}: {{.Temp}}{{.Unit}}{{with .Conditions}}, {{.}}{{end}}, high {{.High}}° low {{.Low}}°`, true),
+	"de": newTextLocale(`{{.Location}}: {{.Temp}}{{.Unit}}{{with .Conditions}}, {{.}}{{end}}, Höchstwert {{.High}}° Tiefstwert {{.Low}}°`, false),
+	"fr": newTextLocale(`{{.Location}} : {{.Temp}}{{.Unit}}{{with .Conditions}}, {{.}}{{end}}, max. {{.High}}° min. {{.Low}}°`, true),
+	"es": newTextLocale(`{{.Location}}: {{.Temp}}{{.Unit}}{{with .Conditions}}, {{.}}{{end}}, máx. {{.High}}° mín. {{.Low}}°`, true),
+	"it": newTextLocale(`{{.Location}}: {{.Temp}}{{.Unit}}{{with .Conditions}}, {{.}}{{end}}, max {{.High}}° min {{.Low}}°`, true),
+	"nl": newTextLocale(`{{.Location}}: {{.Temp}}{{.Unit}}{{with .Conditions}}, {{.}}{{end}}, max {{.High}}° min {{.Low}}°`, true),
+	"pt": newTextLocale(`{{.Location}}: {{.Temp}}{{.Unit}}{{with .Conditions}}, {{.}}{{end}}, máx. {{.High}}° mín. {{.Low}}°`, true),
+}
+
+// textSummary is what a summary template renders.
+type textSummary struct {
+	Location   string
+	Temp       int
+	High, Low  int
+	Unit       string // temperature unit symbol, e.g. °C
+	Conditions string
+}
+
+// textUnitGroups are the unit groups /weather/text can convert to.
+var textUnitGroups = map[string]bool{"metric": true, "us": true, "uk": true}
+
+// temperatureIn converts a Celsius temperature to the unit group's scale, the
+// inverse of temperatureC.
+func temperatureIn(celsius float64, unitGroup string) float64 {
+	switch strings.ToLower(unitGroup) {
+	case "us":
+		return celsius*9/5 + 32
+	case "base":
+		return celsius + 273.15
+	}
+	return celsius
+}
+
+// renderTextSummary phrases today's weather in lang, in the temperature unit
+// of unitGroup. The current temperature is used when the data has current
+// conditions, otherwise the day's mean.
+func renderTextSummary(location string, today weatherDay, current *weatherCurrent, lang, unitGroup string) (string, error) {
+	locale, ok := textLocales[lang]
+	if !ok {
+		locale = textLocales[defaultLang]
+	}
+	temp, conditions := today.Temp, today.Conditions
+	if current != nil && current.Temp != nil {
+		temp = *current.Temp
+		if current.Conditions != "" {
+			conditions = current.Conditions
+		}
+	}
+	if locale.lowerConditions {
+		conditions = strings.ToLower(conditions)
+	}
+	whole := func(celsius float64) int { return int(math.Round(temperatureIn(celsius, unitGroup))) }
+	var b bytes.Buffer
+	err := locale.template.Execute(&b, textSummary{
+		Location:   location,
+		Temp:       whole(temp),
+		High:       whole(today.TempMax),
+		Low:        whole(today.TempMin),
+		Unit:       unitsFor(unitGroup).Temperature,
+		Conditions: conditions,
+	})
+	return b.String(), err
+}
+
+// getTextHandler handles GET /weather/text requests, returning today's weather
+// as one plain-text sentence for voice assistants and simple displays, e.g.
+// "London: 14°C, rain, partially cloudy, high 16° low 9°". lang selects the
+// phrasing and the language of the conditions; units (metric, us or uk) the
+// temperature scale.
+func getTextHandler(c *gin.Context) {
+	unitGroup := strings.ToLower(c.DefaultQuery("units", upstreamUnitGroup))
+	if !textUnitGroups[unitGroup] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "units must be one of: metric us uk"})
+		return
+	}
+	q, data, days, ok := loadWeather(c)
+	if !ok {
+		return
+	}
+	current, err := decodeCurrent(data)
+	if err != nil {
+		current = nil
+	}
+	lang := q.Lang
+	if lang == "" {
+		lang = defaultLang
+	}
+	text, err := renderTextSummary(q.Location, days[0], current, lang, unitGroup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to render summary: %v", err)})
+		return
+	}
+	c.String(http.StatusOK, text+"\n")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRenderTextSummary(t *testing.T) {
+	today := weatherDay{Temp: 12.4, TempMax: 16.2, TempMin: 8.6, Conditions: "Rain, Partially cloudy"}
+	currentTemp := 14.0
+	current := &weatherCurrent{Temp: &currentTemp, Conditions: "Overcast"}
+	for _, tc := range []struct {
+		name, lang, unitGroup string
+		current               *weatherCurrent
+		want                  string
+	}{
+		{"english day", "en", "metric", nil, "London: 12°C, rain, partially cloudy, high 16° low 9°"},
+		{"current conditions", "en", "metric", current, "London: 14°C, overcast, high 16° low 9°"},
+		{"fahrenheit", "en", "us", current, "London: 57°F, overcast, high 61° low 47°"},
+		{"uk is celsius", "en", "uk", nil, "London: 12°C, rain, partially cloudy, high 16° low 9°"},
+		{"german keeps capitals", "de", "metric", nil, "London: 12°C, Rain, Partially cloudy, Höchstwert 16° Tiefstwert 9°"},
+		{"french", "fr", "metric", current, "London : 14°C, overcast, max. 16° min. 9°"},
+		{"unknown language", "xx", "metric", current, "London: 14°C, overcast, high 16° low 9°"},
+	} {
+		got, err := renderTextSummary("London", today, tc.current, tc.lang, tc.unitGroup)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: renderTextSummary = %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+
+	// Without conditions the clause is left out rather than left empty.
+	got, _ := renderTextSummary("Oslo", weatherDay{Temp: -3, TempMax: -1, TempMin: -6}, nil, "en", "metric")
+	if want := "Oslo: -3°C, high -1° low -6°"; got != want {
+		t.Errorf("no conditions: %q, want %q", got, want)
+	}
+}
+
+func TestTemperatureIn(t *testing.T) {
+	for _, tc := range []struct {
+		celsius   float64
+		unitGroup string
+		want      float64
+	}{
+		{0, "metric", 0},
+		{0, "uk", 0},
+		{0, "us", 32},
+		{100, "US", 212},
+		{-40, "us", -40},
+		{0, "base", 273.15},
+	} {
+		if got := temperatureIn(tc.celsius, tc.unitGroup); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("temperatureIn(%v, %q) = %v, want %v", tc.celsius, tc.unitGroup, got, tc.want)
+		}
+		if back := temperatureC(temperatureIn(tc.celsius, tc.unitGroup), tc.unitGroup); math.Abs(back-tc.celsius) > 1e-9 {
+			t.Errorf("temperatureC doesn't invert temperatureIn(%v, %q): %v", tc.celsius, tc.unitGroup, back)
+		}
+	}
+}
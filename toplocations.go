@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// locationCounter counts requests per location in a bounded table using the
+// space-saving scheme: once it holds capacity locations, a new location replaces
+// the least-counted one and inherits its count plus one. Counts for locations
+// that stay in the table are exact; newcomers may be overestimated by at most
+// the count they inherited, which is reported as the error bound.
+type locationCounter struct {
+	mu       sync.Mutex
+	capacity int
+	counts   map[string]*locationCount
+}
+
+type locationCount struct {
+	Location string `json:"location"`
+	Count    int64  `json:"count"`
+	Error    int64  `json:"error,omitempty"` // maximum overestimate of Count
+}
+
+// newLocationCounter returns a counter tracking at most capacity locations.
+func newLocationCounter(capacity int) *locationCounter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &locationCounter{capacity: capacity, counts: make(map[string]*locationCount)}
+}
+
+// topLocations counts the locations served since startup. It is replaced by main
+// once TOP_LOCATIONS_CAPACITY is known.
+var topLocations = newLocationCounter(1000)
+
+// record counts one request for location.
+func (lc *locationCounter) record(location string) {
+	location = normalizeLocation(location)
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if e, ok := lc.counts[location]; ok {
+		e.Count++
+		return
+	}
+	if len(lc.counts) < lc.capacity {
+		lc.counts[location] = &locationCount{Location: location, Count: 1}
+		return
+	}
+
+	var min *locationCount
+	for _, e := range lc.counts {
+		if min == nil || e.Count < min.Count {
+			min = e
+		}
+	}
+	delete(lc.counts, min.Location)
+	lc.counts[location] = &locationCount{Location: location, Count: min.Count + 1, Error: min.Count}
+}
+
+// top returns the n most requested locations, most requested first.
+func (lc *locationCounter) top(n int) []locationCount {
+	lc.mu.Lock()
+	out := make([]locationCount, 0, len(lc.counts))
+	for _, e := range lc.counts {
+		out = append(out, *e)
+	}
+	lc.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Location < out[j].Location
+	})
+	if n < len(out) {
+		out = out[:n]
+	}
+	return out
+}
+
+// topLocationsHandler handles GET /stats/top, listing the most requested
+// locations since startup. n (default 10, at most 100) limits the list.
+func topLocationsHandler(c *gin.Context) {
+	n := 10
+	if raw := c.Query("n"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "n must be an integer between 1 and 100"})
+			return
+		}
+		n = v
+	}
+	c.JSON(http.StatusOK, gin.H{"locations": topLocations.top(n)})
+}
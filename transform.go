@@ -0,0 +1,189 @@
+package main
+
+import (
+	"sort"
+
+	"golang.org/x/text/message"
+)
+
+// weatherTransform rewrites the decoded data of a /weather response for one
+// query option or setting. It may modify data in place or return a new map.
+type weatherTransform func(data map[string]interface{}, in transformInput) map[string]interface{}
+
+// transformInput is what transforms may consult besides the data itself.
+type transformInput struct {
+	q          weatherQuery
+	result     weatherResult
+	sourceKeys []string // top-level keys of the data as looked up, see upstreamKeys
+}
+
+// transformStage orders the transforms of a pipeline. Stages run in this order;
+// transforms of one stage run in the order they were added.
+type transformStage int
+
+const (
+	// stageAnnotate adds derived fields next to the upstream fields they are
+	// computed from, which are still unchanged.
+	stageAnnotate transformStage = iota
+	// stageFilter drops days. It runs before paging, so offsets and
+	// X-Total-Days count the remaining days.
+	stageFilter
+	// stageClean rewrites null and empty fields, after any annotation added them.
+	stageClean
+	// stageDecorate adds top-level objects describing the response as a whole.
+	stageDecorate
+	// stageRename renames fields. It runs last, so every other transform and
+	// query parameter refers to upstream field names.
+	stageRename
+)
+
+// stagedTransform is one named step of a transformPipeline.
+type stagedTransform struct {
+	stage transformStage
+	name  string
+	apply weatherTransform
+}
+
+// transformPipeline is the ordered list of transforms a /weather request asked
+// for. Output formats with a fixed shape run only a prefix of it, see run.
+type transformPipeline []stagedTransform
+
+// add appends a transform to its stage.
+func (p *transformPipeline) add(stage transformStage, name string, apply weatherTransform) {
+	*p = append(*p, stagedTransform{stage: stage, name: name, apply: apply})
+	sort.SliceStable(*p, func(i, j int) bool { return (*p)[i].stage < (*p)[j].stage })
+}
+
+// run applies the transforms of the stages from first to last, inclusive, and
+// returns the resulting data. They work on a copy, so data itself is left as
+// it was.
+func (p transformPipeline) run(data map[string]interface{}, in transformInput, first, last transformStage) map[string]interface{} {
+	copied := false
+	for _, t := range p {
+		if t.stage < first || t.stage > last {
+			continue
+		}
+		if !copied && data != nil {
+			data = copyJSONValue(data).(map[string]interface{})
+			copied = true
+		}
+		data = t.apply(data, in)
+	}
+	return data
+}
+
+// copyJSONValue returns a deep copy of decoded JSON. Values of other types,
+// such as those transforms add, are shared with the original.
+func copyJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = copyJSONValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = copyJSONValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+// inPlace adapts a transform that modifies the data in place.
+func inPlace(fn func(data map[string]interface{})) weatherTransform {
+	return func(data map[string]interface{}, _ transformInput) map[string]interface{} {
+		fn(data)
+		return data
+	}
+}
+
+// weatherPipeline builds the transform pipeline of a /weather request from its
+// query parameters and the configuration. debug must only be set for admins.
+func weatherPipeline(params weatherParams, debug bool, gustThreshold float64, downsample int, numbers *message.Printer) transformPipeline {
+	var p transformPipeline
+	if debug {
+		p.add(stageAnnotate, "debug", func(data map[string]interface{}, in transformInput) map[string]interface{} {
+			data["_debug"] = debugInfo(in.result)
+			return data
+		})
+	}
+	if params.WindLabel == "true" {
+		p.add(stageAnnotate, "windLabel", inPlace(applyWindLabels))
+	}
+	if params.Beaufort == "true" {
+		p.add(stageAnnotate, "beaufort", func(data map[string]interface{}, in transformInput) map[string]interface{} {
+			applyBeaufort(data, in.q.unitGroup())
+			return data
+		})
+	}
+	if params.GustThreshold != "" {
+		p.add(stageAnnotate, "gustThreshold", func(data map[string]interface{}, in transformInput) map[string]interface{} {
+			applyGustThreshold(data, gustThreshold, in.q.unitGroup())
+			return data
+		})
+	}
+	if params.Normals == "true" {
+		p.add(stageAnnotate, "normals", inPlace(applyNormals))
+	}
+	if params.LocalTime == "true" {
+		p.add(stageAnnotate, "localTime", inPlace(func(data map[string]interface{}) {
+			applyLocalTime(data, clock.Now())
+		}))
+	}
+	if params.Intervals == "true" || params.Intervals == "derived" {
+		p.add(stageAnnotate, "intervals", inPlace(func(data map[string]interface{}) {
+			applyIntervals(data, clock.Now(), params.Intervals == "derived")
+		}))
+	}
+	if params.Deltas == "true" {
+		p.add(stageAnnotate, "deltas", inPlace(applyDeltas))
+	}
+	if params.Confidence == "true" || params.MinConfidence != "" {
+		p.add(stageFilter, "confidence", inPlace(func(data map[string]interface{}) {
+			applyConfidence(data, clock.Now(), params.MinConfidence)
+		}))
+	}
+	if downsample > 0 {
+		p.add(stageFilter, "downsample", inPlace(func(data map[string]interface{}) {
+			applyDownsample(data, downsample)
+		}))
+	}
+	if cfg.NullPolicy != nullPolicyKeep {
+		p.add(stageClean, "nullPolicy", inPlace(func(data map[string]interface{}) {
+			applyNullPolicy(data, cfg.NullPolicy)
+		}))
+	}
+	if params.Prune == "true" {
+		p.add(stageClean, "prune", inPlace(func(data map[string]interface{}) { pruneEmpty(data) }))
+	}
+	if numbers != nil {
+		p.add(stageClean, "numberFormat", inPlace(func(data map[string]interface{}) {
+			applyNumberFormat(data, numbers)
+		}))
+	}
+	if params.IncludeUnits == "true" {
+		p.add(stageDecorate, "units", func(data map[string]interface{}, in transformInput) map[string]interface{} {
+			data["units"] = unitsFor(in.q.unitGroup())
+			return data
+		})
+	}
+	if params.IncludeProvenance == "true" {
+		p.add(stageDecorate, "provenance", func(data map[string]interface{}, in transformInput) map[string]interface{} {
+			return withProvenance(data, in.sourceKeys, in.result, cfg.FieldRenames)
+		})
+	}
+	if cfg.ResponseMeta {
+		p.add(stageDecorate, "meta", func(data map[string]interface{}, in transformInput) map[string]interface{} {
+			return withMeta(data, in.result, in.q)
+		})
+	}
+	if len(cfg.FieldRenames) > 0 {
+		p.add(stageRename, "renames", func(data map[string]interface{}, _ transformInput) map[string]interface{} {
+			return renameFields(data, cfg.FieldRenames)
+		})
+	}
+	return p
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// recordStage returns a transform noting name in data["ran"], in place.
+func recordStage(name string) weatherTransform {
+	return inPlace(func(data map[string]interface{}) {
+		ran, _ := data["ran"].([]interface{})
+		data["ran"] = append(ran, name)
+		if days, ok := data["days"].([]interface{}); ok && len(days) > 0 {
+			if day, ok := days[0].(map[string]interface{}); ok {
+				day["seen"] = true
+			}
+		}
+	})
+}
+
+func TestTransformPipelineRun(t *testing.T) {
+	var p transformPipeline
+	// Added out of stage order; within a stage, the order added is kept.
+	p.add(stageRename, "renames", recordStage("renames"))
+	p.add(stageClean, "nullPolicy", recordStage("nullPolicy"))
+	p.add(stageAnnotate, "beaufort", recordStage("beaufort"))
+	p.add(stageDecorate, "units", recordStage("units"))
+	p.add(stageClean, "prune", recordStage("prune"))
+	p.add(stageFilter, "downsample", recordStage("downsample"))
+	p.add(stageAnnotate, "deltas", recordStage("deltas"))
+
+	for _, tc := range []struct {
+		name        string
+		first, last transformStage
+		want        []interface{}
+	}{
+		{"all", stageAnnotate, stageRename, []interface{}{"beaufort", "deltas", "downsample", "nullPolicy", "prune", "units", "renames"}},
+		{"before paging", stageAnnotate, stageFilter, []interface{}{"beaufort", "deltas", "downsample"}},
+		{"clean only", stageClean, stageClean, []interface{}{"nullPolicy", "prune"}},
+		{"after paging", stageDecorate, stageRename, []interface{}{"units", "renames"}},
+	} {
+		input := map[string]interface{}{"days": []interface{}{map[string]interface{}{"datetime": "2026-10-14"}}}
+		before := copyJSONValue(input)
+		out := p.run(input, transformInput{}, tc.first, tc.last)
+		if got := out["ran"]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: ran %v, want %v", tc.name, got, tc.want)
+		}
+		if !reflect.DeepEqual(input, before) {
+			t.Errorf("%s: run changed its input to %v", tc.name, input)
+		}
+	}
+
+	// Without a transform in range the data is returned as is.
+	input := map[string]interface{}{"address": "London"}
+	if out := p.run(input, transformInput{}, stageFilter+10, stageFilter+10); !reflect.DeepEqual(out, input) {
+		t.Errorf("empty stage range returned %v", out)
+	}
+}
+
+func TestWeatherPipelineStages(t *testing.T) {
+	c := testConfig(t)
+	c.NullPolicy = nullPolicyKeep
+	c.FieldRenames = map[string]string{"temp": "temperature"}
+	setupTest(t, c, nil)
+
+	p := weatherPipeline(weatherParams{Prune: "true", Deltas: "true", IncludeUnits: "true", Beaufort: "true"}, false, 0, 0, nil)
+	var names []string
+	for _, st := range p {
+		names = append(names, st.name)
+	}
+	if want := []string{"beaufort", "deltas", "prune", "units", "renames"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("weatherPipeline stages %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// compassPoints are the 16 compass labels in clockwise order starting at north.
+var compassPoints = [16]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// compassLabel maps a wind direction in degrees to its 16-point compass label.
+// Each point covers 22.5°, centred on its heading, so 350° and 10° are both "N".
+// Values outside 0-360 (including negatives) are wrapped.
+func compassLabel(deg float64) string {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return compassPoints[int(math.Floor(deg/22.5+0.5))%16]
+}
+
+// forEachPeriod calls fn for every day object and for the current conditions in
+// a weather response.
+func forEachPeriod(data map[string]interface{}, fn func(period map[string]interface{})) {
+	if days, ok := data["days"].([]interface{}); ok {
+		for _, d := range days {
+			if day, ok := d.(map[string]interface{}); ok {
+				fn(day)
+			}
+		}
+	}
+	if current, ok := data["currentConditions"].(map[string]interface{}); ok {
+		fn(current)
+	}
+}
+
+// applyWindLabels adds a winddirLabel next to every numeric winddir.
+func applyWindLabels(data map[string]interface{}) {
+	forEachPeriod(data, func(period map[string]interface{}) {
+		if deg, ok := period["winddir"].(float64); ok {
+			period["winddirLabel"] = compassLabel(deg)
+		}
+	})
+}
+
+// parseFieldRenames parses the FIELD_RENAMES JSON object mapping upstream field
+// names to the names clients expect.
+func parseFieldRenames(raw string) (map[string]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var renames map[string]string
+	if err := json.Unmarshal([]byte(raw), &renames); err != nil {
+		return nil, err
+	}
+	return renames, nil
+}
+
+// renameFields renames top-level and per-period keys of a weather response
+// according to renames, returning the renamed top-level map. Period maps are
+// renamed in place. Fields without an entry keep their upstream names.
+func renameFields(data map[string]interface{}, renames map[string]string) map[string]interface{} {
+	if len(renames) == 0 {
+		return data
+	}
+	forEachPeriod(data, func(period map[string]interface{}) {
+		renameKeys(period, renames)
+	})
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	renameKeys(out, renames)
+	return out
+}
+
+// renameKeys renames the keys of m in place. Keys are collected first so a rename
+// onto another renamed key (e.g. swapping two names) doesn't clobber it.
+func renameKeys(m map[string]interface{}, renames map[string]string) {
+	moved := make(map[string]interface{})
+	for from, to := range renames {
+		if v, ok := m[from]; ok {
+			moved[to] = v
+			delete(m, from)
+		}
+	}
+	for k, v := range moved {
+		m[k] = v
+	}
+}
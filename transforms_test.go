@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestCompassLabel(t *testing.T) {
+	for _, tc := range []struct {
+		deg  float64
+		want string
+	}{
+		{0, "N"},
+		{11.24, "N"},
+		{11.25, "NNE"},
+		{45, "NE"},
+		{90, "E"},
+		{135, "SE"},
+		{180, "S"},
+		{202.5, "SSW"},
+		{270, "W"},
+		{337.5, "NNW"},
+		{348.74, "NNW"},
+		{348.75, "N"},
+		{350, "N"},
+		{360, "N"},
+		{370, "N"},
+		{720 + 90, "E"},
+		{-10, "N"},
+		{-90, "W"},
+	} {
+		if got := compassLabel(tc.deg); got != tc.want {
+			t.Errorf("compassLabel(%v) = %q, want %q", tc.deg, got, tc.want)
+		}
+	}
+}
+
+func TestApplyWindLabels(t *testing.T) {
+	day := map[string]interface{}{"winddir": 225.0}
+	calm := map[string]interface{}{"winddir": nil}
+	current := map[string]interface{}{"winddir": 10.0}
+	applyWindLabels(map[string]interface{}{"days": []interface{}{day, calm}, "currentConditions": current})
+
+	if day["winddirLabel"] != "SW" || current["winddirLabel"] != "N" {
+		t.Errorf("labels %v and %v, want SW and N", day["winddirLabel"], current["winddirLabel"])
+	}
+	if _, ok := calm["winddirLabel"]; ok {
+		t.Error("label added without a wind direction")
+	}
+}
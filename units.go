@@ -0,0 +1,45 @@
+package main
+
+import "strings"
+
+// measurementUnits names the unit of each kind of measurement in a response.
+type measurementUnits struct {
+	UnitGroup      string `json:"unitGroup"`
+	Temperature    string `json:"temperature"`    // temp, tempmax, tempmin, feelslike*, dew
+	Precipitation  string `json:"precipitation"`  // precip
+	Snow           string `json:"snow"`           // snow, snowdepth
+	WindSpeed      string `json:"windSpeed"`      // windspeed, windgust
+	WindDirection  string `json:"windDirection"`  // winddir
+	Visibility     string `json:"visibility"`     // visibility
+	Pressure       string `json:"pressure"`       // pressure
+	Percentage     string `json:"percentage"`     // humidity, cloudcover, precipprob, precipcover
+	SolarRadiation string `json:"solarRadiation"` // solarradiation
+	SolarEnergy    string `json:"solarEnergy"`    // solarenergy
+}
+
+// unitsFor returns the units Visual Crossing reports measurements in for a unit
+// group. Unknown unit groups are taken as metric, as in windSpeedKmh.
+func unitsFor(unitGroup string) measurementUnits {
+	u := measurementUnits{
+		UnitGroup:      "metric",
+		Temperature:    "°C",
+		Precipitation:  "mm",
+		Snow:           "cm",
+		WindSpeed:      "km/h",
+		WindDirection:  "degrees",
+		Visibility:     "km",
+		Pressure:       "mb",
+		Percentage:     "%",
+		SolarRadiation: "W/m²",
+		SolarEnergy:    "MJ/m²",
+	}
+	switch strings.ToLower(unitGroup) {
+	case "us":
+		u.UnitGroup, u.Temperature, u.Precipitation, u.Snow, u.WindSpeed, u.Visibility = "us", "°F", "in", "in", "mph", "mi"
+	case "uk":
+		u.UnitGroup, u.WindSpeed, u.Visibility = "uk", "mph", "mi"
+	case "base":
+		u.UnitGroup, u.Temperature, u.WindSpeed = "base", "K", "m/s"
+	}
+	return u
+}
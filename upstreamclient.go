@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamClient makes every request to the upstream: weather fetches, the
+// upstream health probe and the startup warmup. main replaces it with one tuned
+// by the UPSTREAM_* transport settings.
+var upstreamClient = http.DefaultClient
+
+// upstreamTransport holds the connection settings of upstreamClient.
+type upstreamTransport struct {
+	MaxIdleConnsPerHost   int
+	DisableKeepAlives     bool
+	ResponseHeaderTimeout time.Duration // zero waits for headers as long as the request context allows
+	ExpectContinueTimeout time.Duration
+}
+
+// newUpstreamClient returns an HTTP client whose transport is Go's default
+// (proxy from the environment, HTTP/2, dial and TLS timeouts) with t applied.
+// All upstream requests go to one host, so Go's default of two idle
+// connections per host would close most connections after a burst and redial
+// them on the next one.
+func newUpstreamClient(t upstreamTransport) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+	if transport.MaxIdleConns < t.MaxIdleConnsPerHost {
+		transport.MaxIdleConns = t.MaxIdleConnsPerHost
+	}
+	transport.DisableKeepAlives = t.DisableKeepAlives
+	transport.ResponseHeaderTimeout = t.ResponseHeaderTimeout
+	transport.ExpectContinueTimeout = t.ExpectContinueTimeout
+	return &http.Client{Transport: transport}
+}
+
+// parseUpstreamTimeout reads the timeout parameter, in seconds, which must be
+// positive and at most max; empty gives def.
+func parseUpstreamTimeout(raw string, def, max time.Duration) (time.Duration, error) {
+	if raw == "" {
+		return def, nil
+	}
+	seconds, err := strconv.ParseFloat(raw, 64)
+	// The bounds are checked in seconds, as NaN and infinities convert to no
+	// meaningful Duration; a fraction of a nanosecond converts to none.
+	timeout := time.Duration(seconds * float64(time.Second))
+	if err != nil || !(seconds > 0) || seconds > max.Seconds() || timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be a number of seconds above 0 and at most %g", max.Seconds())
+	}
+	return timeout, nil
+}
+
+// upstreamTimeoutMiddleware gives each weather request a deadline: the timeout
+// parameter, for clients such as batch jobs that would rather wait longer than
+// fail, or else UPSTREAM_TIMEOUT. The deadline bounds the request's Redis calls,
+// queueing and upstream fetch alike, since they all run under its context; a
+// request running out of time gets 504. Timeouts above UPSTREAM_TIMEOUT_MAX
+// are rejected with 400.
+func upstreamTimeoutMiddleware(def, max time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, err := parseUpstreamTimeout(c.Query("timeout"), def, max)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "INVALID_TIMEOUT"})
+			return
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
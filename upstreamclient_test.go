@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseUpstreamTimeout(t *testing.T) {
+	const def, max = 5 * time.Second, 60 * time.Second
+	for _, tc := range []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", def, false},
+		{"10", 10 * time.Second, false},
+		{"2.5", 2500 * time.Millisecond, false},
+		{"0.001", time.Millisecond, false},
+		{"60", max, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"60.5", 0, true},
+		{"1e300", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+		{"-Inf", 0, true},
+		{"1e-12", 0, true},
+		{"soon", 0, true},
+	} {
+		got, err := parseUpstreamTimeout(tc.raw, def, max)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("parseUpstreamTimeout(%q) = %s, %v; want %s, error %v", tc.raw, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestTimeoutAboveMaxRejected(t *testing.T) {
+	c := testConfig(t)
+	c.UpstreamTimeoutMax = 10 * time.Second
+	setupTest(t, c, respondWith(http.StatusOK, fixtureWeather))
+
+	w := requestWeather("location=London&timeout=11")
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "INVALID_TIMEOUT" {
+		t.Errorf("timeout above UPSTREAM_TIMEOUT_MAX: %d %s, want 400 INVALID_TIMEOUT", w.Code, w.Body)
+	}
+	if w := requestWeather("location=London&timeout=10"); w.Code != http.StatusOK {
+		t.Errorf("timeout at UPSTREAM_TIMEOUT_MAX: %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestExpiredTimeoutGatewayTimeout(t *testing.T) {
+	setupTest(t, testConfig(t), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		respondWith(http.StatusOK, fixtureWeather).ServeHTTP(w, r)
+	}))
+
+	start := time.Now()
+	w := requestWeather("location=London&timeout=0.1")
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("lookup outliving its timeout: %d %s, want 504", w.Code, w.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("lookup took %s, past its 100ms timeout", elapsed)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// upstreamAcceptEncoding is sent with every upstream weather request. Setting
+// it explicitly turns off the transport's transparent gzip handling, so the body
+// is decoded by decodeUpstreamBody instead, which also understands deflate.
+const upstreamAcceptEncoding = "gzip, deflate"
+
+// decodeUpstreamBody returns a reader of resp's body decoded according to its
+// Content-Encoding. The caller still closes resp.Body. Unknown encodings are an
+// error rather than being read as JSON.
+func decodeUpstreamBody(resp *http.Response) (io.ReadCloser, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return io.NopCloser(resp.Body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return newDeflateReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+}
+
+// newDeflateReader reads an HTTP deflate body. The standard calls for zlib
+// framing, but some servers send raw deflate data, so the zlib header is checked
+// before picking the decoder.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
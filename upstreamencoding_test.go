@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// compress encodes body with the named Content-Encoding; "raw-deflate" is
+// deflate without the zlib framing some servers leave out.
+func compress(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	if _, err := io.WriteString(w, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodedUpstream answers with body compressed by encoding, recording the
+// Accept-Encoding it was sent.
+func encodedUpstream(t *testing.T, encoding, body string, accepted *string) http.Handler {
+	compressed := compress(t, encoding, body)
+	header := strings.TrimPrefix(encoding, "raw-")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*accepted = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", header)
+		w.Write(compressed)
+	})
+}
+
+func TestCompressedUpstreamResponse(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate"} {
+		var accepted string
+		setupTest(t, testConfig(t), encodedUpstream(t, encoding, fixtureWeather, &accepted))
+		w := requestWeather("location=London")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"resolvedAddress":"London"`) {
+			t.Errorf("%s: got %d %s", encoding, w.Code, w.Body)
+		}
+		if accepted != upstreamAcceptEncoding {
+			t.Errorf("%s: sent Accept-Encoding %q, want %q", encoding, accepted, upstreamAcceptEncoding)
+		}
+	}
+}
+
+// TestCompressedUpstreamResponseLimit checks MAX_UPSTREAM_RESPONSE_BYTES
+// bounds the decoded body, not the compressed one.
+func TestCompressedUpstreamResponseLimit(t *testing.T) {
+	var accepted string
+	body := largeWeather()
+	c := testConfig(t)
+	c.MaxUpstreamResponseBytes = int64(len(body)) - 1
+	setupTest(t, c, encodedUpstream(t, "gzip", body, &accepted))
+	if w := requestWeather("location=London"); w.Code != http.StatusBadGateway || errorCode(t, w) != "UPSTREAM_TOO_LARGE" {
+		t.Errorf("got %d %s, want 502 UPSTREAM_TOO_LARGE", w.Code, w.Body)
+	}
+}
+
+func TestDecodeUpstreamBodyUnsupported(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"br"}}, Body: io.NopCloser(strings.NewReader("{}"))}
+	if _, err := decodeUpstreamBody(resp); err == nil {
+		t.Error("br body decoded")
+	}
+	resp.Header.Set("Content-Encoding", "gzip")
+	if _, err := decodeUpstreamBody(resp); err == nil {
+		t.Error("plain body decoded as gzip")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamHealthState is the upstream status maintained by the health poller,
+// see startUpstreamHealthPoller. After UPSTREAM_HEALTH_FAILURES consecutive
+// failed polls the upstream is considered down and cache misses fail fast
+// without calling it; the next successful poll brings it back up.
+type upstreamHealthState struct {
+	mu          sync.Mutex
+	polled      bool
+	down        bool
+	consecutive int
+	lastPoll    time.Time
+	lastLatency time.Duration
+	lastError   string
+}
+
+var upstreamHealth = &upstreamHealthState{}
+
+// allow reports whether upstream fetches may go ahead, i.e. the poller hasn't
+// found the upstream down.
+func (h *upstreamHealthState) allow() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.down
+}
+
+// upstreamUnwell reports whether a failed poll says the upstream is unwell:
+// the failures retryableFetch retries, except 429s, which only say the
+// service is sending too much.
+func upstreamUnwell(ctx context.Context, err error) bool {
+	var ae *apiError
+	if errors.As(err, &ae) && ae.UpstreamStatus == http.StatusTooManyRequests {
+		return false
+	}
+	return retryableFetch(ctx, err)
+}
+
+// record notes the outcome of one poll. Only failures saying the upstream is
+// unwell count, see upstreamUnwell; a rejected key, rate limiting or an
+// exhausted quota doesn't mean it is down. Coming back up also closes every
+// open location breaker, since their failures were likely the outage.
+func (h *upstreamHealthState) record(ctx context.Context, err error, latency time.Duration, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.polled, h.lastPoll, h.lastLatency = true, now, latency
+	if err == nil || !upstreamUnwell(ctx, err) {
+		h.lastError = ""
+		if err != nil {
+			h.lastError = pollError(err)
+		}
+		if h.down {
+			log.Printf("Upstream health poll succeeded, upstream is up again")
+			locationBreakers.reset()
+		}
+		h.down, h.consecutive = false, 0
+		return
+	}
+	h.consecutive++
+	h.lastError = pollError(err)
+	if !h.down && h.consecutive >= cfg.UpstreamHealthFailures {
+		h.down = true
+		log.Printf("Upstream considered down after %d failed health polls, last: %s", h.consecutive, h.lastError)
+	}
+}
+
+// report describes the polled status for /health: disabled without a poller,
+// unknown before the first poll, otherwise up or down.
+func (h *upstreamHealthState) report() gin.H {
+	if cfg.UpstreamHealthInterval == 0 || cfg.MockMode {
+		return gin.H{"state": "disabled"}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.polled {
+		return gin.H{"state": "unknown"}
+	}
+	state := "up"
+	if h.down {
+		state = "down"
+	}
+	report := gin.H{
+		"state":               state,
+		"lastPoll":            h.lastPoll.UTC().Format(time.RFC3339),
+		"latency_ms":          h.lastLatency.Milliseconds(),
+		"consecutiveFailures": h.consecutive,
+	}
+	if h.lastError != "" {
+		report["error"] = h.lastError
+	}
+	return report
+}
+
+// pollError describes a failed poll for /health. Transport errors are reduced
+// to their cause, since their URL carries the API key.
+func pollError(err error) string {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err.Error()
+	}
+	return err.Error()
+}
+
+// errUpstreamDown is returned for cache misses while the poller finds the
+// upstream down.
+func errUpstreamDown() *apiError {
+	return &apiError{
+		Status:     http.StatusServiceUnavailable,
+		Code:       "UPSTREAM_DOWN",
+		Message:    "the upstream weather API is currently unavailable, try again later",
+		RetryAfter: cfg.UpstreamHealthInterval,
+	}
+}
+
+// startUpstreamHealthPoller fetches today's weather for CANARY_LOCATION every
+// interval, bypassing the cache, and records the outcome in upstreamHealth,
+// until ctx is done. Polls go through the upstream queue and are skipped while
+// the queue is busy or the quota is exhausted, so they never crowd out requests.
+// The returned channel is closed once it has stopped.
+func startUpstreamHealthPoller(ctx context.Context, interval, timeout time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			pollUpstream(ctx, timeout)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// pollUpstream runs one health poll.
+func pollUpstream(ctx context.Context, timeout time.Duration) {
+	if _, exhausted := quotaExhaustedUntil(clock.Now()); exhausted || upstreamQueue.busy() {
+		return
+	}
+	pctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	q := weatherQuery{Location: cfg.CanaryLocation, Period: "today"}
+	start := time.Now()
+	err := upstreamQueue.do(pctx, func() error {
+		_, _, err := fetchWeatherData(pctx, q)
+		return err
+	})
+	if ctx.Err() != nil {
+		return
+	}
+	upstreamHealth.record(pctx, err, time.Since(start), clock.Now())
+}
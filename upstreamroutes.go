@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Query types an upstream route is chosen by.
+const (
+	queryCurrent    = "current"    // undated, without days or hours
+	queryForecast   = "forecast"   // undated, or dated reaching today or later
+	queryHistorical = "historical" // dated, ending before today
+	queryHourly     = "hourly"     // undated /weather/hourly
+)
+
+// upstreamRoute adjusts the upstream call for a query type. Period replaces the
+// default 15-day forecast of undated queries with a Visual Crossing dynamic
+// period such as "today" or "next24hours"; Include replaces the endpoint's
+// include set. Empty fields leave the query as it is.
+type upstreamRoute struct {
+	Period  string `json:"period,omitempty"`
+	Include string `json:"include,omitempty"`
+}
+
+// defaultUpstreamRoutes only fetch the period undated current and hourly
+// queries use, instead of the full forecast.
+var defaultUpstreamRoutes = map[string]upstreamRoute{
+	queryCurrent:    {Period: "today"},
+	queryForecast:   {},
+	queryHistorical: {},
+	queryHourly:     {Period: "next24hours"},
+}
+
+// parseUpstreamRoutes builds the route table from the defaults and the
+// UPSTREAM_ROUTES overrides, a JSON object mapping query types to routes.
+// An override replaces the whole default route of its type.
+func parseUpstreamRoutes(raw string) (map[string]upstreamRoute, error) {
+	routes := make(map[string]upstreamRoute, len(defaultUpstreamRoutes))
+	for kind, route := range defaultUpstreamRoutes {
+		routes[kind] = route
+	}
+	if raw == "" {
+		return routes, nil
+	}
+	var overrides map[string]upstreamRoute
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, err
+	}
+	for kind, route := range overrides {
+		if _, ok := defaultUpstreamRoutes[kind]; !ok {
+			return nil, fmt.Errorf("unknown query type %q", kind)
+		}
+		if !validPeriod(route.Period) {
+			return nil, fmt.Errorf("invalid period %q for %s", route.Period, kind)
+		}
+		route.Include = normalizeInclude(route.Include)
+		routes[kind] = route
+	}
+	return routes, nil
+}
+
+// validPeriod reports whether period is empty or a plausible dynamic period:
+// lower-case letters and digits only, so it can't add path segments or
+// parameters to the upstream URL.
+func validPeriod(period string) bool {
+	for _, r := range period {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// queryType classifies a query built for the endpoint at path.
+func queryType(q weatherQuery, path string, now time.Time) string {
+	if q.Start != "" {
+		last := q.End
+		if last == "" {
+			last = q.Start
+		}
+		if last < now.UTC().Format(dateLayout) {
+			return queryHistorical
+		}
+		return queryForecast
+	}
+	if path == "/weather/hourly" {
+		return queryHourly
+	}
+	include := parseSet(q.include())
+	if !include["days"] && !include["hours"] {
+		return queryCurrent
+	}
+	return queryForecast
+}
+
+// routeQuery applies the upstream route of the query's type.
+func routeQuery(q weatherQuery, path string, now time.Time) weatherQuery {
+	route := cfg.UpstreamRoutes[queryType(q, path, now)]
+	if route.Include != "" {
+		q.Include = route.Include
+	}
+	if q.Start == "" {
+		q.Period = route.Period
+	}
+	return q
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryType(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		q    weatherQuery
+		path string
+		want string
+	}{
+		{"default include", weatherQuery{}, "/weather", queryForecast},
+		{"days", weatherQuery{Include: "days"}, "/weather", queryForecast},
+		{"hours", weatherQuery{Include: "hours"}, "/weather", queryForecast},
+		{"current only", weatherQuery{Include: "current"}, "/weather", queryCurrent},
+		{"alerts and current", weatherQuery{Include: "alerts,current"}, "/weather", queryCurrent},
+		{"hourly", weatherQuery{Include: "days,hours"}, "/weather/hourly", queryHourly},
+		{"past day", weatherQuery{Start: "2026-10-13"}, "/weather", queryHistorical},
+		{"past range", weatherQuery{Start: "2026-09-01", End: "2026-10-13"}, "/weather", queryHistorical},
+		{"today", weatherQuery{Start: "2026-10-14"}, "/weather", queryForecast},
+		{"range reaching today", weatherQuery{Start: "2026-10-01", End: "2026-10-14"}, "/weather", queryForecast},
+		{"dated hourly", weatherQuery{Start: "2026-10-01"}, "/weather/hourly", queryHistorical},
+	} {
+		if got := queryType(tc.q, tc.path, now); got != tc.want {
+			t.Errorf("%s: queryType = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseUpstreamRoutes(t *testing.T) {
+	withOverride := func(kind string, route upstreamRoute) map[string]upstreamRoute {
+		routes := make(map[string]upstreamRoute)
+		for k, r := range defaultUpstreamRoutes {
+			routes[k] = r
+		}
+		routes[kind] = route
+		return routes
+	}
+	for _, tc := range []struct {
+		raw  string
+		want map[string]upstreamRoute
+		err  string
+	}{
+		{"", defaultUpstreamRoutes, ""},
+		{`{"current":{"period":"next24hours"}}`, withOverride(queryCurrent, upstreamRoute{Period: "next24hours"}), ""},
+		// An override replaces the whole route, and the include set is normalised.
+		{`{"hourly":{"include":"Hours, days,hours"}}`, withOverride(queryHourly, upstreamRoute{Include: "days,hours"}), ""},
+		{`{"weekly":{}}`, nil, "unknown query type"},
+		{`{"current":{"period":"today/../x"}}`, nil, "invalid period"},
+		{`{"current":{"period":"Today"}}`, nil, "invalid period"},
+		{`[1]`, nil, "cannot unmarshal"},
+	} {
+		got, err := parseUpstreamRoutes(tc.raw)
+		switch {
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("parseUpstreamRoutes(%s) error %v, want %q", tc.raw, err, tc.err)
+		case tc.err == "" && (err != nil || !reflect.DeepEqual(got, tc.want)):
+			t.Errorf("parseUpstreamRoutes(%s) = %v, %v; want %v", tc.raw, got, err, tc.want)
+		}
+	}
+}
+
+func TestRouteQuery(t *testing.T) {
+	oldCfg := cfg
+	t.Cleanup(func() { cfg = oldCfg })
+	cfg.UpstreamRoutes = withRoutes(t, `{"historical":{"include":"days"}}`)
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name         string
+		q            weatherQuery
+		path         string
+		period, incl string
+	}{
+		{"current", weatherQuery{Include: "current"}, "/weather", "today", "current"},
+		{"hourly", weatherQuery{Include: "days,hours"}, "/weather/hourly", "next24hours", "days,hours"},
+		{"forecast", weatherQuery{Include: "days"}, "/weather", "", "days"},
+		// Dated queries keep their dates and never get a period.
+		{"historical", weatherQuery{Start: "2026-10-01", Include: "days,hours"}, "/weather", "", "days"},
+	} {
+		got := routeQuery(tc.q, tc.path, now)
+		if got.Period != tc.period || got.Include != tc.incl || got.Start != tc.q.Start {
+			t.Errorf("%s: routed to period %q, include %q, start %q; want %q, %q, %q", tc.name, got.Period, got.Include, got.Start, tc.period, tc.incl, tc.q.Start)
+		}
+	}
+}
+
+// withRoutes parses an UPSTREAM_ROUTES value for a test.
+func withRoutes(t *testing.T, raw string) map[string]upstreamRoute {
+	t.Helper()
+	routes, err := parseUpstreamRoutes(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return routes
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// upstreamBodyExcerpt bounds how much of an upstream error body is repeated in
+// the error message.
+const upstreamBodyExcerpt = 200
+
+// upstreamStatusError maps a non-200 upstream answer, other than an exhausted
+// quota, onto the response for it by status class:
+//
+//   - 4xx: the upstream rejected the query itself, typically an unknown
+//     location or invalid date. 400 and 404 are passed on; other client errors
+//     become 502, since they concern the service's own request.
+//   - 5xx, 408, 429 and anything else: the upstream is having trouble or
+//     turning requests away for now, reported as 503.
+func upstreamStatusError(status int, body []byte) *apiError {
+	excerpt := strings.TrimSpace(string(truncateBytes(body, upstreamBodyExcerpt)))
+	transient := status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+	if status >= 400 && status < 500 && !transient {
+		ae := &apiError{
+			Status:         http.StatusBadGateway,
+			Code:           "UPSTREAM_REJECTED",
+			Message:        "upstream weather API rejected the request",
+			UpstreamStatus: status,
+		}
+		if status == http.StatusBadRequest || status == http.StatusNotFound {
+			ae.Status = status
+		}
+		if excerpt != "" {
+			ae.Message += ": " + excerpt
+		}
+		return ae
+	}
+	return &apiError{
+		Status:         http.StatusServiceUnavailable,
+		Code:           "UPSTREAM_UNAVAILABLE",
+		Message:        "upstream weather API is unavailable, try again later",
+		UpstreamStatus: status,
+	}
+}
+
+// negativelyCacheable reports whether an error may be cached for
+// CLIENT_ERROR_CACHE_TTL. Only upstream 4xx answers qualify, and not the ones
+// about the service's credentials or request rate (401, 403, 408, 429), which
+// say nothing about the query and may clear up on their own. 5xx answers are
+// transient and never cached.
+func negativelyCacheable(ae *apiError) bool {
+	switch ae.UpstreamStatus {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return ae.UpstreamStatus >= 400 && ae.UpstreamStatus < 500
+}
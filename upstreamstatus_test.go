@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUpstreamStatusCaching(t *testing.T) {
+	for _, tc := range []struct {
+		upstream, status int
+		code             string
+		cached           bool
+	}{
+		{http.StatusOK, http.StatusOK, "", true},
+		{http.StatusBadRequest, http.StatusBadRequest, "UPSTREAM_REJECTED", true},
+		{http.StatusNotFound, http.StatusNotFound, "UPSTREAM_REJECTED", true},
+		{http.StatusUnprocessableEntity, http.StatusBadGateway, "UPSTREAM_REJECTED", true},
+		{http.StatusUnauthorized, http.StatusBadGateway, "UPSTREAM_REJECTED", false},
+		{http.StatusForbidden, http.StatusBadGateway, "UPSTREAM_REJECTED", false},
+		{http.StatusRequestTimeout, http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", false},
+		{http.StatusTooManyRequests, http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", false},
+		{http.StatusInternalServerError, http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", false},
+		{http.StatusServiceUnavailable, http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", false},
+	} {
+		body := fixtureWeather
+		if tc.upstream != http.StatusOK {
+			body = "Bad API Request: Invalid location parameter value."
+		}
+		c := testConfig(t)
+		c.UpstreamRetries = 0
+		mr := setupTest(t, c, respondWith(tc.upstream, body))
+
+		w := requestWeather("location=London")
+		if w.Code != tc.status {
+			t.Errorf("upstream %d: got %d, want %d", tc.upstream, w.Code, tc.status)
+		}
+		if tc.code != "" {
+			if code := errorCode(t, w); code != tc.code {
+				t.Errorf("upstream %d: code %q, want %q", tc.upstream, code, tc.code)
+			}
+		}
+		if mr.Exists(londonKey) != tc.cached {
+			t.Errorf("upstream %d: cached %v, want %v", tc.upstream, !tc.cached, tc.cached)
+		}
+		if tc.cached && tc.upstream != http.StatusOK {
+			if ttl := mr.TTL(londonKey); ttl <= 0 || ttl > c.ClientErrorCacheTTL {
+				t.Errorf("upstream %d: negative entry TTL %s, want at most CLIENT_ERROR_CACHE_TTL", tc.upstream, ttl)
+			}
+			again := requestWeather("location=London")
+			if again.Code != tc.status || errorCode(t, again) != tc.code {
+				t.Errorf("upstream %d: cached answer %d %s", tc.upstream, again.Code, again.Body)
+			}
+		}
+	}
+}
+
+func TestUpstreamClientErrorCachingDisabled(t *testing.T) {
+	c := testConfig(t)
+	c.ClientErrorCacheTTL = 0
+	mr := setupTest(t, c, respondWith(http.StatusNotFound, "not found"))
+	if w := requestWeather("location=London"); w.Code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", w.Code)
+	}
+	if mr.Exists(londonKey) {
+		t.Error("404 cached with CLIENT_ERROR_CACHE_TTL=0")
+	}
+}
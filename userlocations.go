@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/errgroup"
+)
+
+// savedLocationFetches bounds how many saved locations /user/weather looks up
+// at once.
+const savedLocationFetches = 4
+
+// savedLocationsKey returns the Redis key of an API key's saved locations. The
+// key is hashed so raw API keys never appear in Redis key names.
+func savedLocationsKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "user:locations:" + hex.EncodeToString(sum[:8])
+}
+
+// savedLocationsRequest is the body of PUT /user/locations.
+type savedLocationsRequest struct {
+	Locations []string `json:"locations" binding:"required"`
+}
+
+// normalizeSavedLocations validates a saved location list, returning the
+// locations in normalised form (aliases resolved, airports and coordinates as
+// canonical coordinates) with duplicates dropped, in their original order.
+func normalizeSavedLocations(raw []string, max int) ([]string, error) {
+	seen := make(map[string]bool, len(raw))
+	locations := []string{}
+	for _, r := range raw {
+		parsed, err := parseLocation(queryParams{Location: r})
+		if err != nil {
+			return nil, fmt.Errorf("invalid location %q", r)
+		}
+		if !locationAllowed(parsed.Upstream) {
+			return nil, fmt.Errorf("location %q is not allowed", r)
+		}
+		if seen[parsed.Key] {
+			continue
+		}
+		seen[parsed.Key] = true
+		locations = append(locations, parsed.Key)
+	}
+	if len(locations) > max {
+		return nil, fmt.Errorf("at most %d saved locations are allowed", max)
+	}
+	return locations, nil
+}
+
+// savedLocations reads the saved locations of an API key, nil when there are
+// none. The read is bounded by ctx and skipped while redisBreaker is open.
+func savedLocations(ctx context.Context, apiKey string) ([]string, error) {
+	if !redisBreaker.allow() {
+		return nil, errRedisCircuitOpen
+	}
+	raw, err := redisClient.Get(ctx, savedLocationsKey(apiKey)).Bytes()
+	redisBreaker.record(err)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var locations []string
+	if err := json.Unmarshal(raw, &locations); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// requireAPIKey returns the request's authenticated API key. Without one, as
+// when authentication is disabled, it writes a 401 and returns false.
+func requireAPIKey(c *gin.Context) (string, bool) {
+	apiKey := c.GetString("apiKey")
+	if apiKey == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "saved locations require an authenticated " + apiKeyHeader, "code": "AUTH_REQUIRED"})
+		return "", false
+	}
+	return apiKey, true
+}
+
+// putSavedLocationsHandler handles PUT /user/locations, replacing the caller's
+// saved locations with the list in the body, {"locations":[...]}. At most
+// SAVED_LOCATIONS_MAX distinct locations may be saved; an empty list clears
+// them. The stored list is returned.
+func putSavedLocationsHandler(c *gin.Context) {
+	apiKey, ok := requireAPIKey(c)
+	if !ok {
+		return
+	}
+	var req savedLocationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `body must be a JSON object like {"locations":["London"]}`})
+		return
+	}
+	locations, err := normalizeSavedLocations(req.Locations, cfg.SavedLocationsMax)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := savedLocationsKey(apiKey)
+	if !redisBreaker.allow() {
+		err = errRedisCircuitOpen
+	} else if len(locations) == 0 {
+		err = redisClient.Del(c.Request.Context(), key).Err()
+		redisBreaker.record(err)
+	} else {
+		b, _ := json.Marshal(locations)
+		err = redisClient.Set(c.Request.Context(), key, b, 0).Err()
+		redisBreaker.record(err)
+	}
+	if err != nil {
+		log.Printf("Error storing saved locations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"locations": locations})
+}
+
+// getSavedLocationsHandler handles GET /user/locations, listing the caller's
+// saved locations.
+func getSavedLocationsHandler(c *gin.Context) {
+	apiKey, ok := requireAPIKey(c)
+	if !ok {
+		return
+	}
+	locations, err := savedLocations(c.Request.Context(), apiKey)
+	if err != nil {
+		log.Printf("Error reading saved locations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if locations == nil {
+		locations = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"locations": locations})
+}
+
+// savedLocationWeather is the weather of one saved location in a /user/weather
+// response; a location whose lookup failed carries the error instead.
+type savedLocationWeather struct {
+	Location string                 `json:"location"`
+	Weather  map[string]interface{} `json:"weather,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Code     string                 `json:"code,omitempty"`
+}
+
+// getUserWeatherHandler handles GET /user/weather, returning the forecast of
+// every saved location of the caller in one response, in the order they were
+// saved. Locations are looked up concurrently like any /weather request, so
+// they share its cache entries; a failed lookup is reported for its location
+// without failing the others.
+func getUserWeatherHandler(c *gin.Context) {
+	apiKey, ok := requireAPIKey(c)
+	if !ok {
+		return
+	}
+	locations, err := savedLocations(c.Request.Context(), apiKey)
+	if err != nil {
+		log.Printf("Error reading saved locations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	lang := cfg.DefaultLang
+	if raw := c.Query("lang"); raw != "" {
+		if lang, err = parseLang(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	weather := make([]savedLocationWeather, len(locations))
+	for i, lookup := range lookupLocations(c, locations, "/user/weather", lang, savedLocationFetches) {
+		weather[i].Location = locations[i]
+		if lookup.err != nil {
+			weather[i].Error = lookup.err.Error()
+			var ae *apiError
+			if errors.As(lookup.err, &ae) {
+				weather[i].Code = ae.Code
+			}
+			continue
+		}
+		weather[i].Weather = lookup.result.Data
+	}
+	c.JSON(http.StatusOK, gin.H{"locations": weather})
+}
+
+// locationLookup is the outcome of looking up one of several locations.
+type locationLookup struct {
+	result *weatherResult
+	err    error
+}
+
+// errBatchTimeout marks the locations of a multi-location request that
+// weren't resolved within BATCH_TIMEOUT.
+var errBatchTimeout = &apiError{
+	Status:  http.StatusGatewayTimeout,
+	Code:    "TIMEOUT",
+	Message: "timed out before this location was resolved",
+}
+
+// lookupLocations looks up the locations concurrently, at most limit at a
+// time, as the endpoint at path with the given language, so they share the
+// cache entries of that endpoint's single-location requests. Their cache
+// entries are read up front with one MGET per Redis, so only the misses cost a
+// round trip of their own; if that read fails, each lookup reads its entry
+// itself. Lookups are returned in the order of locations; a failed one carries
+// its error without failing the others. The lookups share a deadline of
+// BATCH_TIMEOUT: once it passes, what was resolved is returned and the rest
+// carry errBatchTimeout, so a few slow locations can't hold up the whole
+// response.
+func lookupLocations(c *gin.Context, locations []string, path, lang string, limit int) []locationLookup {
+	now := clock.Now()
+	lctx := c.Request.Context()
+	if cfg.BatchTimeout > 0 {
+		var cancel context.CancelFunc
+		lctx, cancel = context.WithTimeout(lctx, cfg.BatchTimeout)
+		defer cancel()
+	}
+
+	lookups := make([]locationLookup, len(locations))
+	done := make([]bool, len(locations))
+	queries := make([]weatherQuery, len(locations))
+	var pending []int
+	for i, location := range locations {
+		parsed, err := parseLocation(queryParams{Location: location})
+		switch {
+		case err != nil:
+			lookups[i], done[i] = locationLookup{err: errInvalidLocation}, true
+		case !locationAllowed(parsed.Upstream):
+			lookups[i], done[i] = locationLookup{err: errLocationNotAllowed}, true
+		default:
+			q := weatherQuery{Location: parsed.Upstream, Airport: parsed.Airport, Include: endpointInclude(path), Lang: lang}
+			queries[i] = routeQuery(q, path, now)
+			pending = append(pending, i)
+		}
+	}
+	cached := make([]*string, len(locations))
+	if len(pending) > 0 && !cfg.MockMode {
+		cacheKeys := make([]string, 0, len(pending))
+		for _, i := range pending {
+			cacheKeys = append(cacheKeys, queries[i].cacheKey())
+		}
+		vals, err := cacheMGet(lctx, cacheKeys)
+		if err != nil && err != errRedisCircuitOpen {
+			log.Printf("Error reading %d cache entries at once, reading them one by one: %v", len(cacheKeys), err)
+		}
+		for j, val := range vals {
+			val := val
+			cached[pending[j]] = &val
+		}
+	}
+
+	type resolved struct {
+		i      int
+		lookup locationLookup
+	}
+	// Buffered so lookups finishing after the deadline never block.
+	results := make(chan resolved, len(pending))
+	go func() {
+		var g errgroup.Group
+		g.SetLimit(limit)
+		for _, i := range pending {
+			i := i
+			g.Go(func() error {
+				r := resolved{i: i}
+				if result, err := lookupWeather(lctx, queries[i], lookupOptions{Cached: cached[i]}); err != nil {
+					r.lookup.err = err
+				} else {
+					r.lookup.result = &result
+				}
+				results <- r
+				return nil
+			})
+		}
+		g.Wait()
+	}()
+
+collect:
+	for range pending {
+		select {
+		case r := <-results:
+			lookups[r.i], done[r.i] = r.lookup, true
+			// The context isn't safe for concurrent use, so lookups are noted here.
+			if r.lookup.result != nil {
+				noteLookup(c, queries[r.i], *r.lookup.result)
+			}
+		case <-lctx.Done():
+			break collect
+		}
+	}
+	for i := range lookups {
+		if !done[i] || errors.Is(lookups[i].err, context.DeadlineExceeded) {
+			lookups[i] = locationLookup{err: errBatchTimeout}
+		}
+	}
+	return lookups
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uvDay is the per-day UV detail returned by /weather/uv.
+type uvDay struct {
+	Date    string   `json:"date"`
+	UVIndex *float64 `json:"uvindex"`
+	Risk    string   `json:"risk,omitempty"`
+}
+
+// uvRisk returns the WHO exposure category for a UV index: low (below 3),
+// moderate (below 6), high (below 8), very high (below 11) or extreme.
+func uvRisk(index float64) string {
+	switch {
+	case index < 3:
+		return "low"
+	case index < 6:
+		return "moderate"
+	case index < 8:
+		return "high"
+	case index < 11:
+		return "very high"
+	}
+	return "extreme"
+}
+
+// uvDays extracts the UV index per day and labels its risk. Days without a UV
+// index are reported with a null index and no risk.
+func uvDays(days []weatherDay) []uvDay {
+	out := make([]uvDay, 0, len(days))
+	for _, d := range days {
+		ud := uvDay{Date: d.Datetime, UVIndex: d.UVIndex}
+		if d.UVIndex != nil {
+			ud.Risk = uvRisk(*d.UVIndex)
+		}
+		out = append(out, ud)
+	}
+	return out
+}
+
+// getUVHandler handles GET /weather/uv requests, returning the per-day UV index
+// and risk category from the (cached) full response.
+func getUVHandler(c *gin.Context) {
+	q, days, ok := loadDays(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"location": q.Location,
+		"days":     uvDays(days),
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUVRisk(t *testing.T) {
+	for _, tc := range []struct {
+		index float64
+		want  string
+	}{
+		{0, "low"},
+		{2.9, "low"},
+		{3, "moderate"},
+		{5.9, "moderate"},
+		{6, "high"},
+		{7.9, "high"},
+		{8, "very high"},
+		{10.9, "very high"},
+		{11, "extreme"},
+		{15, "extreme"},
+	} {
+		if got := uvRisk(tc.index); got != tc.want {
+			t.Errorf("uvRisk(%v) = %q, want %q", tc.index, got, tc.want)
+		}
+	}
+}
+
+func TestUVDays(t *testing.T) {
+	index := 7.0
+	got := uvDays([]weatherDay{
+		{Datetime: "2026-10-14", UVIndex: &index},
+		{Datetime: "2026-10-15"},
+	})
+	want := []uvDay{
+		{Date: "2026-10-14", UVIndex: &index, Risk: "high"},
+		{Date: "2026-10-15"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uvDays = %+v, want %+v", got, want)
+	}
+}
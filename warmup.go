@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// warmupTimeout bounds the startup warmup request.
+const warmupTimeout = 10 * time.Second
+
+// warmUpUpstream sends a throwaway HEAD request to the upstream endpoint so DNS,
+// TCP and TLS setup happen before the first real request and the connection is
+// left in the pool. It carries no API key, so it costs no quota; any HTTP answer,
+// even an error status, counts as a successful warmup.
+func warmUpUpstream(url string) {
+	wctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(wctx, http.MethodHead, url, nil)
+	if err != nil {
+		log.Printf("Upstream warmup failed: %v", err)
+		return
+	}
+	start := time.Now()
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		log.Printf("Upstream warmup failed: %v", err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("Upstream warmup completed in %s (status %d)", time.Since(start).Round(time.Millisecond), resp.StatusCode)
+}
@@ -0,0 +1,507 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.1
+// 	protoc        v4.25.3
+// source: weatherpb/weather.proto
+
+package weatherpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type WeatherResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address           string             `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	ResolvedAddress   string             `protobuf:"bytes,2,opt,name=resolved_address,json=resolvedAddress,proto3" json:"resolved_address,omitempty"`
+	Latitude          float64            `protobuf:"fixed64,3,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude         float64            `protobuf:"fixed64,4,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Timezone          string             `protobuf:"bytes,5,opt,name=timezone,proto3" json:"timezone,omitempty"`
+	CurrentConditions *CurrentConditions `protobuf:"bytes,6,opt,name=current_conditions,json=currentConditions,proto3" json:"current_conditions,omitempty"`
+	Days              []*Day             `protobuf:"bytes,7,rep,name=days,proto3" json:"days,omitempty"`
+}
+
+func (x *WeatherResponse) Reset() {
+	*x = WeatherResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WeatherResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherResponse) ProtoMessage() {}
+
+func (x *WeatherResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherResponse.ProtoReflect.Descriptor instead.
+func (*WeatherResponse) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WeatherResponse) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *WeatherResponse) GetResolvedAddress() string {
+	if x != nil {
+		return x.ResolvedAddress
+	}
+	return ""
+}
+
+func (x *WeatherResponse) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *WeatherResponse) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *WeatherResponse) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+func (x *WeatherResponse) GetCurrentConditions() *CurrentConditions {
+	if x != nil {
+		return x.CurrentConditions
+	}
+	return nil
+}
+
+func (x *WeatherResponse) GetDays() []*Day {
+	if x != nil {
+		return x.Days
+	}
+	return nil
+}
+
+type CurrentConditions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Datetime   string   `protobuf:"bytes,1,opt,name=datetime,proto3" json:"datetime,omitempty"`
+	Temp       *float64 `protobuf:"fixed64,2,opt,name=temp,proto3,oneof" json:"temp,omitempty"`
+	Feelslike  *float64 `protobuf:"fixed64,3,opt,name=feelslike,proto3,oneof" json:"feelslike,omitempty"`
+	Humidity   *float64 `protobuf:"fixed64,4,opt,name=humidity,proto3,oneof" json:"humidity,omitempty"`
+	Conditions string   `protobuf:"bytes,5,opt,name=conditions,proto3" json:"conditions,omitempty"`
+	Icon       string   `protobuf:"bytes,6,opt,name=icon,proto3" json:"icon,omitempty"`
+}
+
+func (x *CurrentConditions) Reset() {
+	*x = CurrentConditions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CurrentConditions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CurrentConditions) ProtoMessage() {}
+
+func (x *CurrentConditions) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CurrentConditions.ProtoReflect.Descriptor instead.
+func (*CurrentConditions) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CurrentConditions) GetDatetime() string {
+	if x != nil {
+		return x.Datetime
+	}
+	return ""
+}
+
+func (x *CurrentConditions) GetTemp() float64 {
+	if x != nil && x.Temp != nil {
+		return *x.Temp
+	}
+	return 0
+}
+
+func (x *CurrentConditions) GetFeelslike() float64 {
+	if x != nil && x.Feelslike != nil {
+		return *x.Feelslike
+	}
+	return 0
+}
+
+func (x *CurrentConditions) GetHumidity() float64 {
+	if x != nil && x.Humidity != nil {
+		return *x.Humidity
+	}
+	return 0
+}
+
+func (x *CurrentConditions) GetConditions() string {
+	if x != nil {
+		return x.Conditions
+	}
+	return ""
+}
+
+func (x *CurrentConditions) GetIcon() string {
+	if x != nil {
+		return x.Icon
+	}
+	return ""
+}
+
+type Day struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Datetime   string   `protobuf:"bytes,1,opt,name=datetime,proto3" json:"datetime,omitempty"`
+	Tempmax    float64  `protobuf:"fixed64,2,opt,name=tempmax,proto3" json:"tempmax,omitempty"`
+	Tempmin    float64  `protobuf:"fixed64,3,opt,name=tempmin,proto3" json:"tempmin,omitempty"`
+	Temp       float64  `protobuf:"fixed64,4,opt,name=temp,proto3" json:"temp,omitempty"`
+	Feelslike  *float64 `protobuf:"fixed64,5,opt,name=feelslike,proto3,oneof" json:"feelslike,omitempty"`
+	Precip     float64  `protobuf:"fixed64,6,opt,name=precip,proto3" json:"precip,omitempty"`
+	Precipprob float64  `protobuf:"fixed64,7,opt,name=precipprob,proto3" json:"precipprob,omitempty"`
+	Preciptype []string `protobuf:"bytes,8,rep,name=preciptype,proto3" json:"preciptype,omitempty"`
+	Humidity   *float64 `protobuf:"fixed64,9,opt,name=humidity,proto3,oneof" json:"humidity,omitempty"`
+	Dew        *float64 `protobuf:"fixed64,10,opt,name=dew,proto3,oneof" json:"dew,omitempty"`
+	Uvindex    *float64 `protobuf:"fixed64,11,opt,name=uvindex,proto3,oneof" json:"uvindex,omitempty"`
+	Conditions string   `protobuf:"bytes,12,opt,name=conditions,proto3" json:"conditions,omitempty"`
+	Icon       string   `protobuf:"bytes,13,opt,name=icon,proto3" json:"icon,omitempty"`
+}
+
+func (x *Day) Reset() {
+	*x = Day{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Day) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Day) ProtoMessage() {}
+
+func (x *Day) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Day.ProtoReflect.Descriptor instead.
+func (*Day) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Day) GetDatetime() string {
+	if x != nil {
+		return x.Datetime
+	}
+	return ""
+}
+
+func (x *Day) GetTempmax() float64 {
+	if x != nil {
+		return x.Tempmax
+	}
+	return 0
+}
+
+func (x *Day) GetTempmin() float64 {
+	if x != nil {
+		return x.Tempmin
+	}
+	return 0
+}
+
+func (x *Day) GetTemp() float64 {
+	if x != nil {
+		return x.Temp
+	}
+	return 0
+}
+
+func (x *Day) GetFeelslike() float64 {
+	if x != nil && x.Feelslike != nil {
+		return *x.Feelslike
+	}
+	return 0
+}
+
+func (x *Day) GetPrecip() float64 {
+	if x != nil {
+		return x.Precip
+	}
+	return 0
+}
+
+func (x *Day) GetPrecipprob() float64 {
+	if x != nil {
+		return x.Precipprob
+	}
+	return 0
+}
+
+func (x *Day) GetPreciptype() []string {
+	if x != nil {
+		return x.Preciptype
+	}
+	return nil
+}
+
+func (x *Day) GetHumidity() float64 {
+	if x != nil && x.Humidity != nil {
+		return *x.Humidity
+	}
+	return 0
+}
+
+func (x *Day) GetDew() float64 {
+	if x != nil && x.Dew != nil {
+		return *x.Dew
+	}
+	return 0
+}
+
+func (x *Day) GetUvindex() float64 {
+	if x != nil && x.Uvindex != nil {
+		return *x.Uvindex
+	}
+	return 0
+}
+
+func (x *Day) GetConditions() string {
+	if x != nil {
+		return x.Conditions
+	}
+	return ""
+}
+
+func (x *Day) GetIcon() string {
+	if x != nil {
+		return x.Icon
+	}
+	return ""
+}
+
+var File_weatherpb_weather_proto protoreflect.FileDescriptor
+
+var file_weatherpb_weather_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x70, 0x62, 0x2f, 0x77, 0x65, 0x61, 0x74,
+	0x68, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x77, 0x65, 0x61, 0x74, 0x68,
+	0x65, 0x72, 0x61, 0x70, 0x69, 0x22, 0x9f, 0x02, 0x0a, 0x0f, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65,
+	0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64,
+	0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x64, 0x5f,
+	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x72,
+	0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x1a,
+	0x0a, 0x08, 0x6c, 0x61, 0x74, 0x69, 0x74, 0x75, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x08, 0x6c, 0x61, 0x74, 0x69, 0x74, 0x75, 0x64, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6c, 0x6f,
+	0x6e, 0x67, 0x69, 0x74, 0x75, 0x64, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x6c,
+	0x6f, 0x6e, 0x67, 0x69, 0x74, 0x75, 0x64, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x74, 0x69, 0x6d, 0x65,
+	0x7a, 0x6f, 0x6e, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x74, 0x69, 0x6d, 0x65,
+	0x7a, 0x6f, 0x6e, 0x65, 0x12, 0x4c, 0x0a, 0x12, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x5f,
+	0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1d, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x61, 0x70, 0x69, 0x2e, 0x43, 0x75,
+	0x72, 0x72, 0x65, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52,
+	0x11, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x12, 0x23, 0x0a, 0x04, 0x64, 0x61, 0x79, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0f, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61,
+	0x79, 0x52, 0x04, 0x64, 0x61, 0x79, 0x73, 0x22, 0xe4, 0x01, 0x0a, 0x11, 0x43, 0x75, 0x72, 0x72,
+	0x65, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x1a, 0x0a,
+	0x08, 0x64, 0x61, 0x74, 0x65, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x64, 0x61, 0x74, 0x65, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x17, 0x0a, 0x04, 0x74, 0x65, 0x6d,
+	0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x04, 0x74, 0x65, 0x6d, 0x70, 0x88,
+	0x01, 0x01, 0x12, 0x21, 0x0a, 0x09, 0x66, 0x65, 0x65, 0x6c, 0x73, 0x6c, 0x69, 0x6b, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x01, 0x48, 0x01, 0x52, 0x09, 0x66, 0x65, 0x65, 0x6c, 0x73, 0x6c, 0x69,
+	0x6b, 0x65, 0x88, 0x01, 0x01, 0x12, 0x1f, 0x0a, 0x08, 0x68, 0x75, 0x6d, 0x69, 0x64, 0x69, 0x74,
+	0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x48, 0x02, 0x52, 0x08, 0x68, 0x75, 0x6d, 0x69, 0x64,
+	0x69, 0x74, 0x79, 0x88, 0x01, 0x01, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x64,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x69, 0x63, 0x6f, 0x6e, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x69, 0x63, 0x6f, 0x6e, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x74,
+	0x65, 0x6d, 0x70, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x66, 0x65, 0x65, 0x6c, 0x73, 0x6c, 0x69, 0x6b,
+	0x65, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x68, 0x75, 0x6d, 0x69, 0x64, 0x69, 0x74, 0x79, 0x22, 0x9e,
+	0x03, 0x0a, 0x03, 0x44, 0x61, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x61, 0x74, 0x65, 0x74, 0x69,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x61, 0x74, 0x65, 0x74, 0x69,
+	0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x65, 0x6d, 0x70, 0x6d, 0x61, 0x78, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x07, 0x74, 0x65, 0x6d, 0x70, 0x6d, 0x61, 0x78, 0x12, 0x18, 0x0a, 0x07,
+	0x74, 0x65, 0x6d, 0x70, 0x6d, 0x69, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x07, 0x74,
+	0x65, 0x6d, 0x70, 0x6d, 0x69, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x6d, 0x70, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x74, 0x65, 0x6d, 0x70, 0x12, 0x21, 0x0a, 0x09, 0x66, 0x65,
+	0x65, 0x6c, 0x73, 0x6c, 0x69, 0x6b, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52,
+	0x09, 0x66, 0x65, 0x65, 0x6c, 0x73, 0x6c, 0x69, 0x6b, 0x65, 0x88, 0x01, 0x01, 0x12, 0x16, 0x0a,
+	0x06, 0x70, 0x72, 0x65, 0x63, 0x69, 0x70, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x70,
+	0x72, 0x65, 0x63, 0x69, 0x70, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x72, 0x65, 0x63, 0x69, 0x70, 0x70,
+	0x72, 0x6f, 0x62, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0a, 0x70, 0x72, 0x65, 0x63, 0x69,
+	0x70, 0x70, 0x72, 0x6f, 0x62, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x72, 0x65, 0x63, 0x69, 0x70, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x70, 0x72, 0x65, 0x63, 0x69,
+	0x70, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1f, 0x0a, 0x08, 0x68, 0x75, 0x6d, 0x69, 0x64, 0x69, 0x74,
+	0x79, 0x18, 0x09, 0x20, 0x01, 0x28, 0x01, 0x48, 0x01, 0x52, 0x08, 0x68, 0x75, 0x6d, 0x69, 0x64,
+	0x69, 0x74, 0x79, 0x88, 0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x64, 0x65, 0x77, 0x18, 0x0a, 0x20,
+	0x01, 0x28, 0x01, 0x48, 0x02, 0x52, 0x03, 0x64, 0x65, 0x77, 0x88, 0x01, 0x01, 0x12, 0x1d, 0x0a,
+	0x07, 0x75, 0x76, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x01, 0x48, 0x03,
+	0x52, 0x07, 0x75, 0x76, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x88, 0x01, 0x01, 0x12, 0x1e, 0x0a, 0x0a,
+	0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x12, 0x0a, 0x04,
+	0x69, 0x63, 0x6f, 0x6e, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x69, 0x63, 0x6f, 0x6e,
+	0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x66, 0x65, 0x65, 0x6c, 0x73, 0x6c, 0x69, 0x6b, 0x65, 0x42, 0x0b,
+	0x0a, 0x09, 0x5f, 0x68, 0x75, 0x6d, 0x69, 0x64, 0x69, 0x74, 0x79, 0x42, 0x06, 0x0a, 0x04, 0x5f,
+	0x64, 0x65, 0x77, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x75, 0x76, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x42,
+	0x17, 0x5a, 0x15, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2d, 0x61, 0x70, 0x69, 0x2f, 0x77,
+	0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_weatherpb_weather_proto_rawDescOnce sync.Once
+	file_weatherpb_weather_proto_rawDescData = file_weatherpb_weather_proto_rawDesc
+)
+
+func file_weatherpb_weather_proto_rawDescGZIP() []byte {
+	file_weatherpb_weather_proto_rawDescOnce.Do(func() {
+		file_weatherpb_weather_proto_rawDescData = protoimpl.X.CompressGZIP(file_weatherpb_weather_proto_rawDescData)
+	})
+	return file_weatherpb_weather_proto_rawDescData
+}
+
+var file_weatherpb_weather_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_weatherpb_weather_proto_goTypes = []interface{}{
+	(*WeatherResponse)(nil),   // 0: weatherapi.WeatherResponse
+	(*CurrentConditions)(nil), // 1: weatherapi.CurrentConditions
+	(*Day)(nil),               // 2: weatherapi.Day
+}
+var file_weatherpb_weather_proto_depIdxs = []int32{
+	1, // 0: weatherapi.WeatherResponse.current_conditions:type_name -> weatherapi.CurrentConditions
+	2, // 1: weatherapi.WeatherResponse.days:type_name -> weatherapi.Day
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_weatherpb_weather_proto_init() }
+func file_weatherpb_weather_proto_init() {
+	if File_weatherpb_weather_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_weatherpb_weather_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WeatherResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_weatherpb_weather_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CurrentConditions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_weatherpb_weather_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Day); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_weatherpb_weather_proto_msgTypes[1].OneofWrappers = []interface{}{}
+	file_weatherpb_weather_proto_msgTypes[2].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_weatherpb_weather_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_weatherpb_weather_proto_goTypes,
+		DependencyIndexes: file_weatherpb_weather_proto_depIdxs,
+		MessageInfos:      file_weatherpb_weather_proto_msgTypes,
+	}.Build()
+	File_weatherpb_weather_proto = out.File
+	file_weatherpb_weather_proto_rawDesc = nil
+	file_weatherpb_weather_proto_goTypes = nil
+	file_weatherpb_weather_proto_depIdxs = nil
+}
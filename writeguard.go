@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache write failure counters, reported by /stats.
+var (
+	cacheWriteFailures    atomic.Int64 // failed Redis writes
+	cacheWritesSkipped    atomic.Int64 // writes skipped while the breaker was open
+	cacheEntriesOversized atomic.Int64 // entries not written for exceeding MAX_CACHE_ENTRY_BYTES
+	cacheWritesStale      atomic.Int64 // writes dropped for an entry fetched at the same time or later
+)
+
+// cacheWriteGuard tracks consecutive cache write failures. After threshold of them
+// in a row it logs a warning and, when the breaker is enabled, suspends writes
+// until the cooldown has passed so requests stop paying for doomed writes.
+type cacheWriteGuard struct {
+	mu          sync.Mutex
+	consecutive int
+	openUntil   time.Time
+}
+
+var writeGuard cacheWriteGuard
+
+// allow reports whether a cache write should be attempted now.
+func (g *cacheWriteGuard) allow() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !clock.Now().Before(g.openUntil)
+}
+
+// record notes the outcome of a cache write.
+func (g *cacheWriteGuard) record(err error) {
+	if isContextError(err) {
+		// The request gave up; that says nothing about Redis.
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil {
+		if g.consecutive >= cfg.CacheWriteFailureThreshold {
+			log.Printf("Cache writes recovered after %d consecutive failures", g.consecutive)
+		}
+		g.consecutive = 0
+		return
+	}
+
+	cacheWriteFailures.Add(1)
+	g.consecutive++
+	if cfg.CacheWriteFailureThreshold <= 0 || g.consecutive < cfg.CacheWriteFailureThreshold {
+		return
+	}
+	if g.consecutive == cfg.CacheWriteFailureThreshold {
+		log.Printf("WARNING: %d consecutive cache write failures, last: %v", g.consecutive, err)
+	}
+	if cfg.CacheWriteCooldown > 0 {
+		g.openUntil = clock.Now().Add(cfg.CacheWriteCooldown)
+		log.Printf("WARNING: suspending cache writes for %s", cfg.CacheWriteCooldown)
+	}
+}
+
+// open reports whether cache writes are currently suspended.
+func (g *cacheWriteGuard) open() bool {
+	return !g.allow()
+}